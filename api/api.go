@@ -158,7 +158,9 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Consensus API Calls
 	if api.cs != nil {
 		router.GET("/consensus", api.consensusHandler)
+		router.GET("/consensus/subscribers", api.consensusSubscribersHandler)
 		router.GET("/consensus/transactions/:id", api.consensusGetTransactionHandler)
+		router.GET("/consensus/proofs/transactions/:id", api.consensusGetTransactionProofHandler)
 		router.GET("/consensus/unspent/coinoutputs/:id", api.consensusGetUnspentCoinOutputHandler)
 		router.GET("/consensus/unspent/blockstakeoutputs/:id", api.consensusGetUnspentBlockstakeOutputHandler)
 	}
@@ -178,6 +180,12 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.GET("/gateway", api.gatewayHandler)
 		router.POST("/gateway/connect/:netaddress", RequirePassword(api.gatewayConnectHandler, requiredPassword))
 		router.POST("/gateway/disconnect/:netaddress", RequirePassword(api.gatewayDisconnectHandler, requiredPassword))
+		router.GET("/gateway/bans", api.gatewayBansHandler)
+		router.POST("/gateway/bans", RequirePassword(api.gatewayBansPOSTHandler, requiredPassword))
+		router.GET("/gateway/allowlist", api.gatewayAllowlistHandler)
+		router.POST("/gateway/allowlist", RequirePassword(api.gatewayAllowlistPOSTHandler, requiredPassword))
+		router.GET("/gateway/settings", api.gatewaySettingsHandler)
+		router.POST("/gateway/settings", RequirePassword(api.gatewaySettingsPOSTHandler, requiredPassword))
 	}
 
 	// TransactionPool API Calls
@@ -185,6 +193,7 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		// TODO: re-enable this route once the transaction pool API has been finalized
 		router.GET("/transactionpool/transactions", api.transactionpoolTransactionsHandler)
 		router.POST("/transactionpool/transactions", RequirePassword(api.transactionpoolPostTransactionHandler, requiredPassword))
+		router.GET("/transactionpool/fee", api.transactionpoolFeeHandler)
 	}
 
 	// Wallet API Calls
@@ -203,6 +212,7 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.POST("/wallet/coins", RequirePassword(api.walletCoinsHandler, requiredPassword))
 		router.POST("/wallet/blockstakes", RequirePassword(api.walletBlockStakesHandler, requiredPassword))
 		router.POST("/wallet/data", RequirePassword(api.walletDataHandler, requiredPassword))
+		router.POST("/wallet/bumpfee/:id", RequirePassword(api.walletBumpFeeHandler, requiredPassword))
 		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
 		router.GET("/wallet/transactions", api.walletTransactionsHandler)
 		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
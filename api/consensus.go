@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -15,6 +16,9 @@ var (
 	errNotFound = errors.New("Transaction not found")
 	// errInvalidIDLength is returned when a supposed id does not have the correct length
 	errInvalidIDLength = errors.New("ID does not have the right length")
+	// errBlockPruned is returned when the requested data is part of a block which
+	// has been pruned from the consensus set
+	errBlockPruned = errors.New("Block has been pruned from the consensus set")
 )
 
 // ConsensusGET contains general information about the consensus set, with tags
@@ -24,6 +28,7 @@ type ConsensusGET struct {
 	Height       types.BlockHeight `json:"height"`
 	CurrentBlock types.BlockID     `json:"currentblock"`
 	Target       types.Target      `json:"target"`
+	PruneHeight  types.BlockHeight `json:"pruneheight"`
 }
 
 // consensusHandler handles the API calls to /consensus.
@@ -35,9 +40,30 @@ func (api *API) consensusHandler(w http.ResponseWriter, req *http.Request, _ htt
 		Height:       api.cs.Height(),
 		CurrentBlock: cbid,
 		Target:       currentTarget,
+		PruneHeight:  api.cs.PruneHeight(),
 	})
 }
 
+// ConsensusSubscribersGET lists the delivery state of the subscribers which
+// receive their consensus changes asynchronously.
+type ConsensusSubscribersGET struct {
+	Subscribers []modules.ConsensusSubscriberStats `json:"subscribers"`
+}
+
+// consensusSubscribersHandler handles the API calls to /consensus/subscribers.
+func (api *API) consensusSubscribersHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, ConsensusSubscribersGET{
+		Subscribers: api.cs.AsyncSubscriberStats(),
+	})
+}
+
+// blockPruned returns true if the block at the given height is not available
+// because it has been pruned from the consensus set. The genesis block is
+// never pruned.
+func (api *API) blockPruned(height types.BlockHeight) bool {
+	return height != 0 && height < api.cs.PruneHeight()
+}
+
 // ConsensusGetTransaction is the object returned by a GET request to
 // /consensus/transaction/:id
 type ConsensusGetTransaction struct {
@@ -69,6 +95,10 @@ func (api *API) consensusGetTransactionHandler(w http.ResponseWriter, req *http.
 			WriteError(w, Error{err.Error()}, http.StatusNoContent)
 			return
 		}
+		if err == errBlockPruned {
+			WriteError(w, Error{err.Error()}, http.StatusGone)
+			return
+		}
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
@@ -87,6 +117,9 @@ func (api *API) getTransactionByShortID(shortID string) (types.Transaction, erro
 	txn, found := api.cs.TransactionAtShortID(txShortID)
 	if !found {
 		err = errNotFound
+		if api.blockPruned(txShortID.BlockHeight()) {
+			err = errBlockPruned
+		}
 	}
 	return txn, err
 }
@@ -103,10 +136,57 @@ func (api *API) getTransactionByLongID(longid string) (types.Transaction, types.
 	txn, txShortID, found := api.cs.TransactionAtID(txID)
 	if !found {
 		err = errNotFound
+		if api.blockPruned(txShortID.BlockHeight()) {
+			err = errBlockPruned
+		}
 	}
 	return txn, txShortID, err
 }
 
+// ConsensusGetTransactionProof is the object returned by a GET request to
+// /consensus/proofs/transactions/:id
+type ConsensusGetTransactionProof struct {
+	types.TransactionProof
+	Transaction types.Transaction        `json:"transaction"`
+	TxShortID   types.TransactionShortID `json:"shortid"`
+}
+
+// consensusGetTransactionProofHandler handles the API call to create a Merkle
+// proof for a transaction which is part of the blockchain.
+func (api *API) consensusGetTransactionProofHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var txID types.TransactionID
+	id := ps.ByName("id")
+	if len(id) != len(txID)*2 {
+		WriteError(w, Error{errInvalidIDLength.Error()}, http.StatusBadRequest)
+		return
+	}
+	err := txID.LoadString(id)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	proof, found := api.cs.TransactionProof(txID)
+	if !found {
+		if _, txShortID, _ := api.cs.TransactionAtID(txID); api.blockPruned(txShortID.BlockHeight()) {
+			WriteError(w, Error{errBlockPruned.Error()}, http.StatusGone)
+			return
+		}
+		WriteError(w, Error{errNotFound.Error()}, http.StatusNoContent)
+		return
+	}
+	txn, txShortID, found := api.cs.TransactionAtID(txID)
+	if !found {
+		WriteError(w, Error{errNotFound.Error()}, http.StatusNoContent)
+		return
+	}
+	WriteJSON(w, ConsensusGetTransactionProof{
+		TransactionProof: proof,
+		Transaction:      txn,
+		TxShortID:        txShortID,
+	})
+}
+
 // ConsensusGetUnspentCoinOutput is the object returned by a GET request to
 // /consensus/unspent/coinoutput/:id
 type ConsensusGetUnspentCoinOutput struct {
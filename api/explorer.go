@@ -152,6 +152,10 @@ func (api *API) explorerBlocksHandler(w http.ResponseWriter, req *http.Request,
 	// Fetch and return the explorer block.
 	block, exists := api.cs.BlockAtHeight(height)
 	if !exists {
+		if api.blockPruned(height) {
+			WriteError(w, Error{"block at input height has been pruned from the consensus set"}, http.StatusGone)
+			return
+		}
 		WriteError(w, Error{"no block found at input height in call to /explorer/block"}, http.StatusBadRequest)
 		return
 	}
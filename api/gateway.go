@@ -1,7 +1,9 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/rivine/rivine/modules"
 
@@ -10,8 +12,58 @@ import (
 
 // GatewayGET contains the fields returned by a GET call to "/gateway".
 type GatewayGET struct {
+	NetAddress modules.NetAddress   `json:"netaddress"`
+	Services   modules.ServiceFlags `json:"services"`
+	Peers      []modules.Peer       `json:"peers"`
+}
+
+// GatewayBansGET contains the fields returned by a GET call to "/gateway/bans".
+type GatewayBansGET struct {
+	Bans []modules.PeerBan `json:"bans"`
+}
+
+// GatewayBansPOST contains the fields of the body of a POST call to
+// "/gateway/bans", which bans or unbans the host of a network address.
+type GatewayBansPOST struct {
 	NetAddress modules.NetAddress `json:"netaddress"`
-	Peers      []modules.Peer     `json:"peers"`
+	// Duration of the ban in seconds,
+	// the default ban duration of the gateway is used if 0.
+	Duration uint64 `json:"duration"`
+	Reason   string `json:"reason"`
+	// Unban lifts the ban of the host, rather than banning it.
+	Unban bool `json:"unban"`
+}
+
+// GatewayAllowlistGET contains the fields returned by a GET call to
+// "/gateway/allowlist".
+type GatewayAllowlistGET struct {
+	// Enabled is true if the gateway runs in private mode,
+	// only allowing nodes on the allowlist to be peers.
+	Enabled bool     `json:"enabled"`
+	Entries []string `json:"entries"`
+}
+
+// GatewayAllowlistPOST contains the fields of the body of a POST call to
+// "/gateway/allowlist", which updates the allowlist.
+type GatewayAllowlistPOST struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+	// Enabled enables or disables the private mode of the gateway,
+	// the mode is left unchanged if it is omitted.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// GatewaySettingsGET contains the fields returned by a GET call to
+// "/gateway/settings".
+type GatewaySettingsGET struct {
+	modules.GatewaySettings
+}
+
+// GatewaySettingsPOST contains the fields of the body of a POST call to
+// "/gateway/settings", which updates the settings. Omitted fields are
+// left unchanged.
+type GatewaySettingsPOST struct {
+	modules.GatewaySettings
 }
 
 // gatewayHandler handles the API call asking for the gatway status.
@@ -23,7 +75,7 @@ func (api *API) gatewayHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{api.gateway.Address(), peers})
+	WriteJSON(w, GatewayGET{api.gateway.Address(), api.gateway.Services(), peers})
 }
 
 // gatewayConnectHandler handles the API call to add a peer to the gateway.
@@ -55,3 +107,79 @@ func (api *API) gatewayDisconnectHandler(w http.ResponseWriter, req *http.Reques
 
 	WriteSuccess(w)
 }
+
+// gatewayBansHandler handles the API call asking for the banned hosts.
+func (api *API) gatewayBansHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayBansGET{api.gateway.Bans()})
+}
+
+// gatewayBansPOSTHandler handles the API call to ban or unban a host.
+func (api *API) gatewayBansPOSTHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var body GatewayBansPOST
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		WriteError(w, Error{"error decoding the supplied ban: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var err error
+	if body.Unban {
+		err = api.gateway.UnbanPeer(body.NetAddress)
+	} else {
+		err = api.gateway.BanPeer(body.NetAddress, time.Duration(body.Duration)*time.Second, body.Reason)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// gatewayAllowlistHandler handles the API call asking for the allowlist.
+func (api *API) gatewayAllowlistHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	allowlist := api.gateway.Allowlist()
+	WriteJSON(w, GatewayAllowlistGET{
+		Enabled: allowlist.Enabled,
+		Entries: allowlist.Entries,
+	})
+}
+
+// gatewayAllowlistPOSTHandler handles the API call to update the allowlist.
+func (api *API) gatewayAllowlistPOSTHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var body GatewayAllowlistPOST
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		WriteError(w, Error{"error decoding the supplied allowlist update: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err := api.gateway.UpdateAllowlist(body.Add, body.Remove)
+	if err == nil && body.Enabled != nil {
+		err = api.gateway.SetAllowlistEnabled(*body.Enabled)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// gatewaySettingsHandler handles the API call asking for the gateway settings.
+func (api *API) gatewaySettingsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewaySettingsGET{api.gateway.Settings()})
+}
+
+// gatewaySettingsPOSTHandler handles the API call to update the gateway settings.
+func (api *API) gatewaySettingsPOSTHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// decode the body on top of the current settings,
+	// such that omitted fields are left unchanged
+	body := GatewaySettingsPOST{api.gateway.Settings()}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		WriteError(w, Error{"error decoding the supplied settings: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.gateway.SetSettings(body.GatewaySettings); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
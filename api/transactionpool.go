@@ -3,7 +3,9 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -19,6 +21,32 @@ func (api *API) transactionpoolTransactionsHandler(w http.ResponseWriter, req *h
 	WriteJSON(w, TransactionPoolGET{Transactions: api.tpool.TransactionList()})
 }
 
+// TransactionPoolFeeGET contains the fee estimation of the transaction pool,
+// as well as the minimum fee it currently requires.
+// The fees are expressed per byte of a transaction set.
+type TransactionPoolFeeGET struct {
+	modules.FeeEstimate
+	Minimum types.Currency `json:"minimum"`
+}
+
+// transactionpoolFeeHandler handles the API call to get the fee estimation
+// of the transaction pool, for an optional target number of blocks.
+func (api *API) transactionpoolFeeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	target := types.BlockHeight(modules.DefaultFeeTarget)
+	if targetStr := req.FormValue("target"); targetStr != "" {
+		n, err := strconv.ParseUint(targetStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"parsing integer value for parameter `target` failed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		target = types.BlockHeight(n)
+	}
+	WriteJSON(w, TransactionPoolFeeGET{
+		FeeEstimate: api.tpool.FeeEstimation(target),
+		Minimum:     api.tpool.MinimumFee(),
+	})
+}
+
 // TransactionPoolPOST is the success response for a POST to /transactionpool/transactions.
 // It is the ID of the newly posted transaction
 type TransactionPoolPOST struct {
@@ -102,6 +102,13 @@ type (
 		TransactionID types.TransactionID `json:"transactionids"`
 	}
 
+	// WalletBumpFeePOSTResp contains the ID of the transaction which
+	// replaced the given transaction as a result of a POST call to
+	// /wallet/bumpfee/:id.
+	WalletBumpFeePOSTResp struct {
+		TransactionID types.TransactionID `json:"transactionid"`
+	}
+
 	// WalletSeedsGET contains the seeds used by the wallet.
 	WalletSeedsGET struct {
 		PrimarySeed        string   `json:"primaryseed"`
@@ -468,6 +475,24 @@ func (api *API) walletBlockStakesHandler(w http.ResponseWriter, req *http.Reques
 	})
 }
 
+// walletBumpFeeHandler handles API calls to /wallet/bumpfee/:id.
+func (api *API) walletBumpFeeHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id types.TransactionID
+	err := id.LoadString(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/bumpfee: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txn, err := api.wallet.BumpFee(id)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/bumpfee: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, WalletBumpFeePOSTResp{
+		TransactionID: txn.ID(),
+	})
+}
+
 // walletDataHandler handles the API calls to /wallet/data
 func (api *API) walletDataHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	dest, err := scanAddress(req.FormValue("destination"))
@@ -35,6 +35,23 @@ func (t *MerkleTree) PushObject(obj interface{}) {
 	t.Push(encoding.Marshal(obj))
 }
 
+// Prove is a redefinition of merkletree.Tree.Prove, returning the proof as a
+// base and a hash set instead of a [][]byte. The base and hash set are nil if
+// no leaf exists at the index established by SetIndex.
+func (t *MerkleTree) Prove() (base []byte, hashSet []Hash) {
+	_, proof, _, _ := t.Tree.Prove()
+	if len(proof) == 0 {
+		return nil, nil
+	}
+
+	base = proof[0]
+	hashSet = make([]Hash, len(proof)-1)
+	for i, p := range proof[1:] {
+		copy(hashSet[i][:], p)
+	}
+	return base, hashSet
+}
+
 // Root is a redefinition of merkletree.Tree.Root, returning a Hash instead of
 // a []byte.
 func (t *MerkleTree) Root() (h Hash) {
@@ -122,19 +139,8 @@ func MerkleProof(b []byte, proofIndex uint64) (base []byte, hashSet []Hash) {
 		t.Push(buf.Next(SegmentSize))
 	}
 
-	// Get the proof and convert it to a base + hash set.
-	_, proof, _, _ := t.Prove()
-	if len(proof) == 0 {
-		// There's no proof, because there's no data. Return blank values.
-		return nil, nil
-	}
-
-	base = proof[0]
-	hashSet = make([]Hash, len(proof)-1)
-	for i, p := range proof[1:] {
-		copy(hashSet[i][:], p)
-	}
-	return base, hashSet
+	// Get the proof as a base + hash set.
+	return t.Prove()
 }
 
 // VerifySegment will verify that a segment, given the proof, is a part of a
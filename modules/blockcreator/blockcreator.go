@@ -23,6 +23,9 @@ type BlockCreator struct {
 	chainCts  types.ChainConstants
 	genesisID types.BlockID
 
+	// clock defines the timestamps for which blocks are created.
+	clock types.Clock
+
 	// Cache the synced state of the consensus set to avoid unnecessarily locking it
 	csSynced bool
 
@@ -71,8 +74,24 @@ func (b *BlockCreator) startupRescan() error {
 	return nil
 }
 
+// Options contains the optional configuration of the BlockCreator.
+type Options struct {
+	// Clock defines the timestamps for which blocks are created.
+	// The system clock is used if no clock is given.
+	Clock types.Clock
+
+	// Manual disables the continuous creation of blocks,
+	// such that blocks are only created by calling CreateBlock.
+	Manual bool
+}
+
 // New returns a block creator that is collaborating in the pobs protocol.
 func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Wallet, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (*BlockCreator, error) {
+	return NewWithOptions(cs, tpool, w, persistDir, bcInfo, chainCts, Options{})
+}
+
+// NewWithOptions returns a block creator, configured using the given options.
+func NewWithOptions(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Wallet, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants, opts Options) (*BlockCreator, error) {
 	// Create the block creator and its dependencies.
 	if cs == nil {
 		return nil, errors.New("A consensset is required to create a block creator")
@@ -84,6 +103,10 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 		return nil, errors.New("A wallet is required to create a block creator")
 	}
 
+	if opts.Clock == nil {
+		opts.Clock = types.StdClock{}
+	}
+
 	// Assemble the block creator.
 	b := &BlockCreator{
 		cs:     cs,
@@ -94,6 +117,8 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 		chainCts:  chainCts,
 		genesisID: chainCts.GenesisBlockID(),
 
+		clock: opts.Clock,
+
 		unsolvedBlock: &types.Block{},
 
 		persistDir: persistDir,
@@ -134,7 +159,9 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 	}
 
 	//Start the proof of block stake protocol
-	go b.SolveBlocks()
+	if !opts.Manual {
+		go b.SolveBlocks()
+	}
 
 	return b, nil
 }
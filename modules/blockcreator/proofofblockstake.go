@@ -2,6 +2,7 @@ package blockcreator
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 	"time"
 
@@ -9,6 +10,11 @@ import (
 	"github.com/rivine/rivine/types"
 )
 
+var (
+	// ErrNoSolution is returned by CreateBlock if no block could be created.
+	ErrNoSolution = errors.New("none of the unspent block stakes solves a block for the current time")
+)
+
 // SolveBlocks participates in the Proof Of Block Stake protocol by continuously checking if
 // unspent block stake outputs make a solution for the current unsolved block.
 // If a match is found, the block is submitted to the consensus set.
@@ -35,7 +41,7 @@ func (bc *BlockCreator) SolveBlocks() {
 		}
 
 		// Try to solve a block for blocktimes of the next 10 seconds
-		now := time.Now().Unix()
+		now := bc.clock.Now()
 		bc.log.Debugln("[BC] Attempting to solve blocks")
 		b := bc.solveBlock(uint64(now), 10)
 		if b != nil {
@@ -52,6 +58,25 @@ func (bc *BlockCreator) SolveBlocks() {
 	}
 }
 
+// CreateBlock tries to solve a block for the current time, submitting it to
+// the consensus set if a solution is found. It returns ErrNoSolution if none
+// of the unspent block stake outputs solve a block for the current time.
+func (bc *BlockCreator) CreateBlock() (types.Block, error) {
+	if err := bc.tg.Add(); err != nil {
+		return types.Block{}, err
+	}
+	defer bc.tg.Done()
+
+	b := bc.solveBlock(uint64(bc.clock.Now()), 1)
+	if b == nil {
+		return types.Block{}, ErrNoSolution
+	}
+	if err := bc.submitBlock(*b); err != nil {
+		return types.Block{}, err
+	}
+	return *b, nil
+}
+
 func (bc *BlockCreator) solveBlock(startTime uint64, secondsInTheFuture uint64) (b *types.Block) {
 
 	bc.mu.RLock()
@@ -1,6 +1,8 @@
 package blockcreator
 
 import (
+	"sort"
+
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
@@ -49,8 +51,93 @@ func (bc *BlockCreator) ProcessConsensusChange(cc modules.ConsensusChange) {
 
 }
 
+// transactionPackage is a group of dependent unconfirmed transactions, such
+// as a transaction and the transactions spending its outputs, which have to be
+// included in a block together.
+type transactionPackage struct {
+	txns []types.Transaction
+	fees types.Currency
+	size int
+}
+
+// packageTransactions groups the given transactions into packages of
+// dependent transactions, preserving the order of the transactions within
+// each package.
+func packageTransactions(txns []types.Transaction) []*transactionPackage {
+	var packages []*transactionPackage
+	owners := make(map[types.OutputID]*transactionPackage)
+	setOwner := func(txn types.Transaction, pkg *transactionPackage) {
+		for i := range txn.CoinOutputs {
+			owners[types.OutputID(txn.CoinOutputID(uint64(i)))] = pkg
+		}
+		for i := range txn.BlockStakeOutputs {
+			owners[types.OutputID(txn.BlockStakeOutputID(uint64(i)))] = pkg
+		}
+	}
+	for _, txn := range txns {
+		// Find the packages which created the outputs spent by the transaction.
+		var parents []*transactionPackage
+		addParent := func(id types.OutputID) {
+			parent, ok := owners[id]
+			if !ok {
+				return
+			}
+			for _, p := range parents {
+				if p == parent {
+					return
+				}
+			}
+			parents = append(parents, parent)
+		}
+		for _, ci := range txn.CoinInputs {
+			addParent(types.OutputID(ci.ParentID))
+		}
+		for _, bsi := range txn.BlockStakeInputs {
+			addParent(types.OutputID(bsi.ParentID))
+		}
+
+		// Merge the parent packages, or start a new package.
+		var pkg *transactionPackage
+		if len(parents) == 0 {
+			pkg = new(transactionPackage)
+			packages = append(packages, pkg)
+		} else {
+			pkg = parents[0]
+			for _, parent := range parents[1:] {
+				for _, ptxn := range parent.txns {
+					setOwner(ptxn, pkg)
+				}
+				pkg.txns = append(pkg.txns, parent.txns...)
+				pkg.fees = pkg.fees.Add(parent.fees)
+				pkg.size += parent.size
+				parent.txns = nil
+			}
+		}
+
+		pkg.txns = append(pkg.txns, txn)
+		for _, fee := range txn.MinerFees {
+			pkg.fees = pkg.fees.Add(fee)
+		}
+		pkg.size += len(encoding.Marshal(txn))
+		setOwner(txn, pkg)
+	}
+
+	// Leave out the packages which were merged into other packages.
+	merged := packages[:0]
+	for _, pkg := range packages {
+		if len(pkg.txns) != 0 {
+			merged = append(merged, pkg)
+		}
+	}
+	return merged
+}
+
 // ReceiveUpdatedUnconfirmedTransactions will replace the current unconfirmed
-// set of transactions with the input transactions.
+// set of transactions with the input transactions. The transactions are
+// grouped into packages of dependent transactions, which are added to the
+// block in order of their fee-per-byte, until the block is full. As a package
+// pays the fees of all of its transactions, a transaction paying a low fee gets
+// into a block sooner if a transaction spending its outputs pays a high fee.
 func (bc *BlockCreator) ReceiveUpdatedUnconfirmedTransactions(unconfirmedTransactions []types.Transaction, _ modules.ConsensusChange) {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
@@ -61,15 +148,24 @@ func (bc *BlockCreator) ReceiveUpdatedUnconfirmedTransactions(unconfirmedTransac
 		return
 	}
 
-	// Add transactions to the block until the block size limit is reached.
-	// Transactions are assumed to be in a sensible order.
-	var i int
+	// Sort the packages from the highest to the lowest fee-per-byte,
+	// comparing the fees and sizes crosswise to avoid rounding.
+	packages := packageTransactions(unconfirmedTransactions)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].fees.Mul64(uint64(packages[j].size)).Cmp(
+			packages[j].fees.Mul64(uint64(packages[i].size))) > 0
+	})
+
+	// Add packages to the block until the block size limit is reached,
+	// skipping the packages which no longer fit.
+	var txns []types.Transaction
 	remainingSize := int(bc.chainCts.BlockSizeLimit - 5e3) //check this 5k for the first extra
-	for i = range unconfirmedTransactions {
-		remainingSize -= len(encoding.Marshal(unconfirmedTransactions[i]))
-		if remainingSize < 0 {
-			break
+	for _, pkg := range packages {
+		if pkg.size > remainingSize {
+			continue
 		}
+		remainingSize -= pkg.size
+		txns = append(txns, pkg.txns...)
 	}
-	bc.unsolvedBlock.Transactions = unconfirmedTransactions[:i+1]
+	bc.unsolvedBlock.Transactions = txns
 }
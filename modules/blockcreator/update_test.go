@@ -1 +1,61 @@
 package blockcreator
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// TestReceiveUpdatedUnconfirmedTransactions tests that unconfirmed
+// transactions are added to the block as packages of dependent transactions,
+// in order of their fee-per-byte, skipping the packages which don't fit.
+func TestReceiveUpdatedUnconfirmedTransactions(t *testing.T) {
+	// newTxn returns a transaction spending the given output,
+	// creating one output and paying the given fee
+	newTxn := func(parent types.CoinOutputID, fee uint64) types.Transaction {
+		return types.Transaction{
+			Version:     types.TransactionVersionOne,
+			CoinInputs:  []types.CoinInput{{ParentID: parent}},
+			CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(1)}},
+			MinerFees:   []types.Currency{types.NewCurrency64(fee)},
+		}
+	}
+	// a parent paying a low fee, and a child paying a high fee for both
+	parent := newTxn(types.CoinOutputID(crypto.HashObject("parent")), 1)
+	child := newTxn(parent.CoinOutputID(0), 1000e3)
+	// a transaction paying a medium fee
+	medium := newTxn(types.CoinOutputID(crypto.HashObject("medium")), 100e3)
+	// a transaction paying a low fee, with a lot of arbitrary data
+	large := newTxn(types.CoinOutputID(crypto.HashObject("large")), 10)
+	large.ArbitraryData = make([]byte, 2e3)
+	// a transaction paying the lowest fee
+	low := newTxn(types.CoinOutputID(crypto.HashObject("low")), 1)
+
+	size := func(txns ...types.Transaction) (n uint64) {
+		for _, txn := range txns {
+			n += uint64(len(encoding.Marshal(txn)))
+		}
+		return
+	}
+	bc := &BlockCreator{
+		chainCts: types.ChainConstants{
+			// only leave room for all transactions but the large one
+			BlockSizeLimit: 5e3 + size(parent, child, medium, low),
+		},
+		unsolvedBlock: &types.Block{},
+	}
+	bc.ReceiveUpdatedUnconfirmedTransactions([]types.Transaction{low, large, medium, parent, child}, modules.ConsensusChange{})
+
+	expected := []types.Transaction{parent, child, medium, low}
+	if len(bc.unsolvedBlock.Transactions) != len(expected) {
+		t.Fatalf("expected %d transactions in the block, got %d", len(expected), len(bc.unsolvedBlock.Transactions))
+	}
+	for i, txn := range bc.unsolvedBlock.Transactions {
+		if txn.ID() != expected[i].ID() {
+			t.Errorf("unexpected transaction at index %d", i)
+		}
+	}
+}
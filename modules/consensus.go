@@ -47,6 +47,13 @@ var (
 	// should be handled by the module, and not reported to the user.
 	ErrInvalidConsensusChangeID = errors.New("consensus subscription has invalid id - files are inconsistent")
 
+	// ErrPrunedConsensusChange indicates that a subscription was requested
+	// starting from a consensus change which is followed by consensus changes
+	// containing blocks below the prune height of the consensus set. The
+	// bodies and diffs of those blocks are no longer available, such that
+	// the subscriber can not be caught up.
+	ErrPrunedConsensusChange = errors.New("consensus subscription starts below the prune height of the consensus set")
+
 	// ErrNonExtendingBlock indicates that a block is valid but does not result
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
@@ -147,6 +154,40 @@ type (
 		ShortID   types.TransactionShortID
 	}
 
+	// ConsensusChangeFilter selects the parts of a consensus change which are
+	// relevant to a subscriber, based on a set of unlock hashes and output IDs.
+	// An empty filter matches nothing.
+	ConsensusChangeFilter struct {
+		UnlockHashes        map[types.UnlockHash]struct{}
+		CoinOutputIDs       map[types.CoinOutputID]struct{}
+		BlockStakeOutputIDs map[types.BlockStakeOutputID]struct{}
+	}
+
+	// ConsensusSubscriberStats describes the delivery state of a subscriber
+	// which receives its consensus changes asynchronously.
+	ConsensusSubscriberStats struct {
+		// Subscriber describes the type of the subscriber.
+		Subscriber string `json:"subscriber"`
+		// QueueSize is the maximum amount of consensus changes which can be
+		// queued for the subscriber.
+		QueueSize int `json:"queuesize"`
+		// Queued is the amount of consensus changes currently queued.
+		Queued int `json:"queued"`
+		// Delivered is the total amount of consensus changes delivered.
+		Delivered uint64 `json:"delivered"`
+		// CatchingUp indicates the subscriber fell behind, and is catching up
+		// using the changelog of the consensus set.
+		CatchingUp bool `json:"catchingup"`
+		// CatchUps is the amount of times the subscriber fell behind.
+		CatchUps uint64 `json:"catchups"`
+		// CatchUpError is the error which caused the last attempt to catch
+		// up to fail, while the subscriber keeps retrying to catch up.
+		CatchUpError string `json:"catchuperror,omitempty"`
+		// Lag is the amount of blocks the subscriber is behind on
+		// the current path of the consensus set.
+		Lag types.BlockHeight `json:"lag"`
+	}
+
 	// A ConsensusSet accepts blocks and builds an understanding of network
 	// consensus.
 	ConsensusSet interface {
@@ -159,7 +200,8 @@ type (
 		AcceptBlock(types.Block) error
 
 		// BlockAtHeight returns the block found at the input height, with a
-		// bool to indicate whether that block exists.
+		// bool to indicate whether that block exists. Blocks below the
+		// PruneHeight might not exist, as they might have been pruned.
 		BlockAtHeight(types.BlockHeight) (types.Block, bool)
 
 		// BlockHeightOfBlock returns the blockheight of a given block, with a
@@ -176,6 +218,11 @@ type (
 		// does not exist, false is returned
 		TransactionAtID(types.TransactionID) (types.Transaction, types.TransactionShortID, bool)
 
+		// TransactionProof returns a Merkle proof that the transaction with the
+		// given ID is part of a block within the blockchain. If that transaction
+		// does not exist, false is returned.
+		TransactionProof(types.TransactionID) (types.TransactionProof, bool)
+
 		// FindParentBlock finds the parent of a block at the given depth. It guarantees that
 		// the correct parent block is found, even if the block is not on the longest fork.
 		FindParentBlock(b types.Block, depth types.BlockHeight) (block types.Block, exists bool)
@@ -193,8 +240,28 @@ type (
 		// and gives them every consensus change that has occurred since the
 		// change with the provided id. There are a few special cases,
 		// described by the ConsensusChangeX variables in this package.
+		// ErrPrunedConsensusChange is returned if the subscriber would have
+		// to be caught up starting below the prune height.
 		ConsensusSetSubscribe(ConsensusSetSubscriber, ConsensusChangeID) error
 
+		// ConsensusSetSubscribeAsync adds a subscriber which receives its
+		// consensus changes asynchronously, such that a slow subscriber does
+		// not stall the consensus set. At most queueSize consensus changes are
+		// queued, once the subscriber falls further behind it catches up using
+		// the changelog of the consensus set.
+		ConsensusSetSubscribeAsync(subscriber ConsensusSetSubscriber, start ConsensusChangeID, queueSize int) error
+
+		// AsyncSubscriberStats returns the delivery state of all subscribers
+		// which receive their consensus changes asynchronously.
+		AsyncSubscriberStats() []ConsensusSubscriberStats
+
+		// ConsensusSetSubscribeFiltered adds a subscriber which only receives
+		// the diffs, transactions and miner payouts of each consensus change
+		// that match the given filter. Every consensus change is delivered,
+		// even if nothing in it matches, such that the subscriber can keep
+		// track of the consensus change IDs.
+		ConsensusSetSubscribeFiltered(subscriber ConsensusSetSubscriber, start ConsensusChangeID, filter ConsensusChangeFilter) error
+
 		// CurrentBlock returns the latest block in the heaviest known
 		// blockchain.
 		CurrentBlock() types.Block
@@ -203,6 +270,11 @@ type (
 		// routines.
 		Flush() error
 
+		// PruneHeight returns the height from which all blocks of the current
+		// path are available. Blocks below this height might have been pruned
+		// from the consensus set, and are no longer available.
+		PruneHeight() types.BlockHeight
+
 		// Height returns the current height of consensus.
 		Height() types.BlockHeight
 
@@ -253,3 +325,123 @@ func (cc ConsensusChange) Append(cc2 ConsensusChange) ConsensusChange {
 		BlockStakeOutputDiffs: append(cc.BlockStakeOutputDiffs, cc2.BlockStakeOutputDiffs...),
 	}
 }
+
+// NewConsensusChangeFilter creates a filter matching the outputs
+// owned by one of the given unlock hashes.
+func NewConsensusChangeFilter(uhs ...types.UnlockHash) ConsensusChangeFilter {
+	filter := ConsensusChangeFilter{
+		UnlockHashes:        make(map[types.UnlockHash]struct{}, len(uhs)),
+		CoinOutputIDs:       make(map[types.CoinOutputID]struct{}),
+		BlockStakeOutputIDs: make(map[types.BlockStakeOutputID]struct{}),
+	}
+	for _, uh := range uhs {
+		filter.UnlockHashes[uh] = struct{}{}
+	}
+	return filter
+}
+
+// Apply returns the consensus change, only containing the output diffs of
+// the outputs matched by the filter, and the transactions creating or spending
+// those outputs. Miner payouts are only kept if their unlock hash is matched.
+// The reverted and applied blocks are always included, but note that the ID
+// of a filtered block does not equal the ID of the original block.
+func (f ConsensusChangeFilter) Apply(cc ConsensusChange) ConsensusChange {
+	// Collect the IDs of all matched outputs. Outputs spent by this change
+	// are part of the diffs as well, such that the transactions spending
+	// them are matched by their inputs.
+	coinOutputIDs := make(map[types.CoinOutputID]struct{})
+	blockStakeOutputIDs := make(map[types.BlockStakeOutputID]struct{})
+	filtered := cc
+	filtered.CoinOutputDiffs = nil
+	filtered.BlockStakeOutputDiffs = nil
+	for _, cod := range cc.CoinOutputDiffs {
+		if f.matchCoinOutput(cod.ID, cod.CoinOutput) {
+			coinOutputIDs[cod.ID] = struct{}{}
+			filtered.CoinOutputDiffs = append(filtered.CoinOutputDiffs, cod)
+		}
+	}
+	for _, bsod := range cc.BlockStakeOutputDiffs {
+		if f.matchBlockStakeOutput(bsod.ID, bsod.BlockStakeOutput) {
+			blockStakeOutputIDs[bsod.ID] = struct{}{}
+			filtered.BlockStakeOutputDiffs = append(filtered.BlockStakeOutputDiffs, bsod)
+		}
+	}
+
+	filterBlock := func(b types.Block) types.Block {
+		fb := types.Block{
+			ParentID:   b.ParentID,
+			Timestamp:  b.Timestamp,
+			POBSOutput: b.POBSOutput,
+		}
+		for _, mp := range b.MinerPayouts {
+			if _, ok := f.UnlockHashes[mp.UnlockHash]; ok {
+				fb.MinerPayouts = append(fb.MinerPayouts, mp)
+			}
+		}
+		for _, txn := range b.Transactions {
+			if f.matchTransaction(txn, coinOutputIDs, blockStakeOutputIDs) {
+				fb.Transactions = append(fb.Transactions, txn)
+			}
+		}
+		return fb
+	}
+	filtered.RevertedBlocks = make([]types.Block, 0, len(cc.RevertedBlocks))
+	for _, b := range cc.RevertedBlocks {
+		filtered.RevertedBlocks = append(filtered.RevertedBlocks, filterBlock(b))
+	}
+	filtered.AppliedBlocks = make([]types.Block, 0, len(cc.AppliedBlocks))
+	for _, b := range cc.AppliedBlocks {
+		filtered.AppliedBlocks = append(filtered.AppliedBlocks, filterBlock(b))
+	}
+	return filtered
+}
+
+// matchCoinOutput returns true if the coin output is matched by the filter.
+func (f ConsensusChangeFilter) matchCoinOutput(id types.CoinOutputID, co types.CoinOutput) bool {
+	if _, ok := f.CoinOutputIDs[id]; ok {
+		return true
+	}
+	_, ok := f.UnlockHashes[co.Condition.UnlockHash()]
+	return ok
+}
+
+// matchBlockStakeOutput returns true if the blockstake output is matched by the filter.
+func (f ConsensusChangeFilter) matchBlockStakeOutput(id types.BlockStakeOutputID, bso types.BlockStakeOutput) bool {
+	if _, ok := f.BlockStakeOutputIDs[id]; ok {
+		return true
+	}
+	_, ok := f.UnlockHashes[bso.Condition.UnlockHash()]
+	return ok
+}
+
+// matchTransaction returns true if the transaction creates a matched output,
+// or spends one of the given (matched) outputs.
+func (f ConsensusChangeFilter) matchTransaction(txn types.Transaction, coinOutputIDs map[types.CoinOutputID]struct{}, blockStakeOutputIDs map[types.BlockStakeOutputID]struct{}) bool {
+	for i, co := range txn.CoinOutputs {
+		if f.matchCoinOutput(txn.CoinOutputID(uint64(i)), co) {
+			return true
+		}
+	}
+	for i, bso := range txn.BlockStakeOutputs {
+		if f.matchBlockStakeOutput(txn.BlockStakeOutputID(uint64(i)), bso) {
+			return true
+		}
+	}
+	for _, ci := range txn.CoinInputs {
+		if _, ok := coinOutputIDs[ci.ParentID]; ok {
+			return true
+		}
+		if _, ok := f.CoinOutputIDs[ci.ParentID]; ok {
+			return true
+		}
+	}
+	for _, bsi := range txn.BlockStakeInputs {
+		if _, ok := blockStakeOutputIDs[bsi.ParentID]; ok {
+			return true
+		}
+		if _, ok := f.BlockStakeOutputIDs[bsi.ParentID]; ok {
+			return true
+		}
+	}
+	return false
+}
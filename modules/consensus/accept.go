@@ -2,13 +2,11 @@ package consensus
 
 import (
 	"errors"
-	"time"
 
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -16,6 +14,9 @@ var (
 	errNoBlockMap      = errors.New("block map is not in database")
 	errInconsistentSet = errors.New("consensus set is not in a consistent state")
 	errOrphan          = errors.New("block has no known parent")
+
+	errCheckpoint          = errors.New("block conflicts with a checkpoint")
+	errForkBelowCheckpoint = errors.New("block forks off below the latest checkpoint")
 )
 
 // managedBroadcastBlock will broadcast a block header to the consensus set's peers.
@@ -24,6 +25,44 @@ func (cs *ConsensusSet) managedBroadcastBlock(b types.Block) {
 	go cs.gateway.Broadcast("RelayHeader", b.Header(), peers)
 }
 
+// validationDepth returns the number of ancestors that have to be available
+// in full to validate a block: the stake modifier, the target adjustment and
+// the minimum valid timestamp never look back further than this.
+func validationDepth(chainCts types.ChainConstants) types.BlockHeight {
+	depth := chainCts.StakeModifierDelay + 256
+	if chainCts.TargetWindow > depth {
+		depth = chainCts.TargetWindow
+	}
+	if types.BlockHeight(chainCts.MedianTimestampWindow) > depth {
+		depth = types.BlockHeight(chainCts.MedianTimestampWindow)
+	}
+	return depth
+}
+
+// checkPrunedParent returns errBlockPruned if the ancestors required to
+// validate a child of the block at the given height might have been pruned.
+func (cs *ConsensusSet) checkPrunedParent(tx dbTx, parentHeight types.BlockHeight) error {
+	pruneHeight := getPruneHeight(tx)
+	if pruneHeight > 0 && parentHeight+1 < pruneHeight+validationDepth(cs.chainCts) {
+		return errBlockPruned
+	}
+	return nil
+}
+
+// validateCheckpoints checks that a new block with the given ID and height
+// does not conflict with the checkpoints of the chain. The blocks of the
+// current path are known, so any new block at or below the latest checkpoint
+// reached by the current path belongs to a fork below that checkpoint.
+func (cs *ConsensusSet) validateCheckpoints(tx dbTx, id types.BlockID, height types.BlockHeight) error {
+	if checkpoint, ok := cs.chainCts.Checkpoints[height]; ok && checkpoint != id {
+		return errCheckpoint
+	}
+	if checkpoint, ok := cs.chainCts.LatestCheckpoint(getBlockHeight(tx)); ok && height <= checkpoint {
+		return errForkBelowCheckpoint
+	}
+	return nil
+}
+
 // validateHeaderAndBlock does some early, low computation verification on the
 // block. Callers should not assume that validation will happen in a particular
 // order.
@@ -56,6 +95,15 @@ func (cs *ConsensusSet) validateHeaderAndBlock(tx dbTx, b types.Block) error {
 	if err != nil {
 		return err
 	}
+	// Blocks forking off below the pruned part of the chain can't be validated.
+	err = cs.checkPrunedParent(tx, parent.Height)
+	if err != nil {
+		return err
+	}
+	err = cs.validateCheckpoints(tx, id, parent.Height+1)
+	if err != nil {
+		return err
+	}
 	// Check that the timestamp is not too far in the past to be acceptable.
 	minTimestamp := cs.blockRuleHelper.minimumValidChildTimestamp(blockMap, &parent)
 
@@ -94,6 +142,14 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	if err != nil {
 		return err
 	}
+	err = cs.checkPrunedParent(tx, parent.Height)
+	if err != nil {
+		return err
+	}
+	err = cs.validateCheckpoints(tx, id, parent.Height+1)
+	if err != nil {
+		return err
+	}
 
 	// TODO: check if the block is a non extending block once headers-first
 	// downloads are implemented.
@@ -108,7 +164,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	// future and extreme future because there is an assumption that by the time
 	// the extreme future arrives, this block will no longer be a part of the
 	// longest fork because it will have been ignored by all of the miners.
-	if h.Timestamp > types.CurrentTimestamp()+cs.chainCts.ExtremeFutureThreshold {
+	if h.Timestamp > cs.clock.Now()+cs.chainCts.ExtremeFutureThreshold {
 		return errExtremeFutureTimestamp
 	}
 
@@ -133,7 +189,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 // unneeded.
 func (cs *ConsensusSet) addBlockToTree(b types.Block) (ce changeEntry, err error) {
 	var nonExtending bool
-	err = cs.db.Update(func(tx *bolt.Tx) error {
+	err = cs.db.Update(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, b.ParentID)
 		if build.DEBUG && err != nil {
 			panic(err)
@@ -176,7 +232,7 @@ func (cs *ConsensusSet) addBlockToTree(b types.Block) (ce changeEntry, err error
 		if err != nil {
 			return err
 		}
-		return nil
+		return cs.pruneBlocks(tx)
 	})
 	if err != nil {
 		return changeEntry{}, err
@@ -204,7 +260,7 @@ func (cs *ConsensusSet) managedAcceptBlock(b types.Block) error {
 	cs.mu.Lock()
 
 	// Start verification inside of a bolt View tx.
-	err := cs.db.View(func(tx *bolt.Tx) error {
+	err := cs.db.View(func(tx persist.KVTx) error {
 		// Do not accept a block if the database is inconsistent.
 		if inconsistencyDetected(tx) {
 			return errInconsistentSet
@@ -212,7 +268,7 @@ func (cs *ConsensusSet) managedAcceptBlock(b types.Block) error {
 		// Do some relatively inexpensive checks to validate the header and block.
 		// Validation generally occurs in the order of least expensive validation
 		// first.
-		err := cs.validateHeaderAndBlock(boltTxWrapper{tx}, b)
+		err := cs.validateHeaderAndBlock(kvTxWrapper{tx}, b)
 		if err != nil {
 			// If the block is in the near future, but too far to be acceptable, then
 			// save the block and add it to the consensus set after it is no longer
@@ -232,7 +288,13 @@ func (cs *ConsensusSet) managedAcceptBlock(b types.Block) error {
 			// a new block to the cache.
 			if err == errFutureTimestamp {
 				go func() {
-					time.Sleep(time.Duration(b.Timestamp-(types.CurrentTimestamp()+cs.chainCts.FutureThreshold)) * time.Second)
+					// wait on the clock of the consensus set,
+					// as it is the one which judges the timestamp
+					select {
+					case <-cs.clock.Until(b.Timestamp - cs.chainCts.FutureThreshold):
+					case <-cs.tg.StopChan():
+						return
+					}
 					err := cs.managedAcceptBlock(b)
 					if err != nil {
 						cs.log.Debugln("WARN: failed to accept a future block:", err)
@@ -6,14 +6,13 @@ package consensus
 import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 // applyCoinInputs takes all of the coin inputs in a transaction and
 // applies them to the state, updating the diffs in the processed block.
-func applyCoinInputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyCoinInputs(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	// Remove all coin inputs from the unspent siacoin outputs list.
 	for _, sci := range t.CoinInputs {
 		sco, err := getCoinOutput(tx, sci.ParentID)
@@ -32,7 +31,7 @@ func applyCoinInputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
 
 // applyCoinOutputs takes all of the coin outputs in a transaction and
 // applies them to the state, updating the diffs in the processed block.
-func applyCoinOutputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyCoinOutputs(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	// Add all siacoin outputs to the unspent siacoin outputs list.
 	for i, sco := range t.CoinOutputs {
 		scoid := t.CoinOutputID(uint64(i))
@@ -48,7 +47,7 @@ func applyCoinOutputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
 
 // applyBlockStakeInputs takes all of the siafund inputs in a transaction and
 // applies them to the state, updating the diffs in the processed block.
-func applyBlockStakeInputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyBlockStakeInputs(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	for _, sfi := range t.BlockStakeInputs {
 		// Calculate the volume of siacoins to put in the claim output.
 		sfo, err := getBlockStakeOutput(tx, sfi.ParentID)
@@ -69,7 +68,7 @@ func applyBlockStakeInputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction)
 }
 
 // applyBlockStakeOutput applies a siafund output to the consensus set.
-func applyBlockStakeOutputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyBlockStakeOutputs(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	for i, sfo := range t.BlockStakeOutputs {
 		sfoid := t.BlockStakeOutputID(uint64(i))
 		sfod := modules.BlockStakeOutputDiff{
@@ -83,7 +82,7 @@ func applyBlockStakeOutputs(tx *bolt.Tx, pb *processedBlock, t types.Transaction
 }
 
 // applyTransactionIDMapping applies a transaction id mapping to the consensus set
-func applyTransactionIDMapping(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyTransactionIDMapping(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	tidmod := modules.TransactionIDDiff{
 		Direction: modules.DiffApply,
 		LongID:    t.ID(),
@@ -96,7 +95,7 @@ func applyTransactionIDMapping(tx *bolt.Tx, pb *processedBlock, t types.Transact
 // applyTransaction applies the contents of a transaction to the ConsensusSet.
 // This produces a set of diffs, which are stored in the blockNode containing
 // the transaction. No verification is done by this function.
-func applyTransaction(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+func applyTransaction(tx persist.KVTx, pb *processedBlock, t types.Transaction) {
 	applyCoinInputs(tx, pb, t)
 	applyCoinOutputs(tx, pb, t)
 	applyBlockStakeInputs(tx, pb, t)
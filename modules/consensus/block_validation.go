@@ -40,7 +40,7 @@ type stdBlockValidator struct {
 // newBlockValidator creates a new stdBlockValidator with default settings.
 func newBlockValidator(consensusSet *ConsensusSet) stdBlockValidator {
 	return stdBlockValidator{
-		clock:     types.StdClock{},
+		clock:     consensusSet.clock,
 		marshaler: stdMarshaler{},
 		cs:        consensusSet,
 	}
@@ -165,6 +165,12 @@ func (bv stdBlockValidator) ValidateBlock(b types.Block, minTimestamp types.Time
 		return errBadMinerPayouts
 	}
 
+	// Verify that the block adheres to the rules of the active protocol upgrades.
+	err := bv.cs.chainCts.ProtocolRules(height).ValidateBlock(b)
+	if err != nil {
+		return err
+	}
+
 	// Check if the block is in the near future, but too far to be acceptable.
 	// This is the last check because it's an expensive check, and not worth
 	// performing if the payouts are incorrect.
@@ -29,6 +29,16 @@ func (c mockClock) Now() types.Timestamp {
 	return c.now
 }
 
+// Until returns a channel which is closed if the given Timestamp is not after
+// mockClock's pre-defined Timestamp, and never closed otherwise.
+func (c mockClock) Until(t types.Timestamp) <-chan struct{} {
+	ch := make(chan struct{})
+	if t <= c.now {
+		close(ch)
+	}
+	return ch
+}
+
 var validateBlockTests = []struct {
 	now            types.Timestamp
 	minTimestamp   types.Timestamp
@@ -21,9 +21,8 @@ import (
 	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -53,7 +52,7 @@ type (
 )
 
 // appendChangeLog adds a new change entry to the change log.
-func appendChangeLog(tx *bolt.Tx, ce changeEntry) error {
+func appendChangeLog(tx persist.KVTx, ce changeEntry) error {
 	// Insert the change entry.
 	cl := tx.Bucket(ChangeLog)
 	ceid := ce.ID()
@@ -94,7 +93,7 @@ func appendChangeLog(tx *bolt.Tx, ce changeEntry) error {
 
 // getEntry returns the change entry with a given id, using a bool to indicate
 // existence.
-func getEntry(tx *bolt.Tx, id modules.ConsensusChangeID) (ce changeEntry, exists bool) {
+func getEntry(tx persist.KVTx, id modules.ConsensusChangeID) (ce changeEntry, exists bool) {
 	var cn changeNode
 	cl := tx.Bucket(ChangeLog)
 	changeNodeBytes := cl.Get(id[:])
@@ -114,7 +113,7 @@ func (ce *changeEntry) ID() modules.ConsensusChangeID {
 }
 
 // NextEntry returns the entry after the current entry.
-func (ce *changeEntry) NextEntry(tx *bolt.Tx) (nextEntry changeEntry, exists bool) {
+func (ce *changeEntry) NextEntry(tx persist.KVTx) (nextEntry changeEntry, exists bool) {
 	// Get the change node associated with the provided change entry.
 	ceid := ce.ID()
 	var cn changeNode
@@ -129,7 +128,13 @@ func (ce *changeEntry) NextEntry(tx *bolt.Tx) (nextEntry changeEntry, exists boo
 }
 
 // createChangeLog assumes that no change log exists and creates a new one.
-func (cs *ConsensusSet) createChangeLog(tx *bolt.Tx) error {
+func (cs *ConsensusSet) createChangeLog(tx persist.KVTx) error {
+	return initChangeLog(tx, cs.genesisEntry())
+}
+
+// initChangeLog assumes that no change log exists and creates a new one, with
+// the given genesis entry as its first entry.
+func initChangeLog(tx persist.KVTx, ge changeEntry) error {
 	// Create the changelog bucket.
 	cl, err := tx.CreateBucket(ChangeLog)
 	if err != nil {
@@ -137,7 +142,6 @@ func (cs *ConsensusSet) createChangeLog(tx *bolt.Tx) error {
 	}
 
 	// Add the genesis block as the first entry of the change log.
-	ge := cs.genesisEntry()
 	geid := ge.ID()
 	cn := changeNode{
 		Entry: ge,
@@ -0,0 +1,90 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+// TestForkBelowCheckpoint checks that the consensus set does not switch to a
+// heavier fork which forks off below the latest checkpoint, and that it
+// refuses new blocks conflicting with the checkpoints.
+func TestForkBelowCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cts.StakeModifierDelay = 0
+
+	// the blocks created by addTreeTestBlock are known upfront,
+	// such that a block of the main chain can be made a checkpoint
+	mainChain := make([]types.Block, 6)
+	parent := cts.GenesisBlock()
+	for i := range mainChain {
+		mainChain[i] = types.Block{ParentID: parent.ID(), Timestamp: parent.Timestamp + 1}
+		parent = mainChain[i]
+	}
+	cts.Checkpoints = map[types.BlockHeight]types.BlockID{4: mainChain[3].ID()}
+
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	// fork off at height 2, before the checkpoint is reached
+	parent = cs.blockRoot.Block
+	var fork []types.Block
+	for i := range mainChain {
+		addTreeTestBlock(t, cs, parent, 1)
+		if i == 2 {
+			fork = append(fork, addTreeTestBlock(t, cs, parent, 2))
+		}
+		parent = mainChain[i]
+	}
+	if cs.CurrentBlock().ID() != mainChain[5].ID() {
+		t.Fatal("consensus set is not on the main chain")
+	}
+
+	// new blocks below the latest checkpoint are refused
+	err = cs.db.View(func(tx persist.KVTx) error {
+		if err := cs.validateCheckpoints(kvTxWrapper{tx}, fork[0].ID(), 4); err != errCheckpoint {
+			t.Error("expected errCheckpoint, got", err)
+		}
+		if err := cs.validateCheckpoints(kvTxWrapper{tx}, fork[0].ID(), 3); err != errForkBelowCheckpoint {
+			t.Error("expected errForkBelowCheckpoint, got", err)
+		}
+		if err := cs.validateCheckpoints(kvTxWrapper{tx}, fork[0].ID(), 7); err != nil {
+			t.Error(err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// extending the fork, until it is heavier than the main chain,
+	// does not revert the checkpoint
+	for len(fork) < 4 {
+		fork = append(fork, addTreeTestBlock(t, cs, fork[len(fork)-1], 1))
+	}
+	heaviest := types.Block{ParentID: fork[3].ID(), Timestamp: fork[3].Timestamp + 1}
+	if _, err := cs.addBlockToTree(heaviest); err != errForkBelowCheckpoint {
+		t.Fatal("expected errForkBelowCheckpoint, got", err)
+	}
+	if cs.CurrentBlock().ID() != mainChain[5].ID() {
+		t.Fatal("consensus set switched to a fork below the checkpoint")
+	}
+
+	// a heavier fork above the checkpoint is accepted
+	b := mainChain[3]
+	for i := 0; i < 3; i++ {
+		b = addTreeTestBlock(t, cs, b, 2)
+	}
+	if cs.CurrentBlock().ID() != b.ID() {
+		t.Fatal("consensus set did not switch to the heaviest fork")
+	}
+}
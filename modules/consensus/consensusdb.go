@@ -10,9 +10,8 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -56,10 +55,26 @@ var (
 	// TransactionIDMap is a database bucket that containsall of the present
 	// transaction IDs linked to their short ID
 	TransactionIDMap = []byte("TransactionIDMap")
+
+	// PrunedBlockMap is a database bucket containing the pruned blocks of the
+	// current path, keyed by their id. A block is either in the BlockMap or in
+	// the PrunedBlockMap, never in both.
+	PrunedBlockMap = []byte("PrunedBlockMap")
+
+	// PrunedCoinOutputs and PrunedBlockStakeOutputs are database buckets that
+	// contain the outputs that were unspent at the prune height. They take
+	// the place of the diffs of the blocks below the prune height.
+	PrunedCoinOutputs       = []byte("PrunedCoinOutputs")
+	PrunedBlockStakeOutputs = []byte("PrunedBlockStakeOutputs")
+
+	// PruneHeight is a bucket that stores the lowest height from which all
+	// blocks of the current path are guaranteed to be available in full.
+	// Blocks below this height might be pruned, or kept without their diffs.
+	PruneHeight = []byte("PruneHeight")
 )
 
 // createConsensusObjects initialzes the consensus portions of the database.
-func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
+func (cs *ConsensusSet) createConsensusDB(tx persist.KVTx) error {
 	// Enumerate and create the database buckets.
 	buckets := [][]byte{
 		BlockHeight,
@@ -69,6 +84,10 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 		CoinOutputs,
 		BlockStakeOutputs,
 		TransactionIDMap,
+		PrunedBlockMap,
+		PrunedCoinOutputs,
+		PrunedBlockStakeOutputs,
+		PruneHeight,
 	}
 	for _, bucket := range buckets {
 		_, err := tx.CreateBucket(bucket)
@@ -85,6 +104,12 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 		return err
 	}
 
+	// Nothing has been pruned yet.
+	err = tx.Bucket(PruneHeight).Put(PruneHeight, encoding.Marshal(types.BlockHeight(0)))
+	if err != nil {
+		return err
+	}
+
 	// Update the blockstake and coin output diffs map for the genesis block on disk. This
 	// needs to happen between the database being opened/initilized and the
 	// consensus set hash being calculated
@@ -106,7 +131,7 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 }
 
 // blockHeight returns the height of the blockchain.
-func blockHeight(tx *bolt.Tx) (height types.BlockHeight) {
+func blockHeight(tx persist.KVTx) (height types.BlockHeight) {
 	bh := tx.Bucket(BlockHeight)
 	err := encoding.Unmarshal(bh.Get(BlockHeight), &height)
 	if build.DEBUG && err != nil {
@@ -115,8 +140,26 @@ func blockHeight(tx *bolt.Tx) (height types.BlockHeight) {
 	return
 }
 
+// getBlockHeight returns the height of the blockchain, like blockHeight does,
+// but works on any dbTx, returning 0 if the height is not available.
+func getBlockHeight(tx dbTx) (height types.BlockHeight) {
+	bucket := tx.Bucket(BlockHeight)
+	if bucket == nil {
+		return 0
+	}
+	heightBytes := bucket.Get(BlockHeight)
+	if heightBytes == nil {
+		return 0
+	}
+	err := encoding.Unmarshal(heightBytes, &height)
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+	return
+}
+
 // blockTimeStamp returns the timestamp of the block on the given height.
-func blockTimeStamp(tx *bolt.Tx, height types.BlockHeight) (types.Timestamp, error) {
+func blockTimeStamp(tx persist.KVTx, height types.BlockHeight) (types.Timestamp, error) {
 	id, err := getPath(tx, height)
 	if err != nil {
 		return 0, err
@@ -129,7 +172,7 @@ func blockTimeStamp(tx *bolt.Tx, height types.BlockHeight) (types.Timestamp, err
 }
 
 // currentBlockID returns the id of the most recent block in the consensus set.
-func currentBlockID(tx *bolt.Tx) types.BlockID {
+func currentBlockID(tx persist.KVTx) types.BlockID {
 	id, err := getPath(tx, blockHeight(tx))
 	if build.DEBUG && err != nil {
 		panic(err)
@@ -138,7 +181,7 @@ func currentBlockID(tx *bolt.Tx) types.BlockID {
 }
 
 // currentProcessedBlock returns the most recent block in the consensus set.
-func currentProcessedBlock(tx *bolt.Tx) *processedBlock {
+func currentProcessedBlock(tx persist.KVTx) *processedBlock {
 	pb, err := getBlockMap(tx, currentBlockID(tx))
 	if build.DEBUG && err != nil {
 		panic(err)
@@ -146,11 +189,15 @@ func currentProcessedBlock(tx *bolt.Tx) *processedBlock {
 	return pb
 }
 
-// getBlockMap returns a processed block with the input id.
-func getBlockMap(tx *bolt.Tx, id types.BlockID) (*processedBlock, error) {
+// getBlockMap returns a processed block with the input id. errBlockPruned is
+// returned if the block is part of the current path, but has been pruned.
+func getBlockMap(tx persist.KVTx, id types.BlockID) (*processedBlock, error) {
 	// Look up the encoded block.
 	pbBytes := tx.Bucket(BlockMap).Get(id[:])
 	if pbBytes == nil {
+		if tx.Bucket(PrunedBlockMap).Get(id[:]) != nil {
+			return nil, errBlockPruned
+		}
 		return nil, errNilItem
 	}
 
@@ -164,7 +211,7 @@ func getBlockMap(tx *bolt.Tx, id types.BlockID) (*processedBlock, error) {
 }
 
 // addBlockMap adds a processed block to the block map.
-func addBlockMap(tx *bolt.Tx, pb *processedBlock) {
+func addBlockMap(tx persist.KVTx, pb *processedBlock) {
 	id := pb.Block.ID()
 	err := tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(*pb))
 	if build.DEBUG && err != nil {
@@ -172,8 +219,57 @@ func addBlockMap(tx *bolt.Tx, pb *processedBlock) {
 	}
 }
 
+// getPrunedBlock returns the pruned block with the input id.
+func getPrunedBlock(tx persist.KVTx, id types.BlockID) (*prunedBlock, error) {
+	pbBytes := tx.Bucket(PrunedBlockMap).Get(id[:])
+	if pbBytes == nil {
+		return nil, errNilItem
+	}
+	var pb prunedBlock
+	err := encoding.Unmarshal(pbBytes, &pb)
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+	return &pb, nil
+}
+
+// getBlockHeader returns the header and height of the block with the input
+// id, whether that block has been pruned or not.
+func getBlockHeader(tx persist.KVTx, id types.BlockID) (types.BlockHeader, types.BlockHeight, error) {
+	pb, err := getBlockMap(tx, id)
+	if err == nil {
+		return pb.Block.Header(), pb.Height, nil
+	}
+	if err != errBlockPruned {
+		return types.BlockHeader{}, 0, err
+	}
+	ppb, err := getPrunedBlock(tx, id)
+	if err != nil {
+		return types.BlockHeader{}, 0, err
+	}
+	return ppb.Header, ppb.Height, nil
+}
+
+// getPruneHeight returns the height from which all blocks of the current path
+// are available in full. Zero is returned if nothing has been pruned.
+func getPruneHeight(tx dbTx) (height types.BlockHeight) {
+	bucket := tx.Bucket(PruneHeight)
+	if bucket == nil {
+		return 0
+	}
+	heightBytes := bucket.Get(PruneHeight)
+	if heightBytes == nil {
+		return 0
+	}
+	err := encoding.Unmarshal(heightBytes, &height)
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+	return
+}
+
 // getPath returns the block id at 'height' in the block path.
-func getPath(tx *bolt.Tx, height types.BlockHeight) (id types.BlockID, err error) {
+func getPath(tx persist.KVTx, height types.BlockHeight) (id types.BlockID, err error) {
 	idBytes := tx.Bucket(BlockPath).Get(encoding.Marshal(height))
 	if idBytes == nil {
 		return types.BlockID{}, errNilItem
@@ -187,7 +283,7 @@ func getPath(tx *bolt.Tx, height types.BlockHeight) (id types.BlockID, err error
 }
 
 // pushPath adds a block to the BlockPath at current height + 1.
-func pushPath(tx *bolt.Tx, bid types.BlockID) {
+func pushPath(tx persist.KVTx, bid types.BlockID) {
 	// Fetch and update the block height.
 	bh := tx.Bucket(BlockHeight)
 	heightBytes := bh.Get(BlockHeight)
@@ -212,7 +308,7 @@ func pushPath(tx *bolt.Tx, bid types.BlockID) {
 
 // popPath removes a block from the "end" of the chain, i.e. the block
 // with the largest height.
-func popPath(tx *bolt.Tx) {
+func popPath(tx persist.KVTx) {
 	// Fetch and update the block height.
 	bh := tx.Bucket(BlockHeight)
 	oldHeightBytes := bh.Get(BlockHeight)
@@ -238,7 +334,7 @@ func popPath(tx *bolt.Tx) {
 
 // isCoinOutput returns true if there is a coin output of that id in the
 // database.
-func isCoinOutput(tx *bolt.Tx, id types.CoinOutputID) bool {
+func isCoinOutput(tx persist.KVTx, id types.CoinOutputID) bool {
 	bucket := tx.Bucket(CoinOutputs)
 	sco := bucket.Get(id[:])
 	return sco != nil
@@ -246,7 +342,7 @@ func isCoinOutput(tx *bolt.Tx, id types.CoinOutputID) bool {
 
 // getCoinOutput fetches a coin output from the database. An error is
 // returned if the siacoin output does not exist.
-func getCoinOutput(tx *bolt.Tx, id types.CoinOutputID) (types.CoinOutput, error) {
+func getCoinOutput(tx persist.KVTx, id types.CoinOutputID) (types.CoinOutput, error) {
 	scoBytes := tx.Bucket(CoinOutputs).Get(id[:])
 	if scoBytes == nil {
 		return types.CoinOutput{}, errNilItem
@@ -261,7 +357,7 @@ func getCoinOutput(tx *bolt.Tx, id types.CoinOutputID) (types.CoinOutput, error)
 
 // addCoinOutput adds a coin output to the database. An error is returned
 // if the coin output is already in the database.
-func addCoinOutput(tx *bolt.Tx, id types.CoinOutputID, sco types.CoinOutput) {
+func addCoinOutput(tx persist.KVTx, id types.CoinOutputID, sco types.CoinOutput) {
 	// While this is not supposed to be allowed, there's a bug in the consensus
 	// code which means that earlier versions have accetped 0-value outputs
 	// onto the blockchain. A hardfork to remove 0-value outputs will fix this,
@@ -284,7 +380,7 @@ func addCoinOutput(tx *bolt.Tx, id types.CoinOutputID, sco types.CoinOutput) {
 
 // removeCoinOutput removes a coin output from the database. An error is
 // returned if the coin output is not in the database prior to removal.
-func removeCoinOutput(tx *bolt.Tx, id types.CoinOutputID) {
+func removeCoinOutput(tx persist.KVTx, id types.CoinOutputID) {
 	scoBucket := tx.Bucket(CoinOutputs)
 	// Sanity check - should not be removing an item that is not in the db.
 	if build.DEBUG && scoBucket.Get(id[:]) == nil {
@@ -298,7 +394,7 @@ func removeCoinOutput(tx *bolt.Tx, id types.CoinOutputID) {
 
 // getBlockStakeOutput fetches a blockstake output from the database. An error is
 // returned if the blockstake output does not exist.
-func getBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID) (types.BlockStakeOutput, error) {
+func getBlockStakeOutput(tx persist.KVTx, id types.BlockStakeOutputID) (types.BlockStakeOutput, error) {
 	sfoBytes := tx.Bucket(BlockStakeOutputs).Get(id[:])
 	if sfoBytes == nil {
 		return types.BlockStakeOutput{}, errNilItem
@@ -313,7 +409,7 @@ func getBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID) (types.BlockS
 
 // addBlockStakeOutput adds a blockstake output to the database. An error is returned
 // if the blockstake output is already in the database.
-func addBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID, sfo types.BlockStakeOutput) {
+func addBlockStakeOutput(tx persist.KVTx, id types.BlockStakeOutputID, sfo types.BlockStakeOutput) {
 	blockstakeOutputs := tx.Bucket(BlockStakeOutputs)
 	// Sanity check - should not be adding a blockstake output with a value of
 	// zero.
@@ -332,7 +428,7 @@ func addBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID, sfo types.Blo
 
 // removeBlockStakeOutput removes a blockstake output from the database. An error is
 // returned if the blockstake output is not in the database prior to removal.
-func removeBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID) {
+func removeBlockStakeOutput(tx persist.KVTx, id types.BlockStakeOutputID) {
 	sfoBucket := tx.Bucket(BlockStakeOutputs)
 	if build.DEBUG && sfoBucket.Get(id[:]) == nil {
 		panic("nil blockstake output")
@@ -344,7 +440,7 @@ func removeBlockStakeOutput(tx *bolt.Tx, id types.BlockStakeOutputID) {
 }
 
 // addTxnIDMapping adds a transaction ID mapping to the database.
-func addTxnIDMapping(tx *bolt.Tx, longID types.TransactionID, shortID types.TransactionShortID) {
+func addTxnIDMapping(tx persist.KVTx, longID types.TransactionID, shortID types.TransactionShortID) {
 	txIDMapBucket := tx.Bucket(TransactionIDMap)
 	// Sanity check - should not be adding an item already in the db.
 	if build.DEBUG && txIDMapBucket.Get(longID[:]) != nil {
@@ -357,7 +453,7 @@ func addTxnIDMapping(tx *bolt.Tx, longID types.TransactionID, shortID types.Tran
 }
 
 // removeTxnIDMappng removes a transaction ID mapping from the database.
-func removeTxnIDMapping(tx *bolt.Tx, longID types.TransactionID) {
+func removeTxnIDMapping(tx persist.KVTx, longID types.TransactionID) {
 	txIDMapBucket := tx.Bucket(TransactionIDMap)
 	if build.DEBUG && txIDMapBucket.Get(longID[:]) == nil {
 		panic("nil txID mapping")
@@ -370,7 +466,7 @@ func removeTxnIDMapping(tx *bolt.Tx, longID types.TransactionID) {
 
 // getTransactionShortID returns a transaction short ID from
 // a regular transaction ID
-func getTransactionShortID(tx *bolt.Tx, id types.TransactionID) (types.TransactionShortID, error) {
+func getTransactionShortID(tx persist.KVTx, id types.TransactionID) (types.TransactionShortID, error) {
 	shortIDBytes := tx.Bucket(TransactionIDMap).Get(id[:])
 	if shortIDBytes == nil {
 		return types.TransactionShortID(0), errNilItem
@@ -381,7 +477,7 @@ func getTransactionShortID(tx *bolt.Tx, id types.TransactionID) (types.Transacti
 }
 
 // addDCO adds a delayed coin output to the consensus set.
-func addDCO(tx *bolt.Tx, bh types.BlockHeight, id types.CoinOutputID, sco types.CoinOutput) {
+func addDCO(tx persist.KVTx, bh types.BlockHeight, id types.CoinOutputID, sco types.CoinOutput) {
 	// Sanity check - dco should never have a value of zero.
 	if build.DEBUG && sco.Value.IsZero() {
 		panic("zero-value dco being added")
@@ -403,7 +499,7 @@ func addDCO(tx *bolt.Tx, bh types.BlockHeight, id types.CoinOutputID, sco types.
 }
 
 // removeDCO removes a delayed siacoin output from the consensus set.
-func removeDCO(tx *bolt.Tx, bh types.BlockHeight, id types.CoinOutputID) {
+func removeDCO(tx persist.KVTx, bh types.BlockHeight, id types.CoinOutputID) {
 	bucketID := append(prefixDCO, encoding.Marshal(bh)...)
 	// Sanity check - should not remove an item not in the db.
 	dscoBucket := tx.Bucket(bucketID)
@@ -418,7 +514,7 @@ func removeDCO(tx *bolt.Tx, bh types.BlockHeight, id types.CoinOutputID) {
 
 // createDCOBucket creates a bucket for the delayed coin outputs at the
 // input height.
-func createDCOBucket(tx *bolt.Tx, bh types.BlockHeight) {
+func createDCOBucket(tx persist.KVTx, bh types.BlockHeight) {
 	bucketID := append(prefixDCO, encoding.Marshal(bh)...)
 	_, err := tx.CreateBucket(bucketID)
 	if build.DEBUG && err != nil {
@@ -427,7 +523,7 @@ func createDCOBucket(tx *bolt.Tx, bh types.BlockHeight) {
 }
 
 // deleteDCOBucket deletes the bucket that held a set of delayed coin outputs.
-func deleteDCOBucket(tx *bolt.Tx, bh types.BlockHeight) {
+func deleteDCOBucket(tx persist.KVTx, bh types.BlockHeight) {
 	// Delete the bucket.
 	bucketID := append(prefixDCO, encoding.Marshal(bh)...)
 	bucket := tx.Bucket(bucketID)
@@ -5,15 +5,14 @@ package consensus
 
 import (
 	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 // dbBlockHeight is a convenience function allowing blockHeight to be called
-// without a bolt.Tx.
+// without a persist.KVTx.
 func (cs *ConsensusSet) dbBlockHeight() (bh types.BlockHeight) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		bh = blockHeight(tx)
 		return nil
 	})
@@ -24,9 +23,9 @@ func (cs *ConsensusSet) dbBlockHeight() (bh types.BlockHeight) {
 }
 
 // dbCurrentBlockID is a convenience function allowing currentBlockID to be
-// called without a bolt.Tx.
+// called without a persist.KVTx.
 func (cs *ConsensusSet) dbCurrentBlockID() (id types.BlockID) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		id = currentBlockID(tx)
 		return nil
 	})
@@ -37,9 +36,9 @@ func (cs *ConsensusSet) dbCurrentBlockID() (id types.BlockID) {
 }
 
 // dbCurrentProcessedBlock is a convenience function allowing
-// currentProcessedBlock to be called without a bolt.Tx.
+// currentProcessedBlock to be called without a persist.KVTx.
 func (cs *ConsensusSet) dbCurrentProcessedBlock() (pb *processedBlock) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		pb = currentProcessedBlock(tx)
 		return nil
 	})
@@ -50,9 +49,9 @@ func (cs *ConsensusSet) dbCurrentProcessedBlock() (pb *processedBlock) {
 }
 
 // dbGetPath is a convenience function allowing getPath to be called without a
-// bolt.Tx.
+// persist.KVTx.
 func (cs *ConsensusSet) dbGetPath(bh types.BlockHeight) (id types.BlockID, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		id, err = getPath(tx, bh)
 		return nil
 	})
@@ -63,9 +62,9 @@ func (cs *ConsensusSet) dbGetPath(bh types.BlockHeight) (id types.BlockID, err e
 }
 
 // dbPushPath is a convenience function allowing pushPath to be called without a
-// bolt.Tx.
+// persist.KVTx.
 func (cs *ConsensusSet) dbPushPath(bid types.BlockID) {
-	dbErr := cs.db.Update(func(tx *bolt.Tx) error {
+	dbErr := cs.db.Update(func(tx persist.KVTx) error {
 		pushPath(tx, bid)
 		return nil
 	})
@@ -75,9 +74,9 @@ func (cs *ConsensusSet) dbPushPath(bid types.BlockID) {
 }
 
 // dbGetBlockMap is a convenience function allowing getBlockMap to be called
-// without a bolt.Tx.
+// without a persist.KVTx.
 func (cs *ConsensusSet) dbGetBlockMap(id types.BlockID) (pb *processedBlock, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		pb, err = getBlockMap(tx, id)
 		return nil
 	})
@@ -88,9 +87,9 @@ func (cs *ConsensusSet) dbGetBlockMap(id types.BlockID) (pb *processedBlock, err
 }
 
 // dbGetCoinOutput is a convenience function allowing getCoinOutput to be
-// called without a bolt.Tx.
+// called without a persist.KVTx.
 func (cs *ConsensusSet) dbGetCoinOutput(id types.CoinOutputID) (sco types.CoinOutput, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		sco, err = getCoinOutput(tx, id)
 		return nil
 	})
@@ -103,7 +102,7 @@ func (cs *ConsensusSet) dbGetCoinOutput(id types.CoinOutputID) (sco types.CoinOu
 // getArbCoinOutput is a convenience function fetching a single random
 // coin output from the database.
 func (cs *ConsensusSet) getArbCoinOutput() (scoid types.CoinOutputID, sco types.CoinOutput, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		cursor := tx.Bucket(CoinOutputs).Cursor()
 		scoidBytes, scoBytes := cursor.First()
 		copy(scoid[:], scoidBytes)
@@ -119,9 +118,9 @@ func (cs *ConsensusSet) getArbCoinOutput() (scoid types.CoinOutputID, sco types.
 }
 
 // dbGetBlockStakeOutput is a convenience function allowing getSiafundOutput to be
-// called without a bolt.Tx.
+// called without a persist.KVTx.
 func (cs *ConsensusSet) dbGetBlockStakeOutput(id types.BlockStakeOutputID) (sfo types.BlockStakeOutput, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		sfo, err = getBlockStakeOutput(tx, id)
 		return nil
 	})
@@ -132,9 +131,9 @@ func (cs *ConsensusSet) dbGetBlockStakeOutput(id types.BlockStakeOutputID) (sfo
 }
 
 // dbAddBlockStakeOutput is a convenience function allowing addBlockStakeOutput to be
-// called without a bolt.Tx.
+// called without a persist.KVTx.
 func (cs *ConsensusSet) dbAddBlockStakeOutput(id types.BlockStakeOutputID, sfo types.BlockStakeOutput) {
-	dbErr := cs.db.Update(func(tx *bolt.Tx) error {
+	dbErr := cs.db.Update(func(tx persist.KVTx) error {
 		addBlockStakeOutput(tx, id, sfo)
 		return nil
 	})
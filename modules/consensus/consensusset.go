@@ -16,11 +16,12 @@ import (
 	"github.com/rivine/rivine/types"
 
 	"github.com/NebulousLabs/demotemutex"
-	"github.com/rivine/bbolt"
 )
 
 var (
 	errNilGateway = errors.New("cannot have a nil gateway as input")
+	errNilStore   = errors.New("cannot have a nil key-value store as input")
+	errNilLogger  = errors.New("cannot have a nil logger as input")
 )
 
 // marshaler marshals objects into byte slices and unmarshals byte
@@ -81,13 +82,21 @@ type ConsensusSet struct {
 	// whether the consensus set is synced with the network.
 	synced bool
 
+	// pruneDepth is the number of blocks of the current path which are kept
+	// in full. Deeper blocks are pruned, unless pruneDepth is 0.
+	pruneDepth types.BlockHeight
+
+	// clock is used to determine whether blocks and headers are in the
+	// future.
+	clock types.Clock
+
 	// Interfaces to abstract the dependencies of the ConsensusSet.
 	marshaler       marshaler
 	blockRuleHelper blockRuleHelper
 	blockValidator  blockValidator
 
 	// Utilities
-	db         *persist.BoltDatabase
+	db         persist.KVStore
 	log        *persist.Logger
 	mu         demotemutex.DemoteMutex
 	persistDir string
@@ -98,20 +107,156 @@ type ConsensusSet struct {
 	genesisBlockStakeCount types.Currency
 }
 
+// Options contains the optional configuration of the ConsensusSet,
+// which has to be known before the ConsensusSet is started.
+type Options struct {
+	// PruneDepth enables the pruning of blocks which are more than
+	// PruneDepth blocks deep in the current path, as described by
+	// SetPruneDepth. A depth of 0 disables pruning.
+	PruneDepth types.BlockHeight
+}
+
 // New returns a new ConsensusSet, containing at least the genesis block. If
 // there is an existing block database present in the persist directory, it
 // will be loaded.
 func New(gateway modules.Gateway, bootstrap bool, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (*ConsensusSet, error) {
+	return NewWithOptions(gateway, bootstrap, persistDir, bcInfo, chainCts, Options{})
+}
+
+// NewWithOptions returns a new ConsensusSet, configured using the given
+// options. If pruning is enabled, the blocks of an existing block database
+// which are deeper than the prune depth are pruned before the ConsensusSet
+// starts accepting blocks.
+func NewWithOptions(gateway modules.Gateway, bootstrap bool, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants, opts Options) (*ConsensusSet, error) {
+	// Check for nil dependencies.
+	if gateway == nil {
+		return nil, errNilGateway
+	}
+	if opts.PruneDepth != 0 && opts.PruneDepth < MinPruneDepth(chainCts) {
+		return nil, errPruneDepth
+	}
+
+	cs, err := newConsensusSet(persistDir, bcInfo, chainCts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PruneDepth != 0 {
+		cs.pruneDepth = opts.PruneDepth
+		err = cs.db.Update(cs.pruneBlocks)
+		if err != nil {
+			cs.Close()
+			return nil, err
+		}
+	}
+	cs.gateway = gateway
+	go cs.threadedStart(bootstrap)
+	return cs, nil
+}
+
+// NewWithStore returns a new ConsensusSet, which uses the given key-value
+// store as its database, rather than a database within a persist directory.
+// An empty store is initialized with the genesis block. The store is closed
+// when the ConsensusSet is closed, while the logger remains owned by the
+// caller. Combined with persist.NewMemoryKVStore, it allows tests and
+// throwaway networks to run the consensus set without touching the disk.
+func NewWithStore(gateway modules.Gateway, bootstrap bool, store persist.KVStore, log *persist.Logger, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (*ConsensusSet, error) {
 	// Check for nil dependencies.
 	if gateway == nil {
 		return nil, errNilGateway
 	}
+	if store == nil {
+		return nil, errNilStore
+	}
+	if log == nil {
+		return nil, errNilLogger
+	}
+
+	cs, err := newConsensusSetWithStore(store, log, bcInfo, chainCts)
+	if err != nil {
+		return nil, err
+	}
+	cs.gateway = gateway
+	go cs.threadedStart(bootstrap)
+	return cs, nil
+}
+
+// threadedStart synchronizes the consensus set with the network, if
+// bootstrap is true, after which the consensus RPCs are registered.
+func (cs *ConsensusSet) threadedStart(bootstrap bool) {
+	// Sync with the network. Don't sync if we are testing because
+	// typically we don't have any mock peers to synchronize with in
+	// testing.
+	if bootstrap {
+		// We are in a virgin goroutine right now, so calling the threaded
+		// function without a goroutine is okay.
+		err := cs.threadedInitialBlockchainDownload()
+		if err != nil {
+			return
+		}
+	}
+
+	// threadedInitialBlockchainDownload will release the threadgroup 'Add'
+	// it was holding, so another needs to be grabbed to finish off this
+	// goroutine.
+	err := cs.tg.Add()
+	if err != nil {
+		return
+	}
+	defer cs.tg.Done()
+
+	// Register RPCs
+	cs.gateway.RegisterRPC("SendBlocks", cs.rpcSendBlocks)
+	cs.gateway.RegisterRPC("SendHeaders", cs.rpcSendHeaders)
+	cs.gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
+	cs.gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
+	cs.gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
+	cs.tg.OnStop(func() {
+		cs.gateway.UnregisterRPC("SendBlocks")
+		cs.gateway.UnregisterRPC("SendHeaders")
+		cs.gateway.UnregisterRPC("RelayHeader")
+		cs.gateway.UnregisterRPC("SendBlk")
+		cs.gateway.UnregisterConnectCall("SendBlocks")
+	})
+
+	// Mark that we are synced with the network.
+	cs.mu.Lock()
+	cs.synced = true
+	cs.mu.Unlock()
+}
+
+// newConsensusSet creates a ConsensusSet which is not connected to the
+// network, loading the block database from the persist directory.
+func newConsensusSet(persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (*ConsensusSet, error) {
+	cs := createConsensusSet(bcInfo, chainCts)
+	cs.persistDir = persistDir
 
+	// Initialize the consensus persistence structures.
+	err := cs.initPersist()
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// newConsensusSetWithStore creates a ConsensusSet which is not connected to
+// the network, using the given key-value store as its database.
+func newConsensusSetWithStore(store persist.KVStore, log *persist.Logger, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (*ConsensusSet, error) {
+	cs := createConsensusSet(bcInfo, chainCts)
+	cs.db, cs.log = store, log
+
+	err := cs.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// createConsensusSet creates the ConsensusSet object, with its genesis block,
+// but without any persistence structures.
+func createConsensusSet(bcInfo types.BlockchainInfo, chainCts types.ChainConstants) *ConsensusSet {
 	genesisBlock := chainCts.GenesisBlock()
 	// Create the ConsensusSet object.
 	cs := &ConsensusSet{
-		gateway: gateway,
-
 		blockRoot: processedBlock{
 			Block:       genesisBlock,
 			ChildTarget: chainCts.RootTarget(),
@@ -124,8 +269,7 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string, bcInfo type
 
 		marshaler:       stdMarshaler{},
 		blockRuleHelper: stdBlockRuleHelper{chainCts: chainCts},
-
-		persistDir: persistDir,
+		clock:           types.StdClock{},
 
 		bcInfo:                 bcInfo,
 		chainCts:               chainCts,
@@ -154,59 +298,12 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string, bcInfo type
 		}
 		cs.blockRoot.CoinOutputDiffs = append(cs.blockRoot.CoinOutputDiffs, cod)
 	}
-
-	// Initialize the consensus persistence structures.
-	err := cs.initPersist()
-	if err != nil {
-		return nil, err
-	}
-
-	go func() {
-		// Sync with the network. Don't sync if we are testing because
-		// typically we don't have any mock peers to synchronize with in
-		// testing.
-		if bootstrap {
-			// We are in a virgin goroutine right now, so calling the threaded
-			// function without a goroutine is okay.
-			err = cs.threadedInitialBlockchainDownload()
-			if err != nil {
-				return
-			}
-		}
-
-		// threadedInitialBlockchainDownload will release the threadgroup 'Add'
-		// it was holding, so another needs to be grabbed to finish off this
-		// goroutine.
-		err = cs.tg.Add()
-		if err != nil {
-			return
-		}
-		defer cs.tg.Done()
-
-		// Register RPCs
-		gateway.RegisterRPC("SendBlocks", cs.rpcSendBlocks)
-		gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
-		gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
-		gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
-		cs.tg.OnStop(func() {
-			cs.gateway.UnregisterRPC("SendBlocks")
-			cs.gateway.UnregisterRPC("RelayHeader")
-			cs.gateway.UnregisterRPC("SendBlk")
-			cs.gateway.UnregisterConnectCall("SendBlocks")
-		})
-
-		// Mark that we are synced with the network.
-		cs.mu.Lock()
-		cs.synced = true
-		cs.mu.Unlock()
-	}()
-
-	return cs, nil
+	return cs
 }
 
 // BlockAtHeight returns the block at a given height.
 func (cs *ConsensusSet) BlockAtHeight(height types.BlockHeight) (block types.Block, exists bool) {
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		id, err := getPath(tx, height)
 		if err != nil {
 			return err
@@ -224,7 +321,7 @@ func (cs *ConsensusSet) BlockAtHeight(height types.BlockHeight) (block types.Blo
 
 // BlockHeightOfBlock returns the blockheight given a block.
 func (cs *ConsensusSet) BlockHeightOfBlock(block types.Block) (height types.BlockHeight, exists bool) {
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, block.ID())
 		if err != nil {
 			return err
@@ -242,7 +339,7 @@ func (cs *ConsensusSet) BlockHeightOfBlock(block types.Block) (height types.Bloc
 func (cs *ConsensusSet) FindParentBlock(b types.Block, depth types.BlockHeight) (block types.Block, exists bool) {
 	var parent *processedBlock
 	var err error
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pID := b.Header().ParentID
 		// count back to the right block
 		for i := depth; i > 0; i-- {
@@ -283,7 +380,7 @@ func (cs *ConsensusSet) TransactionAtShortID(shortID types.TransactionShortID) (
 func (cs *ConsensusSet) TransactionAtID(id types.TransactionID) (types.Transaction, types.TransactionShortID, bool) {
 	var txnShortID types.TransactionShortID
 	var exists bool
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		shortID, err := getTransactionShortID(tx, id)
 		if err != nil {
 			return err
@@ -296,6 +393,37 @@ func (cs *ConsensusSet) TransactionAtID(id types.TransactionID) (types.Transacti
 	return txn, txnShortID, exists
 }
 
+// TransactionProof returns a Merkle proof that the transaction with the given
+// ID is part of a block within the blockchain. If that transaction does not
+// exist, false is returned.
+func (cs *ConsensusSet) TransactionProof(id types.TransactionID) (types.TransactionProof, bool) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return types.TransactionProof{}, false
+	}
+	defer cs.tg.Done()
+
+	var shortID types.TransactionShortID
+	err = cs.db.View(func(tx persist.KVTx) (err error) {
+		shortID, err = getTransactionShortID(tx, id)
+		return err
+	})
+	if err != nil {
+		return types.TransactionProof{}, false
+	}
+
+	block, found := cs.BlockAtHeight(shortID.BlockHeight())
+	if !found {
+		return types.TransactionProof{}, false
+	}
+	index := int(shortID.TransactionSequenceIndex())
+	if index >= len(block.Transactions) || block.Transactions[index].ID() != id {
+		return types.TransactionProof{}, false
+	}
+	return block.TransactionProof(index)
+}
+
 // ChildTarget returns the target for the child of a block.
 func (cs *ConsensusSet) ChildTarget(id types.BlockID) (target types.Target, exists bool) {
 	// A call to a closed database can cause undefined behavior.
@@ -305,7 +433,7 @@ func (cs *ConsensusSet) ChildTarget(id types.BlockID) (target types.Target, exis
 	}
 	defer cs.tg.Done()
 
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, id)
 		if err != nil {
 			return err
@@ -317,6 +445,16 @@ func (cs *ConsensusSet) ChildTarget(id types.BlockID) (target types.Target, exis
 	return target, exists
 }
 
+// SetClock replaces the clock which is used to determine whether blocks and
+// headers are in the future, such that the consensus set can be run on a
+// virtual clock. It should be set before any blocks are received.
+func (cs *ConsensusSet) SetClock(clock types.Clock) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.clock = clock
+	cs.blockValidator = newBlockValidator(cs)
+}
+
 // Close safely closes the block database.
 func (cs *ConsensusSet) Close() error {
 	return cs.tg.Stop()
@@ -327,7 +465,7 @@ func (cs *ConsensusSet) managedCurrentBlock() (block types.Block) {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb := currentProcessedBlock(tx)
 		block = pb.Block
 		return nil
@@ -345,7 +483,7 @@ func (cs *ConsensusSet) CurrentBlock() (block types.Block) {
 	defer cs.tg.Done()
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb := currentProcessedBlock(tx)
 		block = pb.Block
 		return nil
@@ -368,7 +506,7 @@ func (cs *ConsensusSet) Height() (height types.BlockHeight) {
 	}
 	defer cs.tg.Done()
 
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		height = blockHeight(tx)
 		return nil
 	})
@@ -385,7 +523,7 @@ func (cs *ConsensusSet) InCurrentPath(id types.BlockID) (inPath bool) {
 	}
 	defer cs.tg.Done()
 
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, id)
 		if err != nil {
 			inPath = false
@@ -413,7 +551,7 @@ func (cs *ConsensusSet) MinimumValidChildTimestamp(id types.BlockID) (timestamp
 	defer cs.tg.Done()
 
 	// Error is not checked because it does not matter.
-	_ = cs.db.View(func(tx *bolt.Tx) error {
+	_ = cs.db.View(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, id)
 		if err != nil {
 			return err
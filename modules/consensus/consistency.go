@@ -8,13 +8,12 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 // manageErr handles an error detected by the consistency checks.
-func manageErr(tx *bolt.Tx, err error) {
+func manageErr(tx persist.KVTx, err error) {
 	markInconsistency(tx)
 	if build.DEBUG {
 		panic(err)
@@ -27,13 +26,13 @@ func manageErr(tx *bolt.Tx, err error) {
 // the elements in sorted order into a merkle tree and taking the root. All
 // consensus sets with the same current block should have identical consensus
 // checksums.
-func consensusChecksum(tx *bolt.Tx) crypto.Hash {
+func consensusChecksum(tx persist.KVTx) crypto.Hash {
 	// Create a checksum tree.
 	tree := crypto.NewTree()
 
 	// For all of the constant buckets, push every key and every value. Buckets
 	// are sorted in byte-order, therefore this operation is deterministic.
-	consensusSetBuckets := []*bolt.Bucket{
+	consensusSetBuckets := []persist.KVBucket{
 		tx.Bucket(BlockPath),
 		tx.Bucket(CoinOutputs),
 		tx.Bucket(BlockStakeOutputs),
@@ -52,7 +51,7 @@ func consensusChecksum(tx *bolt.Tx) crypto.Hash {
 	// Iterate through all the buckets looking for buckets prefixed with
 	// prefixDCO or prefixFCEX. Buckets are presented in byte-sorted order by
 	// name.
-	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+	err := tx.ForEach(func(name []byte, b persist.KVBucket) error {
 		// If the bucket is not a delayed coin output bucket or a file
 		// contract expiration bucket, skip.
 		if !bytes.HasPrefix(name, prefixDCO) && !bytes.HasPrefix(name, prefixFCEX) {
@@ -75,7 +74,7 @@ func consensusChecksum(tx *bolt.Tx) crypto.Hash {
 
 // checkBlockStakeCount checks that the number of siafunds countable within the
 // consensus set equal the expected number of BlockStakeOutputs for the block height.
-func (cs *ConsensusSet) checkBlockStakeCount(tx *bolt.Tx) {
+func (cs *ConsensusSet) checkBlockStakeCount(tx persist.KVTx) {
 	var total types.Currency
 	err := tx.Bucket(BlockStakeOutputs).ForEach(func(_, siafundOutputBytes []byte) error {
 		var sfo types.BlockStakeOutput
@@ -98,12 +97,16 @@ func (cs *ConsensusSet) checkBlockStakeCount(tx *bolt.Tx) {
 // consensus set hash matches the hash obtained for the previous block. Then it
 // applies the block again and checks that the consensus set hash matches the
 // original consensus set hash.
-func (cs *ConsensusSet) checkRevertApply(tx *bolt.Tx) {
+func (cs *ConsensusSet) checkRevertApply(tx persist.KVTx) {
 	current := currentProcessedBlock(tx)
-	// Don't perform the check if this block is the genesis block.
+	// Don't perform the check if this block is the genesis block, or if it is
+	// a checkpoint, which can not be reverted.
 	if current.Block.ID() == cs.blockRoot.Block.ID() {
 		return
 	}
+	if _, ok := cs.chainCts.Checkpoints[current.Height]; ok {
+		return
+	}
 
 	parent, err := getBlockMap(tx, current.Block.ParentID)
 	if err != nil {
@@ -130,7 +133,7 @@ func (cs *ConsensusSet) checkRevertApply(tx *bolt.Tx) {
 
 // checkConsistency runs a series of checks to make sure that the consensus set
 // is consistent with some rules that should always be true.
-func (cs *ConsensusSet) checkConsistency(tx *bolt.Tx) {
+func (cs *ConsensusSet) checkConsistency(tx persist.KVTx) {
 	if cs.checkingConsistency {
 		return
 	}
@@ -146,7 +149,7 @@ func (cs *ConsensusSet) checkConsistency(tx *bolt.Tx) {
 // Useful for detecting database corruption in production without needing to go
 // through the extremely slow process of running a consistency check every
 // block.
-func (cs *ConsensusSet) maybeCheckConsistency(tx *bolt.Tx) {
+func (cs *ConsensusSet) maybeCheckConsistency(tx persist.KVTx) {
 	n, err := crypto.RandIntn(1000)
 	if err != nil {
 		manageErr(tx, err)
@@ -2,14 +2,13 @@ package consensus
 
 import (
 	"github.com/rivine/rivine/crypto"
-
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
 )
 
 // dbConsensusChecksum is a convenience function to call consensusChecksum
-// without a bolt.Tx.
+// without a persist.KVTx.
 func (cs *ConsensusSet) dbConsensusChecksum() (checksum crypto.Hash) {
-	err := cs.db.Update(func(tx *bolt.Tx) error {
+	err := cs.db.Update(func(tx persist.KVTx) error {
 		checksum = consensusChecksum(tx)
 		return nil
 	})
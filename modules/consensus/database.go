@@ -11,8 +11,6 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/persist"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -21,6 +19,7 @@ var (
 	errNilItem        = errors.New("requested item does not exist")
 	errDBInconsistent = errors.New("database guard indicates inconsistency within database")
 	errNonEmptyBucket = errors.New("cannot remove a map with objects still in it")
+	errBlockPruned    = errors.New("block has been pruned from the consensus database")
 
 	dbMetadata = persist.Metadata{
 		Header:  "Consensus Set Database",
@@ -40,17 +39,23 @@ type (
 		Bucket(name []byte) dbBucket
 	}
 
-	// boltTxWrapper wraps a bolt.Tx so that it matches the dbTx interface. The
-	// wrap is necessary because bolt.Tx.Bucket() returns a fixed type
-	// (bolt.Bucket), but we want it to return an interface (dbBucket).
-	boltTxWrapper struct {
-		tx *bolt.Tx
+	// kvTxWrapper wraps a persist.KVTx so that it matches the dbTx interface.
+	// The wrap is necessary because persist.KVTx.Bucket() returns a fixed type
+	// (persist.KVBucket), but we want it to return an interface (dbBucket).
+	kvTxWrapper struct {
+		tx persist.KVTx
 	}
 )
 
 // Bucket returns the dbBucket associated with the given bucket name.
-func (b boltTxWrapper) Bucket(name []byte) dbBucket {
-	return b.tx.Bucket(name)
+func (w kvTxWrapper) Bucket(name []byte) dbBucket {
+	// A missing bucket has to be returned as a nil dbBucket,
+	// rather than as a dbBucket wrapping a nil KVBucket.
+	b := w.tx.Bucket(name)
+	if b == nil {
+		return nil
+	}
+	return b
 }
 
 // replaceDatabase backs up the existing database and creates a new one.
@@ -64,18 +69,19 @@ func (cs *ConsensusSet) replaceDatabase(filename string) error {
 
 	// Try again to create a new database, this time without checking for an
 	// outdated database error.
-	cs.db, err = persist.OpenDatabase(dbMetadata, filename)
+	db, err := persist.OpenDatabase(dbMetadata, filename)
 	if err != nil {
 		return errors.New("error opening consensus database: " + err.Error())
 	}
+	cs.db = db.KVStore()
 	return nil
 }
 
 // openDB loads the set database and populates it with the necessary buckets
-func (cs *ConsensusSet) openDB(filename string) (err error) {
-	cs.db, err = persist.OpenDatabase(dbMetadata, filename)
+func (cs *ConsensusSet) openDB(filename string) error {
+	db, err := persist.OpenDatabase(dbMetadata, filename)
 	if err == persist.ErrBadVersion {
-		cs.db, err = convertLegacyDatabase(filename, cs.log)
+		db, err = convertLegacyDatabase(filename, cs.log)
 		if err == persist.ErrBadVersion {
 			return cs.replaceDatabase(filename)
 		}
@@ -83,6 +89,7 @@ func (cs *ConsensusSet) openDB(filename string) (err error) {
 	if err != nil {
 		return errors.New("error opening consensus database: " + err.Error())
 	}
+	cs.db = db.KVStore()
 	return nil
 }
 
@@ -90,13 +97,13 @@ func (cs *ConsensusSet) openDB(filename string) (err error) {
 // if not. Checking for the existence of the siafund pool bucket is typically
 // sufficient to determine whether the database has gone through the
 // initialization process.
-func dbInitialized(tx *bolt.Tx) bool {
+func dbInitialized(tx persist.KVTx) bool {
 	return tx.Bucket(BlockStakeOutputs) != nil
 }
 
 // initDB is run if there is no existing consensus database, creating a
 // database with all the required buckets and sane initial values.
-func (cs *ConsensusSet) initDB(tx *bolt.Tx) error {
+func (cs *ConsensusSet) initDB(tx persist.KVTx) error {
 	// Create the compononents of the database.
 	err := cs.createConsensusDB(tx)
 	if err != nil {
@@ -118,7 +125,7 @@ func (cs *ConsensusSet) initDB(tx *bolt.Tx) error {
 
 // inconsistencyDetected indicates whether inconsistency has been detected
 // within the database.
-func inconsistencyDetected(tx *bolt.Tx) (detected bool) {
+func inconsistencyDetected(tx persist.KVTx) (detected bool) {
 	inconsistencyBytes := tx.Bucket(Consistency).Get(Consistency)
 	err := encoding.Unmarshal(inconsistencyBytes, &detected)
 	if build.DEBUG && err != nil {
@@ -129,7 +136,7 @@ func inconsistencyDetected(tx *bolt.Tx) (detected bool) {
 
 // markInconsistency flags the database to indicate that inconsistency has been
 // detected.
-func markInconsistency(tx *bolt.Tx) {
+func markInconsistency(tx persist.KVTx) {
 	// Place a 'true' in the consistency bucket to indicate that
 	// inconsistencies have been found.
 	err := tx.Bucket(Consistency).Put(Consistency, encoding.Marshal(true))
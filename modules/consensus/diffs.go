@@ -6,8 +6,7 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
-
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
 )
 
 var (
@@ -19,7 +18,7 @@ var (
 
 // commitDiffSetSanity performs a series of sanity checks before committing a
 // diff set.
-func commitDiffSetSanity(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
+func commitDiffSetSanity(tx persist.KVTx, pb *processedBlock, dir modules.DiffDirection) {
 	// This function is purely sanity checks.
 	if !build.DEBUG {
 		return
@@ -48,7 +47,7 @@ func commitDiffSetSanity(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirect
 }
 
 // commitCoinOutputDiff applies or reverts a SiacoinOutputDiff.
-func commitCoinOutputDiff(tx *bolt.Tx, scod modules.CoinOutputDiff, dir modules.DiffDirection) {
+func commitCoinOutputDiff(tx persist.KVTx, scod modules.CoinOutputDiff, dir modules.DiffDirection) {
 	if scod.Direction == dir {
 		addCoinOutput(tx, scod.ID, scod.CoinOutput)
 	} else {
@@ -57,7 +56,7 @@ func commitCoinOutputDiff(tx *bolt.Tx, scod modules.CoinOutputDiff, dir modules.
 }
 
 // commitBlockStakeOutputDiff applies or reverts a Siafund output diff.
-func commitBlockStakeOutputDiff(tx *bolt.Tx, sfod modules.BlockStakeOutputDiff, dir modules.DiffDirection) {
+func commitBlockStakeOutputDiff(tx persist.KVTx, sfod modules.BlockStakeOutputDiff, dir modules.DiffDirection) {
 	if sfod.Direction == dir {
 		addBlockStakeOutput(tx, sfod.ID, sfod.BlockStakeOutput)
 	} else {
@@ -66,7 +65,7 @@ func commitBlockStakeOutputDiff(tx *bolt.Tx, sfod modules.BlockStakeOutputDiff,
 }
 
 // commitTxIDMapDiff applies or reverts a transaction ID mapping diff
-func commitTxIDMapDiff(tx *bolt.Tx, tidmod modules.TransactionIDDiff, dir modules.DiffDirection) {
+func commitTxIDMapDiff(tx persist.KVTx, tidmod modules.TransactionIDDiff, dir modules.DiffDirection) {
 	if tidmod.Direction == dir {
 		addTxnIDMapping(tx, tidmod.LongID, tidmod.ShortID)
 	} else {
@@ -75,7 +74,7 @@ func commitTxIDMapDiff(tx *bolt.Tx, tidmod modules.TransactionIDDiff, dir module
 }
 
 // commitDelayedCoinOutputDiff applies or reverts a delayedCoinOutputDiff.
-func commitDelayedCoinOutputDiff(tx *bolt.Tx, dscod modules.DelayedCoinOutputDiff, dir modules.DiffDirection) {
+func commitDelayedCoinOutputDiff(tx persist.KVTx, dscod modules.DelayedCoinOutputDiff, dir modules.DiffDirection) {
 	if dscod.Direction == dir {
 		addDCO(tx, dscod.MaturityHeight, dscod.ID, dscod.CoinOutput)
 	} else {
@@ -84,7 +83,7 @@ func commitDelayedCoinOutputDiff(tx *bolt.Tx, dscod modules.DelayedCoinOutputDif
 }
 
 // commitNodeDiffs commits all of the diffs in a block node.
-func commitNodeDiffs(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
+func commitNodeDiffs(tx persist.KVTx, pb *processedBlock, dir modules.DiffDirection) {
 	if dir == modules.DiffApply {
 		for _, scod := range pb.CoinOutputDiffs {
 			commitCoinOutputDiff(tx, scod, dir)
@@ -115,7 +114,7 @@ func commitNodeDiffs(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection)
 }
 
 // updateCurrentPath updates the current path after applying a diff set.
-func updateCurrentPath(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
+func updateCurrentPath(tx persist.KVTx, pb *processedBlock, dir modules.DiffDirection) {
 	// Update the current path.
 	if dir == modules.DiffApply {
 		pushPath(tx, pb.Block.ID())
@@ -125,7 +124,7 @@ func updateCurrentPath(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirectio
 }
 
 // commitDiffSet applies or reverts the diffs in a blockNode.
-func commitDiffSet(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
+func commitDiffSet(tx persist.KVTx, pb *processedBlock, dir modules.DiffDirection) {
 	// Sanity checks - there are a few so they were moved to another function.
 	if build.DEBUG {
 		commitDiffSetSanity(tx, pb, dir)
@@ -140,7 +139,7 @@ func commitDiffSet(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
 // transactions are allowed to depend on each other. We can't be sure that a
 // transaction is valid unless we have applied all of the previous transactions
 // in the block, which means we need to apply while we verify.
-func (cs *ConsensusSet) generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
+func (cs *ConsensusSet) generateAndApplyDiff(tx persist.KVTx, pb *processedBlock) error {
 	// Sanity check - the block being applied should have the current block as
 	// a parent.
 	if build.DEBUG && pb.Block.ParentID != currentBlockID(tx) {
@@ -155,8 +154,9 @@ func (cs *ConsensusSet) generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) er
 	// Validate and apply each transaction in the block. They cannot be
 	// validated all at once because some transactions may not be valid until
 	// previous transactions have been applied.
+	rules := cs.chainCts.ProtocolRules(pb.Height)
 	for _, txn := range pb.Block.Transactions {
-		err := validTransaction(tx, txn, cs.chainCts.BlockSizeLimit, cs.chainCts.ArbitraryDataSizeLimit, pb.Height, pb.Block.Timestamp)
+		err := validTransaction(tx, txn, rules, cs.chainCts.BlockSizeLimit, cs.chainCts.ArbitraryDataSizeLimit, pb.Height, pb.Block.Timestamp)
 		if err != nil {
 			return err
 		}
@@ -33,7 +33,7 @@ func TestCommitDelayedSiacoinOutputDiffBadMaturity(t *testing.T) {
 		CoinOutput:     dsco,
 		MaturityHeight: maturityHeight,
 	}
-	_ = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cst.cs.db.Update(func(tx persist.KVTx) error {
 		commitDelayedCoinOutputDiff(tx, dscod, modules.DiffApply)
 		return nil
 	})
@@ -51,7 +51,7 @@ func TestCommitNodeDiffs(t *testing.T) {
 	}
 	defer cst.Close()
 	pb := cst.cs.dbCurrentProcessedBlock()
-	_ = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cst.cs.db.Update(func(tx persist.KVTx) error {
 		commitDiffSet(tx, pb, modules.DiffRevert) // pull the block node out of the consensus set.
 		return nil
 	})
@@ -93,7 +93,7 @@ func TestCommitNodeDiffs(t *testing.T) {
 		MaturityHeight: cst.cs.dbBlockHeight() + types.MaturityDelay,
 	}
 	var siafundPool types.Currency
-	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	err = cst.cs.db.Update(func(tx persist.KVTx) error {
 		siafundPool = getSiafundPool(tx)
 		return nil
 	})
@@ -111,11 +111,11 @@ func TestCommitNodeDiffs(t *testing.T) {
 	pb.FileContractDiffs = append(pb.FileContractDiffs, fcd1)
 	pb.SiafundOutputDiffs = append(pb.SiafundOutputDiffs, sfod0)
 	pb.SiafundOutputDiffs = append(pb.SiafundOutputDiffs, sfod1)
-	_ = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cst.cs.db.Update(func(tx persist.KVTx) error {
 		createUpcomingDelayedOutputMaps(tx, pb, modules.DiffApply)
 		return nil
 	})
-	_ = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cst.cs.db.Update(func(tx persist.KVTx) error {
 		commitNodeDiffs(tx, pb, modules.DiffApply)
 		return nil
 	})
@@ -131,7 +131,7 @@ func TestCommitNodeDiffs(t *testing.T) {
 	if exists {
 		t.Error("intradependent outputs not treated correctly")
 	}
-	_ = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cst.cs.db.Update(func(tx persist.KVTx) error {
 		commitNodeDiffs(tx, pb, modules.DiffRevert)
 		return nil
 	})
@@ -564,7 +564,7 @@ func TestDeleteObsoleteDelayedOutputMapsSanity(t *testing.T) {
 		t.Fatal(err)
 	}
 	pb := cst.cs.currentProcessedBlock()
-	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	err = cst.cs.db.Update(func(tx persist.KVTx) error {
 		return commitDiffSet(tx, pb, modules.DiffRevert)
 	})
 	if err != nil {
@@ -584,19 +584,19 @@ func TestDeleteObsoleteDelayedOutputMapsSanity(t *testing.T) {
 		}
 
 		// Trigger a panic by deleting a map with outputs in it during revert.
-		err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		err = cst.cs.db.Update(func(tx persist.KVTx) error {
 			return createUpcomingDelayedOutputMaps(tx, pb, modules.DiffApply)
 		})
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		err = cst.cs.db.Update(func(tx persist.KVTx) error {
 			return commitNodeDiffs(tx, pb, modules.DiffApply)
 		})
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		err = cst.cs.db.Update(func(tx persist.KVTx) error {
 			return deleteObsoleteDelayedOutputMaps(tx, pb, modules.DiffRevert)
 		})
 		if err != nil {
@@ -605,7 +605,7 @@ func TestDeleteObsoleteDelayedOutputMapsSanity(t *testing.T) {
 	}()
 
 	// Trigger a panic by deleting a map with outputs in it during apply.
-	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+	err = cst.cs.db.Update(func(tx persist.KVTx) error {
 		return deleteObsoleteDelayedOutputMaps(tx, pb, modules.DiffApply)
 	})
 	if err != nil {
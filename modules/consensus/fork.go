@@ -5,8 +5,7 @@ import (
 
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/modules"
-
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
 )
 
 var (
@@ -16,8 +15,8 @@ var (
 // backtrackToCurrentPath traces backwards from 'pb' until it reaches a block
 // in the ConsensusSet's current path (the "common parent"). It returns the
 // (inclusive) set of blocks between the common parent and 'pb', starting from
-// the former.
-func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
+// the former. If the common parent has been pruned, nil is returned.
+func backtrackToCurrentPath(tx persist.KVTx, pb *processedBlock) []*processedBlock {
 	path := []*processedBlock{pb}
 	for {
 		// Error is not checked in production code - an error can only indicate
@@ -35,6 +34,9 @@ func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 		// Prepend the next block to the list of blocks leading from the
 		// current path to the input block.
 		pb, err = getBlockMap(tx, pb.Block.ParentID)
+		if err == errBlockPruned {
+			return nil
+		}
 		if build.DEBUG && err != nil {
 			panic(err)
 		}
@@ -46,7 +48,7 @@ func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 // revertToBlock will revert blocks from the ConsensusSet's current path until
 // 'pb' is the current block. Blocks are returned in the order that they were
 // reverted.  'pb' is not reverted.
-func (cs *ConsensusSet) revertToBlock(tx *bolt.Tx, pb *processedBlock) (revertedBlocks []*processedBlock) {
+func (cs *ConsensusSet) revertToBlock(tx persist.KVTx, pb *processedBlock) (revertedBlocks []*processedBlock) {
 	// Sanity check - make sure that pb is in the current path.
 	currentPathID, err := getPath(tx, pb.Height)
 	if build.DEBUG && (err != nil || currentPathID != pb.Block.ID()) {
@@ -72,7 +74,7 @@ func (cs *ConsensusSet) revertToBlock(tx *bolt.Tx, pb *processedBlock) (reverted
 
 // applyUntilBlock will successively apply the blocks between the consensus
 // set's current path and 'pb'.
-func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (appliedBlocks []*processedBlock, err error) {
+func (cs *ConsensusSet) applyUntilBlock(tx persist.KVTx, pb *processedBlock) (appliedBlocks []*processedBlock, err error) {
 	// Backtrack to the common parent of 'bn' and current path and then apply the new blocks.
 	newPath := backtrackToCurrentPath(tx, pb)
 	for _, block := range newPath[1:] {
@@ -102,7 +104,7 @@ func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (applie
 }
 
 // rewindBlock rewinds a single block from the consensus set. This method assumes that pb is the current top op the chain, i.e. the active fork
-func (cs *ConsensusSet) rewindBlock(tx *bolt.Tx, pb *processedBlock) {
+func (cs *ConsensusSet) rewindBlock(tx persist.KVTx, pb *processedBlock) {
 	cs.log.Debugf("[CS] rewinding block %d\n", pb.Height)
 	createDCOBucket(tx, pb.Height)
 	commitDiffSet(tx, pb, modules.DiffRevert)
@@ -110,7 +112,7 @@ func (cs *ConsensusSet) rewindBlock(tx *bolt.Tx, pb *processedBlock) {
 }
 
 // forwardBlock adds a single block to the chain. It assumes that pb is the block at "currentHeight + 1"
-func (cs *ConsensusSet) forwardBlock(tx *bolt.Tx, pb *processedBlock) {
+func (cs *ConsensusSet) forwardBlock(tx persist.KVTx, pb *processedBlock) {
 	cs.log.Debugf("[CS] reapplying block %d\n", pb.Height)
 	createDCOBucket(tx, pb.Height+cs.chainCts.MaturityDelay)
 	commitDiffSet(tx, pb, modules.DiffApply)
@@ -121,8 +123,25 @@ func (cs *ConsensusSet) forwardBlock(tx *bolt.Tx, pb *processedBlock) {
 // error will be returned if any of the blocks applied in the transition are
 // found to be invalid. forkBlockchain is atomic; the ConsensusSet is only
 // updated if the function returns nil.
-func (cs *ConsensusSet) forkBlockchain(tx *bolt.Tx, newBlock *processedBlock) (revertedBlocks, appliedBlocks []*processedBlock, err error) {
-	commonParent := backtrackToCurrentPath(tx, newBlock)[0]
+func (cs *ConsensusSet) forkBlockchain(tx persist.KVTx, newBlock *processedBlock) (revertedBlocks, appliedBlocks []*processedBlock, err error) {
+	// Blocks below the prune height can not be reverted.
+	newPath := backtrackToCurrentPath(tx, newBlock)
+	if newPath == nil || newPath[0].Height+1 < getPruneHeight(kvTxWrapper{tx}) {
+		return nil, nil, errBlockPruned
+	}
+	commonParent := newPath[0]
+
+	// Blocks below the latest checkpoint can not be reverted, and blocks
+	// conflicting with a checkpoint can not be applied.
+	if checkpoint, ok := cs.chainCts.LatestCheckpoint(blockHeight(tx)); ok && commonParent.Height < checkpoint {
+		return nil, nil, errForkBelowCheckpoint
+	}
+	for _, pb := range newPath[1:] {
+		if checkpoint, ok := cs.chainCts.Checkpoints[pb.Height]; ok && checkpoint != pb.Block.ID() {
+			return nil, nil, errCheckpoint
+		}
+	}
+
 	revertedBlocks = cs.revertToBlock(tx, commonParent)
 	appliedBlocks, err = cs.applyUntilBlock(tx, newBlock)
 	if err != nil {
@@ -1,13 +1,13 @@
 package consensus
 
 import (
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
 )
 
 // dbBacktrackToCurrentPath is a convenience function to call
-// backtrackToCurrentPath without a bolt.Tx.
+// backtrackToCurrentPath without a persist.KVTx.
 func (cs *ConsensusSet) dbBacktrackToCurrentPath(pb *processedBlock) (pbs []*processedBlock) {
-	_ = cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cs.db.Update(func(tx persist.KVTx) error {
 		pbs = backtrackToCurrentPath(tx, pb)
 		return nil
 	})
@@ -15,9 +15,9 @@ func (cs *ConsensusSet) dbBacktrackToCurrentPath(pb *processedBlock) (pbs []*pro
 }
 
 // dbRevertToNode is a convenience function to call revertToBlock without a
-// bolt.Tx.
+// persist.KVTx.
 func (cs *ConsensusSet) dbRevertToNode(pb *processedBlock) (pbs []*processedBlock) {
-	_ = cs.db.Update(func(tx *bolt.Tx) error {
+	_ = cs.db.Update(func(tx persist.KVTx) error {
 		pbs = cs.revertToBlock(tx, pb)
 		return nil
 	})
@@ -25,9 +25,9 @@ func (cs *ConsensusSet) dbRevertToNode(pb *processedBlock) (pbs []*processedBloc
 }
 
 // dbForkBlockchain is a convenience function to call forkBlockchain without a
-// bolt.Tx.
+// persist.KVTx.
 func (cs *ConsensusSet) dbForkBlockchain(pb *processedBlock) (revertedBlocks, appliedBlocks []*processedBlock, err error) {
-	updateErr := cs.db.Update(func(tx *bolt.Tx) error {
+	updateErr := cs.db.Update(func(tx persist.KVTx) error {
 		revertedBlocks, appliedBlocks, err = cs.forkBlockchain(tx, pb)
 		return nil
 	})
@@ -0,0 +1,257 @@
+package consensus
+
+// headerchain.go implements a chain of block headers, which can be used by
+// light clients that wish to follow the blockchain without downloading and
+// validating every block.
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+type (
+	// HeaderChain tracks the heaviest chain of block headers known to it,
+	// starting from the genesis block of the given chain constants.
+	//
+	// Headers are validated using only the information found in the headers
+	// themselves: they have to extend a known header, have a valid timestamp,
+	// and the weight of each fork is computed using the same target adjustment
+	// rules as the consensus set. The proof of blockstake can not be verified
+	// without the block bodies, so a HeaderChain should only be synchronized
+	// with trusted peers, or be cross-checked with multiple peers.
+	HeaderChain struct {
+		nodes map[types.BlockID]*headerNode
+		path  []types.BlockID
+
+		chainCts types.ChainConstants
+		clock    types.Clock
+		mu       sync.RWMutex
+	}
+
+	// headerNode is the header equivalent of a processedBlock.
+	headerNode struct {
+		header      types.BlockHeader
+		id          types.BlockID
+		height      types.BlockHeight
+		depth       types.Target
+		childTarget types.Target
+	}
+)
+
+// NewHeaderChain creates a new HeaderChain, containing only the header of
+// the genesis block.
+func NewHeaderChain(chainCts types.ChainConstants) *HeaderChain {
+	genesis := chainCts.GenesisBlock().Header()
+	root := &headerNode{
+		header:      genesis,
+		id:          genesis.ID(),
+		depth:       chainCts.RootDepth,
+		childTarget: chainCts.RootTarget(),
+	}
+	return &HeaderChain{
+		nodes:    map[types.BlockID]*headerNode{root.id: root},
+		path:     []types.BlockID{root.id},
+		chainCts: chainCts,
+		clock:    types.StdClock{},
+	}
+}
+
+// heavierThan returns true if the headerNode is sufficiently heavier than
+// 'cmp', using the same rules as processedBlock.heavierThan.
+func (hn *headerNode) heavierThan(cmp *headerNode, rootDepth types.Target) bool {
+	requirement := cmp.depth.AddDifficulties(cmp.childTarget.MulDifficulty(SurpassThreshold, rootDepth), rootDepth)
+	return requirement.Cmp(hn.depth) > 0 // Inversed, because the smaller target is actually heavier.
+}
+
+// minimumValidChildTimestamp returns the earliest timestamp that a child of
+// the given node can have, see stdBlockRuleHelper.minimumValidChildTimestamp.
+func (hc *HeaderChain) minimumValidChildTimestamp(hn *headerNode) types.Timestamp {
+	windowTimes := make(types.TimestampSlice, hc.chainCts.MedianTimestampWindow)
+	windowTimes[0] = hn.header.Timestamp
+	parent := hn.header.ParentID
+	for i := uint64(1); i < hc.chainCts.MedianTimestampWindow; i++ {
+		if parent == (types.BlockID{}) {
+			windowTimes[i] = windowTimes[i-1]
+			continue
+		}
+		pn := hc.nodes[parent]
+		windowTimes[i] = pn.header.Timestamp
+		parent = pn.header.ParentID
+	}
+	return medianTimestamp(windowTimes)
+}
+
+// childTarget computes the target of the children of hn, which has the given
+// parent, mirroring ConsensusSet.setChildTarget.
+func (hc *HeaderChain) childTarget(parent, hn *headerNode) types.Target {
+	if hn.height%(hc.chainCts.TargetWindow/2) != 0 {
+		return parent.childTarget
+	}
+
+	// Grab the header that was created 'TargetWindow' blocks prior to the
+	// parent, stopping at the genesis block.
+	var windowSize types.BlockHeight
+	parentID := hn.header.ParentID
+	current := hn.id
+	for windowSize = 0; windowSize < hc.chainCts.TargetWindow && parentID != (types.BlockID{}); windowSize++ {
+		current = parentID
+		parentID = hc.nodes[parentID].header.ParentID
+	}
+	timePassed := hn.header.Timestamp - hc.nodes[current].header.Timestamp
+	expectedTimePassed := hc.chainCts.BlockFrequency * windowSize
+	adjustment := big.NewRat(int64(timePassed), int64(expectedTimePassed))
+
+	// Clamp the adjustment, see ConsensusSet.clampTargetAdjustment.
+	if adjustment.Cmp(hc.chainCts.MaxAdjustmentUp) > 0 {
+		adjustment = hc.chainCts.MaxAdjustmentUp
+	} else if adjustment.Cmp(hc.chainCts.MaxAdjustmentDown) < 0 {
+		adjustment = hc.chainCts.MaxAdjustmentDown
+	}
+	adjustedRatTarget := new(big.Rat).Mul(parent.childTarget.Rat(), adjustment)
+	return types.RatToTarget(adjustedRatTarget, hc.chainCts.RootDepth)
+}
+
+// acceptHeader validates a header and adds it to the header tree, switching
+// the current path to the fork of the header, should that fork become the
+// heaviest known fork.
+func (hc *HeaderChain) acceptHeader(h types.BlockHeader) error {
+	id := h.ID()
+	if _, exists := hc.nodes[id]; exists {
+		return modules.ErrBlockKnown
+	}
+	parent, exists := hc.nodes[h.ParentID]
+	if !exists {
+		return errOrphan
+	}
+	if hc.minimumValidChildTimestamp(parent) > h.Timestamp {
+		return errEarlyTimestamp
+	}
+	if h.Timestamp > hc.clock.Now()+hc.chainCts.ExtremeFutureThreshold {
+		return errExtremeFutureTimestamp
+	}
+	if checkpoint, ok := hc.chainCts.Checkpoints[parent.height+1]; ok && checkpoint != id {
+		return errCheckpoint
+	}
+	if checkpoint, ok := hc.chainCts.LatestCheckpoint(types.BlockHeight(len(hc.path) - 1)); ok && parent.height < checkpoint {
+		return errForkBelowCheckpoint
+	}
+
+	hn := &headerNode{
+		header: h,
+		id:     id,
+		height: parent.height + 1,
+		depth:  parent.depth.AddDifficulties(parent.childTarget, hc.chainCts.RootDepth),
+	}
+	hn.childTarget = hc.childTarget(parent, hn)
+	hc.nodes[id] = hn
+
+	current := hc.nodes[hc.path[len(hc.path)-1]]
+	if !hn.heavierThan(current, hc.chainCts.RootDepth) {
+		return modules.ErrNonExtendingBlock
+	}
+
+	// Backtrack to the current path and replace everything after the common
+	// parent with the new fork.
+	var fork []types.BlockID
+	for n := hn; n.height >= types.BlockHeight(len(hc.path)) || hc.path[n.height] != n.id; n = hc.nodes[n.header.ParentID] {
+		fork = append(fork, n.id)
+	}
+	hc.path = hc.path[:hn.height+1-types.BlockHeight(len(fork))]
+	for i := len(fork) - 1; i >= 0; i-- {
+		hc.path = append(hc.path, fork[i])
+	}
+	return nil
+}
+
+// AcceptHeaders validates the given headers and adds them to the header
+// chain, in order. Headers which are already known, or which do not extend
+// the heaviest known fork, are not considered an error. Validation stops at
+// the first invalid header.
+func (hc *HeaderChain) AcceptHeaders(headers []types.BlockHeader) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for _, h := range headers {
+		err := hc.acceptHeader(h)
+		if err != nil && err != modules.ErrBlockKnown && err != modules.ErrNonExtendingBlock {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentHeader returns the most recent header of the heaviest known fork.
+func (hc *HeaderChain) CurrentHeader() types.BlockHeader {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.nodes[hc.path[len(hc.path)-1]].header
+}
+
+// Height returns the height of the heaviest known fork.
+func (hc *HeaderChain) Height() types.BlockHeight {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return types.BlockHeight(len(hc.path) - 1)
+}
+
+// HeaderAtHeight returns the header found at the given height of the
+// heaviest known fork, with a bool to indicate whether that header exists.
+func (hc *HeaderChain) HeaderAtHeight(height types.BlockHeight) (types.BlockHeader, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	if height >= types.BlockHeight(len(hc.path)) {
+		return types.BlockHeader{}, false
+	}
+	return hc.nodes[hc.path[height]].header, true
+}
+
+// InCurrentPath returns true if the header with the given ID is part of the
+// heaviest known fork.
+func (hc *HeaderChain) InCurrentPath(id types.BlockID) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	hn, exists := hc.nodes[id]
+	return exists && hn.height < types.BlockHeight(len(hc.path)) && hc.path[hn.height] == id
+}
+
+// BlockHistory returns the block history of the heaviest known fork,
+// as it is sent by the calling end of the SendBlocks and SendHeaders RPCs.
+func (hc *HeaderChain) BlockHistory() [32]types.BlockID {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return pathHistory(types.BlockHeight(len(hc.path)-1), func(height types.BlockHeight) types.BlockID {
+		return hc.path[height]
+	})
+}
+
+// ReceiveHeaders is the calling end of the SendHeaders RPC, and can be used
+// by light clients to synchronize the header chain with a peer:
+//
+//	err := g.RPC(addr, "SendHeaders", hc.ReceiveHeaders)
+func (hc *HeaderChain) ReceiveHeaders(conn modules.PeerConn) error {
+	err := setSyncDeadline(conn, sendBlocksTimeout)
+	if err != nil {
+		return err
+	}
+	if err = encoding.WriteObject(conn, hc.BlockHistory()); err != nil {
+		return err
+	}
+	moreAvailable := true
+	for moreAvailable {
+		var headers []types.BlockHeader
+		err = encoding.ReadObject(conn, &headers, 8+uint64(MaxCatchUpHeaders)*types.BlockHeaderSize)
+		if err != nil {
+			return err
+		}
+		if err = encoding.ReadObject(conn, &moreAvailable, 1); err != nil {
+			return err
+		}
+		if err = hc.AcceptHeaders(headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+// extendHeaders creates n headers, each extending the previous one, starting
+// from the given parent. Timestamps are increased by the block frequency.
+func extendHeaders(parent types.BlockHeader, n int, frequency types.BlockHeight, seed byte) []types.BlockHeader {
+	headers := make([]types.BlockHeader, n)
+	for i := range headers {
+		headers[i] = types.BlockHeader{
+			ParentID:  parent.ID(),
+			Timestamp: parent.Timestamp + types.Timestamp(frequency),
+		}
+		headers[i].MerkleRoot[0] = seed
+		parent = headers[i]
+	}
+	return headers
+}
+
+// TestHeaderChainAcceptHeaders probes the basic functionality of the
+// HeaderChain.
+func TestHeaderChainAcceptHeaders(t *testing.T) {
+	cts := types.DefaultChainConstants()
+	hc := NewHeaderChain(cts)
+	if hc.Height() != 0 {
+		t.Fatal("new header chain should be at height 0, not", hc.Height())
+	}
+	genesis := hc.CurrentHeader()
+	if genesis.ID() != cts.GenesisBlockID() {
+		t.Fatal("header chain should start from the genesis block")
+	}
+
+	headers := extendHeaders(genesis, 5, cts.BlockFrequency, 0)
+	err := hc.AcceptHeaders(headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc.Height() != 5 {
+		t.Fatal("expected height 5, got", hc.Height())
+	}
+	if hc.CurrentHeader() != headers[4] {
+		t.Fatal("current header is not the last accepted header")
+	}
+	if h, ok := hc.HeaderAtHeight(3); !ok || h != headers[2] {
+		t.Fatal("unexpected header at height 3")
+	}
+	if _, ok := hc.HeaderAtHeight(6); ok {
+		t.Fatal("header at height 6 should not exist")
+	}
+	// accepting known headers should not be an error
+	err = hc.AcceptHeaders(headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := hc.BlockHistory()
+	if history[0] != headers[4].ID() || history[31] != genesis.ID() {
+		t.Fatal("unexpected block history", history)
+	}
+}
+
+// TestHeaderChainInvalidHeaders checks that headers which can be detected to
+// be invalid without their blocks are rejected.
+func TestHeaderChainInvalidHeaders(t *testing.T) {
+	cts := types.DefaultChainConstants()
+	hc := NewHeaderChain(cts)
+	genesis := hc.CurrentHeader()
+
+	orphan := types.BlockHeader{ParentID: types.BlockID{1, 2, 3}, Timestamp: genesis.Timestamp + 1}
+	if err := hc.AcceptHeaders([]types.BlockHeader{orphan}); err != errOrphan {
+		t.Fatal("expected errOrphan, got", err)
+	}
+	early := types.BlockHeader{ParentID: genesis.ID(), Timestamp: genesis.Timestamp - 1}
+	if err := hc.AcceptHeaders([]types.BlockHeader{early}); err != errEarlyTimestamp {
+		t.Fatal("expected errEarlyTimestamp, got", err)
+	}
+	future := types.BlockHeader{ParentID: genesis.ID(), Timestamp: types.CurrentTimestamp() + 2*cts.ExtremeFutureThreshold}
+	if err := hc.AcceptHeaders([]types.BlockHeader{future}); err != errExtremeFutureTimestamp {
+		t.Fatal("expected errExtremeFutureTimestamp, got", err)
+	}
+	if hc.Height() != 0 {
+		t.Fatal("invalid headers should not extend the header chain")
+	}
+}
+
+// TestHeaderChainFork checks that the header chain switches to a heavier fork.
+func TestHeaderChainFork(t *testing.T) {
+	cts := types.DefaultChainConstants()
+	hc := NewHeaderChain(cts)
+	genesis := hc.CurrentHeader()
+
+	mainFork := extendHeaders(genesis, 4, cts.BlockFrequency, 1)
+	if err := hc.AcceptHeaders(mainFork); err != nil {
+		t.Fatal(err)
+	}
+	// a fork of equal length does not replace the current path
+	altFork := extendHeaders(mainFork[0], 3, cts.BlockFrequency, 2)
+	if err := hc.AcceptHeaders(altFork); err != nil {
+		t.Fatal(err)
+	}
+	if hc.CurrentHeader() != mainFork[3] {
+		t.Fatal("header chain switched to a fork that is not heavier")
+	}
+	// extending the fork makes it the heaviest fork
+	altFork = append(altFork, extendHeaders(altFork[2], 2, cts.BlockFrequency, 2)...)
+	if err := hc.AcceptHeaders(altFork[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if hc.CurrentHeader() != altFork[4] || hc.Height() != 6 {
+		t.Fatal("header chain did not switch to the heaviest fork")
+	}
+	if !hc.InCurrentPath(mainFork[0].ID()) || hc.InCurrentPath(mainFork[1].ID()) {
+		t.Fatal("current path was not correctly rebuilt")
+	}
+	if h, _ := hc.HeaderAtHeight(2); h != altFork[0] {
+		t.Fatal("unexpected header at height 2")
+	}
+}
+
+// TestHeaderChainCheckpoints checks that headers conflicting with a checkpoint,
+// and forks below the latest checkpoint, are rejected.
+func TestHeaderChainCheckpoints(t *testing.T) {
+	cts := types.DefaultChainConstants()
+	genesis := cts.GenesisBlock().Header()
+	mainFork := extendHeaders(genesis, 6, cts.BlockFrequency, 1)
+	cts.Checkpoints = map[types.BlockHeight]types.BlockID{
+		2: mainFork[1].ID(),
+		4: mainFork[3].ID(),
+	}
+	hc := NewHeaderChain(cts)
+
+	// a fork conflicting with the checkpoint is rejected
+	altFork := extendHeaders(genesis, 4, cts.BlockFrequency, 2)
+	if err := hc.AcceptHeaders(altFork); err != errCheckpoint {
+		t.Fatal("expected errCheckpoint, got", err)
+	}
+	if hc.Height() != 1 {
+		t.Fatal("expected height 1, got", hc.Height())
+	}
+	if err := hc.AcceptHeaders(mainFork); err != nil {
+		t.Fatal(err)
+	}
+	// a fork below the latest checkpoint is rejected, even if it would be
+	// heavier, also when it doesn't conflict with any checkpoint
+	altFork = extendHeaders(mainFork[0], 8, cts.BlockFrequency, 2)
+	if err := hc.AcceptHeaders(altFork); err != errCheckpoint {
+		t.Fatal("expected errCheckpoint, got", err)
+	}
+	altFork = extendHeaders(mainFork[1], 8, cts.BlockFrequency, 2)
+	if err := hc.AcceptHeaders(altFork); err != errForkBelowCheckpoint {
+		t.Fatal("expected errForkBelowCheckpoint, got", err)
+	}
+	if hc.CurrentHeader() != mainFork[5] {
+		t.Fatal("header chain switched to a fork below the checkpoint")
+	}
+	// a fork above the checkpoint is accepted
+	altFork = extendHeaders(mainFork[3], 4, cts.BlockFrequency, 2)
+	if err := hc.AcceptHeaders(altFork); err != nil {
+		t.Fatal(err)
+	}
+	if hc.CurrentHeader() != altFork[3] {
+		t.Fatal("header chain did not switch to the heaviest fork")
+	}
+}
@@ -0,0 +1,432 @@
+package consensus
+
+// headers.go implements headers-first synchronization. Block headers are
+// downloaded first and validated using only the information they contain,
+// after which the corresponding blocks are fetched in parallel from multiple
+// peers and fully validated by the consensus set.
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+var (
+	errHeaderNotLinked    = errors.New("header does not extend the previous header")
+	errUnexpectedBlock    = errors.New("received block does not match the requested header")
+	errBlockDownloadStall = errors.New("none of the available peers could provide the requested blocks")
+)
+
+// medianTimestamp returns the median of the given timestamps, without
+// modifying the given slice.
+func medianTimestamp(timestamps types.TimestampSlice) types.Timestamp {
+	sorted := make(types.TimestampSlice, len(timestamps))
+	copy(sorted, timestamps)
+	sort.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// headerSequence validates a sequence of consecutive block headers, using
+// only the information found in those headers and in the block that the
+// sequence extends.
+type headerSequence struct {
+	// parentID is the ID of the last header in the sequence, and height its
+	// height.
+	parentID types.BlockID
+	height   types.BlockHeight
+	// timestamps contains the timestamps of the most recent blocks of the
+	// sequence, the most recent timestamp first. It is used to compute the
+	// minimum valid timestamp of the next header.
+	timestamps types.TimestampSlice
+
+	checkpoints            map[types.BlockHeight]types.BlockID
+	clock                  types.Clock
+	extremeFutureThreshold types.Timestamp
+}
+
+// newHeaderSequence creates a headerSequence which starts from the known block
+// with the given ID. errOrphan is returned if that block is not known.
+func (cs *ConsensusSet) newHeaderSequence(tx persist.KVTx, parentID types.BlockID) (*headerSequence, error) {
+	blockMap := tx.Bucket(BlockMap)
+	parentBytes := blockMap.Get(parentID[:])
+	if parentBytes == nil {
+		return nil, errOrphan
+	}
+	var parentBlock processedBlock
+	err := encoding.Unmarshal(parentBytes, &parentBlock)
+	if err != nil {
+		return nil, err
+	}
+	err = cs.checkPrunedParent(kvTxWrapper{tx}, parentBlock.Height)
+	if err != nil {
+		return nil, err
+	}
+	// Collect the same timestamp window as minimumValidChildTimestamp does.
+	timestamps := make(types.TimestampSlice, cs.chainCts.MedianTimestampWindow)
+	parent, timestamp := (&types.Block{}).UnmarshalBlockHeadersParentIDAndTS(parentBytes)
+	timestamps[0] = timestamp
+	for i := uint64(1); i < cs.chainCts.MedianTimestampWindow; i++ {
+		if parent == (types.BlockID{}) {
+			timestamps[i] = timestamps[i-1]
+			continue
+		}
+		parent, timestamps[i] = (&types.Block{}).UnmarshalBlockHeadersParentIDAndTS(blockMap.Get(parent[:]))
+	}
+	return &headerSequence{
+		parentID:               parentID,
+		height:                 parentBlock.Height,
+		timestamps:             timestamps,
+		checkpoints:            cs.chainCts.Checkpoints,
+		clock:                  cs.clock,
+		extremeFutureThreshold: cs.chainCts.ExtremeFutureThreshold,
+	}, nil
+}
+
+// push validates a header and appends it to the sequence.
+func (hs *headerSequence) push(h types.BlockHeader) error {
+	if h.ParentID != hs.parentID {
+		return errHeaderNotLinked
+	}
+	if medianTimestamp(hs.timestamps) > h.Timestamp {
+		return errEarlyTimestamp
+	}
+	if h.Timestamp > hs.clock.Now()+hs.extremeFutureThreshold {
+		return errExtremeFutureTimestamp
+	}
+	id := h.ID()
+	if checkpoint, ok := hs.checkpoints[hs.height+1]; ok && checkpoint != id {
+		return errCheckpoint
+	}
+	copy(hs.timestamps[1:], hs.timestamps[:len(hs.timestamps)-1])
+	hs.timestamps[0] = h.Timestamp
+	hs.parentID = id
+	hs.height++
+	return nil
+}
+
+// rpcSendHeaders is the receiving end of the SendHeaders RPC. It behaves
+// exactly like the SendBlocks RPC, except that it sends block headers instead
+// of blocks, up to 'MaxCatchUpHeaders' at a time.
+func (cs *ConsensusSet) rpcSendHeaders(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	// Read a list of blocks known to the requester and find the most recent
+	// block from the current path.
+	var knownBlocks [32]types.BlockID
+	err = encoding.ReadObject(conn, &knownBlocks, 32*crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	found := false
+	var start types.BlockHeight
+	cs.mu.RLock()
+	err = cs.db.View(func(tx persist.KVTx) error {
+		start, found = startHeightFromHistory(tx, knownBlocks)
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// If no matching blocks are found, or if the caller has all known blocks,
+	// don't send any headers.
+	if !found {
+		err = encoding.WriteObject(conn, []types.BlockHeader{})
+		if err != nil {
+			return err
+		}
+		return encoding.WriteObject(conn, false)
+	}
+
+	// Send the caller all of the headers that they are missing.
+	moreAvailable := true
+	for moreAvailable {
+		var headers []types.BlockHeader
+		cs.mu.RLock()
+		err = cs.db.View(func(tx persist.KVTx) error {
+			height := blockHeight(tx)
+			for i := start; i <= height && i < start+MaxCatchUpHeaders; i++ {
+				id, err := getPath(tx, i)
+				if build.DEBUG && err != nil {
+					panic(err)
+				}
+				header, _, err := getBlockHeader(tx, id)
+				if build.DEBUG && err != nil {
+					panic(err)
+				}
+				headers = append(headers, header)
+			}
+			moreAvailable = start+MaxCatchUpHeaders <= height
+			start += MaxCatchUpHeaders
+			return nil
+		})
+		cs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if err = encoding.WriteObject(conn, headers); err != nil {
+			return err
+		}
+		if err = encoding.WriteObject(conn, moreAvailable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedReceiveHeaders is the calling end of the SendHeaders RPC. It returns
+// all headers received from the peer, in order, which passed header-only
+// validation. If an error is returned, the headers that were received and
+// validated prior to the error are still returned.
+func (cs *ConsensusSet) managedReceiveHeaders(conn modules.PeerConn) (headers []types.BlockHeader, err error) {
+	err = setSyncDeadline(conn, sendBlocksTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the block history, so the peer can find our common parent.
+	var history [32]types.BlockID
+	cs.mu.RLock()
+	err = cs.db.View(func(tx persist.KVTx) error {
+		history = blockHistory(tx)
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if err = encoding.WriteObject(conn, history); err != nil {
+		return nil, err
+	}
+
+	var seq *headerSequence
+	moreAvailable := true
+	for moreAvailable {
+		var batch []types.BlockHeader
+		err = encoding.ReadObject(conn, &batch, 8+uint64(MaxCatchUpHeaders)*types.BlockHeaderSize)
+		if err != nil {
+			return headers, err
+		}
+		if err = encoding.ReadObject(conn, &moreAvailable, 1); err != nil {
+			return headers, err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		cs.mu.RLock()
+		if seq == nil {
+			// The first header has to extend a block that we already know.
+			err = cs.db.View(func(tx persist.KVTx) error {
+				var err error
+				seq, err = cs.newHeaderSequence(tx, batch[0].ParentID)
+				return err
+			})
+		}
+		for _, h := range batch {
+			if err != nil {
+				break
+			}
+			if _, exists := cs.dosBlocks[h.ID()]; exists {
+				err = errDoSBlock
+				break
+			}
+			err = seq.push(h)
+			if err == nil {
+				headers = append(headers, h)
+			}
+		}
+		cs.mu.RUnlock()
+		if err != nil {
+			return headers, err
+		}
+	}
+	return headers, nil
+}
+
+// managedReceiveBlockForHeader returns an RPCFunc which is used as the calling
+// end of the SendBlk RPC. It requests the block corresponding to the given
+// header, and stores it in b if the received block matches that header.
+func (cs *ConsensusSet) managedReceiveBlockForHeader(h types.BlockHeader, b *types.Block) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		err := setSyncDeadline(conn, sendBlocksTimeout)
+		if err != nil {
+			return err
+		}
+		id := h.ID()
+		if err := encoding.WriteObject(conn, id); err != nil {
+			return err
+		}
+		var block types.Block
+		if err := encoding.ReadObject(conn, &block, cs.chainCts.BlockSizeLimit); err != nil {
+			return err
+		}
+		// Comparing the IDs also ensures the merkle root of the transactions
+		// and miner payouts matches the one found in the header.
+		if block.ID() != id {
+			cs.gateway.PenalizePeer(conn.RPCAddr(), modules.PenaltyInvalidBlock, errUnexpectedBlock.Error())
+			return errUnexpectedBlock
+		}
+		*b = block
+		return nil
+	}
+}
+
+// managedDownloadBlocks downloads the blocks corresponding to the given
+// headers in parallel, from the source peer as well as from other outbound
+// peers. A block which a peer fails to deliver is requested again, from any
+// of the peers. A peer which failed to deliver 'maxBlockDownloadFailures'
+// blocks is no longer used, and the download stalls only once all peers are
+// no longer used. The blocks are returned in the same order as the given
+// headers.
+func (cs *ConsensusSet) managedDownloadBlocks(source modules.NetAddress, headers []types.BlockHeader) ([]types.Block, error) {
+	addrs := []modules.NetAddress{source}
+	for _, p := range cs.gateway.Peers() {
+		if len(addrs) >= maxBlockDownloadPeers {
+			break
+		}
+		if p.Inbound || p.NetAddress == source || !p.Services.Has(modules.ServiceFullHistory) {
+			continue
+		}
+		addrs = append(addrs, p.NetAddress)
+	}
+
+	blocks := make([]types.Block, len(headers))
+	queue := make([]int, len(headers))
+	for i := range queue {
+		queue[i] = i
+	}
+	// pending is the number of blocks which are not downloaded yet, whether
+	// they are queued or being downloaded. Workers wait for blocks to be
+	// queued again as long as some blocks are pending.
+	pending := len(headers)
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr modules.NetAddress) {
+			defer wg.Done()
+			failures := 0
+			for {
+				mu.Lock()
+				for len(queue) == 0 && pending > 0 {
+					cond.Wait()
+				}
+				if pending == 0 {
+					mu.Unlock()
+					return
+				}
+				i := queue[0]
+				queue = queue[1:]
+				mu.Unlock()
+
+				err := cs.gateway.RPC(addr, "SendBlk", cs.managedReceiveBlockForHeader(headers[i], &blocks[i]))
+				mu.Lock()
+				if err == nil {
+					pending--
+					if pending == 0 {
+						cond.Broadcast()
+					}
+					mu.Unlock()
+					continue
+				}
+				cs.log.Debugf("WARN: failed to download block %v from peer %v: %v", headers[i].ID(), addr, err)
+				// Queue the block again, such that it can be requested from
+				// any of the peers.
+				queue = append(queue, i)
+				cond.Signal()
+				mu.Unlock()
+				failures++
+				if failures >= maxBlockDownloadFailures {
+					return
+				}
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	if pending != 0 {
+		return nil, errBlockDownloadStall
+	}
+	return blocks, nil
+}
+
+// managedHeadersFirstSync synchronizes the consensus set with the given peer,
+// using headers-first synchronization. All missing headers are requested and
+// validated first, after which the corresponding blocks are downloaded in
+// batches of 'MaxCatchUpBlocks', from multiple peers in parallel. Should the
+// peer not support the SendHeaders RPC, the blocks are requested using the
+// SendBlocks RPC instead.
+func (cs *ConsensusSet) managedHeadersFirstSync(addr modules.NetAddress) error {
+	var headers []types.BlockHeader
+	headersErr := cs.gateway.RPC(addr, "SendHeaders", func(conn modules.PeerConn) error {
+		var err error
+		headers, err = cs.managedReceiveHeaders(conn)
+		return err
+	})
+	if headersErr != nil && len(headers) == 0 {
+		// COMPATv1.0.6: peers which do not support the SendHeaders RPC
+		// close the connection without sending any headers.
+		cs.log.Debugf("WARN: headers-first synchronization with %v failed, falling back to SendBlocks: %v", addr, headersErr)
+		return cs.gateway.RPC(addr, "SendBlocks", cs.managedReceiveBlocks)
+	}
+
+	// Broadcast the last block accepted, for the same reasons as in
+	// managedReceiveBlocks.
+	chainExtended := false
+	defer func() {
+		cs.mu.RLock()
+		synced := cs.synced
+		cs.mu.RUnlock()
+		if chainExtended && synced {
+			currentBlock := cs.managedCurrentBlock()
+			go cs.gateway.Broadcast("RelayHeader", currentBlock.Header(), cs.gateway.Peers())
+		}
+	}()
+
+	received := len(headers) > 0
+	for len(headers) > 0 {
+		n := len(headers)
+		if n > int(MaxCatchUpBlocks) {
+			n = int(MaxCatchUpBlocks)
+		}
+		blocks, err := cs.managedDownloadBlocks(addr, headers[:n])
+		if err != nil {
+			return err
+		}
+		headers = headers[n:]
+
+		for _, block := range blocks {
+			acceptErr := cs.managedAcceptBlock(block)
+			if acceptErr == nil {
+				chainExtended = true
+			}
+			if acceptErr == modules.ErrNonExtendingBlock || acceptErr == modules.ErrBlockKnown {
+				acceptErr = nil
+			}
+			if acceptErr != nil {
+				// The block matches a header sent by the synchronizing peer.
+				cs.managedPenalizeInvalidBlock(addr, acceptErr)
+				return acceptErr
+			}
+		}
+	}
+	if headersErr == nil && received && !chainExtended {
+		return errNoSyncProgress
+	}
+	return headersErr
+}
@@ -0,0 +1,121 @@
+package consensus
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// mockGatewaySendBlk serves the SendBlk RPC from a set of blocks, failing a
+// given number of calls per peer first.
+type mockGatewaySendBlk struct {
+	modules.Gateway
+	peers    []modules.Peer
+	blocks   map[types.BlockID]types.Block
+	failures map[modules.NetAddress]int
+	mu       sync.Mutex
+}
+
+// mockPipeConn is a pipe implementing the modules.PeerConn interface.
+type mockPipeConn struct {
+	net.Conn
+	addr modules.NetAddress
+}
+
+// RPCAddr implements this method of the modules.PeerConn interface.
+func (pc mockPipeConn) RPCAddr() modules.NetAddress {
+	return pc.addr
+}
+
+func (g *mockGatewaySendBlk) Peers() []modules.Peer {
+	return g.peers
+}
+
+func (g *mockGatewaySendBlk) RPC(addr modules.NetAddress, name string, fn modules.RPCFunc) error {
+	g.mu.Lock()
+	if g.failures[addr] != 0 {
+		g.failures[addr]--
+		g.mu.Unlock()
+		return errors.New("mock RPC failure")
+	}
+	g.mu.Unlock()
+
+	ours, theirs := net.Pipe()
+	defer ours.Close()
+	go func() {
+		defer theirs.Close()
+		var id types.BlockID
+		if encoding.ReadObject(theirs, &id, crypto.HashSize) != nil {
+			return
+		}
+		encoding.WriteObject(theirs, g.blocks[id])
+	}()
+	return fn(mockPipeConn{Conn: ours, addr: addr})
+}
+
+// TestDownloadBlocks checks that managedDownloadBlocks requests blocks which
+// failed to download again, and only stalls once all peers failed too often.
+func TestDownloadBlocks(t *testing.T) {
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	var headers []types.BlockHeader
+	blocks := make(map[types.BlockID]types.Block)
+	for i := 0; i < 10; i++ {
+		b := types.Block{Timestamp: types.Timestamp(i)}
+		headers = append(headers, b.Header())
+		blocks[b.ID()] = b
+	}
+	source := modules.NetAddress("source:1")
+	other := modules.NetAddress("other:1")
+	peers := []modules.Peer{{
+		NetAddress: other,
+		Services:   modules.ServiceFullHistory,
+	}}
+
+	tests := []struct {
+		name     string
+		peers    []modules.Peer
+		failures map[modules.NetAddress]int
+		err      error
+	}{
+		{"single transient failure", nil, map[modules.NetAddress]int{source: 1}, nil},
+		{"failing source", peers, map[modules.NetAddress]int{source: len(headers)}, nil},
+		{"failing peers", peers, map[modules.NetAddress]int{source: len(headers), other: len(headers)}, errBlockDownloadStall},
+	}
+	for _, test := range tests {
+		cs.gateway = &mockGatewaySendBlk{
+			peers:    test.peers,
+			blocks:   blocks,
+			failures: test.failures,
+		}
+		downloaded, err := cs.managedDownloadBlocks(source, headers)
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(downloaded) != len(headers) {
+			t.Errorf("%s: expected %d blocks, got %d", test.name, len(headers), len(downloaded))
+			continue
+		}
+		for i, b := range downloaded {
+			if b.ID() != headers[i].ID() {
+				t.Errorf("%s: unexpected block at index %d", test.name, i)
+			}
+		}
+	}
+}
@@ -6,9 +6,8 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -18,7 +17,7 @@ var (
 
 // applyMinerPayouts adds a block's miner payouts to the consensus set as
 // delayed coin outputs.
-func (cs *ConsensusSet) applyMinerPayouts(tx *bolt.Tx, pb *processedBlock) {
+func (cs *ConsensusSet) applyMinerPayouts(tx persist.KVTx, pb *processedBlock) {
 	for i := range pb.Block.MinerPayouts {
 		mpid := pb.Block.MinerPayoutID(uint64(i))
 		dscod := modules.DelayedCoinOutputDiff{
@@ -40,7 +39,7 @@ func (cs *ConsensusSet) applyMinerPayouts(tx *bolt.Tx, pb *processedBlock) {
 // applyMaturedCoinOutputs goes through the list of coin outputs that
 // have matured and adds them to the consensus set. This also updates the block
 // node diff set.
-func applyMaturedCoinOutputs(tx *bolt.Tx, pb *processedBlock) {
+func applyMaturedCoinOutputs(tx persist.KVTx, pb *processedBlock) {
 	// Iterate through the list of delayed coin outputs. Sometimes boltdb
 	// has trouble if you delete elements in a bucket while iterating through
 	// the bucket (and sometimes not - nondeterministic), so all of the
@@ -106,7 +105,7 @@ func applyMaturedCoinOutputs(tx *bolt.Tx, pb *processedBlock) {
 // applyMaintenance applies block-level alterations to the consensus set.
 // Maintenance is applied after all of the transactions for the block have been
 // applied.
-func (cs *ConsensusSet) applyMaintenance(tx *bolt.Tx, pb *processedBlock) {
+func (cs *ConsensusSet) applyMaintenance(tx persist.KVTx, pb *processedBlock) {
 	cs.applyMinerPayouts(tx, pb)
 	applyMaturedCoinOutputs(tx, pb)
 }
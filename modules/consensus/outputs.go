@@ -1,13 +1,13 @@
 package consensus
 
 import (
-	bolt "github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
 )
 
 // GetCoinOutput returns the unspent coin output for the given ID
 func (cs *ConsensusSet) GetCoinOutput(id types.CoinOutputID) (co types.CoinOutput, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		co, err = getCoinOutput(tx, id)
 		return nil
 	})
@@ -19,7 +19,7 @@ func (cs *ConsensusSet) GetCoinOutput(id types.CoinOutputID) (co types.CoinOutpu
 
 // GetBlockStakeOutput returns the unspent blockstake output for the given ID
 func (cs *ConsensusSet) GetBlockStakeOutput(id types.BlockStakeOutputID) (bso types.BlockStakeOutput, err error) {
-	dbErr := cs.db.View(func(tx *bolt.Tx) error {
+	dbErr := cs.db.View(func(tx persist.KVTx) error {
 		bso, err = getBlockStakeOutput(tx, id)
 		return nil
 	})
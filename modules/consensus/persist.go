@@ -7,10 +7,10 @@ import (
 	"path/filepath"
 
 	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/persist"
-
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/types"
 )
 
 const (
@@ -20,17 +20,12 @@ const (
 	logFile          = modules.ConsensusDir + ".log"
 )
 
-// loadDB pulls all the blocks that have been saved to disk into memory, using
-// them to fill out the ConsensusSet.
+// loadDB initializes the database of the ConsensusSet if it is empty, and
+// checks the consistency of an existing database otherwise. Once loaded,
+// the database is closed when the ConsensusSet is stopped.
 func (cs *ConsensusSet) loadDB() error {
-	// Open the database - a new bolt database will be created if none exists.
-	err := cs.openDB(filepath.Join(cs.persistDir, DatabaseFilename))
-	if err != nil {
-		return err
-	}
-
 	// Walk through initialization for Sia.
-	return cs.db.Update(func(tx *bolt.Tx) error {
+	err := cs.db.Update(func(tx persist.KVTx) error {
 		// Check if the database has been initialized.
 		if !dbInitialized(tx) {
 			return cs.initDB(tx)
@@ -50,8 +45,40 @@ func (cs *ConsensusSet) loadDB() error {
 		if genesisID != cs.blockRoot.Block.ID() {
 			return errors.New("Blockchain has wrong genesis block, exiting.")
 		}
-		return nil
+
+		// Databases created prior to pruning support have no pruning buckets.
+		return createPruningBuckets(tx)
+	})
+	if err != nil {
+		return err
+	}
+	// Set up the closing of the database.
+	cs.tg.AfterStop(func() {
+		err := cs.db.Close()
+		if err != nil {
+			cs.log.Println("ERROR: Unable to close consensus set database at shutdown:", err)
+		}
 	})
+	return nil
+}
+
+// createPruningBuckets creates the buckets used to track pruned blocks, if
+// they do not exist yet.
+func createPruningBuckets(tx persist.KVTx) error {
+	if tx.Bucket(PruneHeight) != nil {
+		return nil
+	}
+	for _, bucket := range [][]byte{PrunedBlockMap, PrunedCoinOutputs, PrunedBlockStakeOutputs} {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+	}
+	b, err := tx.CreateBucket(PruneHeight)
+	if err != nil {
+		return err
+	}
+	return b.Put(PruneHeight, encoding.Marshal(types.BlockHeight(0)))
 }
 
 // initPersist initializes the persistence structures of the consensus set, in
@@ -78,18 +105,11 @@ func (cs *ConsensusSet) initPersist() error {
 		}
 	})
 
-	// Try to load an existing database from disk - a new one will be created
-	// if one does not exist.
-	err = cs.loadDB()
+	// Try to load an existing database from disk - a new bolt database will
+	// be created if one does not exist.
+	err = cs.openDB(filepath.Join(cs.persistDir, DatabaseFilename))
 	if err != nil {
 		return err
 	}
-	// Set up the closing of the database.
-	cs.tg.AfterStop(func() {
-		err := cs.db.Close()
-		if err != nil {
-			cs.log.Println("ERROR: Unable to close consensus set database at shutdown:", err)
-		}
-	})
-	return nil
+	return cs.loadDB()
 }
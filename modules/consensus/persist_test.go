@@ -1,5 +1,66 @@
 package consensus
 
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+// TestMemoryStore checks that a consensus set using an in-memory store
+// arrives at the same consensus state as one using a bolt database.
+func TestMemoryStore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	diskCS, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer diskCS.Close()
+	store := persist.NewMemoryKVStore()
+	memCS, err := newConsensusSetWithStore(store, persist.NewLogger(bcInfo, ioutil.Discard), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum := func(cs *ConsensusSet) (checksum crypto.Hash) {
+		err := cs.db.View(func(tx persist.KVTx) error {
+			checksum = consensusChecksum(tx)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for i := 0; i < 3; i++ {
+		if checksum(memCS) != checksum(diskCS) {
+			t.Fatal("consensus checksums differ at height", memCS.Height())
+		}
+		if addTestBlock(t, memCS) != addTestBlock(t, diskCS) {
+			t.Fatal("consensus sets applied different blocks")
+		}
+	}
+	if memCS.Height() != 3 || memCS.CurrentBlock().ID() != diskCS.CurrentBlock().ID() {
+		t.Fatal("unexpected current block of the in-memory consensus set")
+	}
+
+	// closing the consensus set closes the store
+	err = memCS.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.View(func(persist.KVTx) error { return nil }); err == nil {
+		t.Fatal("store should be closed")
+	}
+}
+
 // TODO: enable and fix
 /*
 // TestSaveLoad populates a blockchain, saves it, loads it, and checks
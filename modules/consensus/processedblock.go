@@ -7,9 +7,8 @@ import (
 	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 // SurpassThreshold is a percentage that dictates how much heavier a competing
@@ -39,6 +38,26 @@ type processedBlock struct {
 	ConsensusChecksum crypto.Hash
 }
 
+// prunedBlock is what remains of a processedBlock in the current path, once
+// its block body and diffs are no longer available, such as for the older
+// blocks of a consensus set imported from a snapshot.
+type prunedBlock struct {
+	Header      types.BlockHeader
+	Height      types.BlockHeight
+	Depth       types.Target
+	ChildTarget types.Target
+}
+
+// newPrunedBlock creates the prunedBlock of a processedBlock.
+func newPrunedBlock(pb *processedBlock) *prunedBlock {
+	return &prunedBlock{
+		Header:      pb.Block.Header(),
+		Height:      pb.Height,
+		Depth:       pb.Depth,
+		ChildTarget: pb.ChildTarget,
+	}
+}
+
 // heavierThan returns true if the blockNode is sufficiently heavier than
 // 'cmp'. 'cmp' is expected to be the current block node. "Sufficient" means
 // that the weight of 'bn' exceeds the weight of 'cmp' by:
@@ -57,7 +76,7 @@ func (pb *processedBlock) childDepth(rootDepth types.Target) types.Target {
 
 // targetAdjustmentBase returns the magnitude that the target should be
 // adjusted by before a clamp is applied.
-func (cs *ConsensusSet) targetAdjustmentBase(blockMap *bolt.Bucket, pb *processedBlock) *big.Rat {
+func (cs *ConsensusSet) targetAdjustmentBase(blockMap persist.KVBucket, pb *processedBlock) *big.Rat {
 	// Grab the block that was generated 'TargetWindow' blocks prior to the
 	// parent. If there are not 'TargetWindow' blocks yet, stop at the genesis
 	// block.
@@ -101,7 +120,7 @@ func (cs *ConsensusSet) clampTargetAdjustment(base *big.Rat) *big.Rat {
 
 // setChildTarget computes the target of a blockNode's child. All children of a node
 // have the same target.
-func (cs *ConsensusSet) setChildTarget(blockMap *bolt.Bucket, pb *processedBlock) {
+func (cs *ConsensusSet) setChildTarget(blockMap persist.KVBucket, pb *processedBlock) {
 	// Fetch the parent block.
 	var parent processedBlock
 	parentBytes := blockMap.Get(pb.Block.ParentID[:])
@@ -122,7 +141,7 @@ func (cs *ConsensusSet) setChildTarget(blockMap *bolt.Bucket, pb *processedBlock
 
 // newChild creates a blockNode from a block and adds it to the parent's set of
 // children. The new node is also returned. It necessarily modifies the database
-func (cs *ConsensusSet) newChild(tx *bolt.Tx, pb *processedBlock, b types.Block) *processedBlock {
+func (cs *ConsensusSet) newChild(tx persist.KVTx, pb *processedBlock, b types.Block) *processedBlock {
 	// Create the child node.
 	childID := b.ID()
 	child := &processedBlock{
@@ -0,0 +1,149 @@
+package consensus
+
+// prune.go implements the pruning mode of the consensus set, in which the
+// bodies and diffs of the blocks deep in the current path are removed from
+// the consensus database. The headers and the current path are kept, as are
+// the blocks which created blockstake outputs that are still unspent, such
+// that new blocks can still be validated.
+
+import (
+	"errors"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+const (
+	// maxPruneBlocks is the maximum number of blocks pruned each time a block
+	// is accepted, such that enabling pruning on a long blockchain does not
+	// stall the consensus set.
+	maxPruneBlocks = 100
+)
+
+var (
+	errPruneDepth = errors.New("prune depth is lower than the minimum prune depth")
+)
+
+// MinPruneDepth returns the minimum number of blocks that have to be kept in
+// full by a pruning consensus set. This allows the consensus set to validate
+// and switch to forks of up to half that depth.
+func MinPruneDepth(chainCts types.ChainConstants) types.BlockHeight {
+	return 2 * validationDepth(chainCts)
+}
+
+// SetPruneDepth enables the pruning of blocks which are more than depth
+// blocks deep in the current path. A depth of 0 disables pruning. Pruning can
+// not be undone: pruned blocks are not available for as long as the consensus
+// database exists.
+func (cs *ConsensusSet) SetPruneDepth(depth types.BlockHeight) error {
+	if depth != 0 && depth < MinPruneDepth(cs.chainCts) {
+		return errPruneDepth
+	}
+	cs.mu.Lock()
+	cs.pruneDepth = depth
+	cs.mu.Unlock()
+	return nil
+}
+
+// PruneHeight returns the height from which all blocks of the current path
+// are available in full. Blocks below this height might have been pruned.
+func (cs *ConsensusSet) PruneHeight() (height types.BlockHeight) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return 0
+	}
+	defer cs.tg.Done()
+
+	_ = cs.db.View(func(tx persist.KVTx) error {
+		height = getPruneHeight(kvTxWrapper{tx})
+		return nil
+	})
+	return height
+}
+
+// pruneBlocks prunes the blocks of the current path which are more than
+// pruneDepth blocks deep, at most maxPruneBlocks at a time.
+func (cs *ConsensusSet) pruneBlocks(tx persist.KVTx) error {
+	height := blockHeight(tx)
+	if cs.pruneDepth == 0 || height < cs.pruneDepth {
+		return nil
+	}
+	target := height - cs.pruneDepth + 1
+	pruneHeight := getPruneHeight(kvTxWrapper{tx})
+	if pruneHeight >= target {
+		return nil
+	}
+	for n := 0; pruneHeight < target && n < maxPruneBlocks; n++ {
+		err := pruneBlock(tx, pruneHeight)
+		if err != nil {
+			return err
+		}
+		pruneHeight++
+	}
+	return tx.Bucket(PruneHeight).Put(PruneHeight, encoding.Marshal(pruneHeight))
+}
+
+// pruneBlock prunes the block at the given height of the current path,
+// applying its diffs to the outputs that are unspent at the prune height.
+// The genesis block is never pruned, and blocks that created a blockstake
+// output which is still unspent are kept without their diffs, as they are
+// needed to validate the blocks created using those blockstake outputs.
+func pruneBlock(tx persist.KVTx, height types.BlockHeight) error {
+	id, err := getPath(tx, height)
+	if err != nil {
+		return err
+	}
+	pb, err := getBlockMap(tx, id)
+	if err != nil {
+		return err
+	}
+	commitPrunedOutputDiffs(tx, pb)
+	if height == 0 {
+		return nil
+	}
+	if createsUnspentBlockStake(tx, pb.Block) {
+		pb.CoinOutputDiffs = nil
+		pb.BlockStakeOutputDiffs = nil
+		pb.DelayedCoinOutputDiffs = nil
+		pb.TxIDDiffs = nil
+		return tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(*pb))
+	}
+	err = tx.Bucket(BlockMap).Delete(id[:])
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(PrunedBlockMap).Put(id[:], encoding.Marshal(*newPrunedBlock(pb)))
+}
+
+// commitPrunedOutputDiffs applies the coin and blockstake output diffs of a
+// processed block to the outputs that are unspent at the prune height.
+func commitPrunedOutputDiffs(tx persist.KVTx, pb *processedBlock) {
+	coins := tx.Bucket(PrunedCoinOutputs)
+	for _, cod := range pb.CoinOutputDiffs {
+		var err error
+		if cod.Direction == modules.DiffApply {
+			err = coins.Put(cod.ID[:], encoding.Marshal(cod.CoinOutput))
+		} else {
+			err = coins.Delete(cod.ID[:])
+		}
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+	}
+	blockStakes := tx.Bucket(PrunedBlockStakeOutputs)
+	for _, bsod := range pb.BlockStakeOutputDiffs {
+		var err error
+		if bsod.Direction == modules.DiffApply {
+			err = blockStakes.Put(bsod.ID[:], encoding.Marshal(bsod.BlockStakeOutput))
+		} else {
+			err = blockStakes.Delete(bsod.ID[:])
+		}
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+	}
+}
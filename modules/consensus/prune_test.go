@@ -0,0 +1,253 @@
+package consensus
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/gateway"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+// TestPruneBlock checks that pruning a block moves it to the PrunedBlockMap,
+// and applies its diffs to the outputs that are unspent at the prune height.
+func TestPruneBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	if err = cs.SetPruneDepth(MinPruneDepth(cts) - 1); err != errPruneDepth {
+		t.Fatal("expected errPruneDepth, got", err)
+	}
+	if err = cs.SetPruneDepth(MinPruneDepth(cts)); err != nil {
+		t.Fatal(err)
+	}
+
+	// add a block spending the first genesis coin output
+	spent := cs.blockRoot.CoinOutputDiffs[0]
+	pb := &processedBlock{
+		Block: types.Block{
+			ParentID:  cs.blockRoot.Block.ID(),
+			Timestamp: cs.blockRoot.Block.Timestamp + 1,
+		},
+		Height: 1,
+		CoinOutputDiffs: []modules.CoinOutputDiff{{
+			Direction:  modules.DiffRevert,
+			ID:         spent.ID,
+			CoinOutput: spent.CoinOutput,
+		}},
+		DiffsGenerated: true,
+	}
+	id := pb.Block.ID()
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		pushPath(tx, id)
+		err := tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(*pb))
+		if err != nil {
+			return err
+		}
+		for height := types.BlockHeight(0); height <= 1; height++ {
+			err = pruneBlock(tx, height)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cs.db.View(func(tx persist.KVTx) error {
+		if _, err := getBlockMap(tx, cs.blockRoot.Block.ID()); err != nil {
+			t.Error("genesis block should not be pruned:", err)
+		}
+		if _, err := getBlockMap(tx, id); err != errBlockPruned {
+			t.Error("expected errBlockPruned, got", err)
+		}
+		header, height, err := getBlockHeader(tx, id)
+		if err != nil || header != pb.Block.Header() || height != 1 {
+			t.Error("header of the pruned block is not available:", err)
+		}
+		coins := tx.Bucket(PrunedCoinOutputs)
+		if coins.Get(spent.ID[:]) != nil {
+			t.Error("spent coin output should not be part of the pruned outputs")
+		}
+		if n := bucketLen(coins); n != len(cs.blockRoot.CoinOutputDiffs)-1 {
+			t.Error("expected", len(cs.blockRoot.CoinOutputDiffs)-1, "pruned coin outputs, got", n)
+		}
+		if n := bucketLen(tx.Bucket(PrunedBlockStakeOutputs)); n != len(cs.blockRoot.BlockStakeOutputDiffs) {
+			t.Error("expected", len(cs.blockRoot.BlockStakeOutputDiffs), "pruned blockstake outputs, got", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := cs.BlockAtHeight(1); exists {
+		t.Fatal("pruned block should not be available")
+	}
+}
+
+// bucketLen returns the number of key-value pairs in a bucket.
+func bucketLen(b persist.KVBucket) (n int) {
+	b.ForEach(func(_, _ []byte) error {
+		n++
+		return nil
+	})
+	return
+}
+
+// TestPrunedSubscription checks that subscriptions which would have to be
+// caught up starting below the prune height are refused.
+func TestPrunedSubscription(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cts.StakeModifierDelay = 0
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	if err = cs.SetPruneDepth(MinPruneDepth(cts)); err != nil {
+		t.Fatal(err)
+	}
+	addSnapshotTestBlocks(t, cs, int(MinPruneDepth(cts))+100)
+	if cs.PruneHeight() == 0 {
+		t.Fatal("expected blocks to be pruned")
+	}
+
+	err = cs.ConsensusSetSubscribe(new(slowSubscriber), modules.ConsensusChangeBeginning)
+	if err != modules.ErrPrunedConsensusChange {
+		t.Error("expected ErrPrunedConsensusChange, got", err)
+	}
+	err = cs.ConsensusSetSubscribeAsync(new(slowSubscriber), modules.ConsensusChangeBeginning, 10)
+	if err != modules.ErrPrunedConsensusChange {
+		t.Error("expected ErrPrunedConsensusChange, got", err)
+	}
+	if n := len(cs.subscribers); n != 0 {
+		t.Error("refused subscribers should not be subscribed, got", n)
+	}
+
+	// subscribers which don't need to be caught up are accepted
+	err = cs.ConsensusSetSubscribe(new(slowSubscriber), modules.ConsensusChangeRecent)
+	if err != nil {
+		t.Error(err)
+	}
+	err = cs.ConsensusSetSubscribeAsync(new(slowSubscriber), modules.ConsensusChangeRecent, 10)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// addTreeTestBlock adds an empty child of the given parent to the consensus
+// set using addBlockToTree. The offset is added to the timestamp of the
+// parent, allowing to create competing children of the same parent.
+func addTreeTestBlock(t *testing.T, cs *ConsensusSet, parent types.Block, offset types.Timestamp) types.Block {
+	b := types.Block{
+		ParentID:  parent.ID(),
+		Timestamp: parent.Timestamp + offset,
+	}
+	_, err := cs.addBlockToTree(b)
+	if err != nil && err != modules.ErrNonExtendingBlock {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestPruneAcceptedBlocks checks that the consensus set prunes the blocks
+// accepted by addBlockToTree, starting when it is constructed, and that forks
+// which would revert pruned blocks are rejected.
+func TestPruneAcceptedBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cts.StakeModifierDelay = 0
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir), bcInfo, cts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	csDir := filepath.Join(testdir, modules.ConsensusDir)
+
+	// build a chain without pruning, with a competing block at height 2
+	cs, err := New(g, false, csDir, bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := addTreeTestBlock(t, cs, cs.blockRoot.Block, 1)
+	fork := addTreeTestBlock(t, cs, b, 2)
+	for i := types.BlockHeight(0); i < MinPruneDepth(cts)+10; i++ {
+		b = addTreeTestBlock(t, cs, b, 1)
+	}
+	if cs.PruneHeight() != 0 {
+		t.Fatal("blocks were pruned without a prune depth")
+	}
+	cs.Close()
+
+	// the consensus set prunes the existing blocks when it is constructed
+	_, err = NewWithOptions(g, false, csDir, bcInfo, cts, Options{PruneDepth: MinPruneDepth(cts) - 1})
+	if err != errPruneDepth {
+		t.Fatal("expected errPruneDepth, got", err)
+	}
+	cs, err = NewWithOptions(g, false, csDir, bcInfo, cts, Options{PruneDepth: MinPruneDepth(cts)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	pruneHeight := cs.PruneHeight()
+	if pruneHeight != cs.Height()-MinPruneDepth(cts)+1 {
+		t.Fatal("unexpected prune height at construction:", pruneHeight)
+	}
+
+	// accepted blocks move the prune height along with the current height
+	for i := 0; i < 10; i++ {
+		b = addTreeTestBlock(t, cs, b, 1)
+	}
+	if cs.PruneHeight() != pruneHeight+10 {
+		t.Fatal("expected prune height", pruneHeight+10, "got", cs.PruneHeight())
+	}
+	err = cs.db.View(func(tx persist.KVTx) error {
+		id, err := getPath(tx, pruneHeight)
+		if err != nil {
+			return err
+		}
+		if _, err = getBlockMap(tx, id); err != errBlockPruned {
+			t.Error("expected errBlockPruned, got", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fork which would revert pruned blocks is rejected
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		pb, err := getBlockMap(tx, fork.ID())
+		if err != nil {
+			return err
+		}
+		if _, _, err = cs.forkBlockchain(tx, pb); err != errBlockPruned {
+			t.Error("expected errBlockPruned, got", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.CurrentBlock().ID() != b.ID() {
+		t.Fatal("the rejected fork changed the current block")
+	}
+}
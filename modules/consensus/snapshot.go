@@ -0,0 +1,659 @@
+package consensus
+
+// snapshot.go implements the export and import of consensus set snapshots. A
+// snapshot contains the unspent outputs, the transaction ID mapping and the
+// current path of the consensus set at a given height, as well as the most
+// recent blocks, which are required to validate the blocks that follow. It
+// allows a node to start from a trusted consensus state, instead of having to
+// download and validate the entire blockchain.
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+const (
+	// SnapshotCurrentHeight can be given to ExportSnapshot to export a
+	// snapshot at the current height of the consensus set.
+	SnapshotCurrentHeight = ^types.BlockHeight(0)
+
+	// snapshotChangeEntrySize is the maximum number of blocks applied by a
+	// single change entry in the change log of an imported snapshot.
+	snapshotChangeEntrySize = 1000
+)
+
+var (
+	errConsensusDBExists = errors.New("a consensus database already exists")
+	errNoConsensusDB     = errors.New("no consensus database exists")
+	errSnapshotBucket    = errors.New("snapshot contains an unknown database bucket")
+	errSnapshotChecksum  = errors.New("consensus checksum of the snapshot does not match the trusted checksum")
+	errSnapshotCorrupt   = errors.New("snapshot does not match the consensus state it describes")
+	errSnapshotHeight    = errors.New("cannot create a snapshot above the current height")
+
+	// errSnapshotRollback is used to roll back the database update in which a
+	// snapshot is exported.
+	errSnapshotRollback = errors.New("rolling back snapshot export")
+
+	snapshotMetadata = persist.Metadata{
+		Header:  "Consensus Set Snapshot",
+		Version: "1.0.6",
+	}
+)
+
+type (
+	// SnapshotInfo describes the consensus state contained in a snapshot.
+	SnapshotInfo struct {
+		Height   types.BlockHeight `json:"height"`
+		BlockID  types.BlockID     `json:"blockid"`
+		Checksum crypto.Hash       `json:"checksum"`
+	}
+
+	// snapshotEntry is a single key/value pair of a database bucket.
+	snapshotEntry struct {
+		Key   []byte
+		Value []byte
+	}
+)
+
+// snapshotPruneHeight returns the prune height of a consensus set imported
+// from a snapshot at the given height. The snapshot contains the full
+// processed blocks from that height onwards: twice the validation depth, such
+// that forks of up to the validation depth can still be validated.
+func snapshotPruneHeight(height types.BlockHeight, chainCts types.ChainConstants) types.BlockHeight {
+	window := 2 * validationDepth(chainCts)
+	if height < window {
+		return 0
+	}
+	return height - window + 1
+}
+
+// snapshotBucketAllowed returns true if a bucket with the given name can be
+// imported from a snapshot.
+func snapshotBucketAllowed(name []byte) bool {
+	for _, bucket := range [][]byte{BlockPath, BlockMap, PrunedBlockMap, PrunedCoinOutputs,
+		PrunedBlockStakeOutputs, CoinOutputs, BlockStakeOutputs, TransactionIDMap} {
+		if bytes.Equal(name, bucket) {
+			return true
+		}
+	}
+	return bytes.HasPrefix(name, prefixDCO) || bytes.HasPrefix(name, prefixFCEX)
+}
+
+// createsUnspentBlockStake returns true if the block created a blockstake
+// output which is still unspent. Such blocks are required to validate the
+// blocks created using those blockstake outputs.
+func createsUnspentBlockStake(tx persist.KVTx, b types.Block) bool {
+	bsos := tx.Bucket(BlockStakeOutputs)
+	for _, txn := range b.Transactions {
+		for i := range txn.BlockStakeOutputs {
+			id := txn.BlockStakeOutputID(uint64(i))
+			if bsos.Get(id[:]) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeSnapshotBucket writes a bucket of n entries to a snapshot, using
+// forEach to write the entries.
+func writeSnapshotBucket(enc *encoding.Encoder, name []byte, n uint64, forEach func(func(k, v []byte) error) error) error {
+	err := enc.EncodeAll(name, n)
+	if err != nil {
+		return err
+	}
+	return forEach(func(k, v []byte) error {
+		return enc.Encode(snapshotEntry{Key: k, Value: v})
+	})
+}
+
+// writeSnapshotMap writes a bucket containing the given entries to a
+// snapshot, sorted by key.
+func writeSnapshotMap(enc *encoding.Encoder, name []byte, entries map[string][]byte) error {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return writeSnapshotBucket(enc, name, uint64(len(keys)), func(fn func(k, v []byte) error) error {
+		for _, k := range keys {
+			if err := fn([]byte(k), entries[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadOutputs returns the encoded outputs of an output bucket, keyed by their
+// id.
+func loadOutputs(tx persist.KVTx, name []byte) map[string][]byte {
+	outputs := make(map[string][]byte)
+	tx.Bucket(name).ForEach(func(k, v []byte) error {
+		outputs[string(k)] = append([]byte(nil), v...)
+		return nil
+	})
+	return outputs
+}
+
+// commitOutputDiffs applies or reverts the coin and blockstake output diffs of
+// a processed block to the given sets of encoded outputs. False is returned if
+// the diffs are inconsistent with the given outputs.
+func commitOutputDiffs(pb *processedBlock, coins, blockStakes map[string][]byte, dir modules.DiffDirection) bool {
+	consistent := true
+	if dir == modules.DiffApply {
+		for _, cod := range pb.CoinOutputDiffs {
+			consistent = commitOutputDiff(coins, cod.ID[:], cod.CoinOutput, cod.Direction == dir) && consistent
+		}
+		for _, bsod := range pb.BlockStakeOutputDiffs {
+			consistent = commitOutputDiff(blockStakes, bsod.ID[:], bsod.BlockStakeOutput, bsod.Direction == dir) && consistent
+		}
+		return consistent
+	}
+	for i := len(pb.CoinOutputDiffs) - 1; i >= 0; i-- {
+		cod := pb.CoinOutputDiffs[i]
+		consistent = commitOutputDiff(coins, cod.ID[:], cod.CoinOutput, cod.Direction == dir) && consistent
+	}
+	for i := len(pb.BlockStakeOutputDiffs) - 1; i >= 0; i-- {
+		bsod := pb.BlockStakeOutputDiffs[i]
+		consistent = commitOutputDiff(blockStakes, bsod.ID[:], bsod.BlockStakeOutput, bsod.Direction == dir) && consistent
+	}
+	return consistent
+}
+
+// commitOutputDiff adds or removes an output from a set of encoded outputs.
+// False is returned if an output is added which already exists, or if an
+// output is removed which does not exist.
+func commitOutputDiff(outputs map[string][]byte, id []byte, output interface{}, add bool) bool {
+	encoded := encoding.Marshal(output)
+	existing, exists := outputs[string(id)]
+	if add {
+		outputs[string(id)] = encoded
+		return !exists
+	}
+	delete(outputs, string(id))
+	return exists && bytes.Equal(existing, encoded)
+}
+
+// ExportSnapshot writes a snapshot of the consensus set found in persistDir to
+// w, at the given height of the current path, or at the current height if the
+// height is SnapshotCurrentHeight. The consensus set can not be in use while
+// the snapshot is being exported.
+func ExportSnapshot(w io.Writer, persistDir string, height types.BlockHeight, bcInfo types.BlockchainInfo, chainCts types.ChainConstants) (SnapshotInfo, error) {
+	_, err := os.Stat(filepath.Join(persistDir, DatabaseFilename))
+	if os.IsNotExist(err) {
+		return SnapshotInfo{}, errNoConsensusDB
+	}
+	cs, err := newConsensusSet(persistDir, bcInfo, chainCts)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer cs.Close()
+
+	// The export happens in a database update which is never committed, such
+	// that the blocks above the requested height can be reverted.
+	var info SnapshotInfo
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		if height == SnapshotCurrentHeight {
+			height = blockHeight(tx)
+		} else if height > blockHeight(tx) {
+			return errSnapshotHeight
+		}
+		pruneHeight := snapshotPruneHeight(height, chainCts)
+		if current := getPruneHeight(kvTxWrapper{tx}); current > 0 && pruneHeight < current {
+			return errBlockPruned
+		}
+		for blockHeight(tx) > height {
+			cs.rewindBlock(tx, currentProcessedBlock(tx))
+		}
+		info = SnapshotInfo{
+			Height:   height,
+			BlockID:  currentBlockID(tx),
+			Checksum: consensusChecksum(tx),
+		}
+		err := cs.writeSnapshot(tx, w, info, pruneHeight)
+		if err != nil {
+			return err
+		}
+		return errSnapshotRollback
+	})
+	if err != errSnapshotRollback {
+		return SnapshotInfo{}, err
+	}
+	return info, nil
+}
+
+// writeSnapshot writes the snapshot of the current consensus state to w.
+// Blocks below the prune height are only included as pruned blocks, unless
+// they created a blockstake output which is still unspent, in which case they
+// are included without their diffs. The outputs that were unspent at the prune
+// height are included separately, so that subscribers can still be given the
+// entire consensus state.
+func (cs *ConsensusSet) writeSnapshot(tx persist.KVTx, w io.Writer, info SnapshotInfo, pruneHeight types.BlockHeight) error {
+	path := make([]types.BlockID, info.Height+1)
+	for i := range path {
+		var err error
+		path[i], err = getPath(tx, types.BlockHeight(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Compute the outputs that were unspent at the prune height, by reverting
+	// the blocks above it.
+	coins := loadOutputs(tx, CoinOutputs)
+	blockStakes := loadOutputs(tx, BlockStakeOutputs)
+	if pruneHeight > 0 {
+		for height := info.Height; height >= pruneHeight; height-- {
+			pb, err := getBlockMap(tx, path[height])
+			if err != nil {
+				return err
+			}
+			if !commitOutputDiffs(pb, coins, blockStakes, modules.DiffRevert) {
+				return errDBInconsistent
+			}
+		}
+	}
+
+	// Sort the processed blocks into kept and pruned blocks.
+	blocks := make(map[string][]byte)
+	prunedBlocks := make(map[string][]byte)
+	for height, id := range path {
+		pb, err := getBlockMap(tx, id)
+		if err == errBlockPruned {
+			ppb, err := getPrunedBlock(tx, id)
+			if err != nil {
+				return err
+			}
+			prunedBlocks[string(id[:])] = encoding.Marshal(*ppb)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if height == 0 || types.BlockHeight(height) >= pruneHeight {
+			blocks[string(id[:])] = encoding.Marshal(*pb)
+			continue
+		}
+		if createsUnspentBlockStake(tx, pb.Block) {
+			pb.CoinOutputDiffs = nil
+			pb.BlockStakeOutputDiffs = nil
+			pb.DelayedCoinOutputDiffs = nil
+			pb.TxIDDiffs = nil
+			blocks[string(id[:])] = encoding.Marshal(*pb)
+			continue
+		}
+		prunedBlocks[string(id[:])] = encoding.Marshal(*newPrunedBlock(pb))
+	}
+
+	// Collect the names of the buckets which are copied as they are.
+	buckets := [][]byte{BlockPath, CoinOutputs, BlockStakeOutputs, TransactionIDMap}
+	err := tx.ForEach(func(name []byte, _ persist.KVBucket) error {
+		if bytes.HasPrefix(name, prefixDCO) || bytes.HasPrefix(name, prefixFCEX) {
+			buckets = append(buckets, append([]byte(nil), name...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := encoding.NewEncoder(w)
+	err = enc.EncodeAll(snapshotMetadata, info, uint64(len(buckets)+4))
+	if err != nil {
+		return err
+	}
+	for _, name := range buckets {
+		b := tx.Bucket(name)
+		var n uint64
+		b.ForEach(func(_, _ []byte) error {
+			n++
+			return nil
+		})
+		err = writeSnapshotBucket(enc, name, n, b.ForEach)
+		if err != nil {
+			return err
+		}
+	}
+	if pruneHeight == 0 {
+		// Nothing is pruned, the genesis block provides the initial outputs.
+		coins, blockStakes = map[string][]byte{}, map[string][]byte{}
+	}
+	for _, bucket := range []struct {
+		name    []byte
+		entries map[string][]byte
+	}{
+		{BlockMap, blocks},
+		{PrunedBlockMap, prunedBlocks},
+		{PrunedCoinOutputs, coins},
+		{PrunedBlockStakeOutputs, blockStakes},
+	} {
+		err = writeSnapshotMap(enc, bucket.name, bucket.entries)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportSnapshot creates a new consensus database in persistDir, containing
+// the consensus state of the snapshot read from r. The snapshot is only
+// imported if its consensus checksum matches the trusted checksum, and if the
+// imported consensus state matches that checksum.
+func ImportSnapshot(r io.Reader, persistDir string, chainCts types.ChainConstants, trustedChecksum crypto.Hash) (SnapshotInfo, error) {
+	dec := encoding.NewDecoder(r)
+	var md persist.Metadata
+	var info SnapshotInfo
+	var numBuckets uint64
+	err := dec.DecodeAll(&md, &info, &numBuckets)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if md.Header != snapshotMetadata.Header {
+		return SnapshotInfo{}, persist.ErrBadHeader
+	} else if md.Version != snapshotMetadata.Version {
+		return SnapshotInfo{}, persist.ErrBadVersion
+	}
+	if info.Checksum != trustedChecksum {
+		return SnapshotInfo{}, errSnapshotChecksum
+	}
+
+	err = os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	filename := filepath.Join(persistDir, DatabaseFilename)
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		return SnapshotInfo{}, errConsensusDBExists
+	}
+	db, err := persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	err = db.KVStore().Update(func(tx persist.KVTx) error {
+		return importSnapshot(tx, dec, numBuckets, info, chainCts)
+	})
+	closeErr := db.Close()
+	if err != nil {
+		os.Remove(filename)
+		return SnapshotInfo{}, err
+	}
+	return info, closeErr
+}
+
+// importSnapshot fills an empty consensus database with the buckets of a
+// snapshot, and verifies the result.
+func importSnapshot(tx persist.KVTx, dec *encoding.Decoder, numBuckets uint64, info SnapshotInfo, chainCts types.ChainConstants) error {
+	buckets := [][]byte{
+		BlockHeight,
+		BlockMap,
+		BlockPath,
+		Consistency,
+		CoinOutputs,
+		BlockStakeOutputs,
+		TransactionIDMap,
+		PrunedBlockMap,
+		PrunedCoinOutputs,
+		PrunedBlockStakeOutputs,
+		PruneHeight,
+	}
+	for _, bucket := range buckets {
+		_, err := tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := uint64(0); i < numBuckets; i++ {
+		var name []byte
+		var n uint64
+		err := dec.DecodeAll(&name, &n)
+		if err != nil {
+			return err
+		}
+		if !snapshotBucketAllowed(name) {
+			return errSnapshotBucket
+		}
+		b, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < n; j++ {
+			var entry snapshotEntry
+			err = dec.Decode(&entry)
+			if err != nil {
+				return err
+			}
+			err = b.Put(entry.Key, entry.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := tx.Bucket(BlockHeight).Put(BlockHeight, encoding.Marshal(info.Height))
+	if err != nil {
+		return err
+	}
+	pruneHeight := snapshotPruneHeight(info.Height, chainCts)
+	err = tx.Bucket(PruneHeight).Put(PruneHeight, encoding.Marshal(pruneHeight))
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket(Consistency).Put(Consistency, encoding.Marshal(false))
+	if err != nil {
+		return err
+	}
+	path, err := verifySnapshot(tx, info, chainCts.GenesisBlockID(), pruneHeight)
+	if err != nil {
+		return err
+	}
+
+	// Create a change log applying all blocks of the current path.
+	err = initChangeLog(tx, changeEntry{AppliedBlocks: path[:1]})
+	if err != nil {
+		return err
+	}
+	for start := 1; start < len(path); start += snapshotChangeEntrySize {
+		end := start + snapshotChangeEntrySize
+		if end > len(path) {
+			end = len(path)
+		}
+		err = appendChangeLog(tx, changeEntry{AppliedBlocks: path[start:end]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifySnapshot verifies that an imported snapshot forms a consistent
+// consensus set matching the snapshot info, and returns its current path.
+//
+// The consensus checksum covers the current path and the unspent outputs,
+// and as block IDs commit to the block contents, the bodies of the blocks
+// which aren't pruned are covered as well. The diffs and the transaction ID
+// mappings of those blocks are verified against their bodies. The data
+// derived from pruned blocks, being their depth, child target and transaction
+// ID mappings, as well as the delayed coin output diffs, can't be verified
+// and are trusted along with the checksum.
+func verifySnapshot(tx persist.KVTx, info SnapshotInfo, genesisID types.BlockID, pruneHeight types.BlockHeight) ([]types.BlockID, error) {
+	// The current path has to be linked, starting from the genesis block.
+	path := make([]types.BlockID, info.Height+1)
+	for i := range path {
+		height := types.BlockHeight(i)
+		id, err := getPath(tx, height)
+		if err != nil {
+			return nil, errSnapshotCorrupt
+		}
+		header, headerHeight, err := getBlockHeader(tx, id)
+		if err != nil || header.ID() != id || headerHeight != height {
+			return nil, errSnapshotCorrupt
+		}
+		if height == 0 && id != genesisID {
+			return nil, errSnapshotCorrupt
+		} else if height > 0 && header.ParentID != path[i-1] {
+			return nil, errSnapshotCorrupt
+		}
+		path[i] = id
+	}
+	if path[info.Height] != info.BlockID || consensusChecksum(tx) != info.Checksum {
+		return nil, errSnapshotCorrupt
+	}
+
+	// Applying the diffs of the full blocks to the outputs that were unspent at
+	// the prune height has to result in the current set of unspent outputs.
+	coins := loadOutputs(tx, PrunedCoinOutputs)
+	blockStakes := loadOutputs(tx, PrunedBlockStakeOutputs)
+	for _, id := range path[pruneHeight:] {
+		pb, err := getBlockMap(tx, id)
+		if err != nil || !pb.DiffsGenerated {
+			return nil, errSnapshotCorrupt
+		}
+		if !verifyBlockDiffs(pb) || !commitOutputDiffs(pb, coins, blockStakes, modules.DiffApply) {
+			return nil, errSnapshotCorrupt
+		}
+	}
+	if !outputsEqual(tx, CoinOutputs, coins) || !outputsEqual(tx, BlockStakeOutputs, blockStakes) {
+		return nil, errSnapshotCorrupt
+	}
+	if !verifyTransactionIDMap(tx, path) {
+		return nil, errSnapshotCorrupt
+	}
+	return path, nil
+}
+
+// verifyBlockDiffs returns true if the diffs of a processed block revert the
+// outputs spent by its transactions, apply the outputs created by them, and
+// map the IDs of its transactions. The transactions of the genesis block are
+// not mapped.
+func verifyBlockDiffs(pb *processedBlock) bool {
+	coins := make(map[types.CoinOutputID][]byte)
+	spentCoins := make(map[types.CoinOutputID]struct{})
+	for _, cod := range pb.CoinOutputDiffs {
+		if cod.Direction == modules.DiffApply {
+			coins[cod.ID] = encoding.Marshal(cod.CoinOutput)
+		} else {
+			spentCoins[cod.ID] = struct{}{}
+		}
+	}
+	blockStakes := make(map[types.BlockStakeOutputID][]byte)
+	spentBlockStakes := make(map[types.BlockStakeOutputID]struct{})
+	for _, bsod := range pb.BlockStakeOutputDiffs {
+		if bsod.Direction == modules.DiffApply {
+			blockStakes[bsod.ID] = encoding.Marshal(bsod.BlockStakeOutput)
+		} else {
+			spentBlockStakes[bsod.ID] = struct{}{}
+		}
+	}
+
+	for _, txn := range pb.Block.Transactions {
+		for _, ci := range txn.CoinInputs {
+			if _, ok := spentCoins[ci.ParentID]; !ok {
+				return false
+			}
+		}
+		for i, co := range txn.CoinOutputs {
+			if !bytes.Equal(coins[txn.CoinOutputID(uint64(i))], encoding.Marshal(co)) {
+				return false
+			}
+		}
+		for _, bsi := range txn.BlockStakeInputs {
+			if _, ok := spentBlockStakes[bsi.ParentID]; !ok {
+				return false
+			}
+		}
+		for i, bso := range txn.BlockStakeOutputs {
+			if !bytes.Equal(blockStakes[txn.BlockStakeOutputID(uint64(i))], encoding.Marshal(bso)) {
+				return false
+			}
+		}
+	}
+
+	if pb.Height == 0 {
+		return len(pb.TxIDDiffs) == 0
+	}
+	if len(pb.TxIDDiffs) != len(pb.Block.Transactions) {
+		return false
+	}
+	for i, txn := range pb.Block.Transactions {
+		expected := modules.TransactionIDDiff{
+			Direction: modules.DiffApply,
+			LongID:    txn.ID(),
+			ShortID:   types.NewTransactionShortID(pb.Height, uint16(i)),
+		}
+		if pb.TxIDDiffs[i] != expected {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyTransactionIDMap returns true if the transaction ID mapping maps the
+// transactions of all blocks of the current path, which aren't pruned, to
+// their short IDs, and if it only contains mappings to transactions of the
+// current path. The mappings of pruned blocks can't be verified.
+func verifyTransactionIDMap(tx persist.KVTx, path []types.BlockID) bool {
+	var expected, verified int
+	for _, id := range path[1:] {
+		pb, err := getBlockMap(tx, id)
+		if err == errBlockPruned {
+			continue
+		} else if err != nil {
+			return false
+		}
+		expected += len(pb.Block.Transactions)
+	}
+
+	err := tx.Bucket(TransactionIDMap).ForEach(func(k, v []byte) error {
+		var shortID types.TransactionShortID
+		err := encoding.Unmarshal(v, &shortID)
+		if err != nil {
+			return err
+		}
+		height := shortID.BlockHeight()
+		if height == 0 || height >= types.BlockHeight(len(path)) {
+			return errSnapshotCorrupt
+		}
+		pb, err := getBlockMap(tx, path[height])
+		if err == errBlockPruned {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		index := int(shortID.TransactionSequenceIndex())
+		if index >= len(pb.Block.Transactions) {
+			return errSnapshotCorrupt
+		}
+		txid := pb.Block.Transactions[index].ID()
+		if !bytes.Equal(k, txid[:]) {
+			return errSnapshotCorrupt
+		}
+		verified++
+		return nil
+	})
+	return err == nil && verified == expected
+}
+
+// outputsEqual returns true if the output bucket contains exactly the given
+// encoded outputs.
+func outputsEqual(tx persist.KVTx, name []byte, outputs map[string][]byte) bool {
+	var n int
+	err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+		n++
+		if !bytes.Equal(outputs[string(k)], v) {
+			return errSnapshotCorrupt
+		}
+		return nil
+	})
+	return err == nil && n == len(outputs)
+}
@@ -0,0 +1,311 @@
+package consensus
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+// TestSnapshotExportImport exports a snapshot of a new consensus set, and
+// checks that importing it results in the same consensus state.
+func TestSnapshotExportImport(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	exportDir := filepath.Join(testdir, "export")
+
+	// a snapshot can only be exported from an existing consensus set
+	var buf bytes.Buffer
+	if _, err := ExportSnapshot(&buf, exportDir, 0, bcInfo, cts); err != errNoConsensusDB {
+		t.Fatal("expected errNoConsensusDB, got", err)
+	}
+	cs, err := newConsensusSet(exportDir, bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := cs.dbConsensusChecksum()
+	cs.Close()
+
+	if _, err = ExportSnapshot(&buf, exportDir, 1, bcInfo, cts); err != errSnapshotHeight {
+		t.Fatal("expected errSnapshotHeight, got", err)
+	}
+	info, err := ExportSnapshot(&buf, exportDir, 0, bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Height != 0 || info.BlockID != cts.GenesisBlockID() || info.Checksum != checksum {
+		t.Fatal("unexpected snapshot info", info)
+	}
+	snapshot := buf.Bytes()
+
+	// the snapshot is only imported if it matches the trusted checksum
+	importDir := filepath.Join(testdir, "import")
+	_, err = ImportSnapshot(bytes.NewReader(snapshot), importDir, cts, crypto.Hash{})
+	if err != errSnapshotChecksum {
+		t.Fatal("expected errSnapshotChecksum, got", err)
+	}
+	if _, err = ImportSnapshot(bytes.NewReader(snapshot), importDir, cts, checksum); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ImportSnapshot(bytes.NewReader(snapshot), importDir, cts, checksum); err != errConsensusDBExists {
+		t.Fatal("expected errConsensusDBExists, got", err)
+	}
+
+	cs, err = newConsensusSet(importDir, bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	if cs.dbConsensusChecksum() != checksum || cs.dbBlockHeight() != 0 {
+		t.Fatal("imported consensus set does not match the exported consensus set")
+	}
+
+	// a transaction ID mapping to a transaction which isn't part of the
+	// current path is detected
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		path := []types.BlockID{cts.GenesisBlockID()}
+		if !verifyTransactionIDMap(tx, path) {
+			t.Error("transaction ID mapping of the imported consensus set should be verified")
+		}
+		txid := types.TransactionID(crypto.HashObject("txn"))
+		addTxnIDMapping(tx, txid, types.NewTransactionShortID(1, 0))
+		if verifyTransactionIDMap(tx, path) {
+			t.Error("mapping to an unknown transaction should not be verified")
+		}
+		return errSnapshotRollback
+	})
+	if err != errSnapshotRollback {
+		t.Fatal(err)
+	}
+}
+
+// TestVerifyBlockDiffs checks that the diffs of a processed block are verified
+// against the transactions of the block.
+func TestVerifyBlockDiffs(t *testing.T) {
+	txn := types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinInputs:  []types.CoinInput{{ParentID: types.CoinOutputID(crypto.HashObject("parent"))}},
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(1)}},
+	}
+	newBlock := func() *processedBlock {
+		return &processedBlock{
+			Block:  types.Block{Transactions: []types.Transaction{txn}},
+			Height: 1,
+			CoinOutputDiffs: []modules.CoinOutputDiff{{
+				Direction: modules.DiffRevert,
+				ID:        txn.CoinInputs[0].ParentID,
+			}, {
+				Direction:  modules.DiffApply,
+				ID:         txn.CoinOutputID(0),
+				CoinOutput: txn.CoinOutputs[0],
+			}},
+			TxIDDiffs: []modules.TransactionIDDiff{{
+				Direction: modules.DiffApply,
+				LongID:    txn.ID(),
+				ShortID:   types.NewTransactionShortID(1, 0),
+			}},
+		}
+	}
+	if !verifyBlockDiffs(newBlock()) {
+		t.Fatal("valid diffs should be verified")
+	}
+
+	pb := newBlock()
+	pb.CoinOutputDiffs = pb.CoinOutputDiffs[:1]
+	if verifyBlockDiffs(pb) {
+		t.Error("diffs missing a created output should not be verified")
+	}
+	pb = newBlock()
+	pb.CoinOutputDiffs[1].CoinOutput.Value = types.NewCurrency64(2)
+	if verifyBlockDiffs(pb) {
+		t.Error("diffs creating a different output should not be verified")
+	}
+	pb = newBlock()
+	pb.CoinOutputDiffs = pb.CoinOutputDiffs[1:]
+	if verifyBlockDiffs(pb) {
+		t.Error("diffs missing a spent output should not be verified")
+	}
+	pb = newBlock()
+	pb.TxIDDiffs[0].ShortID = types.NewTransactionShortID(1, 1)
+	if verifyBlockDiffs(pb) {
+		t.Error("diffs with a wrong transaction ID mapping should not be verified")
+	}
+}
+
+// addSnapshotTestBlocks extends the current path of a consensus set by n
+// blocks, pruning blocks as the consensus set would. The first block spends
+// the first genesis coin output, the last block spends the output created by
+// the first block. The transaction of the last block is returned.
+func addSnapshotTestBlocks(t *testing.T, cs *ConsensusSet, n int) (last types.Transaction) {
+	genesisOutput := cs.blockRoot.CoinOutputDiffs[0]
+	newTxn := func(parentID types.CoinOutputID) types.Transaction {
+		return types.Transaction{
+			Version: cs.chainCts.DefaultTransactionVersion,
+			CoinInputs: []types.CoinInput{{
+				ParentID: parentID,
+				Fulfillment: types.NewFulfillment(&types.SingleSignatureFulfillment{
+					PublicKey: types.Ed25519PublicKey(crypto.PublicKey{}),
+					Signature: make([]byte, crypto.SignatureSize),
+				}),
+			}},
+			CoinOutputs: []types.CoinOutput{genesisOutput.CoinOutput},
+		}
+	}
+	first := newTxn(genesisOutput.ID)
+	last = newTxn(first.CoinOutputID(0))
+	err := cs.db.Update(func(tx persist.KVTx) error {
+		for i := 0; i < n; i++ {
+			parent := currentProcessedBlock(tx)
+			pb := &processedBlock{
+				Block: types.Block{
+					ParentID:  parent.Block.ID(),
+					Timestamp: parent.Block.Timestamp + 1,
+				},
+				Height:         parent.Height + 1,
+				DiffsGenerated: true,
+			}
+			switch i {
+			case 0:
+				pb.Block.Transactions = []types.Transaction{first}
+			case n - 1:
+				pb.Block.Transactions = []types.Transaction{last}
+			}
+			for _, txn := range pb.Block.Transactions {
+				applyTransaction(tx, pb, txn)
+			}
+			pushPath(tx, pb.Block.ID())
+			addBlockMap(tx, pb)
+			err := cs.pruneBlocks(tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return last
+}
+
+// updateSnapshotTestDB updates the consensus database found in dir.
+func updateSnapshotTestDB(t *testing.T, dir string, bcInfo types.BlockchainInfo, cts types.ChainConstants, fn func(persist.KVTx) error) {
+	cs, err := newConsensusSet(dir, bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	err = cs.db.Update(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSnapshotChainExportImport checks that snapshots of a short blockchain,
+// which is exported in full, and of a long, pruned, blockchain can be
+// imported, and that snapshots with tampered diffs or transaction ID mappings
+// are rejected.
+func TestSnapshotChainExportImport(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	// keep the validation depth, and thus the number of blocks needed to
+	// prune blocks, small
+	cts.StakeModifierDelay = 0
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+
+	for _, prune := range []bool{false, true} {
+		name, numBlocks := "unpruned", 10
+		if prune {
+			name, numBlocks = "pruned", int(MinPruneDepth(cts))+100
+		}
+		exportDir := filepath.Join(testdir, name, "export")
+		cs, err := newConsensusSet(exportDir, bcInfo, cts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prune {
+			if err = cs.SetPruneDepth(MinPruneDepth(cts)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		last := addSnapshotTestBlocks(t, cs, numBlocks)
+		checksum, pruneHeight := cs.dbConsensusChecksum(), cs.PruneHeight()
+		cs.Close()
+		if prune && pruneHeight == 0 {
+			t.Fatal("expected blocks to be pruned")
+		}
+
+		// export a snapshot, and import it after applying the given change to
+		// the exported consensus set, which is reverted afterwards
+		importSnapshot := func(dir string, change, revert func(persist.KVTx) error) error {
+			if change != nil {
+				updateSnapshotTestDB(t, exportDir, bcInfo, cts, change)
+				defer updateSnapshotTestDB(t, exportDir, bcInfo, cts, revert)
+			}
+			var buf bytes.Buffer
+			info, err := ExportSnapshot(&buf, exportDir, SnapshotCurrentHeight, bcInfo, cts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Height != types.BlockHeight(numBlocks) || info.Checksum != checksum {
+				t.Fatal("unexpected snapshot info", info)
+			}
+			_, err = ImportSnapshot(&buf, filepath.Join(testdir, name, dir), cts, checksum)
+			return err
+		}
+
+		if err = importSnapshot("import", nil, nil); err != nil {
+			t.Fatal(name, err)
+		}
+		cs, err = newConsensusSet(filepath.Join(testdir, name, "import"), bcInfo, cts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cs.dbConsensusChecksum() != checksum || cs.dbBlockHeight() != types.BlockHeight(numBlocks) || cs.PruneHeight() != pruneHeight {
+			t.Error(name, "imported consensus set does not match the exported consensus set")
+		}
+		if _, _, exists := cs.TransactionAtID(last.ID()); !exists {
+			t.Error(name, "transaction of the last block is not available")
+		}
+		cs.Close()
+
+		// a diff of the last block spending another output is rejected
+		setLastBlockSpentOutput := func(id types.CoinOutputID) func(persist.KVTx) error {
+			return func(tx persist.KVTx) error {
+				pb := currentProcessedBlock(tx)
+				pb.CoinOutputDiffs[0].ID = id
+				bid := pb.Block.ID()
+				return tx.Bucket(BlockMap).Put(bid[:], encoding.Marshal(*pb))
+			}
+		}
+		err = importSnapshot("diff", setLastBlockSpentOutput(types.CoinOutputID(crypto.HashObject("other"))),
+			setLastBlockSpentOutput(last.CoinInputs[0].ParentID))
+		if err != errSnapshotCorrupt {
+			t.Error(name, "expected errSnapshotCorrupt for a tampered diff, got", err)
+		}
+
+		// a transaction ID mapping to another transaction is rejected
+		setLastTxnShortID := func(index uint16) func(persist.KVTx) error {
+			return func(tx persist.KVTx) error {
+				removeTxnIDMapping(tx, last.ID())
+				addTxnIDMapping(tx, last.ID(), types.NewTransactionShortID(types.BlockHeight(numBlocks), index))
+				return nil
+			}
+		}
+		err = importSnapshot("txid", setLastTxnShortID(1), setLastTxnShortID(0))
+		if err != errSnapshotCorrupt {
+			t.Error(name, "expected errSnapshotCorrupt for a tampered transaction ID mapping, got", err)
+		}
+	}
+}
@@ -2,19 +2,24 @@ package consensus
 
 import (
 	"github.com/rivine/rivine/modules"
-
-	"github.com/rivine/bbolt"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
 )
 
 // computeConsensusChange computes the consensus change from the change entry
 // at index 'i' in the change log. If i is out of bounds, an error is returned.
-func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (modules.ConsensusChange, error) {
+// modules.ErrPrunedConsensusChange is returned if the change entry contains
+// blocks below the prune height, as their diffs are no longer available.
+func (cs *ConsensusSet) computeConsensusChange(tx persist.KVTx, ce changeEntry) (modules.ConsensusChange, error) {
 	cc := modules.ConsensusChange{
 		ID: ce.ID(),
 	}
+	pruneHeight := getPruneHeight(kvTxWrapper{tx})
 	for _, revertedBlockID := range ce.RevertedBlocks {
 		revertedBlock, err := getBlockMap(tx, revertedBlockID)
-		if err != nil {
+		if err == errBlockPruned || (err == nil && revertedBlock.Height < pruneHeight) {
+			return modules.ConsensusChange{}, modules.ErrPrunedConsensusChange
+		} else if err != nil {
 			cs.log.Critical("getBlockMap failed in computeConsensusChange:", err)
 			return modules.ConsensusChange{}, err
 		}
@@ -35,7 +40,9 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 	}
 	for _, appliedBlockID := range ce.AppliedBlocks {
 		appliedBlock, err := getBlockMap(tx, appliedBlockID)
-		if err != nil {
+		if err == errBlockPruned || (err == nil && appliedBlock.Height < pruneHeight) {
+			return modules.ConsensusChange{}, modules.ErrPrunedConsensusChange
+		} else if err != nil {
 			cs.log.Critical("getBlockMap failed in computeConsensusChange:", err)
 			return modules.ConsensusChange{}, err
 		}
@@ -49,14 +56,19 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 		}
 	}
 
-	// Grab the child target and the minimum valid child timestamp.
+	// Grab the child target and the minimum valid child timestamp. The
+	// timestamps required for the latter are not available if the recent
+	// block is too close to the prune height.
 	recentBlock := ce.AppliedBlocks[len(ce.AppliedBlocks)-1]
 	pb, err := getBlockMap(tx, recentBlock)
 	if err != nil {
 		cs.log.Critical("could not find process block for known block")
+	} else {
+		cc.ChildTarget = pb.ChildTarget
+		if cs.checkPrunedParent(kvTxWrapper{tx}, pb.Height) == nil {
+			cc.MinimumValidChildTimestamp = cs.blockRuleHelper.minimumValidChildTimestamp(tx.Bucket(BlockMap), pb)
+		}
 	}
-	cc.ChildTarget = pb.ChildTarget
-	cc.MinimumValidChildTimestamp = cs.blockRuleHelper.minimumValidChildTimestamp(tx.Bucket(BlockMap), pb)
 
 	currentBlock := currentBlockID(tx)
 	if cs.synced && recentBlock == currentBlock {
@@ -65,13 +77,49 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 	return cc, nil
 }
 
+// entryPruned returns true if the change entry reverts or applies blocks below
+// the prune height. The bodies or diffs of such blocks have been pruned, and
+// can not be passed to subscribers.
+func entryPruned(tx persist.KVTx, ce changeEntry) bool {
+	pruneHeight := getPruneHeight(kvTxWrapper{tx})
+	if pruneHeight == 0 {
+		return false
+	}
+	for _, ids := range [][]types.BlockID{ce.RevertedBlocks, ce.AppliedBlocks} {
+		for _, id := range ids {
+			pb, err := getBlockMap(tx, id)
+			if err == errBlockPruned || (err == nil && pb.Height < pruneHeight) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSubscriptionPruned returns modules.ErrPrunedConsensusChange if the
+// given change entry, or any of the entries following it, contains blocks
+// below the prune height. A subscriber starting from that entry can not be
+// caught up, and is refused before any consensus change is delivered to it.
+func checkSubscriptionPruned(tx persist.KVTx, entry changeEntry, exists bool) error {
+	if getPruneHeight(kvTxWrapper{tx}) == 0 {
+		return nil
+	}
+	for exists {
+		if entryPruned(tx, entry) {
+			return modules.ErrPrunedConsensusChange
+		}
+		entry, exists = entry.NextEntry(tx)
+	}
+	return nil
+}
+
 // readLockUpdateSubscribers will inform all subscribers of a new update to the
 // consensus set. readlockUpdateSubscribers does not alter the changelog, the
 // changelog must be updated beforehand.
 func (cs *ConsensusSet) readlockUpdateSubscribers(ce changeEntry) {
 	// Get the consensus change and send it to all subscribers.
 	var cc modules.ConsensusChange
-	err := cs.db.View(func(tx *bolt.Tx) error {
+	err := cs.db.View(func(tx persist.KVTx) error {
 		// Compute the consensus change so it can be sent to subscribers.
 		var err error
 		cc, err = cs.computeConsensusChange(tx, ce)
@@ -92,7 +140,7 @@ func (cs *ConsensusSet) readlockUpdateSubscribers(ce changeEntry) {
 // As a special case, using an empty id as the start will have all the changes
 // sent to the modules starting with the genesis block.
 func (cs *ConsensusSet) initializeSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
-	return cs.db.View(func(tx *bolt.Tx) error {
+	return cs.db.View(func(tx persist.KVTx) error {
 		// 'exists' and 'entry' are going to be pointed to the first entry that
 		// has not yet been seen by subscriber.
 		var exists bool
@@ -128,6 +176,11 @@ func (cs *ConsensusSet) initializeSubscribe(subscriber modules.ConsensusSetSubsc
 			entry, exists = entry.NextEntry(tx)
 		}
 
+		err := checkSubscriptionPruned(tx, entry, exists)
+		if err != nil {
+			return err
+		}
+
 		// Send all remaining consensus changes to the subscriber.
 		for exists {
 			cc, err := cs.computeConsensusChange(tx, entry)
@@ -147,6 +200,9 @@ func (cs *ConsensusSet) initializeSubscribe(subscriber modules.ConsensusSetSubsc
 //
 // As a special case, using an empty id as the start will have all the changes
 // sent to the modules starting with the genesis block.
+//
+// modules.ErrPrunedConsensusChange is returned if any of those changes
+// contains blocks which are below the prune height of the consensus set.
 func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
 	err := cs.tg.Add()
 	if err != nil {
@@ -168,23 +224,66 @@ func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSub
 	return nil
 }
 
+// filteredSubscriber delivers the consensus changes to a subscriber,
+// filtered using a consensus change filter.
+type filteredSubscriber struct {
+	subscriber modules.ConsensusSetSubscriber
+	filter     modules.ConsensusChangeFilter
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (fs *filteredSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	fs.subscriber.ProcessConsensusChange(fs.filter.Apply(cc))
+}
+
+// ConsensusSetSubscribeFiltered adds a subscriber to the list of subscribers,
+// and gives them every consensus change that has occurred since the change
+// with the provided id, only containing the diffs, transactions and miner
+// payouts matched by the given filter. Every consensus change is delivered,
+// even if nothing in it is matched.
+func (cs *ConsensusSet) ConsensusSetSubscribeFiltered(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, filter modules.ConsensusChangeFilter) error {
+	return cs.ConsensusSetSubscribe(&filteredSubscriber{
+		subscriber: subscriber,
+		filter:     filter,
+	}, start)
+}
+
 // Unsubscribe removes a subscriber from the list of subscribers, allowing for
 // garbage collection and rescanning. If the subscriber is not found in the
 // subscriber database, no action is taken.
+//
+// Unsubscribing an asynchronous subscriber blocks until the consensus change
+// that is being delivered to it, if any, has been processed.
 func (cs *ConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
 	if cs.tg.Add() != nil {
 		return
 	}
 	defer cs.tg.Done()
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
 
 	// Search for the subscriber in the list of subscribers and remove it if
 	// found.
+	var async *asyncSubscriber
 	for i := range cs.subscribers {
-		if cs.subscribers[i] == subscriber {
+		var wrapped modules.ConsensusSetSubscriber
+		as, ok := cs.subscribers[i].(*asyncSubscriber)
+		if ok {
+			wrapped = as.subscriber
+		} else if fs, ok := cs.subscribers[i].(*filteredSubscriber); ok {
+			wrapped = fs.subscriber
+		}
+		if cs.subscribers[i] == subscriber || wrapped == subscriber {
+			async = as
 			cs.subscribers = append(cs.subscribers[0:i], cs.subscribers[i+1:]...)
 			break
 		}
 	}
+	cs.mu.Unlock()
+
+	// The delivery goroutine is stopped without holding the lock, as the
+	// subscriber might need it to process the change it is delivered.
+	if async != nil {
+		close(async.closeChan)
+		<-async.doneChan
+	}
 }
@@ -0,0 +1,360 @@
+package consensus
+
+// subscribeasync.go implements the asynchronous delivery of consensus changes.
+// An asynchronous subscriber is registered as a regular subscriber, but rather
+// than processing the consensus changes while the consensus set is locked, it
+// queues them, and processes them in its own goroutine. Once its queue is full,
+// the subscriber stops queueing consensus changes, and catches up using the
+// changelog instead, starting from the last consensus change it has seen.
+// Should catching up fail, it is retried after a delay, which doubles after
+// each failed attempt.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+const (
+	// catchUpBatchSize is the maximum number of consensus changes read from
+	// the changelog within a single database transaction while catching up,
+	// such that a slow subscriber does not keep a database transaction open.
+	catchUpBatchSize = 10
+)
+
+var (
+	errQueueSize = errors.New("queue size of an asynchronous subscriber has to be positive")
+
+	// catchUpRetryDelay is the initial delay after which an asynchronous
+	// subscriber retries to catch up, after failing to do so.
+	catchUpRetryDelay = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return time.Second
+		case "standard":
+			return time.Second
+		case "testing":
+			return 10 * time.Millisecond
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+	// maxCatchUpRetryDelay is the maximum delay after which an asynchronous
+	// subscriber retries to catch up.
+	maxCatchUpRetryDelay = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return time.Minute
+		case "standard":
+			return 5 * time.Minute
+		case "testing":
+			return time.Second
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+// asyncSubscriber delivers the consensus changes to a subscriber
+// asynchronously, using a bounded queue.
+type asyncSubscriber struct {
+	subscriber modules.ConsensusSetSubscriber
+	cs         *ConsensusSet
+
+	queue     chan modules.ConsensusChange
+	catchUp   chan struct{}
+	closeChan chan struct{}
+	doneChan  chan struct{}
+
+	// lastID is the id of the last consensus change which was either queued
+	// or delivered from the changelog. While catching up, no consensus
+	// changes are queued.
+	lastID     modules.ConsensusChangeID
+	catchingUp bool
+	catchUps   uint64
+	delivered  uint64
+	// catchUpErr is the error which caused the last attempt to catch up to
+	// fail, it is reset once the subscriber caught up.
+	catchUpErr error
+	// pathLength is the length of the current path, as seen by the subscriber.
+	pathLength types.BlockHeight
+	mu         sync.Mutex
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber, queueing
+// the consensus change, or starting to catch up when the queue is full.
+func (as *asyncSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	// While catching up the consensus change will be read from the changelog.
+	// It might also already have been delivered from the changelog, in case
+	// the subscriber caught up right after the change was committed.
+	if as.catchingUp || cc.ID == as.lastID {
+		return
+	}
+	select {
+	case as.queue <- cc:
+		as.lastID = cc.ID
+	default:
+		as.catchingUp = true
+		as.catchUps++
+		as.signalCatchUp()
+	}
+}
+
+// signalCatchUp signals the delivery goroutine to start catching up.
+func (as *asyncSubscriber) signalCatchUp() {
+	select {
+	case as.catchUp <- struct{}{}:
+	default:
+	}
+}
+
+// threadedDeliver delivers the queued consensus changes to the subscriber,
+// and catches up using the changelog when signaled, until the subscriber is
+// unsubscribed or the consensus set is closed.
+func (as *asyncSubscriber) threadedDeliver() {
+	defer close(as.doneChan)
+	if as.cs.tg.Add() != nil {
+		return
+	}
+	defer as.cs.tg.Done()
+
+	// retry is set while waiting to retry catching up.
+	var retry <-chan time.Time
+	retryDelay := catchUpRetryDelay
+	for {
+		select {
+		case cc := <-as.queue:
+			as.deliver(cc)
+		case <-as.catchUp:
+			// The changelog is read starting from the last queued change, so
+			// all queued changes have to be delivered first.
+			for len(as.queue) > 0 {
+				as.deliver(<-as.queue)
+			}
+			err := as.managedCatchUp()
+			if err == nil {
+				retry, retryDelay = nil, catchUpRetryDelay
+				continue
+			}
+			as.cs.log.Printf("WARN: asynchronous subscriber failed to catch up, retrying in %v: %v\n", retryDelay, err)
+			retry = time.After(retryDelay)
+			retryDelay *= 2
+			if retryDelay > maxCatchUpRetryDelay {
+				retryDelay = maxCatchUpRetryDelay
+			}
+		case <-retry:
+			retry = nil
+			as.signalCatchUp()
+		case <-as.closeChan:
+			return
+		case <-as.cs.tg.StopChan():
+			return
+		}
+	}
+}
+
+// deliver sends a single consensus change to the subscriber.
+func (as *asyncSubscriber) deliver(cc modules.ConsensusChange) {
+	as.subscriber.ProcessConsensusChange(cc)
+	as.mu.Lock()
+	as.delivered++
+	as.pathLength += types.BlockHeight(len(cc.AppliedBlocks))
+	as.pathLength -= types.BlockHeight(len(cc.RevertedBlocks))
+	as.mu.Unlock()
+}
+
+// managedCatchUp delivers the consensus changes following the last delivered
+// consensus change from the changelog, until the subscriber has caught up. An
+// error is returned if the changelog could not be read, in which case the
+// subscriber is still catching up.
+func (as *asyncSubscriber) managedCatchUp() error {
+	for {
+		as.mu.Lock()
+		start := as.lastID
+		as.mu.Unlock()
+
+		var ccs []modules.ConsensusChange
+		err := as.cs.db.View(func(tx persist.KVTx) error {
+			var entry changeEntry
+			var exists bool
+			if start == modules.ConsensusChangeBeginning {
+				entry, exists = as.cs.genesisEntry(), true
+			} else {
+				entry, exists = getEntry(tx, start)
+				if !exists {
+					return modules.ErrInvalidConsensusChangeID
+				}
+				entry, exists = entry.NextEntry(tx)
+			}
+			for exists && len(ccs) < catchUpBatchSize {
+				cc, err := as.cs.computeConsensusChange(tx, entry)
+				if err != nil {
+					return err
+				}
+				ccs = append(ccs, cc)
+				entry, exists = entry.NextEntry(tx)
+			}
+			return nil
+		})
+		if err != nil {
+			as.mu.Lock()
+			as.catchUpErr = err
+			as.mu.Unlock()
+			return err
+		}
+
+		for _, cc := range ccs {
+			select {
+			case <-as.closeChan:
+				return nil
+			case <-as.cs.tg.StopChan():
+				return nil
+			default:
+			}
+			as.deliver(cc)
+			as.mu.Lock()
+			as.lastID = cc.ID
+			as.mu.Unlock()
+		}
+		if len(ccs) == catchUpBatchSize {
+			continue
+		}
+
+		// The subscriber has caught up if the last delivered change is the
+		// tail of the changelog. This is checked while holding the lock, such
+		// that no consensus change is missed between catching up and queueing.
+		as.mu.Lock()
+		var tailID modules.ConsensusChangeID
+		err = as.cs.db.View(func(tx persist.KVTx) error {
+			copy(tailID[:], tx.Bucket(ChangeLog).Get(ChangeLogTailID))
+			return nil
+		})
+		if err == nil && tailID == as.lastID {
+			as.catchingUp = false
+			as.catchUpErr = nil
+		}
+		caughtUp := !as.catchingUp
+		as.mu.Unlock()
+		if caughtUp {
+			return nil
+		}
+	}
+}
+
+// stats returns the delivery state of the subscriber,
+// given the current height of the consensus set.
+func (as *asyncSubscriber) stats(height types.BlockHeight) modules.ConsensusSubscriberStats {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	stats := modules.ConsensusSubscriberStats{
+		Subscriber: fmt.Sprintf("%T", as.subscriber),
+		QueueSize:  cap(as.queue),
+		Queued:     len(as.queue),
+		Delivered:  as.delivered,
+		CatchingUp: as.catchingUp,
+		CatchUps:   as.catchUps,
+	}
+	if as.catchUpErr != nil {
+		stats.CatchUpError = as.catchUpErr.Error()
+	}
+	if height+1 > as.pathLength {
+		stats.Lag = height + 1 - as.pathLength
+	}
+	return stats
+}
+
+// ConsensusSetSubscribeAsync adds a subscriber to the list of subscribers,
+// which receives every consensus change that has occurred since the change
+// with the provided id asynchronously. At most queueSize consensus changes
+// are queued for the subscriber, once it falls further behind, the subscriber
+// catches up using the changelog.
+//
+// The consensus changes are delivered in order, but the subscriber can be
+// behind on the consensus set, and it can not rely on the consensus set being
+// locked while it processes a consensus change.
+func (cs *ConsensusSet) ConsensusSetSubscribeAsync(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, queueSize int) error {
+	if queueSize <= 0 {
+		return errQueueSize
+	}
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	as := &asyncSubscriber{
+		subscriber: subscriber,
+		cs:         cs,
+
+		queue:     make(chan modules.ConsensusChange, queueSize),
+		catchUp:   make(chan struct{}, 1),
+		closeChan: make(chan struct{}),
+		doneChan:  make(chan struct{}),
+
+		lastID:     start,
+		catchingUp: true,
+	}
+	err = cs.db.View(func(tx persist.KVTx) error {
+		switch start {
+		case modules.ConsensusChangeBeginning:
+			// The subscriber catches up starting from the genesis entry.
+			return checkSubscriptionPruned(tx, cs.genesisEntry(), true)
+		case modules.ConsensusChangeRecent:
+			copy(as.lastID[:], tx.Bucket(ChangeLog).Get(ChangeLogTailID))
+			as.catchingUp = false
+			as.pathLength = blockHeight(tx) + 1
+		default:
+			entry, exists := getEntry(tx, start)
+			if !exists {
+				return modules.ErrInvalidConsensusChangeID
+			}
+			_, height, err := getBlockHeader(tx, entry.AppliedBlocks[len(entry.AppliedBlocks)-1])
+			if err != nil {
+				return err
+			}
+			as.pathLength = height + 1
+			next, exists := entry.NextEntry(tx)
+			return checkSubscriptionPruned(tx, next, exists)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if as.catchingUp {
+		as.signalCatchUp()
+	}
+	cs.subscribers = append(cs.subscribers, as)
+	go as.threadedDeliver()
+	return nil
+}
+
+// AsyncSubscriberStats returns the delivery state of all subscribers which
+// receive their consensus changes asynchronously.
+func (cs *ConsensusSet) AsyncSubscriberStats() []modules.ConsensusSubscriberStats {
+	if cs.tg.Add() != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	height := cs.Height()
+	var stats []modules.ConsensusSubscriberStats
+	for _, subscriber := range cs.subscribers {
+		if as, ok := subscriber.(*asyncSubscriber); ok {
+			stats = append(stats, as.stats(height))
+		}
+	}
+	return stats
+}
@@ -0,0 +1,230 @@
+package consensus
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+// slowSubscriber records the ids of the consensus changes it receives,
+// and can be stalled by locking its mutex.
+type slowSubscriber struct {
+	ids []modules.ConsensusChangeID
+	mu  sync.Mutex
+}
+
+func (ss *slowSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	ss.mu.Lock()
+	ss.ids = append(ss.ids, cc.ID)
+	ss.mu.Unlock()
+}
+
+// addTestBlock adds an empty block on top of the current block to the
+// consensus database, and informs the subscribers of the change.
+func addTestBlock(t *testing.T, cs *ConsensusSet) modules.ConsensusChangeID {
+	var ce changeEntry
+	err := cs.db.Update(func(tx persist.KVTx) error {
+		parent, err := getBlockMap(tx, currentBlockID(tx))
+		if err != nil {
+			return err
+		}
+		pb := processedBlock{
+			Block: types.Block{
+				ParentID:  parent.Block.ID(),
+				Timestamp: parent.Block.Timestamp + 1,
+			},
+			Height:         parent.Height + 1,
+			DiffsGenerated: true,
+		}
+		id := pb.Block.ID()
+		err = tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(pb))
+		if err != nil {
+			return err
+		}
+		pushPath(tx, id)
+		ce = changeEntry{AppliedBlocks: []types.BlockID{id}}
+		return appendChangeLog(tx, ce)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.readlockUpdateSubscribers(ce)
+	return ce.ID()
+}
+
+// TestAsyncSubscriber checks that an asynchronous subscriber receives all
+// consensus changes in order, also when it falls behind.
+func TestAsyncSubscriber(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	ss := new(slowSubscriber)
+	if err = cs.ConsensusSetSubscribeAsync(ss, modules.ConsensusChangeBeginning, 0); err != errQueueSize {
+		t.Fatal("expected errQueueSize, got", err)
+	}
+	if err = cs.ConsensusSetSubscribeAsync(ss, modules.ConsensusChangeID{255}, 2); err != modules.ErrInvalidConsensusChangeID {
+		t.Fatal("expected ErrInvalidConsensusChangeID, got", err)
+	}
+
+	// stall the subscriber while more changes are added than fit in its queue
+	ss.mu.Lock()
+	err = cs.ConsensusSetSubscribeAsync(ss, modules.ConsensusChangeBeginning, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisEntry := cs.genesisEntry()
+	expected := []modules.ConsensusChangeID{genesisEntry.ID()}
+	for i := 0; i < 5; i++ {
+		expected = append(expected, addTestBlock(t, cs))
+	}
+	stats := cs.AsyncSubscriberStats()
+	if len(stats) != 1 || !stats[0].CatchingUp || stats[0].Lag == 0 {
+		t.Fatal("expected a lagging subscriber, got", stats)
+	}
+	ss.mu.Unlock()
+
+	// the subscriber catches up using the changelog, after which new changes
+	// are queued again
+	waitForSubscriber := func() {
+		err := build.Retry(100, 10*time.Millisecond, func() error {
+			stats := cs.AsyncSubscriberStats()
+			if len(stats) != 1 || stats[0].CatchingUp || stats[0].Lag != 0 {
+				return errors.New("subscriber did not catch up")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForSubscriber()
+	for i := 0; i < 2; i++ {
+		expected = append(expected, addTestBlock(t, cs))
+	}
+	waitForSubscriber()
+
+	ss.mu.Lock()
+	if len(ss.ids) != len(expected) {
+		t.Fatal("expected", len(expected), "consensus changes, got", len(ss.ids))
+	}
+	for i := range expected {
+		if ss.ids[i] != expected[i] {
+			t.Fatal("consensus change", i, "was delivered out of order")
+		}
+	}
+	ss.mu.Unlock()
+
+	// once unsubscribed, no more changes are delivered
+	cs.Unsubscribe(ss)
+	if len(cs.AsyncSubscriberStats()) != 0 {
+		t.Fatal("subscriber was not unsubscribed")
+	}
+	addTestBlock(t, cs)
+	time.Sleep(10 * time.Millisecond)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if len(ss.ids) != len(expected) {
+		t.Fatal("unsubscribed subscriber received a consensus change")
+	}
+}
+
+// TestAsyncSubscriberCatchUpRetry checks that an asynchronous subscriber
+// keeps retrying to catch up when the changelog can not be read.
+func TestAsyncSubscriberCatchUpRetry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	bcInfo, cts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	cs, err := newConsensusSet(build.TempDir(modules.ConsensusDir, t.Name()), bcInfo, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	// stall the subscriber while more changes are added than fit in its queue
+	ss := new(slowSubscriber)
+	ss.mu.Lock()
+	err = cs.ConsensusSetSubscribeAsync(ss, modules.ConsensusChangeRecent, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []modules.ConsensusChangeID
+	for i := 0; i < 3; i++ {
+		expected = append(expected, addTestBlock(t, cs))
+	}
+
+	// remove the changelog entries from which the subscriber catches up,
+	// depending on how many changes it got queued, such that the
+	// changelog can't be read
+	entries := make(map[modules.ConsensusChangeID][]byte)
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		for _, id := range expected[:2] {
+			entries[id] = append([]byte(nil), tx.Bucket(ChangeLog).Get(id[:])...)
+			if err := tx.Bucket(ChangeLog).Delete(id[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.mu.Unlock()
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		stats := cs.AsyncSubscriberStats()
+		if len(stats) != 1 || !stats[0].CatchingUp || stats[0].CatchUpError == "" {
+			return errors.New("subscriber did not fail to catch up")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once the changelog is restored, the subscriber catches up
+	err = cs.db.Update(func(tx persist.KVTx) error {
+		for id, entry := range entries {
+			if err := tx.Bucket(ChangeLog).Put(id[:], entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(200, 10*time.Millisecond, func() error {
+		stats := cs.AsyncSubscriberStats()
+		if len(stats) != 1 || stats[0].CatchingUp || stats[0].CatchUpError != "" {
+			return errors.New("subscriber did not catch up")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if len(ss.ids) != len(expected) {
+		t.Fatal("expected", len(expected), "consensus changes, got", len(ss.ids))
+	}
+	for i := range expected {
+		if ss.ids[i] != expected[i] {
+			t.Fatal("consensus change", i, "was delivered out of order")
+		}
+	}
+}
@@ -10,15 +10,24 @@ import (
 	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 const (
 	// minNumOutbound is the minimum number of outbound peers required before ibd
 	// is confident we are synced.
 	minNumOutbound = 3
+
+	// maxBlockDownloadPeers is the maximum number of outbound peers that
+	// blocks are downloaded from in parallel during headers-first
+	// synchronization.
+	maxBlockDownloadPeers = 8
+
+	// maxBlockDownloadFailures is the number of times a peer can fail to
+	// deliver a block during headers-first synchronization, before it is
+	// no longer used to download blocks from.
+	maxBlockDownloadFailures = 3
 )
 
 var (
@@ -37,6 +46,21 @@ var (
 			panic("unrecognized build.Release")
 		}
 	}()
+	// MaxCatchUpHeaders is the maximum number of block headers that can be
+	// sent in a single batch of the SendHeaders RPC. Headers are small and
+	// of a fixed size, so far more headers than blocks can be sent at once.
+	MaxCatchUpHeaders = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 500
+		case "standard":
+			return 2000
+		case "testing":
+			return 10
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
 	// sendBlocksTimeout is the timeout for the SendBlocks RPC.
 	sendBlocksTimeout = func() time.Duration {
 		switch build.Release {
@@ -85,6 +109,7 @@ var (
 
 	errEarlyStop         = errors.New("initial blockchain download did not complete by the time shutdown was issued")
 	errSendBlocksStalled = errors.New("SendBlocks RPC timed and never received any blocks")
+	errNoSyncProgress    = errors.New("headers-first synchronization did not extend the blockchain")
 )
 
 // isTimeoutErr is a helper function that returns true if err was caused by a
@@ -100,24 +125,45 @@ func isTimeoutErr(err error) bool {
 	return (err.Error() == "Read timeout" || err.Error() == "Write timeout")
 }
 
+// setSyncDeadline sets a deadline on a connection used to synchronize with a
+// peer, after which the synchronization RPC will time out.
+func setSyncDeadline(conn modules.PeerConn, timeout time.Duration) error {
+	err := conn.SetDeadline(time.Now().Add(timeout))
+	// Ignore errors returned by SetDeadline if the conn is a pipe in testing.
+	// Pipes do not support Set{,Read,Write}Deadline and should only be used in
+	// testing.
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "set" && opErr.Net == "pipe" && build.Release == "testing" {
+		err = nil
+	}
+	return err
+}
+
 // blockHistory returns up to 32 block ids, starting with recent blocks and
 // then proving exponentially increasingly less recent blocks. The genesis
 // block is always included as the last block. This block history can be used
 // to find a common parent that is reasonably recent, usually the most recent
 // common parent is found, but always a common parent within a factor of 2 is
 // found.
-func blockHistory(tx *bolt.Tx) (blockIDs [32]types.BlockID) {
-	height := blockHeight(tx)
+func blockHistory(tx persist.KVTx) (blockIDs [32]types.BlockID) {
+	return pathHistory(blockHeight(tx), func(height types.BlockHeight) types.BlockID {
+		blockID, err := getPath(tx, height)
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+		return blockID
+	})
+}
+
+// pathHistory creates a block history as described by blockHistory,
+// for a path of the given height, using the given function to look up
+// the id of the block at a given height within that path.
+func pathHistory(height types.BlockHeight, pathID func(types.BlockHeight) types.BlockID) (blockIDs [32]types.BlockID) {
 	step := types.BlockHeight(1)
 	// The final step is to include the genesis block, which is why the final
 	// element is skipped during iteration.
 	for i := 0; i < 31; i++ {
 		// Include the next block.
-		blockID, err := getPath(tx, height)
-		if build.DEBUG && err != nil {
-			panic(err)
-		}
-		blockIDs[i] = blockID
+		blockIDs[i] = pathID(height)
 
 		// Determine the height of the next block to include and then increase
 		// the step size. The height must be decreased first to prevent
@@ -134,27 +180,45 @@ func blockHistory(tx *bolt.Tx) (blockIDs [32]types.BlockID) {
 		height -= step
 	}
 	// Include the genesis block as the last element
-	blockID, err := getPath(tx, 0)
-	if build.DEBUG && err != nil {
-		panic(err)
-	}
-	blockIDs[31] = blockID
+	blockIDs[31] = pathID(0)
 	return blockIDs
 }
 
+// startHeightFromHistory finds the most recent block of a block history (as
+// created by blockHistory) that is part of the current path, and returns the
+// height of its child. False is returned if no block of the history is part of
+// the current path, or if the most recent common block is the current block,
+// in which case there is nothing to be sent.
+func startHeightFromHistory(tx persist.KVTx, knownBlocks [32]types.BlockID) (types.BlockHeight, bool) {
+	csHeight := blockHeight(tx)
+	for _, id := range knownBlocks {
+		_, height, err := getBlockHeader(tx, id)
+		if err != nil {
+			continue
+		}
+		pathID, err := getPath(tx, height)
+		if err != nil {
+			continue
+		}
+		if pathID != id {
+			continue
+		}
+		if height == csHeight {
+			return 0, false
+		}
+		// Start from the child of the common block.
+		return height + 1, true
+	}
+	return 0, false
+}
+
 // managedReceiveBlocks is the calling end of the SendBlocks RPC, without the
 // threadgroup wrapping.
 func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr error) {
 	// Set a deadline after which SendBlocks will timeout. During IBD, esepcially,
 	// SendBlocks will timeout. This is by design so that IBD switches peers to
 	// prevent any one peer from stalling IBD.
-	err := conn.SetDeadline(time.Now().Add(sendBlocksTimeout))
-	// Ignore errors returned by SetDeadline if the conn is a pipe in testing.
-	// Pipes do not support Set{,Read,Write}Deadline and should only be used in
-	// testing.
-	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "set" && opErr.Net == "pipe" && build.Release == "testing" {
-		err = nil
-	}
+	err := setSyncDeadline(conn, sendBlocksTimeout)
 	if err != nil {
 		return err
 	}
@@ -168,7 +232,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 	// Get blockIDs to send.
 	var history [32]types.BlockID
 	cs.mu.RLock()
-	err = cs.db.View(func(tx *bolt.Tx) error {
+	err = cs.db.View(func(tx persist.KVTx) error {
 		history = blockHistory(tx)
 		return nil
 	})
@@ -229,6 +293,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 				acceptErr = nil
 			}
 			if acceptErr != nil {
+				cs.managedPenalizeInvalidBlock(conn.RPCAddr(), acceptErr)
 				return acceptErr
 			}
 		}
@@ -236,6 +301,33 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 	return nil
 }
 
+// isInvalidBlockErr returns true if an error returned when accepting a block
+// indicates that the block is invalid, such that the peer which sent it can
+// be held responsible. Only errors which do not depend on the local state
+// count, as honest peers can send blocks which are known, orphaned, not
+// extending the longest chain or slightly in the future, and errors of the
+// local database are not the fault of the peer. Transactions which are
+// invalid within a block get the block marked as a DoS block, such that
+// peers which send it again are held responsible.
+func isInvalidBlockErr(err error) bool {
+	switch err {
+	case errDoSBlock, modules.ErrBlockUnsolved, errLargeBlock, errBadMinerPayouts,
+		errEarlyTimestamp, errExtremeFutureTimestamp, errBlockStakeAgeNotMet,
+		errBlockStakeNotRespent, errCheckpoint:
+		return true
+	default:
+		return false
+	}
+}
+
+// managedPenalizeInvalidBlock penalizes the peer which sent a block,
+// if the given error indicates the block is invalid.
+func (cs *ConsensusSet) managedPenalizeInvalidBlock(addr modules.NetAddress, err error) {
+	if isInvalidBlockErr(err) {
+		cs.gateway.PenalizePeer(addr, modules.PenaltyInvalidBlock, "invalid block: "+err.Error())
+	}
+}
+
 // threadedReceiveBlocks is the calling end of the SendBlocks RPC.
 func (cs *ConsensusSet) threadedReceiveBlocks(conn modules.PeerConn) error {
 	err := cs.tg.Add()
@@ -269,30 +361,9 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 	// Find the most recent block from knownBlocks in the current path.
 	found := false
 	var start types.BlockHeight
-	var csHeight types.BlockHeight
 	cs.mu.RLock()
-	err = cs.db.View(func(tx *bolt.Tx) error {
-		csHeight = blockHeight(tx)
-		for _, id := range knownBlocks {
-			pb, err := getBlockMap(tx, id)
-			if err != nil {
-				continue
-			}
-			pathID, err := getPath(tx, pb.Height)
-			if err != nil {
-				continue
-			}
-			if pathID != pb.Block.ID() {
-				continue
-			}
-			if pb.Height == csHeight {
-				break
-			}
-			found = true
-			// Start from the child of the common block.
-			start = pb.Height + 1
-			break
-		}
+	err = cs.db.View(func(tx persist.KVTx) error {
+		start, found = startHeightFromHistory(tx, knownBlocks)
 		return nil
 	})
 	cs.mu.RUnlock()
@@ -318,7 +389,7 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 		// Get the set of blocks to send.
 		var blocks []types.Block
 		cs.mu.RLock()
-		err = cs.db.View(func(tx *bolt.Tx) error {
+		err = cs.db.View(func(tx persist.KVTx) error {
 			height := blockHeight(tx)
 			for i := start; i <= height && i < start+MaxCatchUpBlocks; i++ {
 				id, err := getPath(tx, i)
@@ -326,6 +397,11 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 					panic(err)
 				}
 				pb, err := getBlockMap(tx, id)
+				if err == errBlockPruned {
+					// The caller will have to get the block from
+					// another peer.
+					return err
+				}
 				if build.DEBUG && err != nil {
 					panic(err)
 				}
@@ -376,9 +452,9 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 
 	// Start verification inside of a bolt View tx.
 	cs.mu.RLock()
-	err = cs.db.View(func(tx *bolt.Tx) error {
+	err = cs.db.View(func(tx persist.KVTx) error {
 		// Do some relatively inexpensive checks to validate the header
-		return cs.validateHeader(boltTxWrapper{tx}, h)
+		return cs.validateHeader(kvTxWrapper{tx}, h)
 	})
 	cs.mu.RUnlock()
 	if err == errOrphan {
@@ -402,6 +478,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 		}()
 		return nil
 	} else if err != nil {
+		cs.managedPenalizeInvalidBlock(conn.RPCAddr(), err)
 		return err
 	}
 
@@ -443,7 +520,7 @@ func (cs *ConsensusSet) rpcSendBlk(conn modules.PeerConn) error {
 	// Lookup the corresponding block.
 	var b types.Block
 	cs.mu.RLock()
-	err = cs.db.View(func(tx *bolt.Tx) error {
+	err = cs.db.View(func(tx persist.KVTx) error {
 		pb, err := getBlockMap(tx, id)
 		if err != nil {
 			return err
@@ -478,6 +555,7 @@ func (cs *ConsensusSet) managedReceiveBlock(id types.BlockID) modules.RPCFunc {
 			return err
 		}
 		if err := cs.managedAcceptBlock(block); err != nil {
+			cs.managedPenalizeInvalidBlock(conn.RPCAddr(), err)
 			return err
 		}
 		cs.managedBroadcastBlock(block)
@@ -513,6 +591,10 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 			if p.Inbound {
 				continue
 			}
+			// Only peers which serve the full history can be synced from.
+			if !p.Services.Has(modules.ServiceFullHistory) {
+				continue
+			}
 
 			// Put the rest of the iteration inside of a thread group.
 			err := func() error {
@@ -522,9 +604,9 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 				}
 				defer cs.tg.Done()
 
-				// Request blocks from the peer. The error returned will only be
-				// 'nil' if there are no more blocks to receive.
-				err = cs.gateway.RPC(p.NetAddress, "SendBlocks", cs.managedReceiveBlocks)
+				// Request headers and blocks from the peer. The error returned
+				// will only be 'nil' if there are no more blocks to receive.
+				err = cs.managedHeadersFirstSync(p.NetAddress)
 				if err == nil {
 					numOutboundSynced++
 					// In this case, 'return nil' is equivalent to skipping to
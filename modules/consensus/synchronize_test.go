@@ -119,7 +119,7 @@ func TestBlockHistory(t *testing.T) {
 	// }
 	//
 	// var history [32]types.BlockID
-	// _ = cst.cs.db.View(func(tx *bolt.Tx) error {
+	// _ = cst.cs.db.View(func(tx persist.KVTx) error {
 	// 	history = blockHistory(tx)
 	// 	return nil
 	// })
@@ -588,7 +588,7 @@ func TestRPCSendBlockSendsOnlyNecessaryBlocks(t *testing.T) {
 	// 	// Get blockIDs to send.
 	// 	var history [32]types.BlockID
 	// 	cs.mu.RLock()
-	// 	err := cs.db.View(func(tx *bolt.Tx) error {
+	// 	err := cs.db.View(func(tx persist.KVTx) error {
 	// 		history = blockHistory(tx)
 	// 		return nil
 	// 	})
@@ -6,9 +6,8 @@ import (
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
-
-	"github.com/rivine/bbolt"
 )
 
 var (
@@ -23,7 +22,7 @@ var (
 // context of the current consensus set, meaning that total coin input sum
 // equals the total coin output sum, as well as the fact that all conditions referenced coin outputs,
 // have been correctly fulfilled by the child coin inputs.
-func validCoins(tx *bolt.Tx, t types.Transaction, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) (err error) {
+func validCoins(tx persist.KVTx, t types.Transaction, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) (err error) {
 	scoBucket := tx.Bucket(CoinOutputs)
 	var inputSum types.Currency
 	for inputIndex, sci := range t.CoinInputs {
@@ -63,7 +62,7 @@ func validCoins(tx *bolt.Tx, t types.Transaction, blockHeight types.BlockHeight,
 // in the context of the consensus set, meaning that block stake input sum
 // equals the block stake output sum, as well as the fact that all conditions
 // of referenced block stake outputs, have been correctly fulfilled by the child block stkae inputs.
-func validBlockStakes(tx *bolt.Tx, t types.Transaction, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) (err error) {
+func validBlockStakes(tx persist.KVTx, t types.Transaction, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) (err error) {
 	// Compare the number of input blockstake to the output blockstake.
 	var blockstakeInputSum types.Currency
 	var blockstakeOutputSum types.Currency
@@ -97,14 +96,23 @@ func validBlockStakes(tx *bolt.Tx, t types.Transaction, blockHeight types.BlockH
 }
 
 // validTransaction checks that all fields are valid within the current
-// consensus state. If not an error is returned.
-func validTransaction(tx *bolt.Tx, t types.Transaction, blockSizeLimit, arbitraryDataSizeLimit uint64, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) error {
+// consensus state and the active protocol rules. If not an error is returned.
+func validTransaction(tx persist.KVTx, t types.Transaction, rules types.ProtocolRules, blockSizeLimit, arbitraryDataSizeLimit uint64, blockHeight types.BlockHeight, blockTimestamp types.Timestamp) error {
 	// StandaloneValid will check things like signatures and properties that
 	// should be inherent to the transaction. (storage proof rules, etc.)
 	err := t.ValidateTransaction(blockSizeLimit, arbitraryDataSizeLimit)
 	if err != nil {
 		return err
 	}
+	// Check that the transaction only uses features of the protocol which
+	// are active, and adheres to the rules of the active protocol upgrades.
+	err = rules.ValidateTransaction(t, types.TransactionValidationConstants{
+		BlockSizeLimit:         blockSizeLimit,
+		ArbitraryDataSizeLimit: arbitraryDataSizeLimit,
+	})
+	if err != nil {
+		return err
+	}
 
 	// Check that each portion of the transaction is legal given the current
 	// consensus set.
@@ -145,14 +153,17 @@ func (cs *ConsensusSet) TryTransactionSet(txns []types.Transaction) (modules.Con
 	// manually manage the tx instead of using 'Update', but that has safety
 	// concerns and is more difficult to implement correctly.
 	errSuccess := errors.New("success")
-	err = cs.db.Update(func(tx *bolt.Tx) error {
+	err = cs.db.Update(func(tx persist.KVTx) error {
 		diffHolder.Height = blockHeight(tx)
 		blockTime, err := blockTimeStamp(tx, diffHolder.Height)
 		if err != nil {
 			return err
 		}
+		// the transactions are validated using the rules of the next block,
+		// as that is the first block they can be part of
+		rules := cs.chainCts.ProtocolRules(diffHolder.Height + 1)
 		for _, txn := range txns {
-			err := validTransaction(tx, txn, cs.chainCts.BlockSizeLimit, cs.chainCts.ArbitraryDataSizeLimit, diffHolder.Height, blockTime)
+			err := validTransaction(tx, txn, rules, cs.chainCts.BlockSizeLimit, cs.chainCts.ArbitraryDataSizeLimit, diffHolder.Height, blockTime)
 			if err != nil {
 				return err
 			}
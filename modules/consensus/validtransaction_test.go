@@ -90,7 +90,7 @@ func TestValidSiacoins(t *testing.T) {
 		Version:    cst.cs.chainCts.DefaultTransactionVersion,
 		CoinInputs: []types.CoinInput{{}},
 	}
-	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+	err = cst.cs.db.View(func(tx persist.KVTx) error {
 		err := validCoins(tx, txn)
 		if err != errMissingCoinOutput {
 			t.Fatal(err)
@@ -112,7 +112,7 @@ func TestValidSiacoins(t *testing.T) {
 			ParentID: scoid,
 		}},
 	}
-	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+	err = cst.cs.db.View(func(tx persist.KVTx) error {
 		err := validCoins(tx, txn)
 		if err != errWrongUnlockConditions {
 			t.Fatal(err)
@@ -130,7 +130,7 @@ func TestValidSiacoins(t *testing.T) {
 			Value: types.NewCurrency64(1),
 		}},
 	}
-	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+	err = cst.cs.db.View(func(tx persist.KVTx) error {
 		err := validCoins(tx, txn)
 		if err != errSiacoinInputOutputMismatch {
 			t.Fatal(err)
@@ -0,0 +1,77 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+// TestConsensusChangeFilter checks that a filter only keeps the diffs,
+// transactions and miner payouts relevant to the filtered unlock hashes.
+func TestConsensusChangeFilter(t *testing.T) {
+	watched := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: [32]byte{1}}
+	other := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: [32]byte{2}}
+	watchedCondition := types.NewCondition(types.NewUnlockHashCondition(watched))
+	otherCondition := types.NewCondition(types.NewUnlockHashCondition(other))
+
+	spentID := types.CoinOutputID{3}
+	receive := types.Transaction{
+		CoinOutputs: []types.CoinOutput{
+			{Value: types.NewCurrency64(1), Condition: otherCondition},
+			{Value: types.NewCurrency64(2), Condition: watchedCondition},
+		},
+	}
+	spend := types.Transaction{
+		CoinInputs:  []types.CoinInput{{ParentID: spentID}},
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(3), Condition: otherCondition}},
+	}
+	unrelated := types.Transaction{
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(4), Condition: otherCondition}},
+	}
+	cc := ConsensusChange{
+		ID: ConsensusChangeID{1},
+		AppliedBlocks: []types.Block{{
+			MinerPayouts: []types.MinerPayout{
+				{Value: types.NewCurrency64(5), UnlockHash: watched},
+				{Value: types.NewCurrency64(6), UnlockHash: other},
+			},
+			Transactions: []types.Transaction{receive, spend, unrelated},
+		}},
+		CoinOutputDiffs: []CoinOutputDiff{
+			{Direction: DiffApply, ID: receive.CoinOutputID(0), CoinOutput: receive.CoinOutputs[0]},
+			{Direction: DiffApply, ID: receive.CoinOutputID(1), CoinOutput: receive.CoinOutputs[1]},
+			{Direction: DiffRevert, ID: spentID, CoinOutput: types.CoinOutput{Value: types.NewCurrency64(3), Condition: watchedCondition}},
+			{Direction: DiffApply, ID: spend.CoinOutputID(0), CoinOutput: spend.CoinOutputs[0]},
+			{Direction: DiffApply, ID: unrelated.CoinOutputID(0), CoinOutput: unrelated.CoinOutputs[0]},
+		},
+	}
+
+	filtered := NewConsensusChangeFilter(watched).Apply(cc)
+	if filtered.ID != cc.ID || len(filtered.AppliedBlocks) != 1 {
+		t.Fatal("filtered consensus change does not contain the applied block")
+	}
+	if len(filtered.CoinOutputDiffs) != 2 || filtered.CoinOutputDiffs[0].ID != receive.CoinOutputID(1) || filtered.CoinOutputDiffs[1].ID != spentID {
+		t.Error("unexpected coin output diffs:", filtered.CoinOutputDiffs)
+	}
+	block := filtered.AppliedBlocks[0]
+	if len(block.MinerPayouts) != 1 || block.MinerPayouts[0].UnlockHash != watched {
+		t.Error("unexpected miner payouts:", block.MinerPayouts)
+	}
+	if len(block.Transactions) != 2 || block.Transactions[0].ID() != receive.ID() || block.Transactions[1].ID() != spend.ID() {
+		t.Error("unexpected transactions:", block.Transactions)
+	}
+
+	// a filter on an output ID matches the transaction spending it
+	filter := NewConsensusChangeFilter()
+	filter.CoinOutputIDs[spentID] = struct{}{}
+	filtered = filter.Apply(cc)
+	if len(filtered.CoinOutputDiffs) != 1 || len(filtered.AppliedBlocks[0].Transactions) != 1 || len(filtered.AppliedBlocks[0].MinerPayouts) != 0 {
+		t.Error("unexpected filtered consensus change:", filtered)
+	}
+
+	// an empty filter matches nothing
+	filtered = ConsensusChangeFilter{}.Apply(cc)
+	if len(filtered.CoinOutputDiffs) != 0 || len(filtered.AppliedBlocks[0].Transactions) != 0 {
+		t.Error("empty filter should not match anything")
+	}
+}
@@ -1,9 +1,13 @@
 package modules
 
 import (
+	"errors"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/types"
 )
 
 const (
@@ -12,6 +16,77 @@ const (
 	GatewayDir = "gateway"
 )
 
+const (
+	// PeerBanScore is the misbehavior score at which the host of a peer
+	// gets banned.
+	PeerBanScore = 100
+
+	// PenaltyInvalidBlock is the misbehavior score added to a peer
+	// which sent an invalid block.
+	PenaltyInvalidBlock = 50
+	// PenaltyInvalidTransactionSet is the misbehavior score added to a peer
+	// which sent a transaction set that is invalid by itself. Sets which
+	// conflict with the consensus set or the transaction pool are never
+	// penalized, as honest peers can relay sets which were valid from
+	// their point of view.
+	PenaltyInvalidTransactionSet = 10
+	// PenaltyBadHandshake is the misbehavior score added to a peer
+	// which failed the gateway handshake in a way honest peers do not.
+	PenaltyBadHandshake = 20
+)
+
+// Encryption modes of the gateway's peer transport.
+const (
+	// EncryptionOptional encrypts the connections with all peers which
+	// support it, and falls back to plaintext for legacy peers.
+	EncryptionOptional EncryptionMode = iota
+	// EncryptionRequired refuses to connect with peers which
+	// cannot encrypt the connection.
+	EncryptionRequired
+	// EncryptionDisabled never encrypts the connections with peers.
+	EncryptionDisabled
+)
+
+var (
+	// ErrInvalidEncryptionMode is returned when an unknown
+	// encryption mode is given.
+	ErrInvalidEncryptionMode = errors.New("invalid encryption mode")
+)
+
+// Service flags, advertised by peers in the gateway handshake.
+const (
+	// ServiceFullHistory indicates that the peer can serve all blocks.
+	ServiceFullHistory ServiceFlags = 1 << iota
+	// ServicePruned indicates that the peer can only serve recent blocks.
+	ServicePruned
+	// ServiceExplorer indicates that the peer runs an explorer.
+	ServiceExplorer
+	// ServiceHeaders indicates that the peer can serve block headers.
+	ServiceHeaders
+	// ServiceRelayTransactions indicates that the peer relays transactions.
+	ServiceRelayTransactions
+	// ServiceNoAdvertise indicates that the peer doesn't want its address to
+	// be advertised, such that it isn't added to the node list of its peers.
+	ServiceNoAdvertise
+)
+
+// DefaultServices are the services of a full node,
+// which are assumed for peers which do not advertise their services.
+const DefaultServices = ServiceFullHistory | ServiceHeaders | ServiceRelayTransactions
+
+// serviceNames maps the known service flags to their names.
+var serviceNames = []struct {
+	flag ServiceFlags
+	name string
+}{
+	{ServiceFullHistory, "fullhistory"},
+	{ServicePruned, "pruned"},
+	{ServiceExplorer, "explorer"},
+	{ServiceHeaders, "headers"},
+	{ServiceRelayTransactions, "relaytransactions"},
+	{ServiceNoAdvertise, "noadvertise"},
+}
+
 type (
 	// Peer contains all the info necessary to Broadcast to a peer.
 	Peer struct {
@@ -20,6 +95,68 @@ type (
 		NetAddress NetAddress `json:"netaddress"`
 		// Rivine Protocol Version used by peer
 		Version build.ProtocolVersion `json:"version"`
+		// Encrypted is true if the connection with the peer is encrypted,
+		// in which case IdentityKey is the authenticated identity of the peer.
+		Encrypted   bool                `json:"encrypted"`
+		IdentityKey *types.SiaPublicKey `json:"identitykey,omitempty"`
+		// Services are the services advertised by the peer.
+		Services ServiceFlags `json:"services"`
+		// ConnectedSince is the time at which the connection with the peer
+		// was established, LastActivity the last time at which data was
+		// sent to or received from the peer.
+		ConnectedSince types.Timestamp `json:"connectedsince"`
+		LastActivity   types.Timestamp `json:"lastactivity"`
+		// BytesSent and BytesReceived are the amount of bytes sent to and
+		// received from the peer on the wire, including the handshakes and
+		// the encryption overhead.
+		// RPCsSent and RPCsReceived count the RPC calls made to and by the
+		// peer, by RPC name. Calls to unknown RPCs are counted as "unknown".
+		BytesSent     uint64            `json:"bytessent"`
+		BytesReceived uint64            `json:"bytesreceived"`
+		RPCsSent      map[string]uint64 `json:"rpcssent"`
+		RPCsReceived  map[string]uint64 `json:"rpcsreceived"`
+	}
+
+	// PeerAllowlist contains the nodes which are allowed to connect with the
+	// gateway while it runs in private mode. An entry is either an IP address,
+	// optionally followed by a port, or an identity key ("ed25519:<hex>").
+	PeerAllowlist struct {
+		Enabled bool     `json:"enabled"`
+		Entries []string `json:"entries"`
+	}
+
+	// GatewaySettings contains the peer limits and connection policy of the
+	// gateway, which can be changed while the gateway is running.
+	GatewaySettings struct {
+		// MaxPeers is the number of peers at which the gateway starts
+		// kicking inbound peers to make room for new ones.
+		MaxPeers int `json:"maxpeers"`
+		// OutboundPeers is the number of outbound peers at which the
+		// gateway stops forming new outbound connections.
+		OutboundPeers int `json:"outboundpeers"`
+		// MaxLocalOutboundPeers is the number of outbound peers after which
+		// the gateway no longer forms outbound connections to local nodes.
+		MaxLocalOutboundPeers int `json:"maxlocaloutboundpeers"`
+		// MaxInboundPeersPerIP is the maximum number of inbound peers
+		// connecting from a single IP address, 0 meaning unlimited.
+		MaxInboundPeersPerIP int `json:"maxinboundpeersperip"`
+		// DisableInbound refuses all inbound connections.
+		DisableInbound bool `json:"disableinbound"`
+	}
+
+	// EncryptionMode defines if and when the gateway encrypts
+	// and authenticates the connections with its peers.
+	EncryptionMode uint8
+
+	// ServiceFlags is a bitfield of the services a node offers to its peers.
+	ServiceFlags uint64
+
+	// PeerBan describes a host the gateway refuses to connect with,
+	// until the ban expires.
+	PeerBan struct {
+		Host   string          `json:"host"`
+		Until  types.Timestamp `json:"until"`
+		Reason string          `json:"reason"`
 	}
 
 	// A PeerConn is the connection type used when communicating with peers during
@@ -87,7 +224,104 @@ type (
 		// Online returns true if the gateway is connected to remote hosts
 		Online() bool
 
+		// PenalizePeer adds the given penalty to the misbehavior score of the
+		// host of the given address. Once the score reaches PeerBanScore,
+		// the host is banned for the default ban duration.
+		PenalizePeer(addr NetAddress, penalty int, reason string)
+
+		// BanPeer bans the host of the given address for the given duration,
+		// disconnecting all of its peers. The default ban duration is used
+		// if the given duration is 0.
+		BanPeer(addr NetAddress, duration time.Duration, reason string) error
+
+		// UnbanPeer lifts the ban of the host of the given address.
+		UnbanPeer(addr NetAddress) error
+
+		// Bans returns the active bans of the gateway.
+		Bans() []PeerBan
+
+		// SetAllowlistEnabled enables or disables the private mode of the
+		// gateway, in which only nodes on the allowlist can be peers.
+		SetAllowlistEnabled(enabled bool) error
+
+		// UpdateAllowlist adds the entries of add to the allowlist,
+		// and removes the entries of remove from it.
+		UpdateAllowlist(add, remove []string) error
+
+		// Allowlist returns the allowlist of the gateway,
+		// and whether or not it is enforced.
+		Allowlist() PeerAllowlist
+
+		// Settings returns the peer limits and connection policy of the gateway.
+		Settings() GatewaySettings
+
+		// SetSettings updates the peer limits and connection policy of the gateway.
+		SetSettings(GatewaySettings) error
+
+		// Services returns the services the gateway advertises to its peers.
+		Services() ServiceFlags
+
+		// SetServices defines the services the gateway advertises to its peers.
+		SetServices(ServiceFlags) error
+
 		// Close safely stops the Gateway's listener process.
 		Close() error
 	}
 )
+
+// String implements fmt.Stringer.String
+func (mode EncryptionMode) String() string {
+	switch mode {
+	case EncryptionOptional:
+		return "optional"
+	case EncryptionRequired:
+		return "required"
+	case EncryptionDisabled:
+		return "disabled"
+	default:
+		return ""
+	}
+}
+
+// LoadString loads the encryption mode from its string representation.
+func (mode *EncryptionMode) LoadString(str string) error {
+	switch str {
+	case "optional":
+		*mode = EncryptionOptional
+	case "required":
+		*mode = EncryptionRequired
+	case "disabled":
+		*mode = EncryptionDisabled
+	default:
+		return ErrInvalidEncryptionMode
+	}
+	return nil
+}
+
+// Has returns true if all of the given services are offered.
+func (flags ServiceFlags) Has(services ServiceFlags) bool {
+	return flags&services == services
+}
+
+// String implements fmt.Stringer.String,
+// listing the names of the services, unknown services excluded.
+func (flags ServiceFlags) String() string {
+	var names []string
+	for _, service := range serviceNames {
+		if flags.Has(service.flag) {
+			names = append(names, service.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// PeersWithServices returns the peers which offer all of the given services.
+func PeersWithServices(peers []Peer, services ServiceFlags) []Peer {
+	var filtered []Peer
+	for _, p := range peers {
+		if p.Services.Has(services) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,242 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// The address manager divides the node list in two tables, in order to make
+// it hard for an attacker to eclipse the gateway by flooding its node list.
+//
+// The new table contains the nodes which the gateway has never successfully
+// connected to. A node is put in one of the buckets of the new table based on
+// its network group and the network group of the peer which shared it. As
+// such, all nodes shared by a single peer end up in a limited number of
+// buckets, no matter how many distinct addresses that peer shares.
+//
+// The tried table contains the nodes which the gateway has been an outbound
+// peer of. A node is put in one of the buckets of the tried table based on
+// its address and network group, such that a single network group can only
+// occupy a limited number of buckets.
+//
+// Both tables have a fixed number of buckets, and each bucket can contain a
+// limited number of nodes. The buckets are derived from a key which is secret
+// to the gateway, such that an attacker can not predict in which bucket a
+// node will end up.
+
+var (
+	errBucketFull = errors.New("address manager bucket is full")
+)
+
+var (
+	// addrManagerSpecifier is mixed into the key which is used to map
+	// nodes to the buckets of the address manager.
+	addrManagerSpecifier = types.Specifier{'a', 'd', 'd', 'r', 'e', 's', 's', ' ', 'm', 'a', 'n', 'a', 'g', 'e', 'r'}
+)
+
+// addressGroup returns the network group of an address. Nodes in the same
+// network group are likely to be controlled by the same entity. IPv4
+// addresses are grouped by their /16 prefix, and IPv6 addresses by their /32
+// prefix. Local addresses are never shared with remote peers, and thus are
+// not grouped at all.
+func addressGroup(addr modules.NetAddress) string {
+	ip := net.ParseIP(addr.Host())
+	if ip == nil || addr.IsLocal() {
+		return string(addr)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
+
+// addrBucket identifies a bucket of the address manager.
+type addrBucket struct {
+	tried bool
+	index int
+}
+
+// addrHash hashes the given objects, using the bucket key of the gateway,
+// and returns the hash as an integer.
+func (g *Gateway) addrHash(objs ...interface{}) uint64 {
+	h := crypto.HashAll(append([]interface{}{g.addrKey}, objs...)...)
+	return binary.LittleEndian.Uint64(h[:8])
+}
+
+// nodeBucket returns the bucket that a node belongs to.
+func (g *Gateway) nodeBucket(n *node) addrBucket {
+	group := addressGroup(n.NetAddress)
+	if n.WasOutboundPeer {
+		h := g.addrHash(n.NetAddress) % uint64(triedBucketsPerGroup)
+		return addrBucket{
+			tried: true,
+			index: int(g.addrHash(group, h) % uint64(triedBucketCount)),
+		}
+	}
+	source := n.Source
+	if source == "" {
+		source = group
+	}
+	h := g.addrHash(group, source) % uint64(newBucketsPerSourceGroup)
+	return addrBucket{
+		index: int(g.addrHash(source, h) % uint64(newBucketCount)),
+	}
+}
+
+// addrTables returns the nodes of the new and tried table, grouped per
+// bucket, for which the given filter returns true.
+func (g *Gateway) addrTables(filter func(*node) bool) (newTable, triedTable map[int][]modules.NetAddress) {
+	newTable = make(map[int][]modules.NetAddress)
+	triedTable = make(map[int][]modules.NetAddress)
+	for bucket, nodes := range g.buckets {
+		table := newTable
+		if bucket.tried {
+			table = triedTable
+		}
+		for _, n := range nodes {
+			if filter(n) {
+				table[bucket.index] = append(table[bucket.index], n.NetAddress)
+			}
+		}
+	}
+	return newTable, triedTable
+}
+
+// selectFromTable removes a random node from a random bucket of the given
+// table, and returns its address.
+func selectFromTable(table map[int][]modules.NetAddress) modules.NetAddress {
+	r := fastrand.Intn(len(table))
+	for index, bucket := range table {
+		if r > 0 {
+			r--
+			continue
+		}
+		i := fastrand.Intn(len(bucket))
+		addr := bucket[i]
+		bucket[i] = bucket[len(bucket)-1]
+		if len(bucket) == 1 {
+			delete(table, index)
+		} else {
+			table[index] = bucket[:len(bucket)-1]
+		}
+		return addr
+	}
+	return ""
+}
+
+// isTerrible returns true if a node is not worth keeping in the node list,
+// as the gateway failed to connect to it too many times.
+func (n *node) isTerrible(now types.Timestamp) bool {
+	if n.LastAttempt+types.Timestamp(time.Minute/time.Second) >= now {
+		// never evict a node which is being connected to
+		return false
+	}
+	if n.LastSuccess == 0 {
+		return n.Attempts >= addrMaxRetries
+	}
+	return n.Attempts >= addrMaxFailures &&
+		n.LastSuccess+types.Timestamp(addrMaxFailureAge/time.Second) < now
+}
+
+// addNodeToTable adds a node to the node list, in the bucket it belongs to.
+// If its bucket in the new table is full, a terrible node of that bucket is
+// evicted to make room for it, and if no such node exists, errBucketFull is
+// returned. If its bucket in the tried table is full, the node of that bucket
+// which was least recently connected to is moved back to the new table.
+func (g *Gateway) addNodeToTable(n *node) error {
+	n.bucket = g.nodeBucket(n)
+	if nodes := g.buckets[n.bucket]; len(nodes) >= addrBucketSize {
+		if n.bucket.tried {
+			var oldest *node
+			for _, bn := range nodes {
+				if oldest == nil || bn.LastSuccess < oldest.LastSuccess {
+					oldest = bn
+				}
+			}
+			g.removeNodeFromTable(oldest)
+			oldest.WasOutboundPeer = false
+			if err := g.addNodeToTable(oldest); err != nil {
+				g.log.Debugf("INFO: evicted node %v from the tried table to make room for %v", oldest.NetAddress, n.NetAddress)
+			} else {
+				g.log.Debugf("INFO: moved node %v from the tried table back to the new table", oldest.NetAddress)
+			}
+		} else {
+			now := types.CurrentTimestamp()
+			var evict *node
+			for _, bn := range nodes {
+				if bn.isTerrible(now) {
+					evict = bn
+					break
+				}
+			}
+			if evict == nil {
+				return errBucketFull
+			}
+			g.removeNodeFromTable(evict)
+			g.log.Debugf("INFO: evicted node %v from the new table to make room for %v", evict.NetAddress, n.NetAddress)
+		}
+	}
+	nodes, ok := g.buckets[n.bucket]
+	if !ok {
+		nodes = make(map[modules.NetAddress]*node)
+		g.buckets[n.bucket] = nodes
+	}
+	nodes[n.NetAddress] = n
+	g.nodes[n.NetAddress] = n
+	return nil
+}
+
+// removeNodeFromTable removes a node from the node list, and from its bucket.
+func (g *Gateway) removeNodeFromTable(n *node) {
+	delete(g.nodes, n.NetAddress)
+	nodes := g.buckets[n.bucket]
+	delete(nodes, n.NetAddress)
+	if len(nodes) == 0 {
+		delete(g.buckets, n.bucket)
+	}
+}
+
+// markAttempt records a connection attempt to a node.
+func (g *Gateway) markAttempt(addr modules.NetAddress) {
+	n, ok := g.nodes[addr]
+	if !ok {
+		return
+	}
+	n.LastAttempt = types.CurrentTimestamp()
+	n.Attempts++
+}
+
+// markTried records a successful outbound connection to a node, moving the
+// node to the tried table.
+func (g *Gateway) markTried(addr modules.NetAddress) {
+	n, ok := g.nodes[addr]
+	if !ok {
+		return
+	}
+	n.LastSuccess = types.CurrentTimestamp()
+	n.Attempts = 0
+	if n.WasOutboundPeer {
+		return
+	}
+	g.removeNodeFromTable(n)
+	n.WasOutboundPeer = true
+	g.addNodeToTable(n)
+}
+
+// outboundGroups returns the network groups of the outbound peers.
+func (g *Gateway) outboundGroups() map[string]struct{} {
+	groups := make(map[string]struct{})
+	for _, p := range g.peers {
+		if !p.Inbound {
+			groups[addressGroup(p.NetAddress)] = struct{}{}
+		}
+	}
+	return groups
+}
@@ -0,0 +1,170 @@
+package gateway
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// TestAddressGroup tests that addresses are grouped by their network.
+func TestAddressGroup(t *testing.T) {
+	tests := []struct {
+		addr  modules.NetAddress
+		group string
+	}{
+		{"111.111.111.111:9981", "111.111.0.0/16"},
+		{"111.111.222.222:1234", "111.111.0.0/16"},
+		{"111.112.111.111:9981", "111.112.0.0/16"},
+		{"[2001:db8:1::1]:9981", "2001:db8::/32"},
+		{"[2001:db8:2::1]:9981", "2001:db8::/32"},
+		{"127.0.0.1:9981", "127.0.0.1:9981"},
+		{"192.168.1.1:9981", "192.168.1.1:9981"},
+	}
+	for _, test := range tests {
+		if group := addressGroup(test.addr); group != test.group {
+			t.Errorf("expected group of %v to be %v, got %v", test.addr, test.group, group)
+		}
+	}
+}
+
+// TestAddrManagerBuckets tests that the nodes shared by a single peer are
+// limited to the capacity of their bucket, that terrible nodes are evicted
+// to make room for new ones, and that connected nodes move to the tried
+// table.
+func TestAddrManagerBuckets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// nodes of a single network, shared by a single peer, end up in
+	// the same bucket
+	source := modules.NetAddress("222.222.222.222:9981")
+	for i := 0; i < addrBucketSize; i++ {
+		if err := g.addNodeFrom(modules.NetAddress("111.111.111.111:"+strconv.Itoa(i+1)), source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.addNodeFrom("111.111.111.112:1", source); err != errBucketFull {
+		t.Fatal("expected errBucketFull, got", err)
+	}
+
+	// terrible nodes are evicted
+	terrible := g.nodes["111.111.111.111:1"]
+	terrible.Attempts = addrMaxRetries
+	if !terrible.isTerrible(types.CurrentTimestamp()) {
+		t.Fatal("expected node to be terrible")
+	}
+	if err := g.addNodeFrom("111.111.111.113:1", source); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := g.nodes[terrible.NetAddress]; exists {
+		t.Fatal("terrible node was not evicted")
+	}
+
+	// a node which is being connected to is never terrible
+	g.markAttempt("111.111.111.111:2")
+	n := g.nodes["111.111.111.111:2"]
+	n.Attempts = addrMaxRetries
+	if n.isTerrible(types.CurrentTimestamp()) {
+		t.Fatal("node which was just attempted should not be terrible")
+	}
+
+	// a connected node moves to the tried table
+	if n.bucket.tried {
+		t.Fatal("node should be in the new table")
+	}
+	g.markTried(n.NetAddress)
+	if !n.bucket.tried || !n.WasOutboundPeer || g.buckets[n.bucket][n.NetAddress] != n {
+		t.Fatal("node should be in the tried table")
+	}
+	if n.LastSuccess == 0 || n.Attempts != 0 {
+		t.Fatal("successful connection was not recorded:", n)
+	}
+	checkAddrBuckets(t, g)
+
+	// the nodes of a single network group can only occupy a limited number
+	// of buckets of the tried table, once those are full, the least recently
+	// connected nodes move back to the new table
+	for i := 0; i < 3*addrBucketSize; i++ {
+		addr := modules.NetAddress("111.111.112.111:" + strconv.Itoa(i+1))
+		err := g.addNodeFrom(addr, modules.NetAddress("222."+strconv.Itoa(i)+".222.222:9981"))
+		if err == errBucketFull {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		g.markTried(addr)
+	}
+	var tried int
+	for _, n := range g.nodes {
+		if n.WasOutboundPeer && addressGroup(n.NetAddress) == "111.111.0.0/16" {
+			tried++
+		}
+	}
+	if tried > triedBucketsPerGroup*addrBucketSize {
+		t.Fatalf("expected at most %d tried nodes of a single network group, got %d", triedBucketsPerGroup*addrBucketSize, tried)
+	}
+	checkAddrBuckets(t, g)
+}
+
+// checkAddrBuckets checks that the buckets of the address manager contain
+// the same nodes as the node list, and that no bucket exceeds its capacity.
+func checkAddrBuckets(t *testing.T, g *Gateway) {
+	var n int
+	for bucket, nodes := range g.buckets {
+		if len(nodes) > addrBucketSize {
+			t.Fatalf("bucket %v contains %d nodes", bucket, len(nodes))
+		}
+		for addr, bn := range nodes {
+			if g.nodes[addr] != bn || bn.bucket != bucket || g.nodeBucket(bn) != bucket {
+				t.Fatalf("node %v is not in the right bucket", addr)
+			}
+		}
+		n += len(nodes)
+	}
+	if n != len(g.nodes) {
+		t.Fatalf("buckets contain %d nodes, while the node list contains %d nodes", n, len(g.nodes))
+	}
+}
+
+// TestPeerManagerNodeListDiversity tests that the peer manager prefers
+// nodes from distinct networks.
+func TestPeerManagerNodeListDiversity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nodes = map[modules.NetAddress]*node{}
+	g.buckets = map[addrBucket]map[modules.NetAddress]*node{}
+	for i := 0; i < 5; i++ {
+		if err := g.addNode(modules.NetAddress("111.111.111.111:" + strconv.Itoa(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.addNode("222.222.222.222:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		nodes := g.buildPeerManagerNodeList()
+		if len(nodes) != 6 {
+			t.Fatal("expected 6 nodes, got", nodes)
+		}
+		if addressGroup(nodes[0]) == addressGroup(nodes[1]) {
+			t.Fatal("expected the first two nodes to be of distinct networks:", nodes)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+var (
+	errPeerNotAllowed         = errors.New("peer is not on the allowlist")
+	errInvalidAllowlistEntry  = errors.New("allowlist entry has to be an IP address, optionally followed by a port, or an ed25519 identity key")
+	errAllowlistEntryNotFound = errors.New("entry is not on the allowlist")
+)
+
+// allowlistEntry returns the normalized form of an allowlist entry,
+// and whether or not it is an address which can be dialed.
+func allowlistEntry(entry string) (string, bool, error) {
+	if strings.HasPrefix(entry, types.SignatureEd25519.String()+":") {
+		var spk types.SiaPublicKey
+		if err := spk.LoadString(entry); err != nil || len(spk.Key) != crypto.PublicKeySize {
+			return "", false, errInvalidAllowlistEntry
+		}
+		return spk.String(), false, nil
+	}
+	host, err := banHost(modules.NetAddress(entry))
+	if err != nil {
+		return "", false, errInvalidAllowlistEntry
+	}
+	port := modules.NetAddress(entry).Port()
+	if port == "" {
+		return host, false, nil
+	}
+	addr := modules.NetAddress(net.JoinHostPort(host, port))
+	if addr.IsStdValid() != nil {
+		return "", false, errInvalidAllowlistEntry
+	}
+	return string(addr), true, nil
+}
+
+// isAllowedAddress returns true if the gateway runs in public mode,
+// or if the given address, or its host, is on the allowlist.
+func (g *Gateway) isAllowedAddress(addr modules.NetAddress) bool {
+	if !g.private {
+		return true
+	}
+	host, err := banHost(addr)
+	if err != nil {
+		return false
+	}
+	if _, ok := g.allowlist[host]; ok {
+		return true
+	}
+	_, ok := g.allowlist[net.JoinHostPort(host, addr.Port())]
+	return ok
+}
+
+// isAllowedPeer returns true if the gateway runs in public mode,
+// or if the address or the authenticated identity of the peer
+// is on the allowlist.
+func (g *Gateway) isAllowedPeer(p *peer) bool {
+	if g.isAllowedAddress(p.NetAddress) {
+		return true
+	}
+	if p.IdentityKey == nil {
+		return false
+	}
+	_, ok := g.allowlist[p.IdentityKey.String()]
+	return ok
+}
+
+// disconnectDisallowedPeers disconnects all peers which are not allowed
+// to be a peer of the gateway.
+func (g *Gateway) disconnectDisallowedPeers() {
+	for addr, p := range g.peers {
+		if g.isAllowedPeer(p) {
+			continue
+		}
+		p.sess.Close()
+		delete(g.peers, addr)
+		g.log.Printf("INFO: disconnected from %v as it is not on the allowlist", addr)
+	}
+}
+
+// SetAllowlistEnabled enables or disables the private mode of the gateway.
+// In private mode only nodes on the allowlist can be peers, peers which are
+// not on it are disconnected, and nodes are no longer shared with peers.
+func (g *Gateway) SetAllowlistEnabled(enabled bool) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.private == enabled {
+		return nil
+	}
+	g.private = enabled
+	g.disconnectDisallowedPeers()
+	g.log.Println("INFO: private mode enabled:", enabled)
+	return g.saveSync()
+}
+
+// UpdateAllowlist adds the entries of add to the allowlist, and removes the
+// entries of remove from it. Addresses which include a port are added to the
+// node list, as the gateway only dials nodes on the allowlist in private mode.
+func (g *Gateway) UpdateAllowlist(add, remove []string) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	// validate all entries prior to applying any of them
+	type entry struct {
+		name     string
+		dialable bool
+	}
+	var added, removed []entry
+	for _, e := range add {
+		name, dialable, err := allowlistEntry(e)
+		if err != nil {
+			return err
+		}
+		added = append(added, entry{name, dialable})
+	}
+	for _, e := range remove {
+		name, _, err := allowlistEntry(e)
+		if err != nil {
+			return err
+		}
+		removed = append(removed, entry{name: name})
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, e := range removed {
+		if _, ok := g.allowlist[e.name]; !ok {
+			return errAllowlistEntryNotFound
+		}
+	}
+	for _, e := range removed {
+		delete(g.allowlist, e.name)
+	}
+	for _, e := range added {
+		g.allowlist[e.name] = struct{}{}
+		if e.dialable {
+			err := g.addNode(modules.NetAddress(e.name))
+			if err != nil && err != errNodeExists && err != errOurAddress {
+				g.log.Printf("WARN: failed to add allowed node '%v': %v", e.name, err)
+			}
+		}
+	}
+	g.disconnectDisallowedPeers()
+	return g.saveSync()
+}
+
+// Allowlist returns the allowlist of the gateway, sorted,
+// and whether or not it is enforced.
+func (g *Gateway) Allowlist() modules.PeerAllowlist {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.persistAllowlist()
+}
@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+// TestAllowlistEntry tests the validation and normalization of allowlist
+// entries.
+func TestAllowlistEntry(t *testing.T) {
+	tests := []struct {
+		entry    string
+		name     string
+		dialable bool
+		err      error
+	}{
+		{"111.111.111.111", "111.111.111.111", false, nil},
+		{"111.111.111.111:9988", "111.111.111.111:9988", true, nil},
+		{"::1", "::1", false, nil},
+		{"[::1]:9988", "[::1]:9988", true, nil},
+		{"ed25519:" + "AA00000000000000000000000000000000000000000000000000000000000000",
+			"ed25519:aa00000000000000000000000000000000000000000000000000000000000000", false, nil},
+		{"ed25519:00", "", false, errInvalidAllowlistEntry},
+		{"foo.com:9988", "", false, errInvalidAllowlistEntry},
+		{"", "", false, errInvalidAllowlistEntry},
+	}
+	for i, test := range tests {
+		name, dialable, err := allowlistEntry(test.entry)
+		if name != test.name || dialable != test.dialable || err != test.err {
+			t.Errorf("test #%d: expected (%v, %v, %v), got (%v, %v, %v)",
+				i, test.name, test.dialable, test.err, name, dialable, err)
+		}
+	}
+}
+
+// TestAllowlist tests that a gateway in private mode only accepts peers on
+// its allowlist, identified by address or identity, and that the allowlist
+// is persisted.
+func TestAllowlist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+
+	if err := g1.UpdateAllowlist([]string{"invalid"}, nil); err != errInvalidAllowlistEntry {
+		t.Fatal("expected errInvalidAllowlistEntry, got", err)
+	}
+	if err := g1.UpdateAllowlist([]string{string(g2.Address())}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.SetAllowlistEnabled(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the allowed node is dialed, and can be a peer
+	g1.mu.RLock()
+	nodes := g1.buildPeerManagerNodeList()
+	g1.mu.RUnlock()
+	if len(nodes) != 1 || nodes[0] != g2.Address() {
+		t.Fatal("expected only the allowed node to be dialed:", nodes)
+	}
+	if err := g1.Connect(g3.Address()); err != errPeerNotAllowed {
+		t.Fatal("expected errPeerNotAllowed, got", err)
+	}
+	// the allowed node might be dialed by the peer manager already
+	if err := g1.Connect(g2.Address()); err != nil && err != errPeerExists {
+		t.Fatal(err)
+	}
+
+	// nodes are allowed by their identity as well
+	identityKey := g3.IdentityKey()
+	if err := g1.UpdateAllowlist([]string{identityKey.String()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g3.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if len(g1.Peers()) != 2 {
+		t.Fatal("expected 2 peers, got", g1.Peers())
+	}
+
+	// removing an entry disconnects the peer it allowed
+	if err := g1.UpdateAllowlist(nil, []string{string(g2.Address())}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.UpdateAllowlist(nil, []string{string(g2.Address())}); err != errAllowlistEntryNotFound {
+		t.Fatal("expected errAllowlistEntryNotFound, got", err)
+	}
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].NetAddress != g3.Address() {
+		t.Fatal("expected only the peer allowed by identity to remain:", peers)
+	}
+
+	// the allowlist is persisted
+	g1.Close()
+	g1, err := New("localhost:0", false, g1.persistDir, types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	allowlist := g1.Allowlist()
+	if !allowlist.Enabled || len(allowlist.Entries) != 1 || allowlist.Entries[0] != identityKey.String() {
+		t.Fatal("allowlist was not persisted:", allowlist)
+	}
+
+	// public mode allows all peers again
+	if err = g1.SetAllowlistEnabled(false); err != nil {
+		t.Fatal(err)
+	}
+	if err = g1.Connect(g2.Address()); err != nil && err != errPeerExists {
+		t.Fatal(err)
+	}
+	if g1.Allowlist().Enabled {
+		t.Fatal("private mode should be disabled")
+	}
+}
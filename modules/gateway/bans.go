@@ -0,0 +1,205 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+var (
+	errPeerBanned    = errors.New("peer is banned")
+	errNotBanned     = errors.New("host is not banned")
+	errInvalidHost   = errors.New("ban address has to be an IP address, optionally followed by a port")
+	errInvalidBanDur = errors.New("ban duration cannot be negative")
+)
+
+// peerScore is the misbehavior score of a host. The score decays over time,
+// such that honest peers which occasionally relay something invalid
+// are not banned eventually.
+type peerScore struct {
+	Score   int
+	Updated time.Time
+}
+
+// current returns the score, with the decay since the last update applied.
+func (ps peerScore) current(now time.Time) int {
+	score := ps.Score - int(now.Sub(ps.Updated)/peerScoreDecayInterval)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// banHost returns the host of an address which is used to identify a
+// banned peer. The port is optional, as a peer can connect from any port.
+func banHost(addr modules.NetAddress) (string, error) {
+	host := addr.Host()
+	if host == "" {
+		host = string(addr)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", errInvalidHost
+	}
+	return ip.String(), nil
+}
+
+// isBanned returns true if the host of the given address is banned.
+// Expired bans are removed.
+func (g *Gateway) isBanned(addr modules.NetAddress) bool {
+	host, err := banHost(addr)
+	if err != nil {
+		return false
+	}
+	ban, ok := g.bans[host]
+	if !ok {
+		return false
+	}
+	if ban.Until <= types.CurrentTimestamp() {
+		delete(g.bans, host)
+		return false
+	}
+	return true
+}
+
+// managedIsBanned returns true if the host of the given address is banned.
+func (g *Gateway) managedIsBanned(addr modules.NetAddress) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.isBanned(addr)
+}
+
+// ban bans a host, disconnecting all of its peers,
+// and removing all of its nodes from the node list.
+func (g *Gateway) ban(host string, duration time.Duration, reason string) {
+	g.bans[host] = modules.PeerBan{
+		Host:   host,
+		Until:  types.CurrentTimestamp() + types.Timestamp(duration/time.Second),
+		Reason: reason,
+	}
+	delete(g.scores, host)
+	for addr, p := range g.peers {
+		if h, _ := banHost(addr); h == host {
+			p.sess.Close()
+			delete(g.peers, addr)
+		}
+	}
+	for addr, n := range g.nodes {
+		if h, _ := banHost(addr); h == host {
+			g.removeNodeFromTable(n)
+		}
+	}
+	g.log.Printf("INFO: banned %v for %v: %v\n", host, duration, reason)
+}
+
+// pruneBans removes the expired bans and the fully decayed scores.
+func (g *Gateway) pruneBans() {
+	now, timestamp := time.Now(), types.CurrentTimestamp()
+	for host, ban := range g.bans {
+		if ban.Until <= timestamp {
+			delete(g.bans, host)
+		}
+	}
+	for host, score := range g.scores {
+		if score.current(now) == 0 {
+			delete(g.scores, host)
+		}
+	}
+}
+
+// PenalizePeer adds the given penalty to the misbehavior score of the host
+// of the given address. Once the score reaches modules.PeerBanScore, the host
+// is banned for the default ban duration. Local peers are never penalized.
+func (g *Gateway) PenalizePeer(addr modules.NetAddress, penalty int, reason string) {
+	if addr.IsLocal() {
+		return
+	}
+	host, err := banHost(addr)
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.isBanned(addr) {
+		return
+	}
+	now := time.Now()
+	score := g.scores[host].current(now) + penalty
+	g.log.Debugf("INFO: penalized %v with %v points (score %v): %v\n", addr, penalty, score, reason)
+	if score < modules.PeerBanScore {
+		g.scores[host] = peerScore{Score: score, Updated: now}
+		return
+	}
+	g.ban(host, banDuration, reason)
+	if err := g.saveSync(); err != nil {
+		g.log.Println("ERROR: Unable to save gateway bans:", err)
+	}
+}
+
+// BanPeer bans the host of the given address for the given duration,
+// disconnecting all of its peers. The default ban duration is used if the
+// given duration is 0.
+func (g *Gateway) BanPeer(addr modules.NetAddress, duration time.Duration, reason string) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	host, err := banHost(addr)
+	if err != nil {
+		return err
+	}
+	if duration < 0 {
+		return errInvalidBanDur
+	}
+	if duration == 0 {
+		duration = banDuration
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ban(host, duration, reason)
+	return g.saveSync()
+}
+
+// UnbanPeer lifts the ban of the host of the given address.
+func (g *Gateway) UnbanPeer(addr modules.NetAddress) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	host, err := banHost(addr)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.isBanned(addr) {
+		return errNotBanned
+	}
+	delete(g.bans, host)
+	g.log.Println("INFO: unbanned", host)
+	return g.saveSync()
+}
+
+// Bans returns the active bans of the gateway, sorted by host.
+func (g *Gateway) Bans() []modules.PeerBan {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pruneBans()
+	bans := make([]modules.PeerBan, 0, len(g.bans))
+	for _, ban := range g.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Host < bans[j].Host
+	})
+	return bans
+}
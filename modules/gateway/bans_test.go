@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// TestBans tests banning and unbanning hosts, and that bans are persisted.
+func TestBans(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+
+	const banned = modules.NetAddress("111.111.111.111:9988")
+	g.mu.Lock()
+	g.addNode(banned)
+	g.mu.Unlock()
+	if err := g.BanPeer("invalid", 0, "test"); err != errInvalidHost {
+		t.Fatal("expected errInvalidHost, got", err)
+	}
+	if err := g.BanPeer("111.111.111.111", 0, "test"); err != nil {
+		t.Fatal(err)
+	}
+	bans := g.Bans()
+	if len(bans) != 1 || bans[0].Host != "111.111.111.111" || bans[0].Reason != "test" {
+		t.Fatal("unexpected bans:", bans)
+	}
+
+	// the nodes of a banned host are removed, and cannot be added again
+	g.mu.Lock()
+	_, exists := g.nodes[banned]
+	err := g.addNode(banned)
+	g.mu.Unlock()
+	if exists {
+		t.Fatal("node of banned host was not removed")
+	}
+	if err != errPeerBanned {
+		t.Fatal("expected errPeerBanned, got", err)
+	}
+	if err = g.Connect(banned); err != errPeerBanned {
+		t.Fatal("expected errPeerBanned, got", err)
+	}
+
+	// bans are persisted
+	g.Close()
+	g, err = New("localhost:0", false, g.persistDir, types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if len(g.Bans()) != 1 {
+		t.Fatal("bans were not loaded")
+	}
+
+	if err = g.UnbanPeer(banned); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.UnbanPeer(banned); err != errNotBanned {
+		t.Fatal("expected errNotBanned, got", err)
+	}
+	if len(g.Bans()) != 0 {
+		t.Fatal("host was not unbanned")
+	}
+}
+
+// TestPenalizePeer tests that a host is banned once its misbehavior score
+// reaches the ban score, and that local peers are not penalized.
+func TestPenalizePeer(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	g.PenalizePeer("127.0.0.1:9988", modules.PeerBanScore, "test")
+	if len(g.Bans()) != 0 {
+		t.Fatal("local peer should not be banned")
+	}
+
+	const addr = modules.NetAddress("111.111.111.111:9988")
+	g.PenalizePeer(addr, modules.PeerBanScore-1, "test")
+	if len(g.Bans()) != 0 {
+		t.Fatal("peer should not be banned yet")
+	}
+	g.PenalizePeer(addr, 1, "test")
+	if bans := g.Bans(); len(bans) != 1 || bans[0].Host != addr.Host() {
+		t.Fatal("peer should be banned, got", bans)
+	}
+	g.mu.Lock()
+	_, scored := g.scores[addr.Host()]
+	g.mu.Unlock()
+	if scored {
+		t.Fatal("score should be reset once banned")
+	}
+}
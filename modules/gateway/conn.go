@@ -23,11 +23,13 @@ func (pc peerConn) RPCAddr() modules.NetAddress {
 // handles things like clean shutdown, fast shutdown, and chooses the correct
 // communication protocol.
 func (g *Gateway) dial(addr modules.NetAddress) (net.Conn, error) {
-	dialer := &net.Dialer{
-		Cancel:  g.threads.StopChan(),
-		Timeout: dialTimeout,
+	var conn net.Conn
+	var err error
+	if g.proxy != nil {
+		conn, err = g.proxy.dial(g.transport, string(addr), g.threads.StopChan())
+	} else {
+		conn, err = g.transport.Dial(string(addr), dialTimeout, g.threads.StopChan())
 	}
-	conn, err := dialer.Dial("tcp", string(addr))
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +23,19 @@ const (
 
 	// saveFrequency defines how often the gateway saves its persistence.
 	saveFrequency = time.Minute * 2
+
+	// addrMaxRetries is the number of failed connection attempts after which
+	// a node that was never connected to can be evicted from the new table.
+	addrMaxRetries = 3
+
+	// addrMaxFailures is the number of failed connection attempts after which
+	// a node that was not connected to in addrMaxFailureAge can be evicted
+	// from the new table.
+	addrMaxFailures = 10
+
+	// addrMaxFailureAge is the time after the last successful connection to a
+	// node, from which on the node can be evicted after addrMaxFailures.
+	addrMaxFailureAge = 7 * 24 * time.Hour
 )
 
 var (
@@ -34,6 +47,14 @@ var (
 	// to replace the wantConn with a NetAddr.
 	HandshakNetAddressUpgrade = build.NewVersion(1, 0, 2)
 
+	// HandshakeEncryptionUpgrade is the version where we extended the handshake,
+	// to negotiate an encrypted and authenticated transport.
+	HandshakeEncryptionUpgrade = build.NewPrereleaseVersion(1, 0, 7, "alpha")
+
+	// HandshakeServicesUpgrade is the version where we extended the handshake,
+	// to exchange the services offered by both peers.
+	HandshakeServicesUpgrade = build.NewPrereleaseVersion(1, 0, 8, "alpha")
+
 	// fastNodePurgeDelay defines the amount of time that is waited between each
 	// iteration of the purge loop when the gateway has enough nodes to be
 	// needing to purge quickly.
@@ -99,6 +120,62 @@ var (
 		Dev:      int(40),
 		Testing:  int(20),
 	}).(int)
+
+	// addrBucketSize defines the number of nodes that fit in a single bucket
+	// of the address manager.
+	addrBucketSize = build.Select(build.Var{
+		Standard: int(64),
+		Dev:      int(32),
+		Testing:  int(16),
+	}).(int)
+
+	// newBucketCount defines the number of buckets of the new table of the
+	// address manager.
+	newBucketCount = build.Select(build.Var{
+		Standard: int(1024),
+		Dev:      int(64),
+		Testing:  int(16),
+	}).(int)
+
+	// newBucketsPerSourceGroup defines the number of buckets of the new table
+	// that the nodes shared by peers of a single network group can end up in.
+	newBucketsPerSourceGroup = build.Select(build.Var{
+		Standard: int(64),
+		Dev:      int(8),
+		Testing:  int(4),
+	}).(int)
+
+	// triedBucketCount defines the number of buckets of the tried table of the
+	// address manager.
+	triedBucketCount = build.Select(build.Var{
+		Standard: int(256),
+		Dev:      int(16),
+		Testing:  int(8),
+	}).(int)
+
+	// triedBucketsPerGroup defines the number of buckets of the tried table
+	// that the nodes of a single network group can end up in.
+	triedBucketsPerGroup = build.Select(build.Var{
+		Standard: int(8),
+		Dev:      int(4),
+		Testing:  int(2),
+	}).(int)
+
+	// banDuration defines how long a host is banned, if it reached the
+	// misbehavior ban score, or if no duration is given for a manual ban.
+	banDuration = build.Select(build.Var{
+		Standard: 24 * time.Hour,
+		Dev:      10 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// peerScoreDecayInterval defines how long it takes for the misbehavior
+	// score of a host to decrease by one point.
+	peerScoreDecayInterval = build.Select(build.Var{
+		Standard: time.Minute,
+		Dev:      10 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
 )
 
 var (
@@ -248,3 +325,30 @@ var (
 		Testing:  100 * time.Millisecond,
 	}).(time.Duration)
 )
+
+var (
+	// dnsSeedDelay defines the amount of time that is waited between
+	// checks of whether the node list has to be seeded using the DNS seeds.
+	dnsSeedDelay = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  200 * time.Millisecond,
+	}).(time.Duration)
+
+	// dnsSeedTimeout defines the amount of time after which resolving a
+	// DNS seed is aborted.
+	dnsSeedTimeout = build.Select(build.Var{
+		Standard: 30 * time.Second,
+		Dev:      10 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// staleNodeListAge defines the amount of time after which the node list
+	// is considered stale, if the gateway didn't successfully connect to any
+	// of its nodes within that time, and has no outbound peers.
+	staleNodeListAge = build.Select(build.Var{
+		Standard: 24 * time.Hour,
+		Dev:      time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+)
@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// DNS seeds are host names whose A and AAAA records point to nodes of the
+// network. Unlike bootstrap peers, which are compiled into the binary, the
+// nodes of a DNS seed can be changed by updating its DNS records. The seeds
+// are resolved whenever the node list is empty or stale, and the resolved
+// nodes are added to the new table of the address manager, using the seed
+// as their source. As such, a single seed can only fill a limited number of
+// buckets of the address manager.
+//
+// DNS seeds are never resolved while outbound connections are dialed through
+// a proxy, as resolving them locally would leak the DNS queries past the
+// proxy, while a SOCKS5 proxy can only connect to a single address of a host
+// name, rather than resolve all of its records.
+
+// resolver resolves host names into IP addresses.
+// It is implemented by net.Resolver.
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// nodeListStale returns true if the node list is empty, or if the gateway
+// didn't successfully connect to any of its nodes within staleNodeListAge.
+// As the last success of a node is only updated when connecting to it, the
+// node list is never stale while the gateway has an outbound peer.
+func (g *Gateway) nodeListStale() bool {
+	for _, p := range g.peers {
+		if !p.Inbound {
+			return false
+		}
+	}
+	cutoff := types.CurrentTimestamp() - types.Timestamp(staleNodeListAge/time.Second)
+	for _, n := range g.nodes {
+		if n.LastSuccess > cutoff {
+			return false
+		}
+	}
+	return true
+}
+
+// managedResolveSeed resolves the DNS seed, which is a host:port address,
+// into the addresses of the nodes it points to.
+func (g *Gateway) managedResolveSeed(r resolver, seed modules.NetAddress) ([]modules.NetAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsSeedTimeout)
+	defer cancel()
+	// Abort the lookup if the gateway is shutting down.
+	go func() {
+		select {
+		case <-g.threads.StopChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ips, err := r.LookupIPAddr(ctx, seed.Host())
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]modules.NetAddress, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, modules.NetAddress(net.JoinHostPort(ip.IP.String(), seed.Port())))
+	}
+	return addrs, nil
+}
+
+// managedSeedNodes resolves all DNS seeds, and adds the resolved nodes to the
+// node list.
+func (g *Gateway) managedSeedNodes() {
+	g.mu.RLock()
+	seeds := g.dnsSeeds
+	r := g.resolver
+	g.mu.RUnlock()
+
+	for _, seed := range seeds {
+		addrs, err := g.managedResolveSeed(r, seed)
+		if err != nil {
+			g.log.Printf("WARN: failed to resolve DNS seed '%v': %v", seed, err)
+			continue
+		}
+		added := 0
+		g.mu.Lock()
+		for _, addr := range addrs {
+			err := g.addNodeFrom(addr, seed)
+			if err == nil {
+				added++
+			} else if err != errNodeExists && err != errBucketFull {
+				g.log.Debugf("WARN: failed to add node '%v' of DNS seed '%v': %v", addr, seed, err)
+			}
+		}
+		g.mu.Unlock()
+		g.log.Printf("INFO: DNS seed '%v' resolved to %v nodes, %v of which were added", seed, len(addrs), added)
+	}
+}
+
+// permanentDNSSeeder seeds the node list using the DNS seeds, whenever the
+// node list is empty or stale. The node list is never seeded in private
+// mode, as only nodes on the allowlist can be peers in that mode, nor when
+// outbound connections are dialed through a proxy.
+func (g *Gateway) permanentDNSSeeder(closeChan chan struct{}) {
+	defer close(closeChan)
+
+	for {
+		g.mu.RLock()
+		seed := len(g.dnsSeeds) > 0 && !g.private && g.proxy == nil && g.nodeListStale()
+		g.mu.RUnlock()
+		if seed {
+			g.managedSeedNodes()
+		}
+
+		select {
+		case <-time.After(dnsSeedDelay):
+		case <-g.threads.StopChan():
+			// The gateway is shutting down, close the thread.
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// testResolver is a resolver which resolves host names using a static map,
+// counting the lookups.
+type testResolver struct {
+	mu      sync.Mutex
+	hosts   map[string][]net.IPAddr
+	lookups int
+}
+
+// LookupIPAddr implements resolver.LookupIPAddr
+func (r *testResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	ips, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host: " + host)
+	}
+	return ips, nil
+}
+
+// numLookups returns the number of lookups made so far.
+func (r *testResolver) numLookups() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+// TestNodeListStale tests when the node list is considered stale.
+func TestNodeListStale(t *testing.T) {
+	g := &Gateway{
+		nodes: make(map[modules.NetAddress]*node),
+		peers: make(map[modules.NetAddress]*peer),
+	}
+	if !g.nodeListStale() {
+		t.Fatal("empty node list should be stale")
+	}
+	g.nodes["111.111.111.111:1"] = &node{NetAddress: "111.111.111.111:1"}
+	if !g.nodeListStale() {
+		t.Fatal("node list without successful connections should be stale")
+	}
+	g.nodes["111.111.111.111:2"] = &node{
+		NetAddress:  "111.111.111.111:2",
+		LastSuccess: types.CurrentTimestamp() - types.Timestamp(2*staleNodeListAge/time.Second),
+	}
+	if !g.nodeListStale() {
+		t.Fatal("node list without recent successful connections should be stale")
+	}
+
+	// connected outbound peers keep the node list fresh,
+	// also when the connection was made a long time ago
+	g.peers["111.111.111.111:2"] = &peer{Peer: modules.Peer{NetAddress: "111.111.111.111:2", Inbound: true}}
+	if !g.nodeListStale() {
+		t.Fatal("inbound peers should not keep the node list fresh")
+	}
+	g.peers["111.111.111.111:2"].Inbound = false
+	if g.nodeListStale() {
+		t.Fatal("node list with an outbound peer should not be stale")
+	}
+	delete(g.peers, "111.111.111.111:2")
+
+	g.nodes["111.111.111.111:3"] = &node{
+		NetAddress:  "111.111.111.111:3",
+		LastSuccess: types.CurrentTimestamp(),
+	}
+	if g.nodeListStale() {
+		t.Fatal("node list with a recent successful connection should not be stale")
+	}
+}
+
+// TestDNSSeeds tests that a gateway seeds its empty node list using its DNS
+// seeds, and stops doing so once the node list is no longer stale.
+func TestDNSSeeds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	r := &testResolver{hosts: map[string][]net.IPAddr{
+		"seed1.rivine.test": {{IP: net.ParseIP("127.0.0.1")}},
+		"seed2.rivine.test": {{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}},
+	}}
+	seeds := []modules.NetAddress{
+		modules.NetAddress(net.JoinHostPort("seed1.rivine.test", g2.Address().Port())),
+		modules.NetAddress(net.JoinHostPort("seed2.rivine.test", "23112")),
+		"unknown.rivine.test:23112",
+	}
+	g1.mu.Lock()
+	g1.dnsSeeds = seeds
+	g1.resolver = r
+	g1.mu.Unlock()
+
+	// the resolved nodes should be added to the node list,
+	// the node of the first seed being g2
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		g1.mu.RLock()
+		defer g1.mu.RUnlock()
+		for _, addr := range []modules.NetAddress{
+			modules.NetAddress(net.JoinHostPort("127.0.0.1", g2.Address().Port())),
+			"127.0.0.1:23112",
+			"[::1]:23112",
+		} {
+			n, ok := g1.nodes[addr]
+			if !ok {
+				return errors.New("node was not added: " + string(addr))
+			}
+			if n.Source == "" {
+				return errors.New("seeded node should have the seed as source: " + string(addr))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once connected to one of the nodes, the seeds are no longer resolved
+	if err := g1.Connect(modules.NetAddress(net.JoinHostPort("127.0.0.1", g2.Address().Port()))); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * dnsSeedDelay)
+	lookups := r.numLookups()
+	time.Sleep(5 * dnsSeedDelay)
+	if r.numLookups() != lookups {
+		t.Fatal("DNS seeds should not be resolved once the node list is no longer stale")
+	}
+}
+
+// TestDNSSeedsProxy tests that a gateway doesn't resolve its DNS seeds while
+// outbound connections are dialed through a proxy.
+func TestDNSSeedsProxy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	proxy, err := parseProxy("127.0.0.1:1080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testResolver{hosts: map[string][]net.IPAddr{
+		"seed.rivine.test": {{IP: net.ParseIP("127.0.0.1")}},
+	}}
+	g.mu.Lock()
+	g.proxy = proxy
+	g.dnsSeeds = []modules.NetAddress{"seed.rivine.test:23112"}
+	g.resolver = r
+	g.mu.Unlock()
+
+	time.Sleep(5 * dnsSeedDelay)
+	if r.numLookups() != 0 {
+		t.Fatal("DNS seeds should not be resolved while a proxy is used")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
@@ -69,25 +70,31 @@ import (
 	// time, the attacked node should already have its set of outbound peers,
 	// limiting the amount of damage that the attacker can do.
 	//
+	// Furthermore, the nodelist is divided into buckets by the address manager,
+	// see addrmanager.go. The nodes shared by a single peer, or located in a
+	// single network, can only occupy a limited number of those buckets. And
+	// when forming outbound connections, nodes in networks which none of the
+	// outbound peers are part of are preferred.
+	//
 	// To limit DNS-based tomfoolry, nodes are only added to the nodelist if their
 	// connection information takes the form of an IP address.
 	//
+	// The gateway encrypts and authenticates the connections with all peers
+	// which support it, see transport.go. Though the gateway participates in a
+	// flood network, practical attacks have been demonstrated which have been
+	// able to confuse nodes by manipulating messages from their peers.
+	// Encryption + authentication makes such attacks more difficult.
+	//
 	// Some research has been done on Bitcoin's flood networks. The more relevant
 	// research has been listed below. The papers listed first are more relevant.
 	//     Eclipse Attacks on Bitcoin's Peer-to-Peer Network (Heilman, Kendler, Zohar, Goldberg)
 	//     Stubborn Mining: Generalizing Selfish Mining and Combining with an Eclipse Attack (Nayak, Kumar, Miller, Shi)
 	//     An Overview of BGP Hijacking (https://www.bishopfox.com/blog/2015/08/an-overview-of-bgp-hijacking/)
 
-	// TODO: Currently the gateway does not do much in terms of bucketing. The
-	// gateway should make sure that it has outbound peers from a wide range of IP
-	// addresses, and when kicking inbound peers it shouldn't just favor kicking
+	// TODO: When kicking inbound peers the gateway shouldn't just favor kicking
 	// peers of the same IP address, it should favor kicking peers of the same ip
 	// address range.
 	//
-	// TODO: There is no public key exhcange,
-	// so communications cannot be effectively encrypted or authenticated.
-	// The nodes must have some way to share keys.
-	//
 	// TODO: Gateway hostname discovery currently has significant centralization,
 	// namely the fallback is a single third-party website that can easily form any
 	// response it wants. Instead, multiple TLS-protected third party websites
@@ -99,12 +106,6 @@ import (
 	// correct hostname. This means that you may give the remote peer the wrong
 	// hostname, which means they will not be able to dial you back, which means
 	// they will not add you to their node list.
-	//
-	// TODO: The gateway should encrypt and authenticate all communications. Though
-	// the gateway participates in a flood network, practical attacks have been
-	// demonstrated which have been able to confuse nodes by manipulating messages
-	// from their peers. Encryption + authentication would have made the attack
-	// more difficult.
 
 	siasync "github.com/rivine/rivine/sync"
 )
@@ -123,11 +124,17 @@ type Gateway struct {
 	// handlers are the RPCs that the Gateway can handle.
 	//
 	// initRPCs are the RPCs that the Gateway calls upon connecting to a peer.
+	//
+	// rpcNames are the full names of the RPCs that the Gateway can handle.
 	handlers map[rpcID]modules.RPCFunc
 	initRPCs map[string]modules.RPCFunc
+	rpcNames map[rpcID]string
 
 	// nodes is the set of all known nodes (i.e. potential peers).
 	//
+	// buckets are the nodes grouped per bucket of the address manager, and
+	// contain the same nodes as the node list.
+	//
 	// peers are the nodes that the gateway is currently connected to.
 	//
 	// peerTG is a special thread group for tracking peer connections, and will
@@ -141,9 +148,25 @@ type Gateway struct {
 	// and would block any threads.Flush() calls. So a second threadgroup is
 	// added which handles clean-shutdown for the peers, without blocking
 	// threads.Flush() calls.
-	nodes  map[modules.NetAddress]*node
-	peers  map[modules.NetAddress]*peer
-	peerTG siasync.ThreadGroup
+	nodes   map[modules.NetAddress]*node
+	buckets map[addrBucket]map[modules.NetAddress]*node
+	peers   map[modules.NetAddress]*peer
+	peerTG  siasync.ThreadGroup
+
+	// bans are the hosts that the gateway refuses to connect with.
+	//
+	// scores are the misbehavior scores of the hosts of peers,
+	// which lead to a ban once they reach modules.PeerBanScore.
+	bans   map[string]modules.PeerBan
+	scores map[string]peerScore
+
+	// allowlist contains the addresses, hosts and identity keys of the nodes
+	// which can be peers while the gateway runs in private mode.
+	allowlist map[string]struct{}
+	private   bool
+
+	// settings are the peer limits and connection policy of the gateway.
+	settings modules.GatewaySettings
 
 	// Utilities.
 	log        *persist.Logger
@@ -154,6 +177,36 @@ type Gateway struct {
 	// Unique ID
 	id gatewayID
 
+	// identity is the key which identifies the gateway to the peers it
+	// has an encrypted connection with.
+	//
+	// encryption defines if and when connections with peers are encrypted.
+	identity   crypto.SecretKey
+	encryption modules.EncryptionMode
+
+	// services are the services the gateway advertises to its peers.
+	services modules.ServiceFlags
+
+	// addrKey is the secret key which maps the nodes to the buckets of the
+	// address manager.
+	addrKey crypto.Hash
+
+	// proxy is the SOCKS5 proxy through which outbound connections are
+	// dialed, if any.
+	//
+	// noAdvertise indicates that the gateway does not discover its external
+	// address, nor forwards its port, such that its address isn't advertised.
+	proxy       *socksProxy
+	noAdvertise bool
+
+	// dnsSeeds are the DNS seeds which are resolved, using the resolver,
+	// when the node list is empty or stale.
+	dnsSeeds []modules.NetAddress
+	resolver resolver
+
+	// transport creates the inbound and outbound connections of the gateway.
+	transport Transport
+
 	bcInfo         types.BlockchainInfo
 	chainCts       types.ChainConstants
 	genesisBlockID types.BlockID
@@ -190,8 +243,53 @@ func (g *Gateway) Close() error {
 	return g.saveSync()
 }
 
+// Options contains the optional configuration of the Gateway,
+// which has to be known before the Gateway is started.
+type Options struct {
+	// Proxy is the address of a SOCKS5 proxy, in the
+	// [user:password@]host:port format, through which all outbound
+	// connections are dialed. Outbound connections are dialed
+	// directly if no proxy is given.
+	Proxy string
+
+	// NoAdvertise disables the discovery of the external address of the
+	// gateway, as well as the forwarding of its port using UPnP, such that
+	// the gateway only announces its local address to its peers.
+	NoAdvertise bool
+
+	// DNSSeeds are host:port addresses, of which the A and AAAA records
+	// are resolved into nodes listening on the given port, whenever the
+	// node list is empty or stale. Like the bootstrap peers, DNS seeds
+	// are only used if the gateway bootstraps. DNS seeds are not used
+	// if a proxy is given, as they would be resolved past the proxy.
+	DNSSeeds []modules.NetAddress
+
+	// Transport is used to listen for inbound connections and to dial
+	// outbound connections. TCP is used if no transport is given.
+	Transport Transport
+}
+
 // New returns an initialized Gateway.
 func New(addr string, bootstrap bool, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants, bootstrapPeers []modules.NetAddress) (*Gateway, error) {
+	return NewWithOptions(addr, bootstrap, persistDir, bcInfo, chainCts, bootstrapPeers, Options{})
+}
+
+// NewWithOptions returns an initialized Gateway, configured using the given options.
+func NewWithOptions(addr string, bootstrap bool, persistDir string, bcInfo types.BlockchainInfo, chainCts types.ChainConstants, bootstrapPeers []modules.NetAddress, opts Options) (*Gateway, error) {
+	var proxy *socksProxy
+	if opts.Proxy != "" {
+		var err error
+		proxy, err = parseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		transport = tcpTransport{}
+	}
+
 	// Create the directory if it doesn't exist.
 	err := os.MkdirAll(persistDir, 0700)
 	if err != nil {
@@ -201,12 +299,28 @@ func New(addr string, bootstrap bool, persistDir string, bcInfo types.Blockchain
 	g := &Gateway{
 		handlers: make(map[rpcID]modules.RPCFunc),
 		initRPCs: make(map[string]modules.RPCFunc),
+		rpcNames: make(map[rpcID]string),
 
-		nodes: make(map[modules.NetAddress]*node),
-		peers: make(map[modules.NetAddress]*peer),
+		nodes:   make(map[modules.NetAddress]*node),
+		buckets: make(map[addrBucket]map[modules.NetAddress]*node),
+		peers:   make(map[modules.NetAddress]*peer),
+
+		bans:   make(map[string]modules.PeerBan),
+		scores: make(map[string]peerScore),
+
+		allowlist: make(map[string]struct{}),
+
+		settings: defaultSettings(),
+		services: modules.DefaultServices,
 
 		persistDir: persistDir,
 
+		proxy:       proxy,
+		noAdvertise: opts.NoAdvertise,
+
+		resolver:  net.DefaultResolver,
+		transport: transport,
+
 		bcInfo:         bcInfo,
 		chainCts:       chainCts,
 		genesisBlockID: chainCts.GenesisBlockID(),
@@ -251,6 +365,11 @@ func New(addr string, bootstrap bool, persistDir string, bcInfo types.Blockchain
 		g.UnregisterConnectCall("ShareNodes")
 	})
 
+	// Load the identity key, or generate it if it doesn't exist yet.
+	if err := g.loadIdentity(); err != nil {
+		return nil, err
+	}
+
 	// Load the old node list. If it doesn't exist, no problem, but if it does,
 	// we want to know about any errors preventing us from loading it.
 	if loadErr := g.load(); loadErr != nil && !os.IsNotExist(loadErr) {
@@ -270,6 +389,7 @@ func New(addr string, bootstrap bool, persistDir string, bcInfo types.Blockchain
 
 	// Add the bootstrap peers to the node list.
 	if bootstrap {
+		g.dnsSeeds = opts.DNSSeeds
 		for _, addr := range bootstrapPeers {
 			if err := addr.TryNameResolution(); err != nil {
 				// Bootstrap nodes can still be in IP:PORT notation so we might still be able to continue
@@ -284,7 +404,7 @@ func New(addr string, bootstrap bool, persistDir string, bcInfo types.Blockchain
 
 	// Create the listener which will listen for new connections from peers.
 	permanentListenClosedChan := make(chan struct{})
-	g.listener, err = net.Listen("tcp", addr)
+	g.listener, err = g.transport.Listen(addr)
 	if err != nil {
 		return nil, err
 	}
@@ -336,9 +456,28 @@ func New(addr string, bootstrap bool, persistDir string, bcInfo types.Blockchain
 	})
 	go g.permanentNodePurger(nodePurgerClosedChan)
 
-	// Spawn threads to take care of port forwarding and hostname discovery.
-	go g.threadedForwardPort(g.port)
-	go g.threadedLearnHostname()
+	// Spawn the DNS seeder and provide tools for ensuring clean shutdown.
+	dnsSeederClosedChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		<-dnsSeederClosedChan
+	})
+	go g.permanentDNSSeeder(dnsSeederClosedChan)
+
+	if g.proxy != nil {
+		g.log.Println("INFO: dialing outbound connections through proxy", g.proxy)
+		if len(g.dnsSeeds) > 0 {
+			g.log.Println("INFO: not resolving DNS seeds, as they would be resolved past the proxy")
+		}
+	}
+
+	// Spawn threads to take care of port forwarding and hostname discovery,
+	// unless the gateway shouldn't advertise its address.
+	if g.noAdvertise {
+		g.log.Println("INFO: not advertising our address, using", g.myAddr)
+	} else {
+		go g.threadedForwardPort(g.port)
+		go g.threadedLearnHostname()
+	}
 
 	return g, nil
 }
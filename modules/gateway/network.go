@@ -0,0 +1,37 @@
+package gateway
+
+import (
+	"net"
+	"time"
+)
+
+// Transport creates the connections of the gateway. By default the gateway
+// uses TCP, but an alternative transport, such as an in-memory network, can
+// be given when creating the gateway, in order to run many gateways within a
+// single process without opening any sockets.
+type Transport interface {
+	// Dial connects to the given host:port address. The dial is aborted
+	// once the timeout has passed, or once the cancel channel is closed.
+	Dial(addr string, timeout time.Duration, cancel <-chan struct{}) (net.Conn, error)
+
+	// Listen returns a listener which accepts the connections
+	// dialed to the given host:port address.
+	Listen(addr string) (net.Listener, error)
+}
+
+// tcpTransport is the default Transport of the gateway.
+type tcpTransport struct{}
+
+// Dial implements Transport.Dial
+func (tcpTransport) Dial(addr string, timeout time.Duration, cancel <-chan struct{}) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Cancel:  cancel,
+		Timeout: timeout,
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+// Listen implements Transport.Listen
+func (tcpTransport) Listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/NebulousLabs/fastrand"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
 )
 
 var (
@@ -18,13 +19,31 @@ var (
 )
 
 // A node represents a potential peer on the Sia network.
+//
+// Nodes which were an outbound peer are part of the tried table of the
+// address manager, all other nodes are part of the new table. Source is the
+// network group of the peer which shared the node, and is empty if the node
+// was not shared by a peer. The bucket of a node is derived when it is added
+// to the node list, and is not persisted.
 type node struct {
 	NetAddress      modules.NetAddress `json:"netaddress"`
 	WasOutboundPeer bool               `json:"wasoutboundpeer"`
+	Source          string             `json:"source,omitempty"`
+	LastAttempt     types.Timestamp    `json:"lastattempt,omitempty"`
+	LastSuccess     types.Timestamp    `json:"lastsuccess,omitempty"`
+	Attempts        int                `json:"attempts,omitempty"`
+
+	bucket addrBucket
 }
 
 // addNode adds an address to the set of nodes on the network.
 func (g *Gateway) addNode(addr modules.NetAddress) error {
+	return g.addNodeFrom(addr, "")
+}
+
+// addNodeFrom adds an address, shared by the peer with the given address,
+// to the new table of the address manager.
+func (g *Gateway) addNodeFrom(addr, source modules.NetAddress) error {
 	if addr == g.myAddr {
 		return errOurAddress
 	} else if _, exists := g.nodes[addr]; exists {
@@ -33,12 +52,17 @@ func (g *Gateway) addNode(addr modules.NetAddress) error {
 		return errors.New("address is not valid: " + string(addr))
 	} else if net.ParseIP(addr.Host()) == nil {
 		return errors.New("address must be an IP address: " + string(addr))
+	} else if g.isBanned(addr) {
+		return errPeerBanned
 	}
-	g.nodes[addr] = &node{
+	n := &node{
 		NetAddress:      addr,
 		WasOutboundPeer: false,
 	}
-	return nil
+	if source != "" {
+		n.Source = addressGroup(source)
+	}
+	return g.addNodeToTable(n)
 }
 
 // pingNode verifies that there is a reachable node at the provided address
@@ -61,10 +85,11 @@ func (g *Gateway) pingNode(addr modules.NetAddress) (err error) {
 
 // removeNode will remove a node from the gateway.
 func (g *Gateway) removeNode(addr modules.NetAddress) error {
-	if _, exists := g.nodes[addr]; !exists {
+	n, exists := g.nodes[addr]
+	if !exists {
 		return errors.New("no record of that node")
 	}
-	delete(g.nodes, addr)
+	g.removeNodeFromTable(n)
 	return nil
 }
 
@@ -75,15 +100,27 @@ func (g *Gateway) randomNode() (modules.NetAddress, error) {
 		return "", errNoPeers
 	}
 
-	// Select a random peer. Note that the algorithm below is roughly linear in
-	// the number of nodes known by the gateway, and this number can approach
-	// every node on the network. If the network gets large, this algorithm
-	// will either need to be refactored, or more likely a cap on the size of
-	// g.nodes will need to be added.
-	r := fastrand.Intn(len(g.nodes))
-	for node := range g.nodes {
-		if r <= 0 {
-			return node, nil
+	// Select a random node from a random bucket of either table, choosing
+	// either table with equal probability if both contain nodes. Note that the
+	// algorithm below is linear in the number of buckets of the address
+	// manager, and in the size of a single bucket.
+	var newBuckets, triedBuckets []map[modules.NetAddress]*node
+	for bucket, nodes := range g.buckets {
+		if bucket.tried {
+			triedBuckets = append(triedBuckets, nodes)
+		} else {
+			newBuckets = append(newBuckets, nodes)
+		}
+	}
+	buckets := newBuckets
+	if len(newBuckets) == 0 || (len(triedBuckets) != 0 && fastrand.Intn(2) == 0) {
+		buckets = triedBuckets
+	}
+	nodes := buckets[fastrand.Intn(len(buckets))]
+	r := fastrand.Intn(len(nodes))
+	for addr := range nodes {
+		if r == 0 {
+			return addr, nil
 		}
 		r--
 	}
@@ -97,10 +134,14 @@ func (g *Gateway) shareNodes(conn modules.PeerConn) error {
 	remoteNA := modules.NetAddress(conn.RemoteAddr().String())
 
 	// Assemble a list of nodes to send to the peer.
+	// No nodes are shared while running in private mode.
 	var nodes []modules.NetAddress
 	func() {
 		g.mu.RLock()
 		defer g.mu.RUnlock()
+		if g.private {
+			return
+		}
 
 		// Gather candidates for sharing.
 		gnodes := make([]modules.NetAddress, 0, len(g.nodes))
@@ -140,10 +181,15 @@ func (g *Gateway) requestNodes(conn modules.PeerConn) error {
 	}
 
 	g.mu.Lock()
+	if g.private {
+		// nodes are not learned from peers while running in private mode
+		g.mu.Unlock()
+		return nil
+	}
 	changed := false
 	for _, node := range nodes {
-		err := g.addNode(node)
-		if err != nil && err != errNodeExists && err != errOurAddress {
+		err := g.addNodeFrom(node, conn.RPCAddr())
+		if err != nil && err != errNodeExists && err != errOurAddress && err != errPeerBanned && err != errBucketFull {
 			g.log.Printf("WARN: peer '%v' sent the invalid addr '%v'", conn.RPCAddr(), node)
 		}
 		if err == nil {
@@ -262,6 +308,7 @@ func (g *Gateway) permanentNodeManager(closeChan chan struct{}) {
 
 		g.mu.RLock()
 		numNodes := len(g.nodes)
+		private := g.private
 		peer, err := g.randomOutboundPeer()
 		g.mu.RUnlock()
 		if err == errNoPeers {
@@ -276,7 +323,7 @@ func (g *Gateway) permanentNodeManager(closeChan chan struct{}) {
 		// Determine whether there are a satisfactory number of nodes in the
 		// nodelist. If there are not, use the random peer from earlier to
 		// expand the node list.
-		if numNodes < healthyNodeListLen {
+		if numNodes < healthyNodeListLen && !private {
 			err := g.managedRPC(peer, "ShareNodes", g.requestNodes)
 			if err != nil {
 				g.log.Debugf("WARN: RPC ShareNodes failed on peer %q: %v", peer, err)
@@ -183,9 +183,11 @@ func TestShareNodes(t *testing.T) {
 	// remove all nodes from both peers
 	g1.mu.Lock()
 	g1.nodes = map[modules.NetAddress]*node{}
+	g1.buckets = map[addrBucket]map[modules.NetAddress]*node{}
 	g1.mu.Unlock()
 	g2.mu.Lock()
 	g2.nodes = map[modules.NetAddress]*node{}
+	g2.buckets = map[addrBucket]map[modules.NetAddress]*node{}
 	g2.mu.Unlock()
 
 	// SharePeers should now return no peers
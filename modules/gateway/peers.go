@@ -9,6 +9,7 @@ import (
 
 	"github.com/NebulousLabs/fastrand"
 	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
@@ -18,6 +19,8 @@ var (
 	errPeerExists       = errors.New("already connected to this peer")
 	errPeerRejectedConn = errors.New("peer rejected connection")
 	errPeerNoConnWanted = errors.New("peer did not want a connection")
+
+	errInvalidHeaderLength = errors.New("invalid data len received, cannot proceed with accept handshake")
 )
 
 var (
@@ -36,7 +39,8 @@ func (s insufficientVersionError) Error() string {
 
 type peer struct {
 	modules.Peer
-	sess streamSession
+	sess  streamSession
+	stats *peerStats
 }
 
 // sessionHeader is sent as the initial exchange between peers.
@@ -55,6 +59,17 @@ type sessionHeader struct {
 	WantConn  bool
 }
 
+// setIdentity stores the authenticated identity of the peer,
+// if the connection with the peer is encrypted.
+func (p *peer) setIdentity(remoteInfo remoteInfo) {
+	if !remoteInfo.Encrypted {
+		return
+	}
+	identityKey := types.Ed25519PublicKey(remoteInfo.IdentityKey)
+	p.Encrypted = true
+	p.IdentityKey = &identityKey
+}
+
 func (p *peer) open() (modules.PeerConn, error) {
 	conn, err := p.sess.Open()
 	if err != nil {
@@ -134,10 +149,26 @@ func (g *Gateway) threadedAcceptConn(conn net.Conn) {
 
 	addr := modules.NetAddress(conn.RemoteAddr().String())
 	g.log.Debugf("INFO: %v wants to connect", addr)
+	if g.managedIsBanned(addr) {
+		g.log.Debugf("INFO: %v wanted to connect but is banned", addr)
+		conn.Close()
+		return
+	}
+	g.mu.RLock()
+	inboundDisabled := g.settings.DisableInbound
+	g.mu.RUnlock()
+	if inboundDisabled {
+		g.log.Debugf("INFO: %v wanted to connect but inbound connections are disabled", addr)
+		conn.Close()
+		return
+	}
 
 	remoteInfo, err := g.acceptConnHandshake(conn, g.bcInfo.ProtocolVersion, g.id)
 	if err != nil {
 		g.log.Debugf("INFO: %v wanted to connect but handshake failed: %v", addr, err)
+		if err == errPeerGenesisID || err == errInvalidHeaderLength {
+			g.PenalizePeer(addr, modules.PenaltyBadHandshake, "bad handshake: "+err.Error())
+		}
 		conn.Close()
 		return
 	}
@@ -177,14 +208,30 @@ func (g *Gateway) managedAcceptConnPeer(conn net.Conn, remoteInfo remoteInfo) er
 			// by the host but keeping note of the port number so we can call back
 			NetAddress: remoteAddr,
 			Version:    remoteInfo.Version,
+			Services:   remoteInfo.Services,
 		},
-		sess: newSmuxServer(conn),
 	}
+	peer.setIdentity(remoteInfo)
 
 	g.mu.Lock()
+	if !g.isAllowedPeer(peer) {
+		g.mu.Unlock()
+		return errPeerNotAllowed
+	}
+	if err := g.checkInboundPeer(remoteAddr); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	peer.sess = newSmuxServer(peer.trackConn(remoteInfo))
 	g.acceptPeer(peer)
 	g.mu.Unlock()
 
+	// A peer which doesn't want its address to be advertised is not added
+	// to our node list, such that it isn't shared with other peers either.
+	if remoteInfo.Services.Has(modules.ServiceNoAdvertise) {
+		return nil
+	}
+
 	// Attempt to ping the supplied address. If successful, we will add
 	// remoteInfo.NetAddress to our node list after accepting the peer. We do this in a
 	// goroutine so that we can start communicating with the peer immediately.
@@ -210,7 +257,7 @@ func (g *Gateway) managedAcceptConnPeer(conn net.Conn, remoteInfo remoteInfo) er
 // peers, then adds the peer to the peer list.
 func (g *Gateway) acceptPeer(p *peer) {
 	// If we are not fully connected, add the peer without kicking any out.
-	if len(g.peers) < fullyConnectedThreshold {
+	if len(g.peers) < g.settings.MaxPeers {
 		g.addPeer(p)
 		return
 	}
@@ -251,11 +298,29 @@ func (g *Gateway) acceptPeer(p *peer) {
 type remoteInfo struct {
 	Version    build.ProtocolVersion
 	NetAddress modules.NetAddress
+
+	// Conn is the connection to use for the session with the peer,
+	// which is encrypted if Encrypted is true,
+	// in which case IdentityKey is the authenticated identity of the peer.
+	Conn        net.Conn
+	Encrypted   bool
+	IdentityKey crypto.PublicKey
+
+	// Services are the services advertised by the peer.
+	Services modules.ServiceFlags
+
+	// stats tracks the bandwidth of the raw connection with the peer,
+	// starting with the handshake.
+	stats *peerStats
 }
 
 // connectHandshake performs the version handshake and should be called
 // on the side making the connection request.
 func (g *Gateway) connectHandshake(conn net.Conn, version build.ProtocolVersion, uniqueID gatewayID, netAddress modules.NetAddress, wantConn bool) (remoteInfo remoteInfo, err error) {
+	// track the bytes on the wire, such that the handshakes
+	// and the encryption overhead are accounted for as well
+	sc := newStatsConn(conn)
+	conn, remoteInfo.Conn, remoteInfo.stats = sc, sc, sc.stats
 	// Send our version header.
 	if err = encoding.WriteObject(conn, version); err != nil {
 		err = fmt.Errorf("failed to write version header: %v", err)
@@ -324,6 +389,12 @@ func (g *Gateway) connectHandshake(conn net.Conn, version build.ProtocolVersion,
 	if err == nil && !theirs.WantConn {
 		err = errPeerNoConnWanted
 	}
+	if err == nil && wantConn {
+		err = g.transportHandshake(conn, lowestVersion, true, &remoteInfo)
+	}
+	if err == nil && wantConn {
+		err = g.servicesHandshake(remoteInfo.Conn, lowestVersion, true, &remoteInfo)
+	}
 	return
 }
 
@@ -378,6 +449,10 @@ func (g *Gateway) acceptConnHandshake(conn net.Conn, version build.ProtocolVersi
 		theirs sessionHeader
 		legacy bool
 	)
+	// track the bytes on the wire, such that the handshakes
+	// and the encryption overhead are accounted for as well
+	sc := newStatsConn(conn)
+	conn, remoteInfo.Conn, remoteInfo.stats = sc, sc, sc.stats
 	remoteInfo.Version, theirs, legacy, err = g.readRemoteHeaders(conn)
 	if err != nil {
 		return
@@ -402,7 +477,11 @@ func (g *Gateway) acceptConnHandshake(conn net.Conn, version build.ProtocolVersi
 			err = errPeerGenesisID
 		} else if theirs.UniqueID == uniqueID {
 			err = errOurAddress
+		} else if g.managedEncryptionMode() == modules.EncryptionRequired {
+			err = errEncryptionRequired
 		}
+		// legacy peers do not advertise their services
+		remoteInfo.Services = modules.DefaultServices
 		var legacyErr error
 		remoteInfo.NetAddress, legacyErr = g.legacyAcceptConnectHandshake(conn, version, uniqueID, err == nil)
 		if legacyErr != nil {
@@ -465,6 +544,12 @@ func (g *Gateway) acceptConnHandshake(conn net.Conn, version build.ProtocolVersi
 	if err == nil && !theirs.WantConn {
 		err = errPeerNoConnWanted
 	}
+	if err == nil {
+		err = g.transportHandshake(conn, lowestVersion, false, &remoteInfo)
+	}
+	if err == nil {
+		err = g.servicesHandshake(remoteInfo.Conn, lowestVersion, false, &remoteInfo)
+	}
 	return
 }
 
@@ -499,7 +584,7 @@ func (g *Gateway) readRemoteHeaders(conn net.Conn) (remoteVersion build.Protocol
 
 	// should equal version size
 	if dataLen != build.EncodedVersionLength {
-		err = errors.New("invalid data len received, cannot proceed with accept handshake")
+		err = errInvalidHeaderLength
 		return
 	}
 
@@ -632,12 +717,19 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	if net.ParseIP(addr.Host()) == nil {
 		return errors.New("address must be an IP address")
 	}
-	g.mu.RLock()
+	g.mu.Lock()
 	_, exists := g.peers[addr]
-	g.mu.RUnlock()
+	banned := g.isBanned(addr)
+	if !exists && !banned {
+		g.markAttempt(addr)
+	}
+	g.mu.Unlock()
 	if exists {
 		return errPeerExists
 	}
+	if banned {
+		return errPeerBanned
+	}
 
 	// Dial the peer and perform peer initialization.
 	conn, err := g.dial(addr)
@@ -660,17 +752,28 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	g.addPeer(&peer{
+	peer := &peer{
 		Peer: modules.Peer{
 			Inbound:    false,
 			Local:      addr.IsLocal(),
 			NetAddress: addr,
 			Version:    remoteInfo.Version,
+			Services:   remoteInfo.Services,
 		},
-		sess: newSmuxClient(conn),
-	})
-	g.addNode(addr)
-	g.nodes[addr].WasOutboundPeer = true
+	}
+	peer.setIdentity(remoteInfo)
+	if !g.isAllowedPeer(peer) {
+		conn.Close()
+		return errPeerNotAllowed
+	}
+	peer.sess = newSmuxClient(peer.trackConn(remoteInfo))
+	g.addPeer(peer)
+	if err := g.addNode(addr); err == errBucketFull {
+		// the node is moved to the tried table right away,
+		// so there is no need to make room for it in the new table
+		g.addNodeToTable(&node{NetAddress: addr, WasOutboundPeer: true})
+	}
+	g.markTried(addr)
 
 	if err := g.saveSync(); err != nil {
 		g.log.Println("ERROR: Unable to save new outbound peer to gateway:", err)
@@ -726,7 +829,9 @@ func (g *Gateway) Disconnect(addr modules.NetAddress) error {
 	// Peer is removed from the peer list as well as the node list, to prevent
 	// the node from being re-connected while looking for a replacement peer.
 	delete(g.peers, addr)
-	delete(g.nodes, addr)
+	if n, exists := g.nodes[addr]; exists {
+		g.removeNodeFromTable(n)
+	}
 	g.mu.Unlock()
 
 	g.log.Println("INFO: disconnected from peer", addr)
@@ -739,7 +844,9 @@ func (g *Gateway) Peers() []modules.Peer {
 	defer g.mu.RUnlock()
 	var peers []modules.Peer
 	for _, p := range g.peers {
-		peers = append(peers, p.Peer)
+		mp := p.Peer
+		p.stats.apply(&mp)
+		peers = append(peers, mp)
 	}
 	return peers
 }
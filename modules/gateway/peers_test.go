@@ -546,7 +546,8 @@ func TestPeerManager(t *testing.T) {
 	// g1's node list should only contain g2
 	g1.mu.Lock()
 	g1.nodes = map[modules.NetAddress]*node{}
-	g1.nodes[g2.Address()] = &node{NetAddress: g2.Address()}
+	g1.buckets = map[addrBucket]map[modules.NetAddress]*node{}
+	g1.addNodeToTable(&node{NetAddress: g2.Address()})
 	g1.mu.Unlock()
 
 	// when peerManager wakes up, it should connect to g2.
@@ -840,12 +841,18 @@ func TestPeerManagerOutboundSave(t *testing.T) {
 // TestBuildPeerManagerNodeList tests the buildPeerManagerNodeList method.
 func TestBuildPeerManagerNodeList(t *testing.T) {
 	g := &Gateway{
-		nodes: map[modules.NetAddress]*node{
-			"foo":  {NetAddress: "foo", WasOutboundPeer: true},
-			"bar":  {NetAddress: "bar", WasOutboundPeer: false},
-			"baz":  {NetAddress: "baz", WasOutboundPeer: true},
-			"quux": {NetAddress: "quux", WasOutboundPeer: false},
-		},
+		nodes:   make(map[modules.NetAddress]*node),
+		buckets: make(map[addrBucket]map[modules.NetAddress]*node),
+	}
+	for _, n := range []*node{
+		{NetAddress: "foo", WasOutboundPeer: true},
+		{NetAddress: "bar", WasOutboundPeer: false},
+		{NetAddress: "baz", WasOutboundPeer: true},
+		{NetAddress: "quux", WasOutboundPeer: false},
+	} {
+		if err := g.addNodeToTable(n); err != nil {
+			t.Fatal(err)
+		}
 	}
 	nodelist := g.buildPeerManagerNodeList()
 	// all outbound nodes should be at the front of the list
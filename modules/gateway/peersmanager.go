@@ -1,7 +1,6 @@
 package gateway
 
 import (
-	"github.com/NebulousLabs/fastrand"
 	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/modules"
 )
@@ -31,10 +30,7 @@ func (g *Gateway) managedPeerManagerConnect(addr modules.NetAddress) {
 			// race condition could mean that the peer was disconnected
 			// before this code block was reached.
 			p.Inbound = false
-			if n, ok := g.nodes[p.NetAddress]; ok && !n.WasOutboundPeer {
-				n.WasOutboundPeer = true
-				g.nodes[n.NetAddress] = n
-			}
+			g.markTried(p.NetAddress)
 			g.log.Debugf("[PMC] [SUCCESS] [%v] existing peer has been converted to outbound peer", addr)
 		}
 		g.mu.Unlock()
@@ -95,8 +91,9 @@ func (g *Gateway) permanentPeerManager(closedChan chan struct{}) {
 			g.mu.RLock()
 			numOutboundPeers := g.numOutboundPeers()
 			isOutboundPeer := g.peers[addr] != nil && !g.peers[addr].Inbound
+			settings := g.settings
 			g.mu.RUnlock()
-			if numOutboundPeers >= wellConnectedThreshold {
+			if numOutboundPeers >= settings.OutboundPeers {
 				g.log.Debugln("INFO: [PPM] Gateway has enough peers, sleeping.")
 				if !g.managedSleep(wellConnectedDelay) {
 					return
@@ -118,7 +115,7 @@ func (g *Gateway) permanentPeerManager(closedChan chan struct{}) {
 			// this peer is a local peer, do not consider it for an outbound peer.
 			// Sleep briefly to prevent the gateway from hogging the CPU if all
 			// peers are local.
-			if numOutboundPeers >= maxLocalOutboundPeers && addr.IsLocal() && build.Release != "testing" {
+			if numOutboundPeers >= settings.MaxLocalOutboundPeers && addr.IsLocal() && build.Release != "testing" {
 				g.log.Debugln("[PPM] Ignorning selected peer; this peer is local and we already have multiple outbound peers:", addr)
 				if !g.managedSleep(unwantedLocalPeerDelay) {
 					return
@@ -158,23 +155,30 @@ func (g *Gateway) permanentPeerManager(closedChan chan struct{}) {
 }
 
 // buildPeerManagerNodeList returns the gateway's node list in the order that
-// permanentPeerManager should attempt to connect to them.
+// permanentPeerManager should attempt to connect to them. In private mode
+// only the nodes with an address on the allowlist are returned.
+//
+// Nodes are selected from random buckets, with the nodes of the tried table
+// preceding the nodes of the new table. Nodes in a network group which none of
+// the outbound peers are part of are put in front of the list, as to keep the
+// outbound peers spread over many networks.
 func (g *Gateway) buildPeerManagerNodeList() []modules.NetAddress {
-	// flatten the node map, inserting in random order
-	nodes := make([]modules.NetAddress, len(g.nodes))
-	perm := fastrand.Perm(len(nodes))
-	for _, node := range g.nodes {
-		nodes[perm[0]] = node.NetAddress
-		perm = perm[1:]
-	}
-
-	// swap the outbound nodes to the front of the list
-	numOutbound := 0
-	for i, node := range nodes {
-		if g.nodes[node].WasOutboundPeer {
-			nodes[numOutbound], nodes[i] = nodes[i], nodes[numOutbound]
-			numOutbound++
+	newTable, triedTable := g.addrTables(func(n *node) bool {
+		return g.isAllowedAddress(n.NetAddress)
+	})
+	groups := g.outboundGroups()
+	var diverse, rest []modules.NetAddress
+	for _, table := range []map[int][]modules.NetAddress{triedTable, newTable} {
+		for len(table) != 0 {
+			addr := selectFromTable(table)
+			group := addressGroup(addr)
+			if _, exists := groups[group]; exists {
+				rest = append(rest, addr)
+				continue
+			}
+			groups[group] = struct{}{}
+			diverse = append(diverse, addr)
 		}
 	}
-	return nodes
+	return append(diverse, rest...)
 }
@@ -1,7 +1,9 @@
 package gateway
 
 import (
+	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/rivine/rivine/modules"
@@ -12,6 +14,12 @@ const (
 	// nodesFile is the name of the file that contains all seen nodes.
 	nodesFile = "nodes.json"
 
+	// bansFile is the name of the file that contains all banned hosts.
+	bansFile = "bans.json"
+
+	// allowlistFile is the name of the file that contains the allowlist.
+	allowlistFile = "allowlist.json"
+
 	// logFile is the name of the log file.
 	logFile = modules.GatewayDir + ".log"
 )
@@ -23,6 +31,20 @@ var persistMetadata = persist.Metadata{
 	Version: "1.3.0",
 }
 
+// bansMetadata contains the header and version strings that identify the
+// gateway bans file.
+var bansMetadata = persist.Metadata{
+	Header:  "Gateway Ban List",
+	Version: "1.0.0",
+}
+
+// allowlistMetadata contains the header and version strings that identify the
+// gateway allowlist file.
+var allowlistMetadata = persist.Metadata{
+	Header:  "Gateway Allowlist",
+	Version: "1.0.0",
+}
+
 // persistData returns the data in the Gateway that will be saved to disk.
 func (g *Gateway) persistData() (nodes []*node) {
 	for _, node := range g.nodes {
@@ -31,16 +53,60 @@ func (g *Gateway) persistData() (nodes []*node) {
 	return
 }
 
+// persistBans returns the bans in the Gateway that will be saved to disk.
+func (g *Gateway) persistBans() (bans []modules.PeerBan) {
+	g.pruneBans()
+	for _, ban := range g.bans {
+		bans = append(bans, ban)
+	}
+	return
+}
+
+// persistAllowlist returns the allowlist of the gateway, as it is persisted.
+func (g *Gateway) persistAllowlist() modules.PeerAllowlist {
+	allowlist := modules.PeerAllowlist{
+		Enabled: g.private,
+		Entries: make([]string, 0, len(g.allowlist)),
+	}
+	for entry := range g.allowlist {
+		allowlist.Entries = append(allowlist.Entries, entry)
+	}
+	sort.Strings(allowlist.Entries)
+	return allowlist
+}
+
 // load loads the Gateway's persistent data from disk.
 func (g *Gateway) load() error {
+	var allowlist modules.PeerAllowlist
+	err := persist.LoadJSON(allowlistMetadata, &allowlist, filepath.Join(g.persistDir, allowlistFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	g.private = allowlist.Enabled
+	for _, entry := range allowlist.Entries {
+		g.allowlist[entry] = struct{}{}
+	}
+
+	var bans []modules.PeerBan
+	err = persist.LoadJSON(bansMetadata, &bans, filepath.Join(g.persistDir, bansFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, ban := range bans {
+		g.bans[ban.Host] = ban
+	}
+	g.pruneBans()
+
 	var nodes []*node
-	err := persist.LoadJSON(persistMetadata, &nodes, filepath.Join(g.persistDir, nodesFile))
+	err = persist.LoadJSON(persistMetadata, &nodes, filepath.Join(g.persistDir, nodesFile))
 	if err != nil {
 		// COMPATv1.2.1
 		return g.loadv033persist()
 	}
 	for i := range nodes {
-		g.nodes[nodes[i].NetAddress] = nodes[i]
+		if err := g.addNodeToTable(nodes[i]); err != nil {
+			g.log.Debugf("WARN: dropped node %v while loading the node list: %v", nodes[i].NetAddress, err)
+		}
 	}
 	return nil
 }
@@ -48,7 +114,15 @@ func (g *Gateway) load() error {
 // saveSync stores the Gateway's persistent data on disk, and then syncs to
 // disk to minimize the possibility of data loss.
 func (g *Gateway) saveSync() error {
-	return persist.SaveJSON(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
+	err := persist.SaveJSON(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
+	if err != nil {
+		return err
+	}
+	err = persist.SaveJSON(bansMetadata, g.persistBans(), filepath.Join(g.persistDir, bansFile))
+	if err != nil {
+		return err
+	}
+	return persist.SaveJSON(allowlistMetadata, g.persistAllowlist(), filepath.Join(g.persistDir, allowlistFile))
 }
 
 // threadedSaveLoop periodically saves the gateway.
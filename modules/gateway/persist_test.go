@@ -39,6 +39,7 @@ func TestLoadv033(t *testing.T) {
 	buf.Reset()
 	g := &Gateway{
 		nodes:      make(map[modules.NetAddress]*node),
+		buckets:    make(map[addrBucket]map[modules.NetAddress]*node),
 		persistDir: filepath.Join("testdata", t.Name()),
 		log:        log,
 	}
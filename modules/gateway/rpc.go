@@ -55,6 +55,7 @@ func (g *Gateway) managedRPC(addr modules.NetAddress, name string, fn modules.RP
 		return err
 	}
 	defer conn.Close()
+	peer.stats.addRPC(name, true)
 
 	// write header
 	conn.SetDeadline(time.Now().Add(rpcStdDeadline))
@@ -88,6 +89,7 @@ func (g *Gateway) RegisterRPC(name string, fn modules.RPCFunc) {
 		build.Critical("RPC already registered: " + name)
 	}
 	g.handlers[handlerName(name)] = fn
+	g.rpcNames[handlerName(name)] = name
 }
 
 // UnregisterRPC unregisters an RPC and removes the corresponding RPCFunc from
@@ -99,6 +101,7 @@ func (g *Gateway) UnregisterRPC(name string) {
 		build.Critical("RPC not registered: " + name)
 	}
 	delete(g.handlers, handlerName(name))
+	delete(g.rpcNames, handlerName(name))
 }
 
 // RegisterConnectCall registers a name and RPCFunc to be called on a peer
@@ -177,7 +180,7 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 
 		// The handler is responsible for closing the connection, though a
 		// default deadline has been set.
-		go g.threadedHandleConn(conn)
+		go g.threadedHandleConn(p, conn)
 		if !g.managedSleep(peerRPCDelay) {
 			break
 		}
@@ -189,9 +192,9 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 	<-connClosedChan
 }
 
-// threadedHandleConn reads header data from a connection, then routes it to the
-// appropriate handler for further processing.
-func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
+// threadedHandleConn reads header data from a connection with the given peer,
+// then routes it to the appropriate handler for further processing.
+func (g *Gateway) threadedHandleConn(p *peer, conn modules.PeerConn) {
 	defer conn.Close()
 	if g.threads.Add() != nil {
 		return
@@ -209,7 +212,9 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 	// call registered handler for this ID
 	g.mu.RLock()
 	fn, ok := g.handlers[id]
+	name := g.rpcName(id)
 	g.mu.RUnlock()
+	p.stats.addRPC(name, false)
 	if !ok {
 		g.log.Debugf("WARN: incoming conn %v requested unknown RPC \"%v\"", conn.RPCAddr(), id)
 		return
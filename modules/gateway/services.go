@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+)
+
+var (
+	errConflictingServices = errors.New("a node can not serve the full history while being pruned")
+)
+
+// Services returns the services the gateway advertises to its peers.
+// A gateway which doesn't advertise its address always includes
+// ServiceNoAdvertise.
+func (g *Gateway) Services() modules.ServiceFlags {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.noAdvertise {
+		return g.services | modules.ServiceNoAdvertise
+	}
+	return g.services
+}
+
+// SetServices defines the services the gateway advertises to its peers.
+// Peers which are already connected keep the services they were told about.
+func (g *Gateway) SetServices(services modules.ServiceFlags) error {
+	if services.Has(modules.ServiceFullHistory | modules.ServicePruned) {
+		return errConflictingServices
+	}
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	g.services = services
+	g.mu.Unlock()
+	g.log.Printf("INFO: advertising services: [%v]", services)
+	return nil
+}
+
+// servicesHandshake exchanges the service flags of both peers, storing the
+// services of the remote peer in remoteInfo. Peers which do not support the
+// exchange are assumed to offer the default services of a full node.
+func (g *Gateway) servicesHandshake(conn net.Conn, lowestVersion build.ProtocolVersion, initiator bool, remoteInfo *remoteInfo) error {
+	remoteInfo.Services = modules.DefaultServices
+	if lowestVersion.Compare(HandshakeServicesUpgrade) < 0 {
+		return nil
+	}
+	ours := g.Services()
+
+	var err error
+	if initiator {
+		err = encoding.WriteObject(conn, ours)
+		if err == nil {
+			err = encoding.ReadObject(conn, &remoteInfo.Services, 8)
+		}
+	} else {
+		err = encoding.ReadObject(conn, &remoteInfo.Services, 8)
+		if err == nil {
+			err = encoding.WriteObject(conn, ours)
+		}
+	}
+	if err != nil {
+		return errors.New("could not exchange services: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+)
+
+// TestServicesHandshake tests that peers exchange their services during the
+// handshake.
+func TestServicesHandshake(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if g1.Services() != modules.DefaultServices {
+		t.Fatal("gateway should advertise the default services, got", g1.Services())
+	}
+	if err := g1.SetServices(modules.ServiceFullHistory | modules.ServicePruned); err != errConflictingServices {
+		t.Fatal("expected errConflictingServices, got", err)
+	}
+	pruned := modules.ServicePruned | modules.ServiceHeaders
+	if err := g1.SetServices(pruned); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].Services != modules.DefaultServices {
+		t.Fatal("g1 should know the services of g2:", peers)
+	}
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		peers := g2.Peers()
+		if len(peers) != 1 || peers[0].Services != pruned {
+			return errors.New("g2 should know the services of g1")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err, g2.Peers())
+	}
+}
@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"errors"
+
+	"github.com/rivine/rivine/modules"
+)
+
+var (
+	errInvalidMaxPeers      = errors.New("the maximum number of peers has to be at least 1")
+	errInvalidOutboundPeers = errors.New("the number of outbound peers can not be negative, nor exceed the maximum number of peers")
+	errInvalidLocalOutbound = errors.New("the maximum number of local outbound peers can not be negative")
+	errInvalidInboundPerIP  = errors.New("the maximum number of inbound peers per IP address can not be negative")
+	errInboundDisabled      = errors.New("inbound connections are disabled")
+	errTooManyInboundFromIP = errors.New("too many inbound peers from the same IP address")
+)
+
+// defaultSettings returns the default peer limits and connection policy.
+func defaultSettings() modules.GatewaySettings {
+	return modules.GatewaySettings{
+		MaxPeers:              fullyConnectedThreshold,
+		OutboundPeers:         wellConnectedThreshold,
+		MaxLocalOutboundPeers: maxLocalOutboundPeers,
+	}
+}
+
+// validateSettings returns an error if the given settings are invalid.
+func validateSettings(settings modules.GatewaySettings) error {
+	if settings.MaxPeers < 1 {
+		return errInvalidMaxPeers
+	}
+	if settings.OutboundPeers < 0 || settings.OutboundPeers > settings.MaxPeers {
+		return errInvalidOutboundPeers
+	}
+	if settings.MaxLocalOutboundPeers < 0 {
+		return errInvalidLocalOutbound
+	}
+	if settings.MaxInboundPeersPerIP < 0 {
+		return errInvalidInboundPerIP
+	}
+	return nil
+}
+
+// checkInboundPeer returns an error if the inbound peer with the given
+// address is not accepted according to the connection policy.
+func (g *Gateway) checkInboundPeer(addr modules.NetAddress) error {
+	if g.settings.DisableInbound {
+		return errInboundDisabled
+	}
+	if g.settings.MaxInboundPeersPerIP == 0 {
+		return nil
+	}
+	n := 0
+	for _, p := range g.peers {
+		if p.Inbound && p.NetAddress.Host() == addr.Host() {
+			n++
+		}
+	}
+	if n >= g.settings.MaxInboundPeersPerIP {
+		return errTooManyInboundFromIP
+	}
+	return nil
+}
+
+// Settings returns the peer limits and connection policy of the gateway.
+func (g *Gateway) Settings() modules.GatewaySettings {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.settings
+}
+
+// SetSettings updates the peer limits and connection policy of the gateway.
+// Disabling inbound connections disconnects all inbound peers, other limits
+// only apply to new connections. The settings are not persisted.
+func (g *Gateway) SetSettings(settings modules.GatewaySettings) error {
+	if err := validateSettings(settings); err != nil {
+		return err
+	}
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settings = settings
+	if settings.DisableInbound {
+		for addr, p := range g.peers {
+			if !p.Inbound {
+				continue
+			}
+			p.sess.Close()
+			delete(g.peers, addr)
+			g.log.Printf("INFO: disconnected from %v as inbound connections are disabled", addr)
+		}
+	}
+	g.log.Printf("INFO: gateway settings updated: %+v", settings)
+	return nil
+}
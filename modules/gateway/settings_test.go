@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+)
+
+// TestValidateSettings tests the validation of the gateway settings.
+func TestValidateSettings(t *testing.T) {
+	tests := []struct {
+		settings modules.GatewaySettings
+		err      error
+	}{
+		{defaultSettings(), nil},
+		{modules.GatewaySettings{MaxPeers: 1}, nil},
+		{modules.GatewaySettings{MaxPeers: 8, OutboundPeers: 8, MaxInboundPeersPerIP: 2}, nil},
+		{modules.GatewaySettings{MaxPeers: 0}, errInvalidMaxPeers},
+		{modules.GatewaySettings{MaxPeers: 8, OutboundPeers: 9}, errInvalidOutboundPeers},
+		{modules.GatewaySettings{MaxPeers: 8, OutboundPeers: -1}, errInvalidOutboundPeers},
+		{modules.GatewaySettings{MaxPeers: 8, MaxLocalOutboundPeers: -1}, errInvalidLocalOutbound},
+		{modules.GatewaySettings{MaxPeers: 8, MaxInboundPeersPerIP: -1}, errInvalidInboundPerIP},
+	}
+	for _, test := range tests {
+		if err := validateSettings(test.settings); err != test.err {
+			t.Errorf("%+v: expected error %v, got %v", test.settings, test.err, err)
+		}
+	}
+}
+
+// TestSetSettings tests that invalid settings are not applied.
+func TestSetSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+	if g.Settings() != defaultSettings() {
+		t.Fatal("gateway should start with the default settings, got", g.Settings())
+	}
+	if err := g.SetSettings(modules.GatewaySettings{}); err != errInvalidMaxPeers {
+		t.Fatal("expected errInvalidMaxPeers, got", err)
+	}
+	if g.Settings() != defaultSettings() {
+		t.Fatal("invalid settings should not have been applied")
+	}
+}
+
+// TestDisableInbound tests that a gateway with inbound connections disabled
+// disconnects its inbound peers, and refuses new ones.
+func TestDisableInbound(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+
+	if err := g2.Connect(g1.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g3.Address()); err != nil {
+		t.Fatal(err)
+	}
+	settings := g1.Settings()
+	settings.DisableInbound = true
+	if err := g1.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the outbound peer should remain
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].NetAddress != g3.Address() {
+		t.Fatal("expected only the outbound peer to remain, got", peers)
+	}
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		if len(g2.Peers()) != 0 {
+			return errors.New("g2 should have been disconnected")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.Connect(g1.Address()); err == nil {
+		t.Fatal("inbound connection should have been refused")
+	}
+
+	// inbound connections are accepted again once enabled
+	settings.DisableInbound = false
+	if err := g1.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.Connect(g1.Address()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMaxInboundPeersPerIP tests that a gateway limits the number of inbound
+// peers from a single IP address.
+func TestMaxInboundPeersPerIP(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+	g4 := newNamedTestingGateway(t, "4")
+	defer g4.Close()
+
+	settings := g1.Settings()
+	settings.MaxInboundPeersPerIP = 2
+	if err := g1.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// all testing gateways share the same IP address
+	for _, g := range []*Gateway{g2, g3, g4} {
+		// the last connection might fail or be closed by g1
+		g.Connect(g1.Address())
+	}
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		if n := len(g1.Peers()); n != 2 {
+			return errors.New("expected 2 peers")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err, g1.Peers())
+	}
+	g1.mu.RLock()
+	err = g1.checkInboundPeer(g4.Address())
+	g1.mu.RUnlock()
+	if err != errTooManyInboundFromIP {
+		t.Fatal("expected errTooManyInboundFromIP, got", err)
+	}
+}
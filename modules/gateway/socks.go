@@ -0,0 +1,217 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The gateway can dial its outbound connections through a SOCKS5 proxy,
+// as specified by RFC 1928. Both proxies which require no authentication
+// and proxies which require username/password authentication (RFC 1929)
+// are supported.
+
+const (
+	socksVersion = 5
+
+	socksAuthNone     = 0
+	socksAuthPassword = 2
+
+	socksAuthPasswordVersion = 1
+
+	socksCmdConnect = 1
+
+	socksAddrIPv4   = 1
+	socksAddrDomain = 3
+	socksAddrIPv6   = 4
+)
+
+var (
+	errInvalidProxy            = errors.New("proxy has to be a host:port address, optionally prefixed by user:password@")
+	errSOCKSInvalidVersion     = errors.New("socks5 proxy replied with an invalid version")
+	errSOCKSNoAcceptableAuth   = errors.New("socks5 proxy does not accept any of the offered authentication methods")
+	errSOCKSAuthFailed         = errors.New("socks5 proxy rejected the username and password")
+	errSOCKSInvalidAddressType = errors.New("socks5 proxy replied with an invalid address type")
+)
+
+// socksReplies contains the descriptions of the SOCKS5 reply codes.
+var socksReplies = []string{
+	"succeeded",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// socksProxy is a SOCKS5 proxy which is used to dial outbound connections.
+type socksProxy struct {
+	addr     string
+	username string
+	password string
+}
+
+// parseProxy parses a proxy in the [user:password@]host:port format.
+func parseProxy(proxy string) (*socksProxy, error) {
+	p := &socksProxy{addr: proxy}
+	if i := strings.LastIndex(proxy, "@"); i >= 0 {
+		p.addr = proxy[i+1:]
+		credentials := strings.SplitN(proxy[:i], ":", 2)
+		if len(credentials) != 2 || len(credentials[0]) == 0 ||
+			len(credentials[0]) > 255 || len(credentials[1]) > 255 {
+			return nil, errInvalidProxy
+		}
+		p.username, p.password = credentials[0], credentials[1]
+	}
+	if _, port, err := net.SplitHostPort(p.addr); err != nil || port == "" {
+		return nil, errInvalidProxy
+	}
+	return p, nil
+}
+
+// String returns the address of the proxy, omitting any credentials.
+func (p *socksProxy) String() string {
+	return p.addr
+}
+
+// dial connects to the given address through the proxy,
+// using the given transport to connect to the proxy itself.
+func (p *socksProxy) dial(transport Transport, addr string, cancel <-chan struct{}) (net.Conn, error) {
+	conn, err := transport.Dial(p.addr, dialTimeout, cancel)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(connStdDeadline))
+	if err = p.handshake(conn, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to %v through proxy %v: %v", addr, p.addr, err)
+	}
+	return conn, nil
+}
+
+// handshake negotiates the authentication method with the proxy,
+// authenticates if required, and requests it to connect to the given address.
+func (p *socksProxy) handshake(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	// offer the authentication methods
+	methods := []byte{socksAuthNone}
+	if p.username != "" {
+		methods = append(methods, socksAuthPassword)
+	}
+	if _, err = conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err = io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return errSOCKSInvalidVersion
+	}
+	switch reply[1] {
+	case socksAuthNone:
+	case socksAuthPassword:
+		if p.username == "" {
+			return errSOCKSNoAcceptableAuth
+		}
+		if err = p.authenticate(conn); err != nil {
+			return err
+		}
+	default:
+		return errSOCKSNoAcceptableAuth
+	}
+
+	// request the connection
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("host name is too long: " + host)
+		}
+		req = append(req, socksAddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socksAddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socksAddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, socksPort(uint16(port))...)
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	// read the reply, discarding the address bound by the proxy
+	var header [4]byte
+	if _, err = io.ReadFull(conn, header[:]); err != nil {
+		return err
+	}
+	if header[0] != socksVersion {
+		return errSOCKSInvalidVersion
+	}
+	if code := int(header[1]); code != 0 {
+		if code < len(socksReplies) {
+			return errors.New("socks5 proxy: " + socksReplies[code])
+		}
+		return fmt.Errorf("socks5 proxy: unknown error %d", code)
+	}
+	var addrLen int
+	switch header[3] {
+	case socksAddrIPv4:
+		addrLen = net.IPv4len
+	case socksAddrIPv6:
+		addrLen = net.IPv6len
+	case socksAddrDomain:
+		var l [1]byte
+		if _, err = io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		addrLen = int(l[0])
+	default:
+		return errSOCKSInvalidAddressType
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
+
+// authenticate authenticates with the proxy using the username and password.
+func (p *socksProxy) authenticate(conn net.Conn) error {
+	req := []byte{socksAuthPasswordVersion, byte(len(p.username))}
+	req = append(req, p.username...)
+	req = append(req, byte(len(p.password)))
+	req = append(req, p.password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socksAuthPasswordVersion || reply[1] != 0 {
+		return errSOCKSAuthFailed
+	}
+	return nil
+}
+
+// socksPort encodes a port as it is sent in a SOCKS5 request.
+func socksPort(port uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, port)
+	return b
+}
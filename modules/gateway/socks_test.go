@@ -0,0 +1,243 @@
+package gateway
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// testSOCKSProxy is a minimal SOCKS5 proxy, which only supports the CONNECT
+// command, optionally requiring username/password authentication.
+type testSOCKSProxy struct {
+	listener net.Listener
+	username string
+	password string
+	conns    int32
+}
+
+// newTestSOCKSProxy starts a SOCKS5 proxy on a random local port.
+func newTestSOCKSProxy(t *testing.T, username, password string) *testSOCKSProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testSOCKSProxy{listener: l, username: username, password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+// serve handles a single proxied connection.
+func (p *testSOCKSProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 262)
+	// greeting
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	if p.username == "" {
+		conn.Write([]byte{socksVersion, socksAuthNone})
+	} else {
+		conn.Write([]byte{socksVersion, socksAuthPassword})
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		username := make([]byte, buf[1])
+		if _, err := io.ReadFull(conn, username); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		password := make([]byte, buf[0])
+		if _, err := io.ReadFull(conn, password); err != nil {
+			return
+		}
+		if string(username) != p.username || string(password) != p.password {
+			conn.Write([]byte{socksAuthPasswordVersion, 1})
+			return
+		}
+		conn.Write([]byte{socksAuthPasswordVersion, 0})
+	}
+	// connect request, only IPv4 addresses are used by the tests
+	if _, err := io.ReadFull(conn, buf[:10]); err != nil || buf[3] != socksAddrIPv4 {
+		return
+	}
+	addr := net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(buf[8])<<8|int(buf[9])))
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{socksVersion, 5, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(&p.conns, 1)
+	conn.Write([]byte{socksVersion, 0, 0, socksAddrIPv4, 127, 0, 0, 1, 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// TestParseProxy tests the parsing of proxy addresses.
+func TestParseProxy(t *testing.T) {
+	tests := []struct {
+		proxy    string
+		addr     string
+		username string
+		password string
+		err      error
+	}{
+		{"127.0.0.1:1080", "127.0.0.1:1080", "", "", nil},
+		{"proxy.example.com:1080", "proxy.example.com:1080", "", "", nil},
+		{"user:pass@127.0.0.1:1080", "127.0.0.1:1080", "user", "pass", nil},
+		{"user:p@ss@127.0.0.1:1080", "127.0.0.1:1080", "user", "p@ss", nil},
+		{"127.0.0.1", "", "", "", errInvalidProxy},
+		{"user@127.0.0.1:1080", "", "", "", errInvalidProxy},
+		{":pass@127.0.0.1:1080", "", "", "", errInvalidProxy},
+	}
+	for _, test := range tests {
+		p, err := parseProxy(test.proxy)
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.proxy, test.err, err)
+			continue
+		}
+		if err == nil && (p.addr != test.addr || p.username != test.username || p.password != test.password) {
+			t.Errorf("%v: parsed into unexpected proxy %v", test.proxy, p)
+		}
+	}
+}
+
+// TestProxyConnect tests that a gateway dials its peers through a proxy, and
+// that a gateway which doesn't advertise its address keeps its local address.
+func TestProxyConnect(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	newProxiedGateway := func(suffix, proxy string) *Gateway {
+		g, err := NewWithOptions("localhost:0", false,
+			build.TempDir("gateway", t.Name()+suffix),
+			types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil,
+			Options{Proxy: proxy, NoAdvertise: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	// no authentication
+	proxy := newTestSOCKSProxy(t, "", "")
+	defer proxy.listener.Close()
+	g1 := newProxiedGateway("1", proxy.listener.Addr().String())
+	defer g1.Close()
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&proxy.conns) == 0 {
+		t.Fatal("connection was not dialed through the proxy")
+	}
+	if !g1.Address().IsLocal() {
+		t.Fatal("gateway should not advertise an external address:", g1.Address())
+	}
+
+	// username/password authentication
+	authProxy := newTestSOCKSProxy(t, "user", "pass")
+	defer authProxy.listener.Close()
+	g3 := newProxiedGateway("3", "user:pass@"+authProxy.listener.Addr().String())
+	defer g3.Close()
+	if err := g3.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g4 := newProxiedGateway("4", "user:wrong@"+authProxy.listener.Addr().String())
+	defer g4.Close()
+	if err := g4.Connect(g2.Address()); err == nil {
+		t.Fatal("expected the proxy to reject the credentials")
+	}
+
+	// the proxy is validated on construction
+	_, err := NewWithOptions("localhost:0", false,
+		filepath.Join(build.TempDir("gateway", t.Name()), "invalid"),
+		types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil,
+		Options{Proxy: "invalid"})
+	if err != errInvalidProxy {
+		t.Fatal("expected errInvalidProxy, got", err)
+	}
+	if len(g2.Peers()) != 2 {
+		t.Fatal("expected 2 peers, got", g2.Peers())
+	}
+}
+
+// TestNoAdvertise tests that a gateway which doesn't advertise its address is
+// not added to the node list of the peers it connects to.
+func TestNoAdvertise(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1, err := NewWithOptions("localhost:0", false,
+		build.TempDir("gateway", t.Name()+"1"),
+		types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil,
+		Options{NoAdvertise: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+
+	if !g1.Services().Has(modules.ServiceNoAdvertise) {
+		t.Fatal("gateway should advertise the noadvertise service:", g1.Services())
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g3.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for g2 to add the advertising gateway, by which time it would
+	// have added the other gateway as well
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		g2.mu.RLock()
+		defer g2.mu.RUnlock()
+		if _, ok := g2.nodes[g3.Address()]; !ok {
+			return errors.New("advertising gateway was not added to the node list")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2.mu.RLock()
+	_, ok := g2.nodes[g1.Address()]
+	p, connected := g2.peers[g1.Address()]
+	g2.mu.RUnlock()
+	if ok {
+		t.Fatal("gateway which doesn't advertise its address was added to the node list")
+	}
+	if !connected || !p.Services.Has(modules.ServiceNoAdvertise) {
+		t.Fatal("peer should be connected with the noadvertise service")
+	}
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"net"
+	"sync"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// unknownRPCName is the name under which calls to RPCs that are not
+// registered are counted, as to not let a peer grow the RPC counts
+// with arbitrary names.
+const unknownRPCName = "unknown"
+
+// peerStats tracks the bandwidth and RPC usage of the connection with a peer.
+type peerStats struct {
+	mu            sync.Mutex
+	bytesSent     uint64
+	bytesReceived uint64
+	lastActivity  types.Timestamp
+	rpcsSent      map[string]uint64
+	rpcsReceived  map[string]uint64
+}
+
+// addBytes records the given amount of bytes as sent to
+// and received from the peer.
+func (ps *peerStats) addBytes(sent, received int) {
+	if sent <= 0 && received <= 0 {
+		return
+	}
+	ps.mu.Lock()
+	ps.bytesSent += uint64(sent)
+	ps.bytesReceived += uint64(received)
+	ps.lastActivity = types.CurrentTimestamp()
+	ps.mu.Unlock()
+}
+
+// addRPC records a call of the RPC with the given name,
+// made to the peer if sent is true, or by the peer otherwise.
+func (ps *peerStats) addRPC(name string, sent bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	rpcs := &ps.rpcsReceived
+	if sent {
+		rpcs = &ps.rpcsSent
+	}
+	if *rpcs == nil {
+		*rpcs = make(map[string]uint64)
+	}
+	(*rpcs)[name]++
+}
+
+// apply copies the tracked usage into the given peer.
+func (ps *peerStats) apply(p *modules.Peer) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p.BytesSent = ps.bytesSent
+	p.BytesReceived = ps.bytesReceived
+	p.LastActivity = ps.lastActivity
+	p.RPCsSent = make(map[string]uint64, len(ps.rpcsSent))
+	for name, n := range ps.rpcsSent {
+		p.RPCsSent[name] = n
+	}
+	p.RPCsReceived = make(map[string]uint64, len(ps.rpcsReceived))
+	for name, n := range ps.rpcsReceived {
+		p.RPCsReceived[name] = n
+	}
+}
+
+// statsConn wraps the raw connection with a peer, as to track its bandwidth,
+// including the handshakes and the encryption overhead.
+type statsConn struct {
+	net.Conn
+	stats *peerStats
+}
+
+// newStatsConn wraps the given raw connection,
+// tracking its bandwidth using a new peerStats.
+func newStatsConn(conn net.Conn) *statsConn {
+	return &statsConn{Conn: conn, stats: new(peerStats)}
+}
+
+// Read implements net.Conn.Read
+func (sc *statsConn) Read(b []byte) (int, error) {
+	n, err := sc.Conn.Read(b)
+	sc.stats.addBytes(0, n)
+	return n, err
+}
+
+// Write implements net.Conn.Write
+func (sc *statsConn) Write(b []byte) (int, error) {
+	n, err := sc.Conn.Write(b)
+	sc.stats.addBytes(n, 0)
+	return n, err
+}
+
+// trackConn attaches the stats tracked since the handshake to the peer,
+// marks the peer as connected since now and returns the connection to use
+// for the session with the peer.
+func (p *peer) trackConn(remoteInfo remoteInfo) net.Conn {
+	p.stats = remoteInfo.stats
+	if p.stats == nil {
+		p.stats = new(peerStats)
+	}
+	p.ConnectedSince = types.CurrentTimestamp()
+	p.stats.mu.Lock()
+	p.stats.lastActivity = p.ConnectedSince
+	p.stats.mu.Unlock()
+	return remoteInfo.Conn
+}
+
+// rpcName returns the name of the registered RPC with the given ID,
+// or unknownRPCName if no such RPC is registered.
+func (g *Gateway) rpcName(id rpcID) string {
+	if name, ok := g.rpcNames[id]; ok {
+		return name
+	}
+	return unknownRPCName
+}
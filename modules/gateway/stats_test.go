@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+)
+
+// TestPeerStats tests that the bandwidth and RPC calls of peers are tracked.
+func TestPeerStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	g2.RegisterRPC("Foo", func(conn modules.PeerConn) error {
+		return encoding.WriteObject(conn, "foo")
+	})
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	// the bytes of the handshake are counted as well
+	if peers := g1.Peers(); len(peers) != 1 || peers[0].BytesSent == 0 || peers[0].BytesReceived == 0 {
+		t.Fatal("handshake bandwidth was not tracked:", peers)
+	}
+	var foo string
+	err := g1.RPC(g2.Address(), "Foo", func(conn modules.PeerConn) error {
+		return encoding.ReadObject(conn, &foo, 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g1.RPC(g2.Address(), "Bar", func(modules.PeerConn) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peers := g1.Peers()
+	if len(peers) != 1 {
+		t.Fatal("expected 1 peer, got", peers)
+	}
+	p := peers[0]
+	if p.RPCsSent["Foo"] != 1 || p.RPCsSent["Bar"] != 1 {
+		t.Fatal("RPC calls were not counted:", p.RPCsSent)
+	}
+	if p.BytesSent == 0 || p.BytesReceived == 0 {
+		t.Fatal("bandwidth was not tracked:", p.BytesSent, p.BytesReceived)
+	}
+	if p.ConnectedSince == 0 || p.LastActivity < p.ConnectedSince {
+		t.Fatal("connection times were not tracked:", p.ConnectedSince, p.LastActivity)
+	}
+
+	// the incoming RPCs are handled asynchronously
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		peers := g2.Peers()
+		if len(peers) != 1 {
+			return errors.New("expected 1 peer")
+		}
+		if peers[0].RPCsReceived["Foo"] != 1 || peers[0].RPCsReceived[unknownRPCName] != 1 {
+			return errors.New("RPC calls were not counted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err, g2.Peers())
+	}
+}
@@ -0,0 +1,385 @@
+package gateway
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+	"golang.org/x/crypto/curve25519"
+)
+
+// The encrypted transport is negotiated during the gateway handshake,
+// once both peers support it. Both peers announce their encryption mode,
+// and if neither of them disabled encryption, a secure handshake follows:
+//
+//    1. Both peers exchange an ephemeral X25519 public key,
+//       together with their persistent ed25519 identity key.
+//    2. Both peers derive a key per direction from the shared X25519 secret
+//       and the transcript of the exchanged keys, and wrap the connection
+//       in a secureConn.
+//    3. Over the encrypted connection, both peers send a signature of the
+//       transcript made with their identity key, proving that they own it.
+//
+// From then on all traffic, including the stream multiplexer,
+// flows through authenticated and encrypted frames.
+
+const (
+	// identityFile is the name of the file that contains the identity key
+	// of the gateway.
+	identityFile = "identity.json"
+
+	// maxSecureFramePayload is the maximum amount of plaintext bytes
+	// which are encrypted as a single frame.
+	maxSecureFramePayload = 1 << 16
+
+	// encodedSecureHelloLength is the static length of an encoded secureHello.
+	// sizeof(X25519 public key) + sizeof(ed25519 public key) = 32 + 32 = 64
+	encodedSecureHelloLength = 64
+)
+
+var (
+	errEncryptionRequired     = errors.New("peer does not support encryption, while encryption is required")
+	errPeerRequiresEncryption = errors.New("peer requires encryption, while encryption is disabled")
+	errSecureFrameTooLarge    = errors.New("encrypted frame exceeds the maximum frame size")
+	errSecureFrameInvalid     = errors.New("encrypted frame could not be authenticated")
+	errInvalidEphemeralKey    = errors.New("peer sent an invalid ephemeral key")
+)
+
+var (
+	// transportSpecifier is mixed into the transcript of the secure handshake,
+	// as to bind the derived keys to this protocol.
+	transportSpecifier = types.Specifier{'g', 'a', 't', 'e', 'w', 'a', 'y', ' ', 'x', '2', '5', '5', '1', '9'}
+
+	// identityMetadata contains the header and version strings that identify
+	// the gateway identity file.
+	identityMetadata = persist.Metadata{
+		Header:  "Gateway Identity",
+		Version: "1.0.0",
+	}
+)
+
+type (
+	// secureHello is sent by both peers at the start of the secure handshake.
+	secureHello struct {
+		EphemeralKey [32]byte
+		IdentityKey  crypto.PublicKey
+	}
+
+	// identityPersist is the persisted identity of the gateway.
+	identityPersist struct {
+		SecretKey crypto.SecretKey `json:"secretkey"`
+	}
+
+	// secureConn is a net.Conn which encrypts and authenticates
+	// all data written to, and read from, the underlying connection.
+	// Each frame is prefixed by its length, which is authenticated as well.
+	// Each direction uses its own key, and the nonce of a frame
+	// is the number of frames sent before it in that direction.
+	secureConn struct {
+		net.Conn
+
+		readMu    sync.Mutex
+		readAEAD  cipher.AEAD
+		readNonce uint64
+		readBuf   []byte
+
+		writeMu    sync.Mutex
+		writeAEAD  cipher.AEAD
+		writeNonce uint64
+	}
+)
+
+// loadIdentity loads the identity key of the gateway,
+// generating and persisting a new one if it does not exist yet.
+func (g *Gateway) loadIdentity() error {
+	var identity identityPersist
+	filename := filepath.Join(g.persistDir, identityFile)
+	err := persist.LoadJSON(identityMetadata, &identity, filename)
+	if os.IsNotExist(err) {
+		identity.SecretKey, _ = crypto.GenerateKeyPair()
+		err = persist.SaveJSON(identityMetadata, identity, filename)
+	}
+	if err != nil {
+		return err
+	}
+	g.identity = identity.SecretKey
+	// derive the bucket key of the address manager from the identity,
+	// such that nodes remain in the same bucket across restarts
+	g.addrKey = crypto.HashAll(addrManagerSpecifier, g.identity)
+	return nil
+}
+
+// IdentityKey returns the public key which identifies the gateway
+// to the peers it has an encrypted connection with.
+func (g *Gateway) IdentityKey() types.SiaPublicKey {
+	return types.Ed25519PublicKey(g.identity.PublicKey())
+}
+
+// SetEncryptionMode defines if and when the gateway encrypts
+// the connections with its peers. When encryption is required,
+// all peers with a plaintext connection are disconnected.
+func (g *Gateway) SetEncryptionMode(mode modules.EncryptionMode) error {
+	if mode.String() == "" {
+		return modules.ErrInvalidEncryptionMode
+	}
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.encryption = mode
+	if mode != modules.EncryptionRequired {
+		return nil
+	}
+	for addr, p := range g.peers {
+		if p.Encrypted {
+			continue
+		}
+		p.sess.Close()
+		delete(g.peers, addr)
+		g.log.Printf("INFO: disconnected from %v as its connection is not encrypted", addr)
+	}
+	return nil
+}
+
+// managedEncryptionMode returns the encryption mode of the gateway.
+func (g *Gateway) managedEncryptionMode() modules.EncryptionMode {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.encryption
+}
+
+// transportHandshake secures the connection with the peer if both peers
+// support and agree to encrypt it, storing the result in remoteInfo.
+// Peers which do not support encryption are refused if encryption is required.
+func (g *Gateway) transportHandshake(conn net.Conn, lowestVersion build.ProtocolVersion, initiator bool, remoteInfo *remoteInfo) error {
+	if lowestVersion.Compare(HandshakeEncryptionUpgrade) < 0 {
+		if g.managedEncryptionMode() == modules.EncryptionRequired {
+			return errEncryptionRequired
+		}
+		return nil
+	}
+	sconn, identityKey, err := g.negotiateEncryption(conn, initiator)
+	if err != nil || sconn == nil {
+		return err
+	}
+	remoteInfo.Conn = sconn
+	remoteInfo.Encrypted = true
+	remoteInfo.IdentityKey = identityKey
+	return nil
+}
+
+// encryptionAgreed returns whether or not two peers,
+// using the given encryption modes, encrypt their connection.
+func encryptionAgreed(ours, theirs modules.EncryptionMode) (bool, error) {
+	if ours.String() == "" || theirs.String() == "" {
+		return false, modules.ErrInvalidEncryptionMode
+	}
+	if ours == modules.EncryptionDisabled {
+		if theirs == modules.EncryptionRequired {
+			return false, errPeerRequiresEncryption
+		}
+		return false, nil
+	}
+	if theirs == modules.EncryptionDisabled {
+		if ours == modules.EncryptionRequired {
+			return false, errEncryptionRequired
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// negotiateEncryption exchanges the encryption modes of both peers,
+// and secures the connection if both peers agree to encrypt it.
+// A nil secureConn is returned if the connection is not to be encrypted.
+func (g *Gateway) negotiateEncryption(conn net.Conn, initiator bool) (*secureConn, crypto.PublicKey, error) {
+	ours := g.managedEncryptionMode()
+
+	var theirs modules.EncryptionMode
+	var err error
+	if initiator {
+		err = encoding.WriteObject(conn, ours)
+		if err == nil {
+			err = encoding.ReadObject(conn, &theirs, 8)
+		}
+	} else {
+		err = encoding.ReadObject(conn, &theirs, 8)
+		if err == nil {
+			err = encoding.WriteObject(conn, ours)
+		}
+	}
+	if err != nil {
+		return nil, crypto.PublicKey{}, errors.New("could not exchange encryption mode: " + err.Error())
+	}
+
+	encrypt, err := encryptionAgreed(ours, theirs)
+	if err != nil || !encrypt {
+		return nil, crypto.PublicKey{}, err
+	}
+	return g.secureHandshake(conn, initiator)
+}
+
+// secureHandshake performs the secure handshake, returning the encrypted
+// connection and the authenticated identity key of the peer.
+func (g *Gateway) secureHandshake(conn net.Conn, initiator bool) (*secureConn, crypto.PublicKey, error) {
+	var ephemeralKey [32]byte
+	fastrand.Read(ephemeralKey[:])
+	ours := secureHello{IdentityKey: g.identity.PublicKey()}
+	curve25519.ScalarBaseMult(&ours.EphemeralKey, &ephemeralKey)
+
+	// exchange the hello messages, the initiator sends first
+	var theirs secureHello
+	var err error
+	if initiator {
+		err = encoding.WriteObject(conn, ours)
+		if err == nil {
+			err = encoding.ReadObject(conn, &theirs, encodedSecureHelloLength)
+		}
+	} else {
+		err = encoding.ReadObject(conn, &theirs, encodedSecureHelloLength)
+		if err == nil {
+			err = encoding.WriteObject(conn, ours)
+		}
+	}
+	if err != nil {
+		return nil, crypto.PublicKey{}, errors.New("could not exchange secure hello: " + err.Error())
+	}
+
+	var secret [32]byte
+	curve25519.ScalarMult(&secret, &ephemeralKey, &theirs.EphemeralKey)
+	if secret == ([32]byte{}) {
+		// the peer sent a low order point
+		return nil, crypto.PublicKey{}, errInvalidEphemeralKey
+	}
+
+	// derive a key per direction, bound to the transcript
+	initiatorHello, responderHello := ours, theirs
+	if !initiator {
+		initiatorHello, responderHello = theirs, ours
+	}
+	transcript := crypto.HashAll(transportSpecifier, g.genesisBlockID, initiatorHello, responderHello)
+	initiatorKey := crypto.TwofishKey(crypto.HashAll(secret[:], transcript, "initiator"))
+	responderKey := crypto.TwofishKey(crypto.HashAll(secret[:], transcript, "responder"))
+
+	var sconn *secureConn
+	var ourRole, theirRole string
+	if initiator {
+		sconn = newSecureConn(conn, responderKey, initiatorKey)
+		ourRole, theirRole = "initiator", "responder"
+	} else {
+		sconn = newSecureConn(conn, initiatorKey, responderKey)
+		ourRole, theirRole = "responder", "initiator"
+	}
+
+	// prove the ownership of the identity keys, the initiator proves first
+	ourSig := crypto.SignHash(crypto.HashAll(transcript, ourRole), g.identity)
+	var theirSig crypto.Signature
+	if initiator {
+		err = encoding.WriteObject(sconn, ourSig)
+		if err == nil {
+			err = encoding.ReadObject(sconn, &theirSig, crypto.SignatureSize)
+		}
+	} else {
+		err = encoding.ReadObject(sconn, &theirSig, crypto.SignatureSize)
+		if err == nil {
+			err = encoding.WriteObject(sconn, ourSig)
+		}
+	}
+	if err != nil {
+		return nil, crypto.PublicKey{}, errors.New("could not exchange identity proof: " + err.Error())
+	}
+	err = crypto.VerifyHash(crypto.HashAll(transcript, theirRole), theirs.IdentityKey, theirSig)
+	if err != nil {
+		return nil, crypto.PublicKey{}, errors.New("invalid identity proof: " + err.Error())
+	}
+	return sconn, theirs.IdentityKey, nil
+}
+
+// newSecureConn wraps a connection, decrypting all data read with readKey,
+// and encrypting all data written with writeKey.
+func newSecureConn(conn net.Conn, readKey, writeKey crypto.TwofishKey) *secureConn {
+	// NOTE: NewGCM only returns an error if twofishCipher.BlockSize != 16.
+	readAEAD, _ := cipher.NewGCM(readKey.NewCipher())
+	writeAEAD, _ := cipher.NewGCM(writeKey.NewCipher())
+	return &secureConn{
+		Conn:      conn,
+		readAEAD:  readAEAD,
+		writeAEAD: writeAEAD,
+	}
+}
+
+// frameNonce returns the nonce of the n-th frame.
+func frameNonce(aead cipher.AEAD, n uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, encoding.EncUint64(n))
+	return nonce
+}
+
+// Read implements net.Conn.Read
+func (sc *secureConn) Read(b []byte) (int, error) {
+	sc.readMu.Lock()
+	defer sc.readMu.Unlock()
+
+	for len(sc.readBuf) == 0 {
+		prefix := make([]byte, 8)
+		if _, err := io.ReadFull(sc.Conn, prefix); err != nil {
+			return 0, err
+		}
+		frameLen := encoding.DecUint64(prefix)
+		if frameLen > maxSecureFramePayload+uint64(sc.readAEAD.Overhead()) {
+			return 0, errSecureFrameTooLarge
+		}
+		frame := make([]byte, frameLen)
+		if _, err := io.ReadFull(sc.Conn, frame); err != nil {
+			return 0, err
+		}
+		plaintext, err := sc.readAEAD.Open(frame[:0], frameNonce(sc.readAEAD, sc.readNonce), frame, prefix)
+		if err != nil {
+			return 0, errSecureFrameInvalid
+		}
+		sc.readNonce++
+		sc.readBuf = plaintext
+	}
+	n := copy(b, sc.readBuf)
+	sc.readBuf = sc.readBuf[n:]
+	return n, nil
+}
+
+// Write implements net.Conn.Write
+func (sc *secureConn) Write(b []byte) (int, error) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxSecureFramePayload {
+			chunk = chunk[:maxSecureFramePayload]
+		}
+		prefix := encoding.EncUint64(uint64(len(chunk) + sc.writeAEAD.Overhead()))
+		frame := make([]byte, len(prefix), len(prefix)+len(chunk)+sc.writeAEAD.Overhead())
+		copy(frame, prefix)
+		frame = sc.writeAEAD.Seal(frame, frameNonce(sc.writeAEAD, sc.writeNonce), chunk, prefix)
+		if _, err := sc.Conn.Write(frame); err != nil {
+			return written, err
+		}
+		sc.writeNonce++
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// TestSecureConn tests that data written to a secureConn is read back
+// unaltered, and that tampered frames are rejected.
+func TestSecureConn(t *testing.T) {
+	keyA, keyB := crypto.GenerateTwofishKey(), crypto.GenerateTwofishKey()
+	connA, connB := net.Pipe()
+	a := newSecureConn(connA, keyB, keyA)
+	b := newSecureConn(connB, keyA, keyB)
+
+	// data larger than a single frame is split over multiple frames
+	data := fastrand.Bytes(maxSecureFramePayload*2 + 1)
+	go func() {
+		if _, err := a.Write(data); err != nil {
+			panic(err)
+		}
+	}()
+	read := make([]byte, len(data))
+	if _, err := io.ReadFull(b, read); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, read) {
+		t.Fatal("data read differs from data written")
+	}
+
+	// a frame which was tampered with cannot be read
+	go func() {
+		frame := make([]byte, 8+16+16)
+		copy(frame, []byte{32})
+		connA.Write(frame)
+	}()
+	if _, err := b.Read(read); err != errSecureFrameInvalid {
+		t.Fatal("expected errSecureFrameInvalid, got", err)
+	}
+}
+
+// TestEncryptionAgreed tests which encryption modes lead to an encrypted
+// connection, and which ones are incompatible.
+func TestEncryptionAgreed(t *testing.T) {
+	tests := []struct {
+		ours, theirs modules.EncryptionMode
+		encrypt      bool
+		err          error
+	}{
+		{modules.EncryptionOptional, modules.EncryptionOptional, true, nil},
+		{modules.EncryptionOptional, modules.EncryptionRequired, true, nil},
+		{modules.EncryptionRequired, modules.EncryptionRequired, true, nil},
+		{modules.EncryptionOptional, modules.EncryptionDisabled, false, nil},
+		{modules.EncryptionDisabled, modules.EncryptionDisabled, false, nil},
+		{modules.EncryptionRequired, modules.EncryptionDisabled, false, errEncryptionRequired},
+		{modules.EncryptionDisabled, modules.EncryptionRequired, false, errPeerRequiresEncryption},
+		{modules.EncryptionOptional, modules.EncryptionMode(42), false, modules.ErrInvalidEncryptionMode},
+	}
+	for i, test := range tests {
+		encrypt, err := encryptionAgreed(test.ours, test.theirs)
+		if encrypt != test.encrypt || err != test.err {
+			t.Errorf("test #%d: expected (%v, %v), got (%v, %v)", i, test.encrypt, test.err, encrypt, err)
+		}
+	}
+}
+
+// TestEncryptedPeers tests that gateways encrypt their connection,
+// authenticate each other, and refuse plaintext peers when encryption is required.
+func TestEncryptedPeers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+
+	// the identity key is persisted
+	identityKey := g1.IdentityKey()
+	g1.Close()
+	g1, err := New("localhost:0", false, g1.persistDir, types.DefaultBlockchainInfo(), types.DefaultChainConstants(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	if !bytes.Equal(g1.IdentityKey().Key, identityKey.Key) {
+		t.Fatal("identity key was not persisted")
+	}
+
+	if err = g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	peers := g1.Peers()
+	if len(peers) != 1 || !peers[0].Encrypted || peers[0].IdentityKey == nil {
+		t.Fatal("connection should be encrypted:", peers)
+	}
+	if !bytes.Equal(peers[0].IdentityKey.Key, g2.IdentityKey().Key) {
+		t.Fatal("peer was not authenticated with its identity key")
+	}
+
+	// a gateway which requires encryption refuses a gateway which disables it
+	if err = g3.SetEncryptionMode(modules.EncryptionDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if err = g1.SetEncryptionMode(modules.EncryptionRequired); err != nil {
+		t.Fatal(err)
+	}
+	if err = g1.Connect(g3.Address()); err != errEncryptionRequired {
+		t.Fatal("expected errEncryptionRequired, got", err)
+	}
+	if len(g1.Peers()) != 1 {
+		t.Fatal("encrypted peer should still be connected")
+	}
+
+	// a plaintext connection is used if only one of the gateways disables encryption
+	if err = g3.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	peers = g3.Peers()
+	if len(peers) != 1 || peers[0].Encrypted {
+		t.Fatal("connection should not be encrypted:", peers)
+	}
+}
@@ -0,0 +1,45 @@
+package modules
+
+import (
+	"testing"
+)
+
+// TestServiceFlags tests the String and Has methods of ServiceFlags,
+// as well as the filtering of peers by their services.
+func TestServiceFlags(t *testing.T) {
+	tests := []struct {
+		flags ServiceFlags
+		str   string
+	}{
+		{0, ""},
+		{DefaultServices, "fullhistory,headers,relaytransactions"},
+		{ServicePruned | ServiceExplorer, "pruned,explorer"},
+		{ServiceHeaders | 1<<63, "headers"},
+	}
+	for _, test := range tests {
+		if s := test.flags.String(); s != test.str {
+			t.Errorf("expected %v to be formatted as %q, got %q", uint64(test.flags), test.str, s)
+		}
+	}
+
+	if !DefaultServices.Has(ServiceFullHistory | ServiceHeaders) {
+		t.Error("default services should include full history and headers")
+	}
+	if DefaultServices.Has(ServiceFullHistory | ServicePruned) {
+		t.Error("default services should not include pruned")
+	}
+
+	peers := []Peer{
+		{NetAddress: "111.111.111.111:1", Services: DefaultServices},
+		{NetAddress: "111.111.111.111:2", Services: ServicePruned | ServiceHeaders},
+		{NetAddress: "111.111.111.111:3", Services: ServiceFullHistory | ServiceRelayTransactions},
+	}
+	filtered := PeersWithServices(peers, ServiceHeaders)
+	if len(filtered) != 2 || filtered[0].NetAddress != peers[0].NetAddress || filtered[1].NetAddress != peers[1].NetAddress {
+		t.Error("unexpected peers with header service:", filtered)
+	}
+	filtered = PeersWithServices(peers, ServiceFullHistory|ServiceRelayTransactions)
+	if len(filtered) != 2 || filtered[0].NetAddress != peers[0].NetAddress || filtered[1].NetAddress != peers[2].NetAddress {
+		t.Error("unexpected peers with full history and relay services:", filtered)
+	}
+}
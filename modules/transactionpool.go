@@ -16,6 +16,10 @@ const (
 	// TransactionSetSizeLimit defines the largest set of dependent unconfirmed
 	// transactions that will be accepted by the transaction pool.
 	TransactionSetSizeLimit = 250e3
+
+	// DefaultFeeTarget is the number of blocks within which the transactions
+	// sent by the wallet are targeted to be confirmed.
+	DefaultFeeTarget = 3
 )
 
 var (
@@ -64,12 +68,17 @@ type TransactionPool interface {
 	Close() error
 
 	// FeeEstimation returns an estimation for how high the transaction fee
-	// needs to be per byte. The minimum recommended targets getting accepted
-	// in ~3 blocks, and the maximum recommended targets getting accepted
-	// immediately. Taking the average has a moderate chance of being accepted
-	// within one block. The minimum has a strong chance of getting accepted
-	// within 10 blocks.
-	FeeEstimation() (minimumRecommended, maximumRecommended types.Currency)
+	// needs to be per byte, in order for a transaction set to get confirmed
+	// within the given number of blocks. The estimation is based on the fees
+	// paid by the transactions of recent blocks, and by the transaction sets
+	// which are waiting in the transaction pool.
+	FeeEstimation(target types.BlockHeight) FeeEstimate
+
+	// MinimumFee returns the minimum fee-per-byte a transaction set needs to
+	// pay in order to be accepted by the transaction pool. It is zero unless
+	// the transaction pool is full, in which case a set has to outbid the
+	// cheapest set in the pool.
+	MinimumFee() types.Currency
 
 	// IsStandardTransaction returns `err = nil` if the transaction is
 	// standard, otherwise it returns an error explaining what is not standard.
@@ -97,6 +106,17 @@ type TransactionPool interface {
 	Unsubscribe(TransactionPoolSubscriber)
 }
 
+// FeeEstimate contains the suggested fees-per-byte for getting a transaction
+// set confirmed within a target number of blocks. The low fee has a fair
+// chance of getting confirmed within the target, the medium fee a good chance
+// and the high fee a strong chance.
+type FeeEstimate struct {
+	Target types.BlockHeight `json:"target"`
+	Low    types.Currency    `json:"low"`
+	Medium types.Currency    `json:"medium"`
+	High   types.Currency    `json:"high"`
+}
+
 // ConsensusConflict implements the error interface, and indicates that a
 // transaction was rejected due to being incompatible with the current
 // consensus set, meaning either a double spend or a consensus rule violation -
@@ -6,7 +6,6 @@ package transactionpool
 import (
 	"errors"
 
-	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
@@ -15,19 +14,15 @@ import (
 )
 
 const (
-	// The TransactionPoolSizeLimit is first checked, and then a transaction
-	// set is added. The current transaction pool does not do any priority
-	// ordering, so the size limit is such that the transaction pool will never
-	// exceed the size of a block.
+	// DefaultTransactionPoolSize is the default maximum size, in bytes, of
+	// all transaction sets in the transaction pool combined. Once reached,
+	// the sets paying the lowest fee-per-byte are evicted to make room for
+	// sets paying more.
 	//
-	// TODO: Add a priority structure that will allow the transaction pool to
-	// fill up beyond the size of a single block, without being subject to
-	// manipulation.
-	//
-	// The first ~1/4 of the transaction pool can be filled for free. This is
-	// mostly to preserve compatibility with clients that do not add fees.
-	TransactionPoolSizeLimit  = 2e6 - 5e3 - modules.TransactionSetSizeLimit
-	TransactionPoolSizeForFee = 500e3
+	// The first ~500 kB of the transaction pool can be filled for free. This
+	// is mostly to preserve compatibility with clients that do not add fees.
+	DefaultTransactionPoolSize = 20e6
+	TransactionPoolSizeForFee  = 500e3
 )
 
 var (
@@ -65,10 +60,9 @@ func relatedObjectIDs(ts []types.Transaction) []ObjectID {
 // checkMinerFees checks that the total amount of transaction fees in the
 // transaction set is sufficient to earn a spot in the transaction pool.
 func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
-	// Transactions cannot be added after the TransactionPoolSizeLimit has been
-	// hit.
-	if tp.transactionListSize > TransactionPoolSizeLimit {
-		return errFullTransactionPool
+	// While the pool is full, sets have to outbid the cheapest set.
+	if minFee := tp.minimumFee(); !minFee.IsZero() && modules.CalculateFee(ts).Cmp(minFee) < 0 {
+		return errLowMinerFees
 	}
 
 	// The first TransactionPoolSizeForFee transactions do not need fees.
@@ -96,7 +90,7 @@ func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
 // IsStandard.
 func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction) error {
 	// Check that the transaction set is not already known.
-	setID := TransactionSetID(crypto.HashObject(ts))
+	setID := transactionSetID(ts)
 	_, exists := tp.transactionSets[setID]
 	if exists {
 		return modules.ErrDuplicateTransactionSet
@@ -124,8 +118,10 @@ func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction
 }
 
 // handleConflicts detects whether the conflicts in the transaction pool are
-// legal children of the new transaction pool set or not.
-func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []TransactionSetID) error {
+// legal children of the new transaction pool set or not. If they are, the
+// conflicts are merged with the new set, and the ID of the merged set is
+// returned.
+func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []TransactionSetID) (TransactionSetID, error) {
 	// Create a list of all the transaction ids that compose the set of
 	// conflicts.
 	conflictMap := make(map[types.TransactionID]TransactionSetID)
@@ -146,7 +142,7 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 		dedupSet = append(dedupSet, t)
 	}
 	if len(dedupSet) == 0 {
-		return modules.ErrDuplicateTransactionSet
+		return TransactionSetID{}, modules.ErrDuplicateTransactionSet
 	}
 	// If transactions were pruned, it's possible that the set of
 	// dependencies/conflicts has also reduced. To minimize computational load
@@ -188,26 +184,35 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 	// IsStandard rules (this is a new set, the rules must be rechecked).
 	err := tp.checkTransactionSetComposition(superset)
 	if err != nil {
-		return err
+		return TransactionSetID{}, err
 	}
 
 	// Check that the transaction set is valid.
 	cc, err := tp.consensusSet.TryTransactionSet(superset)
 	if err != nil {
-		return modules.NewConsensusConflict(err.Error())
+		// Rather than depending on the conflicts, the set might double spend
+		// the outputs they spend, in which case it might replace them.
+		replacementCC, replacementErr := tp.consensusSet.TryTransactionSet(dedupSet)
+		if replacementErr != nil {
+			return TransactionSetID{}, modules.NewConsensusConflict(err.Error())
+		}
+		return tp.replaceTransactionSets(dedupSet, replacementCC, supersetMap)
 	}
 
-	// Remove the conflicts from the transaction pool. The diffs do not need to
-	// be removed, they will be overwritten later in the function.
-	for _, conflict := range conflictMap {
-		conflictSet := tp.transactionSets[conflict]
-		tp.transactionListSize -= len(encoding.Marshal(conflictSet))
-		delete(tp.transactionSets, conflict)
-		delete(tp.transactionSetDiffs, conflict)
+	// Make room for the superset, evicting the cheapest sets if needed.
+	sf := newSetFee(superset)
+	err = tp.makeRoom(sf, supersetMap)
+	if err != nil {
+		return TransactionSetID{}, err
+	}
+
+	// Remove the conflicts from the transaction pool.
+	for conflict := range supersetMap {
+		tp.removeTransactionSet(conflict)
 	}
 
 	// Add the transaction set to the pool.
-	setID := TransactionSetID(crypto.HashObject(superset))
+	setID := transactionSetID(superset)
 	tp.transactionSets[setID] = superset
 	for _, diff := range cc.CoinOutputDiffs {
 		tp.knownObjects[ObjectID(diff.ID)] = setID
@@ -216,19 +221,23 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 		tp.knownObjects[ObjectID(diff.ID)] = setID
 	}
 	tp.transactionSetDiffs[setID] = cc
-	tp.transactionListSize += len(encoding.Marshal(superset))
-	return nil
+	tp.transactionSetFees[setID] = sf
+	tp.transactionListSize += int(sf.size)
+	tp.dirtySets[setID] = struct{}{}
+	return setID, nil
 }
 
 // acceptTransactionSet verifies that a transaction set is allowed to be in the
-// transaction pool, and then adds it to the transaction pool.
-func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
+// transaction pool, and then adds it to the transaction pool, returning the ID
+// of the set as it was added. Transactions which are already confirmed are
+// left out of the set, and the set is merged with the sets it depends on.
+func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) (TransactionSetID, error) {
 	if len(ts) == 0 {
-		return errEmptySet
+		return TransactionSetID{}, errEmptySet
 	}
 
 	// Remove all transactions that have been confirmed in the transaction set.
-	err := tp.db.Update(func(tx *bolt.Tx) error {
+	err := tp.db.View(func(tx *bolt.Tx) error {
 		oldTS := ts
 		ts = []types.Transaction{}
 		for _, txn := range oldTS {
@@ -239,18 +248,18 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
 		return nil
 	})
 	if err != nil {
-		return err
+		return TransactionSetID{}, err
 	}
 	// If no transactions remain, return a duplicate error.
 	if len(ts) == 0 {
-		return modules.ErrDuplicateTransactionSet
+		return TransactionSetID{}, modules.ErrDuplicateTransactionSet
 	}
 
 	// Check the composition of the transaction set, including fees and
 	// IsStandard rules.
 	err = tp.checkTransactionSetComposition(ts)
 	if err != nil {
-		return err
+		return TransactionSetID{}, err
 	}
 
 	// Check for conflicts with other transactions, which would indicate a
@@ -269,18 +278,33 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
 	}
 	cc, err := tp.consensusSet.TryTransactionSet(ts)
 	if err != nil {
-		return modules.NewConsensusConflict(err.Error())
+		return TransactionSetID{}, modules.NewConsensusConflict(err.Error())
+	}
+
+	// Make room for the set, evicting the cheapest sets if needed.
+	sf := newSetFee(ts)
+	err = tp.makeRoom(sf, nil)
+	if err != nil {
+		return TransactionSetID{}, err
 	}
 
 	// Add the transaction set to the pool.
-	setID := TransactionSetID(crypto.HashObject(ts))
+	return tp.addTransactionSet(ts, oids, cc, sf), nil
+}
+
+// addTransactionSet adds a transaction set to the pool, which relates to the
+// given objects, returning its ID.
+func (tp *TransactionPool) addTransactionSet(ts []types.Transaction, oids []ObjectID, cc modules.ConsensusChange, sf setFee) TransactionSetID {
+	setID := transactionSetID(ts)
 	tp.transactionSets[setID] = ts
 	for _, oid := range oids {
 		tp.knownObjects[oid] = setID
 	}
 	tp.transactionSetDiffs[setID] = cc
-	tp.transactionListSize += len(encoding.Marshal(ts))
-	return nil
+	tp.transactionSetFees[setID] = sf
+	tp.transactionListSize += int(sf.size)
+	tp.dirtySets[setID] = struct{}{}
+	return setID
 }
 
 // AcceptTransaction adds a transaction to the unconfirmed set of
@@ -290,27 +314,64 @@ func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
-	err := tp.acceptTransactionSet(ts)
+	setID, err := tp.acceptTransactionSet(ts)
 	if err != nil {
 		return err
 	}
+	// The set is persisted along with the other unconfirmed sets, once the
+	// consensus set changes or the pool is closed.
 
-	// Notify subscribers and broadcast the transaction set.
-	go tp.gateway.Broadcast("RelayTransactionSet", ts, tp.gateway.Peers())
+	// Notify subscribers and relay the transaction set
+	// to the peers which relay transactions.
+	tp.relayTransactionSets(map[TransactionSetID][]types.Transaction{setID: tp.transactionSets[setID]}, false)
 	tp.updateSubscribersTransactions()
 	return nil
 }
 
-// relayTransactionSet is an RPC that accepts a transaction set from a peer. If
-// the accept is successful, the transaction will be relayed to the gateway's
-// other peers.
-func (tp *TransactionPool) relayTransactionSet(conn modules.PeerConn) error {
-	var ts []types.Transaction
-	err := encoding.ReadObject(conn, &ts, tp.chainCts.BlockSizeLimit)
+// validateStandaloneTransactionSet returns an error if the given transaction
+// set is invalid by itself, regardless of the state of the consensus set and
+// the transaction pool. Only such sets are held against the peer which relayed
+// them, as sets which conflict with the consensus set or with other sets, or
+// which are rejected because of the state of the pool, can be relayed by
+// honest peers whose state differs from ours.
+//
+// For the same reason the protocol rules, which depend on the height of the
+// consensus set, and the standardness rules, which can differ between
+// versions of the software, are not checked: only the size, encoding and
+// structure of the transactions are.
+func (tp *TransactionPool) validateStandaloneTransactionSet(ts []types.Transaction) error {
+	if len(ts) == 0 {
+		return errEmptySet
+	}
+	totalSize := 0
+	for _, txn := range ts {
+		size := len(encoding.Marshal(txn))
+		if size > modules.TransactionSizeLimit {
+			return modules.ErrLargeTransaction
+		}
+		totalSize += size
+		if totalSize > modules.TransactionSetSizeLimit {
+			return modules.ErrLargeTransactionSet
+		}
+		err := txn.ValidateTransaction(tp.chainCts.BlockSizeLimit, tp.chainCts.ArbitraryDataSizeLimit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// penalizeInvalidTransactionSet penalizes the peer which relayed a
+// transaction set that could not be accepted, if that set is invalid by
+// itself.
+func (tp *TransactionPool) penalizeInvalidTransactionSet(addr modules.NetAddress, ts []types.Transaction, acceptErr error) {
+	if acceptErr == nil || acceptErr == modules.ErrDuplicateTransactionSet {
+		return
+	}
+	err := tp.validateStandaloneTransactionSet(ts)
 	if err != nil {
-		return err
+		tp.gateway.PenalizePeer(addr, modules.PenaltyInvalidTransactionSet, "invalid transaction set: "+err.Error())
 	}
-	return tp.AcceptTransactionSet(ts)
 }
 
 func (tp *TransactionPool) transactionMinFee() types.Currency {
@@ -0,0 +1,152 @@
+package transactionpool
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// The size of the transaction pool is bounded. Once a transaction set does
+// not fit in the pool, the sets paying the lowest fee-per-byte are evicted to
+// make room for it, as long as they pay less than the new set. As dependent
+// transactions are merged into a single set, evicting a set evicts all of its
+// dependents as well. While the pool is full, new sets have to outbid the
+// cheapest set in the pool.
+
+var (
+	errPoolSizeTooSmall = errors.New("the transaction pool has to be able to hold a transaction set of the maximum size")
+)
+
+// newSetFee returns the fee-per-byte paid by the given transaction set,
+// and its size.
+func newSetFee(ts []types.Transaction) setFee {
+	return setFee{
+		fee:  modules.CalculateFee(ts),
+		size: uint64(len(encoding.Marshal(ts))),
+	}
+}
+
+// removeTransactionSet removes a transaction set from the pool.
+func (tp *TransactionPool) removeTransactionSet(id TransactionSetID) {
+	for _, oid := range relatedObjectIDs(tp.transactionSets[id]) {
+		if tp.knownObjects[oid] == id {
+			delete(tp.knownObjects, oid)
+		}
+	}
+	tp.transactionListSize -= int(tp.transactionSetFees[id].size)
+	delete(tp.transactionSets, id)
+	delete(tp.transactionSetDiffs, id)
+	delete(tp.transactionSetFees, id)
+	tp.dirtySets[id] = struct{}{}
+}
+
+// cheapestSets returns the IDs of the transaction sets in the pool, ordered
+// from the lowest to the highest fee-per-byte, leaving out the given sets.
+func (tp *TransactionPool) cheapestSets(exclude map[TransactionSetID]struct{}) []TransactionSetID {
+	ids := make([]TransactionSetID, 0, len(tp.transactionSetFees))
+	for id := range tp.transactionSetFees {
+		if _, ok := exclude[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return tp.transactionSetFees[ids[i]].fee.Cmp(tp.transactionSetFees[ids[j]].fee) < 0
+	})
+	return ids
+}
+
+// makeRoom evicts the transaction sets paying the lowest fee-per-byte until
+// there is room for the given set, which replaces the given sets. Only sets
+// paying less than the given set are evicted, if that doesn't free enough
+// space, no set is evicted and an error is returned.
+func (tp *TransactionPool) makeRoom(sf setFee, replaced map[TransactionSetID]struct{}) error {
+	free := tp.maxSize - tp.transactionListSize
+	for id := range replaced {
+		free += int(tp.transactionSetFees[id].size)
+	}
+	if int(sf.size) <= free {
+		return nil
+	}
+
+	var evict []TransactionSetID
+	for _, id := range tp.cheapestSets(replaced) {
+		if int(sf.size) <= free {
+			break
+		}
+		cheapest := tp.transactionSetFees[id]
+		if cheapest.fee.Cmp(sf.fee) >= 0 {
+			break
+		}
+		evict = append(evict, id)
+		free += int(cheapest.size)
+	}
+	if int(sf.size) > free {
+		return errFullTransactionPool
+	}
+	for _, id := range evict {
+		tp.removeTransactionSet(id)
+	}
+	return nil
+}
+
+// minimumFee returns the fee-per-byte a transaction set has to pay in order
+// to be accepted. While the pool is full, meaning that a set of the maximum
+// size would not fit, sets have to pay more than the cheapest set in the pool.
+func (tp *TransactionPool) minimumFee() types.Currency {
+	if tp.transactionListSize+modules.TransactionSetSizeLimit <= tp.maxSize {
+		return types.ZeroCurrency
+	}
+	var cheapest types.Currency
+	var found bool
+	for _, sf := range tp.transactionSetFees {
+		if !found || sf.fee.Cmp(cheapest) < 0 {
+			cheapest, found = sf.fee, true
+		}
+	}
+	if !found {
+		return types.ZeroCurrency
+	}
+	return cheapest.Add(types.NewCurrency64(1))
+}
+
+// MinimumFee returns the fee-per-byte a transaction set has to pay in order
+// to be accepted by the transaction pool, which rises while the pool is full.
+func (tp *TransactionPool) MinimumFee() types.Currency {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.minimumFee()
+}
+
+// MaxSize returns the maximum size, in bytes, of all transaction sets in the
+// pool combined.
+func (tp *TransactionPool) MaxSize() int {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.maxSize
+}
+
+// SetMaxSize sets the maximum size, in bytes, of all transaction sets in the
+// pool combined. Should the pool be larger, the sets paying the lowest
+// fee-per-byte are evicted.
+func (tp *TransactionPool) SetMaxSize(size int) error {
+	if size < modules.TransactionSetSizeLimit {
+		return errPoolSizeTooSmall
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.maxSize = size
+	if tp.transactionListSize <= tp.maxSize {
+		return nil
+	}
+	for _, id := range tp.cheapestSets(nil) {
+		if tp.transactionListSize <= tp.maxSize {
+			break
+		}
+		tp.removeTransactionSet(id)
+	}
+	tp.updateSubscribersTransactions()
+	return nil
+}
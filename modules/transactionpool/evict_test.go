@@ -0,0 +1,335 @@
+package transactionpool
+
+import (
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/gateway"
+	"github.com/rivine/rivine/types"
+)
+
+// testOutputValue is the value of the coin outputs created by the
+// transactions of the unit tests, chosen such that all transactions with the
+// same structure encode to the same size.
+var testOutputValue = types.NewCurrency64(1 << 40)
+
+// mockConsensusSet is a consensus set which only knows a set of unspent coin
+// outputs, against which transaction sets are validated. All other methods
+// panic, as they are not implemented.
+type mockConsensusSet struct {
+	modules.ConsensusSet
+	outputs map[types.CoinOutputID]types.CoinOutput
+}
+
+// ConsensusSetSubscribe implements modules.ConsensusSet.ConsensusSetSubscribe.
+func (mcs *mockConsensusSet) ConsensusSetSubscribe(modules.ConsensusSetSubscriber, modules.ConsensusChangeID) error {
+	return nil
+}
+
+// Unsubscribe implements modules.ConsensusSet.Unsubscribe.
+func (mcs *mockConsensusSet) Unsubscribe(modules.ConsensusSetSubscriber) {}
+
+// Height implements modules.ConsensusSet.Height.
+func (mcs *mockConsensusSet) Height() types.BlockHeight {
+	return 0
+}
+
+// TryTransactionSet implements modules.ConsensusSet.TryTransactionSet,
+// checking that the transactions only spend unspent coin outputs.
+func (mcs *mockConsensusSet) TryTransactionSet(ts []types.Transaction) (modules.ConsensusChange, error) {
+	unspent := make(map[types.CoinOutputID]types.CoinOutput, len(mcs.outputs))
+	for id, co := range mcs.outputs {
+		unspent[id] = co
+	}
+	var cc modules.ConsensusChange
+	for _, txn := range ts {
+		for _, ci := range txn.CoinInputs {
+			co, ok := unspent[ci.ParentID]
+			if !ok {
+				return modules.ConsensusChange{}, errors.New("coin output is not unspent")
+			}
+			delete(unspent, ci.ParentID)
+			cc.CoinOutputDiffs = append(cc.CoinOutputDiffs, modules.CoinOutputDiff{
+				Direction:  modules.DiffRevert,
+				ID:         ci.ParentID,
+				CoinOutput: co,
+			})
+		}
+		for i, co := range txn.CoinOutputs {
+			id := txn.CoinOutputID(uint64(i))
+			unspent[id] = co
+			cc.CoinOutputDiffs = append(cc.CoinOutputDiffs, modules.CoinOutputDiff{
+				Direction:  modules.DiffApply,
+				ID:         id,
+				CoinOutput: co,
+			})
+		}
+	}
+	return cc, nil
+}
+
+// newMockTransactionPool returns a transaction pool using a mock consensus
+// set, without any unspent coin outputs.
+func newMockTransactionPool(t *testing.T) (*TransactionPool, *mockConsensusSet) {
+	testdir := build.TempDir(modules.TransactionPoolDir, t.Name())
+	bcInfo, chainCts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir), bcInfo, chainCts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcs := &mockConsensusSet{outputs: make(map[types.CoinOutputID]types.CoinOutput)}
+	tp, err := New(mcs, g, filepath.Join(testdir, modules.TransactionPoolDir), bcInfo, chainCts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tp, mcs
+}
+
+// confirmedOutput adds an unspent coin output to the mock consensus set,
+// returning its ID.
+func (mcs *mockConsensusSet) confirmedOutput() types.CoinOutputID {
+	var id types.CoinOutputID
+	rand.Read(id[:])
+	mcs.outputs[id] = types.CoinOutput{Value: testOutputValue}
+	return id
+}
+
+// newTestTransaction returns a transaction spending the given coin outputs,
+// which creates n coin outputs and pays the given miner fee.
+func newTestTransaction(tp *TransactionPool, inputs []types.CoinOutputID, n int, fee types.Currency) types.Transaction {
+	txn := types.Transaction{
+		Version:   tp.chainCts.DefaultTransactionVersion,
+		MinerFees: []types.Currency{fee},
+	}
+	for _, id := range inputs {
+		txn.CoinInputs = append(txn.CoinInputs, types.CoinInput{
+			ParentID: id,
+			Fulfillment: types.NewFulfillment(&types.SingleSignatureFulfillment{
+				PublicKey: types.Ed25519PublicKey(crypto.PublicKey{}),
+				Signature: make([]byte, crypto.SignatureSize),
+			}),
+		})
+	}
+	for i := 0; i < n; i++ {
+		uh := types.UnlockHash{Type: types.UnlockTypePubKey}
+		rand.Read(uh.Hash[:])
+		txn.CoinOutputs = append(txn.CoinOutputs, types.CoinOutput{
+			Value:     testOutputValue,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(uh)),
+		})
+	}
+	return txn
+}
+
+// addTestSets adds a transaction set for each of the given fees to the pool,
+// each consisting of a single transaction spending a confirmed output, and
+// returns their IDs. All sets have the same size.
+func addTestSets(t *testing.T, tp *TransactionPool, mcs *mockConsensusSet, fees ...uint64) []TransactionSetID {
+	ids := make([]TransactionSetID, 0, len(fees))
+	for _, fee := range fees {
+		txn := newTestTransaction(tp, []types.CoinOutputID{mcs.confirmedOutput()}, 1, types.NewCurrency64(fee<<32))
+		id, err := tp.acceptTransactionSet([]types.Transaction{txn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// TestMakeRoom probes the eviction of the cheapest transaction sets, in order
+// to make room for a new set.
+func TestMakeRoom(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name string
+		// fees of the sets in the pool
+		fees []uint64
+		// room in the pool, in number of sets
+		room int
+		// fee and size, in number of sets, of the new set
+		fee  uint64
+		size int
+		// indices of the sets the new set replaces
+		replaced []int
+		// indices of the sets which are evicted, in order,
+		// nil if the set does not fit
+		evicted []int
+	}{
+		{"fits", []uint64{3, 1, 2}, 4, 1, 1, nil, []int{}},
+		{"evicts the cheapest", []uint64{3, 1, 2}, 3, 4, 1, nil, []int{1}},
+		{"evicts the cheapest sets", []uint64{3, 1, 2}, 3, 4, 2, nil, []int{1, 2}},
+		{"only evicts cheaper sets", []uint64{3, 1, 2}, 3, 2, 2, nil, nil},
+		{"doesn't outbid the cheapest", []uint64{3, 1, 2}, 3, 1, 1, nil, nil},
+		{"replaced sets make room", []uint64{3, 1, 2}, 3, 1, 1, []int{0}, []int{}},
+		{"replaced sets are not evicted", []uint64{3, 1, 2}, 3, 4, 2, []int{1}, []int{2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp, mcs := newMockTransactionPool(t)
+			defer tp.Close()
+			ids := addTestSets(t, tp, mcs, test.fees...)
+			setSize := int(tp.transactionSetFees[ids[0]].size)
+			tp.maxSize = test.room * setSize
+
+			replaced := make(map[TransactionSetID]struct{})
+			for _, i := range test.replaced {
+				replaced[ids[i]] = struct{}{}
+			}
+			sf := setFee{
+				fee:  tp.transactionSetFees[ids[0]].fee.Div64(test.fees[0]).Mul64(test.fee),
+				size: uint64(test.size * setSize),
+			}
+			err := tp.makeRoom(sf, replaced)
+			if test.evicted == nil {
+				if err != errFullTransactionPool {
+					t.Fatal("expected errFullTransactionPool, got", err)
+				}
+				if len(tp.transactionSets) != len(test.fees) {
+					t.Fatal("no set should be evicted if the new set does not fit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tp.transactionSets) != len(test.fees)-len(test.evicted) {
+				t.Fatal("expected", len(test.evicted), "evicted sets, got", len(test.fees)-len(tp.transactionSets))
+			}
+			for _, i := range test.evicted {
+				if _, ok := tp.transactionSets[ids[i]]; ok {
+					t.Error("set", i, "should be evicted")
+				}
+			}
+			if tp.transactionListSize != (len(test.fees)-len(test.evicted))*setSize {
+				t.Error("size of the pool does not match the remaining sets:", tp.transactionListSize)
+			}
+		})
+	}
+}
+
+// TestMinimumFee checks that the minimum fee rises to outbid the cheapest set
+// while the pool is full.
+func TestMinimumFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tp, mcs := newMockTransactionPool(t)
+	defer tp.Close()
+	if !tp.minimumFee().IsZero() {
+		t.Fatal("an empty pool should not require a fee")
+	}
+	ids := addTestSets(t, tp, mcs, 2, 3)
+	if !tp.minimumFee().IsZero() {
+		t.Fatal("a pool which isn't full should not require a fee")
+	}
+
+	// fill the pool, such that the cheapest set has to be outbid
+	tp.maxSize = tp.transactionListSize
+	expected := tp.transactionSetFees[ids[0]].fee.Add(types.NewCurrency64(1))
+	if tp.minimumFee().Cmp(expected) != 0 {
+		t.Fatal("expected minimum fee", expected, "got", tp.minimumFee())
+	}
+
+	// a set paying the minimum fee evicts the cheapest set,
+	// after which the minimum fee rises to the next cheapest set
+	txn := newTestTransaction(tp, []types.CoinOutputID{mcs.confirmedOutput()}, 1, types.NewCurrency64(4<<32))
+	if _, err := tp.acceptTransactionSet([]types.Transaction{txn}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tp.transactionSets[ids[0]]; ok {
+		t.Fatal("the cheapest set should be evicted")
+	}
+	expected = tp.transactionSetFees[ids[1]].fee.Add(types.NewCurrency64(1))
+	if tp.minimumFee().Cmp(expected) != 0 {
+		t.Fatal("expected minimum fee", expected, "got", tp.minimumFee())
+	}
+
+	// a set paying less than the minimum fee is rejected
+	txn = newTestTransaction(tp, []types.CoinOutputID{mcs.confirmedOutput()}, 1, types.NewCurrency64(3<<32))
+	if _, err := tp.acceptTransactionSet([]types.Transaction{txn}); err != errLowMinerFees {
+		t.Fatal("expected errLowMinerFees, got", err)
+	}
+}
+
+// TestEvictDescendants checks that the descendants of an evicted transaction
+// are evicted along with it, as they are part of the same set.
+func TestEvictDescendants(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tp, mcs := newMockTransactionPool(t)
+	defer tp.Close()
+
+	parent := newTestTransaction(tp, []types.CoinOutputID{mcs.confirmedOutput()}, 1, types.NewCurrency64(1<<32))
+	child := newTestTransaction(tp, []types.CoinOutputID{parent.CoinOutputID(0)}, 1, types.NewCurrency64(1<<32))
+	if _, err := tp.acceptTransactionSet([]types.Transaction{parent}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tp.acceptTransactionSet([]types.Transaction{child}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tp.transactionSets) != 1 {
+		t.Fatal("the child should be merged into the set of its parent")
+	}
+
+	// make room for a single, more expensive, set of the same size
+	tp.maxSize = tp.transactionListSize
+	addTestSets(t, tp, mcs, 2)
+	if len(tp.transactionSets) != 1 {
+		t.Fatal("expected a single set in the pool, got", len(tp.transactionSets))
+	}
+	for _, txn := range tp.TransactionList() {
+		if txn.ID() == parent.ID() || txn.ID() == child.ID() {
+			t.Fatal("the parent and its child should be evicted")
+		}
+	}
+	if _, ok := tp.knownObjects[ObjectID(child.CoinOutputID(0))]; ok {
+		t.Fatal("the outputs of the child should be forgotten")
+	}
+}
+
+// TestSetMaxSize checks that shrinking the pool evicts the cheapest sets.
+func TestSetMaxSize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tp, mcs := newMockTransactionPool(t)
+	defer tp.Close()
+	if err := tp.SetMaxSize(modules.TransactionSetSizeLimit - 1); err != errPoolSizeTooSmall {
+		t.Fatal("expected errPoolSizeTooSmall, got", err)
+	}
+
+	// add sets of close to the maximum transaction size, with descending
+	// fees, until the pool holds more than the smallest allowed size
+	var ids []TransactionSetID
+	for fee := uint64(100); tp.transactionListSize <= modules.TransactionSetSizeLimit; fee-- {
+		txn := newTestTransaction(tp, []types.CoinOutputID{mcs.confirmedOutput()}, 250, types.NewCurrency64(fee<<32))
+		id, err := tp.acceptTransactionSet([]types.Transaction{txn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := tp.SetMaxSize(modules.TransactionSetSizeLimit); err != nil {
+		t.Fatal(err)
+	}
+	if tp.MaxSize() != modules.TransactionSetSizeLimit || tp.transactionListSize > tp.MaxSize() {
+		t.Fatal("pool does not fit within its maximum size:", tp.transactionListSize)
+	}
+	// only the cheapest, most recently added, set had to be evicted
+	last := len(ids) - 1
+	if _, ok := tp.transactionSets[ids[last]]; ok {
+		t.Fatal("the cheapest set should be evicted")
+	}
+	if len(tp.transactionSets) != last {
+		t.Fatal("only the cheapest set should be evicted, got", len(ids)-len(tp.transactionSets))
+	}
+}
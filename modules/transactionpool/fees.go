@@ -0,0 +1,188 @@
+package transactionpool
+
+import (
+	"sort"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// Fees are estimated using the fees-per-byte paid by the transactions of
+// recent blocks, and by the transaction sets waiting in the pool. A block
+// which was full only accepted transactions paying at least as much as its
+// cheapest transaction, while a block which was not full accepted any fee.
+// A transaction set gets confirmed within a target number of blocks if it
+// gets into any one of them, such that for every window of that many
+// consecutive recent blocks, the lowest fee-per-byte required by one of its
+// blocks is what a set had to pay. The low, medium and high estimations are
+// percentiles of these requirements. On top of that, a set has to outbid the
+// sets in the pool which don't fit in the blocks of its target, and has to pay
+// at least the minimum fee of the pool.
+
+const (
+	// blockSizeReserved is the space of a block which the block creator
+	// does not fill with transactions from the transaction pool.
+	blockSizeReserved = 5e3
+
+	// fullBlockPercentage is the percentage of the available space of a
+	// block which has to be filled with transactions for it to be full.
+	fullBlockPercentage = 95
+
+	// The percentiles of the fee requirements of recent blocks,
+	// used as low, medium and high fee estimation.
+	lowFeePercentile    = 50
+	mediumFeePercentile = 75
+	highFeePercentile   = 95
+)
+
+var (
+	// feeEstimationBlocks is the number of recent blocks of which the fee
+	// requirements are tracked, which is also the maximum estimation target.
+	feeEstimationBlocks = build.Select(build.Var{
+		Standard: types.BlockHeight(144),
+		Dev:      types.BlockHeight(50),
+		Testing:  types.BlockHeight(10),
+	}).(types.BlockHeight)
+)
+
+type (
+	// blockFees contains the fee-per-byte a transaction set
+	// had to pay in order to get into a block.
+	blockFees struct {
+		id       types.BlockID
+		required types.Currency
+	}
+
+	// setFee is the fee-per-byte paid by a transaction set, and its size.
+	setFee struct {
+		fee  types.Currency
+		size uint64
+	}
+)
+
+// blockCapacity returns the space of a block available for transactions.
+func (tp *TransactionPool) blockCapacity() uint64 {
+	return tp.chainCts.BlockSizeLimit - blockSizeReserved
+}
+
+// blockFeeRequirement returns the fee-per-byte a transaction set had to pay
+// in order to get into the given block. Transactions which pay no fees, such
+// as the block stake transaction of the block creator, are not taken into
+// account.
+func (tp *TransactionPool) blockFeeRequirement(b types.Block) types.Currency {
+	var size uint64
+	var cheapest types.Currency
+	var paid bool
+	for _, txn := range b.Transactions {
+		size += uint64(len(encoding.Marshal(txn)))
+		if len(txn.MinerFees) == 0 {
+			continue
+		}
+		fee := modules.CalculateFee([]types.Transaction{txn})
+		if !paid || fee.Cmp(cheapest) < 0 {
+			cheapest, paid = fee, true
+		}
+	}
+	if size*100 < tp.blockCapacity()*fullBlockPercentage {
+		return types.ZeroCurrency
+	}
+	return cheapest
+}
+
+// updateRecentBlockFees updates the fee requirements of the recent blocks
+// using the blocks reverted and applied by the given consensus change.
+func (tp *TransactionPool) updateRecentBlockFees(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		n := len(tp.recentBlockFees)
+		if n > 0 && tp.recentBlockFees[n-1].id == block.ID() {
+			tp.recentBlockFees = tp.recentBlockFees[:n-1]
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		tp.recentBlockFees = append(tp.recentBlockFees, blockFees{
+			id:       block.ID(),
+			required: tp.blockFeeRequirement(block),
+		})
+	}
+	if n := len(tp.recentBlockFees); n > int(feeEstimationBlocks) {
+		tp.recentBlockFees = tp.recentBlockFees[n-int(feeEstimationBlocks):]
+	}
+}
+
+// poolFeeRequirement returns the fee-per-byte a transaction set has to pay
+// in order to outbid the sets in the pool which don't fit in the given
+// number of blocks.
+func (tp *TransactionPool) poolFeeRequirement(target types.BlockHeight) types.Currency {
+	fees := make([]setFee, 0, len(tp.transactionSetFees))
+	for _, sf := range tp.transactionSetFees {
+		fees = append(fees, sf)
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].fee.Cmp(fees[j].fee) > 0
+	})
+
+	space := tp.blockCapacity() * uint64(target)
+	for _, sf := range fees {
+		if sf.size > space {
+			return sf.fee.Add(types.NewCurrency64(1))
+		}
+		space -= sf.size
+	}
+	return types.ZeroCurrency
+}
+
+// percentile returns the p-th percentile of the given sorted fees,
+// or zero if no fees are given.
+func percentile(fees []types.Currency, p int) types.Currency {
+	if len(fees) == 0 {
+		return types.ZeroCurrency
+	}
+	return fees[(len(fees)-1)*p/100]
+}
+
+// maxCurrency returns the largest of the two given currencies.
+func maxCurrency(a, b types.Currency) types.Currency {
+	if a.Cmp(b) < 0 {
+		return b
+	}
+	return a
+}
+
+// FeeEstimation returns the suggested fees-per-byte for getting a
+// transaction set confirmed within the given number of blocks. The target
+// is limited to the number of recent blocks which are tracked.
+func (tp *TransactionPool) FeeEstimation(target types.BlockHeight) modules.FeeEstimate {
+	if target < 1 {
+		target = 1
+	} else if target > feeEstimationBlocks {
+		target = feeEstimationBlocks
+	}
+
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	// A set had to pay the lowest requirement of the blocks of each window.
+	var required []types.Currency
+	for i := 0; i+int(target) <= len(tp.recentBlockFees); i++ {
+		req := tp.recentBlockFees[i].required
+		for _, bf := range tp.recentBlockFees[i+1 : i+int(target)] {
+			if bf.required.Cmp(req) < 0 {
+				req = bf.required
+			}
+		}
+		required = append(required, req)
+	}
+	sort.Slice(required, func(i, j int) bool {
+		return required[i].Cmp(required[j]) < 0
+	})
+
+	pool := maxCurrency(tp.poolFeeRequirement(target), tp.minimumFee())
+	return modules.FeeEstimate{
+		Target: target,
+		Low:    maxCurrency(percentile(required, lowFeePercentile), pool),
+		Medium: maxCurrency(percentile(required, mediumFeePercentile), pool),
+		High:   maxCurrency(percentile(required, highFeePercentile), pool),
+	}
+}
@@ -0,0 +1,96 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+// TestFeeEstimation probes the fee estimation based on the fee requirements
+// of recent blocks, for different targets.
+func TestFeeEstimation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name string
+		// fee requirements of the recent blocks, oldest first
+		required []uint64
+		target   types.BlockHeight
+		// expected target and low, medium and high estimation
+		expectedTarget types.BlockHeight
+		expected       [3]uint64
+	}{
+		{"empty history", nil, 1, 1, [3]uint64{0, 0, 0}},
+		{"target below one", []uint64{4}, 0, 1, [3]uint64{4, 4, 4}},
+		{"target beyond the window", []uint64{4, 4, 4, 4, 4, 4, 4, 4, 4, 4}, 1000, feeEstimationBlocks, [3]uint64{4, 4, 4}},
+		{"history shorter than the target", []uint64{4, 4}, 3, 3, [3]uint64{0, 0, 0}},
+		{"percentiles", []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 1, 1, [3]uint64{5, 7, 9}},
+		{"cheapest block of each window", []uint64{9, 1, 9, 9, 2, 9, 9, 3, 9, 9}, 2, 2, [3]uint64{3, 9, 9}},
+		{"empty blocks", []uint64{9, 0, 9, 9, 0, 9, 9, 0, 9, 9}, 3, 3, [3]uint64{0, 0, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp, _ := newMockTransactionPool(t)
+			defer tp.Close()
+			for i, req := range test.required {
+				tp.recentBlockFees = append(tp.recentBlockFees, blockFees{
+					id:       types.BlockID{byte(i)},
+					required: types.NewCurrency64(req),
+				})
+			}
+			est := tp.FeeEstimation(test.target)
+			if est.Target != test.expectedTarget {
+				t.Fatal("expected target", test.expectedTarget, "got", est.Target)
+			}
+			for i, fee := range []types.Currency{est.Low, est.Medium, est.High} {
+				if fee.Cmp(types.NewCurrency64(test.expected[i])) != 0 {
+					t.Fatalf("expected %v, got %v", test.expected, est)
+				}
+			}
+		})
+	}
+}
+
+// TestFeeEstimationPool checks that the fee estimation outbids the sets in
+// the pool which don't fit in the target number of blocks, and that it is at
+// least the minimum fee of the pool.
+func TestFeeEstimationPool(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tp, mcs := newMockTransactionPool(t)
+	defer tp.Close()
+	ids := addTestSets(t, tp, mcs, 3, 1, 2)
+	cheapest, next := tp.transactionSetFees[ids[1]], tp.transactionSetFees[ids[2]]
+
+	// a block only fits 2 of the sets
+	tp.chainCts.BlockSizeLimit = blockSizeReserved + 2*cheapest.size
+	if est := tp.FeeEstimation(2); !est.High.IsZero() {
+		t.Fatal("expected all sets to fit in the target, got", est)
+	}
+	est := tp.FeeEstimation(1)
+	expected := cheapest.fee.Add(types.NewCurrency64(1))
+	if est.Low.Cmp(expected) != 0 || est.High.Cmp(expected) != 0 {
+		t.Fatalf("expected the cheapest set %v to be outbid, got %v", cheapest.fee, est)
+	}
+
+	// the history is used once it requires more than the pool
+	tp.recentBlockFees = []blockFees{{required: next.fee.Mul64(2)}}
+	if est := tp.FeeEstimation(1); est.Low.Cmp(next.fee.Mul64(2)) != 0 {
+		t.Fatal("expected the fee requirement of the recent block, got", est)
+	}
+
+	// while the pool is full, the estimation is at least its minimum fee
+	tp.recentBlockFees = nil
+	tp.chainCts.BlockSizeLimit = blockSizeReserved + 10*cheapest.size
+	tp.maxSize = tp.transactionListSize
+	minFee := tp.minimumFee()
+	if minFee.IsZero() {
+		t.Fatal("expected a full pool to require a minimum fee")
+	}
+	est = tp.FeeEstimation(1)
+	if est.Low.Cmp(minFee) != 0 || est.High.Cmp(minFee) != 0 {
+		t.Fatalf("expected the minimum fee %v, got %v", minFee, est)
+	}
+}
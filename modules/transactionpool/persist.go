@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/persist"
 	"github.com/rivine/rivine/types"
@@ -21,6 +22,10 @@ var (
 	// been confirmed on the blockchain.
 	bucketConfirmedTransactions = []byte("ConfirmedTransactions")
 
+	// bucketUnconfirmedTransactionSets holds the transaction sets of the
+	// pool, such that they survive a restart.
+	bucketUnconfirmedTransactionSets = []byte("UnconfirmedTransactionSets")
+
 	// errNilConsensusChange is returned if there is no consensus change in the
 	// database.
 	errNilConsensusChange = errors.New("no consensus change found")
@@ -52,19 +57,29 @@ func (tp *TransactionPool) initPersist() error {
 		return err
 	}
 
+	// Start logging.
+	tp.log, err = persist.NewFileLogger(tp.bcInfo,
+		filepath.Join(tp.persistDir, logFile))
+	if err != nil {
+		return err
+	}
+
 	// Open the database file.
 	tp.db, err = persist.OpenDatabase(dbMetadata, filepath.Join(tp.persistDir, dbFilename))
 	if err != nil {
 		return err
 	}
 
-	// Create the database and get the most recent consensus change.
+	// Create the database and get the most recent consensus change, as well
+	// as the transaction sets which were unconfirmed when the pool was closed.
 	var cc modules.ConsensusChangeID
+	var unconfirmedSets map[TransactionSetID][]types.Transaction
 	err = tp.db.Update(func(tx *bolt.Tx) error {
 		// Create the database buckets.
 		buckets := [][]byte{
 			bucketRecentConsensusChange,
 			bucketConfirmedTransactions,
+			bucketUnconfirmedTransactionSets,
 		}
 		for _, bucket := range buckets {
 			_, err := tx.CreateBucketIfNotExists(bucket)
@@ -73,6 +88,11 @@ func (tp *TransactionPool) initPersist() error {
 			}
 		}
 
+		unconfirmedSets, err = tp.getUnconfirmedSets(tx)
+		if err != nil {
+			return err
+		}
+
 		// Get the recent consensus change.
 		cc, err = tp.getRecentConsensusChange(tx)
 		if err == errNilConsensusChange {
@@ -95,9 +115,74 @@ func (tp *TransactionPool) initPersist() error {
 		if resetErr != nil {
 			return resetErr
 		}
-		return tp.consensusSet.ConsensusSetSubscribe(tp, modules.ConsensusChangeBeginning)
+		err = tp.consensusSet.ConsensusSetSubscribe(tp, modules.ConsensusChangeBeginning)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Revalidate the unconfirmed transaction sets against the consensus set,
+	// sets which are no longer valid are removed from the database.
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for id, ts := range unconfirmedSets {
+		tp.dirtySets[id] = struct{}{}
+		tp.acceptTransactionSet(ts) // Error is not checked.
+	}
+	return tp.saveUnconfirmedSets()
+}
+
+// getUnconfirmedSets returns the unconfirmed transaction sets from the
+// database.
+func (tp *TransactionPool) getUnconfirmedSets(tx *bolt.Tx) (map[TransactionSetID][]types.Transaction, error) {
+	sets := make(map[TransactionSetID][]types.Transaction)
+	err := tx.Bucket(bucketUnconfirmedTransactionSets).ForEach(func(k, v []byte) error {
+		var id TransactionSetID
+		copy(id[:], k)
+		var ts []types.Transaction
+		err := encoding.Unmarshal(v, &ts)
+		if err != nil {
+			return err
+		}
+		sets[id] = ts
+		return nil
+	})
+	return sets, err
+}
+
+// saveUnconfirmedSets persists the transaction sets which were added to or
+// removed from the pool since the unconfirmed sets were last persisted.
+func (tp *TransactionPool) saveUnconfirmedSets() error {
+	if len(tp.dirtySets) == 0 {
+		return nil
+	}
+	err := tp.db.Update(func(tx *bolt.Tx) error {
+		return tp.putUnconfirmedSets(tx)
+	})
+	if err != nil {
+		return err
+	}
+	tp.dirtySets = make(map[TransactionSetID]struct{})
+	return nil
+}
+
+// putUnconfirmedSets updates the unconfirmed transaction sets in the
+// database, for the sets which were added to or removed from the pool since
+// the last update.
+func (tp *TransactionPool) putUnconfirmedSets(tx *bolt.Tx) error {
+	bucket := tx.Bucket(bucketUnconfirmedTransactionSets)
+	for id := range tp.dirtySets {
+		var err error
+		if ts, ok := tp.transactionSets[id]; !ok {
+			err = bucket.Delete(id[:])
+		} else if bucket.Get(id[:]) == nil {
+			err = bucket.Put(id[:], encoding.Marshal(ts))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // getRecentConsensusChange returns the most recent consensus change from the
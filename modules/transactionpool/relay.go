@@ -0,0 +1,321 @@
+package transactionpool
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// Transaction sets are relayed using inventory announcements. Rather than
+// pushing a full transaction set to all peers, the IDs of new transaction
+// sets are announced to the peers which are not known to have them yet.
+// A peer which receives an announcement requests the sets it lacks, one at
+// a time, using the SendTxnSet RPC, after which it announces those
+// sets to its own peers. For each peer, the transaction pool remembers the
+// sets which were announced to or by it, or which it sent, such that no set
+// is announced or sent twice to the same peer. Peers which do not support
+// inventory announcements still receive the full transaction sets.
+
+const (
+	// maxAnnouncedSetIDs is the maximum number of transaction set IDs
+	// which can be announced in a single announcement.
+	maxAnnouncedSetIDs = 1000
+
+	// maxKnownSetIDs is the maximum number of transaction set IDs which are
+	// remembered per peer. Once reached, the oldest IDs are forgotten first.
+	maxKnownSetIDs = 10000
+)
+
+var (
+	// inventoryRelayUpgrade is the first protocol version
+	// which supports inventory announcements.
+	inventoryRelayUpgrade = build.NewPrereleaseVersion(1, 0, 9, "alpha")
+
+	// defaultRebroadcastDelay is the default number of blocks a transaction
+	// set has to remain unconfirmed before it is broadcast again.
+	defaultRebroadcastDelay = build.Select(build.Var{
+		Standard: types.BlockHeight(6),
+		Dev:      types.BlockHeight(5),
+		Testing:  types.BlockHeight(2),
+	}).(types.BlockHeight)
+
+	errZeroRebroadcastDelay = errors.New("the rebroadcast delay has to be at least one block")
+
+	errTooManySetIDs            = errors.New("announcement contains too many transaction set IDs")
+	errUnknownTransactionSet    = errors.New("transaction set is not in the transaction pool")
+	errUnexpectedTransactionSet = errors.New("peer sent a different transaction set than the one requested")
+)
+
+// inventoryFilter is a bounded set of the transaction set IDs known by a peer.
+type inventoryFilter struct {
+	ids   map[TransactionSetID]struct{}
+	order []TransactionSetID
+}
+
+func newInventoryFilter() *inventoryFilter {
+	return &inventoryFilter{ids: make(map[TransactionSetID]struct{})}
+}
+
+// add marks the given ID as known,
+// forgetting the oldest ID if the maximum is reached.
+func (f *inventoryFilter) add(id TransactionSetID) {
+	if _, ok := f.ids[id]; ok {
+		return
+	}
+	if len(f.order) >= maxKnownSetIDs {
+		delete(f.ids, f.order[0])
+		f.order = f.order[1:]
+	}
+	f.ids[id] = struct{}{}
+	f.order = append(f.order, id)
+}
+
+// has returns whether or not the given ID is known.
+func (f *inventoryFilter) has(id TransactionSetID) bool {
+	_, ok := f.ids[id]
+	return ok
+}
+
+// transactionSetID returns the ID of a transaction set.
+func transactionSetID(ts []types.Transaction) TransactionSetID {
+	return TransactionSetID(crypto.HashObject(ts))
+}
+
+// markKnown marks the given transaction sets as known by the given peer.
+func (tp *TransactionPool) markKnown(addr modules.NetAddress, ids ...TransactionSetID) {
+	known, ok := tp.knownSetIDs[addr]
+	if !ok {
+		known = newInventoryFilter()
+		tp.knownSetIDs[addr] = known
+	}
+	for _, id := range ids {
+		known.add(id)
+	}
+}
+
+// relayTransactionSets announces the given transaction sets to the peers
+// which relay transactions and are not known to have them, sending the full
+// sets to the peers which do not support inventory announcements. When
+// rebroadcasting, the sets are relayed to all of those peers, as they might
+// have dropped them since. The IDs due to a peer are announced in batches of
+// at most maxAnnouncedSetIDs, and peers which are due the same IDs share a
+// single broadcast per batch.
+func (tp *TransactionPool) relayTransactionSets(sets map[TransactionSetID][]types.Transaction, rebroadcast bool) {
+	peers := modules.PeersWithServices(tp.gateway.Peers(), modules.ServiceRelayTransactions)
+
+	// forget the peers which are no longer connected
+	connected := make(map[modules.NetAddress]struct{}, len(peers))
+	for _, p := range peers {
+		connected[p.NetAddress] = struct{}{}
+	}
+	for addr := range tp.knownSetIDs {
+		if _, ok := connected[addr]; !ok {
+			delete(tp.knownSetIDs, addr)
+		}
+	}
+
+	ids := make([]TransactionSetID, 0, len(sets))
+	for id := range sets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+
+	// group the peers by the IDs which are due to them
+	announcements := make(map[crypto.Hash][]TransactionSetID)
+	announcePeers := make(map[crypto.Hash][]modules.Peer)
+	legacyPeers := make(map[TransactionSetID][]modules.Peer)
+	for _, p := range peers {
+		known := tp.knownSetIDs[p.NetAddress]
+		var due []TransactionSetID
+		for _, id := range ids {
+			if known != nil && known.has(id) && !rebroadcast {
+				continue
+			}
+			due = append(due, id)
+		}
+		if len(due) == 0 {
+			continue
+		}
+		tp.markKnown(p.NetAddress, due...)
+		if p.Version.Compare(inventoryRelayUpgrade) >= 0 {
+			key := crypto.HashObject(due)
+			announcements[key] = due
+			announcePeers[key] = append(announcePeers[key], p)
+		} else {
+			for _, id := range due {
+				legacyPeers[id] = append(legacyPeers[id], p)
+			}
+		}
+	}
+	for key, due := range announcements {
+		for len(due) > 0 {
+			n := len(due)
+			if n > maxAnnouncedSetIDs {
+				n = maxAnnouncedSetIDs
+			}
+			go tp.gateway.Broadcast("RelayTxnSetIDs", due[:n], announcePeers[key])
+			due = due[n:]
+		}
+	}
+	for id, peers := range legacyPeers {
+		go tp.gateway.Broadcast("RelayTransactionSet", sets[id], peers)
+	}
+}
+
+// rebroadcastTransactionSets ages the transaction sets in the pool by the
+// number of blocks applied by the given consensus change, and broadcasts the
+// sets which remained unconfirmed for rebroadcastDelay blocks once more. Sets
+// are only rebroadcast once the consensus set is synced.
+func (tp *TransactionPool) rebroadcastTransactionSets(cc modules.ConsensusChange) {
+	ages := make(map[TransactionSetID]types.BlockHeight, len(tp.transactionSets))
+	due := make(map[TransactionSetID][]types.Transaction)
+	for id, ts := range tp.transactionSets {
+		age := tp.broadcastAges[id] + types.BlockHeight(len(cc.AppliedBlocks))
+		if cc.Synced && age >= tp.rebroadcastDelay {
+			due[id] = ts
+			age = 0
+		}
+		ages[id] = age
+	}
+	tp.broadcastAges = ages
+	if len(due) > 0 {
+		tp.relayTransactionSets(due, true)
+	}
+}
+
+// RebroadcastDelay returns the number of blocks a transaction set has to
+// remain unconfirmed before it is broadcast again.
+func (tp *TransactionPool) RebroadcastDelay() types.BlockHeight {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.rebroadcastDelay
+}
+
+// SetRebroadcastDelay sets the number of blocks a transaction set has to
+// remain unconfirmed before it is broadcast again.
+func (tp *TransactionPool) SetRebroadcastDelay(blocks types.BlockHeight) error {
+	if blocks == 0 {
+		return errZeroRebroadcastDelay
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.rebroadcastDelay = blocks
+	return nil
+}
+
+// rpcRelayTransactionSet is an RPC that accepts a transaction set from a
+// peer. It is used by peers which do not support inventory announcements.
+// If the accept is successful, the transaction set will be relayed to the
+// gateway's other peers.
+func (tp *TransactionPool) rpcRelayTransactionSet(conn modules.PeerConn) error {
+	var ts []types.Transaction
+	err := encoding.ReadObject(conn, &ts, tp.chainCts.BlockSizeLimit)
+	if err != nil {
+		return err
+	}
+	tp.mu.Lock()
+	tp.markKnown(conn.RPCAddr(), transactionSetID(ts))
+	tp.mu.Unlock()
+
+	err = tp.AcceptTransactionSet(ts)
+	tp.penalizeInvalidTransactionSet(conn.RPCAddr(), ts, err)
+	return err
+}
+
+// rpcRelayTxnSetIDs is an RPC that receives the IDs of transaction
+// sets announced by a peer, and requests the sets which are not yet in the
+// transaction pool.
+func (tp *TransactionPool) rpcRelayTxnSetIDs(conn modules.PeerConn) error {
+	var ids []TransactionSetID
+	err := encoding.ReadObject(conn, &ids, 8+maxAnnouncedSetIDs*crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	if len(ids) > maxAnnouncedSetIDs {
+		return errTooManySetIDs
+	}
+
+	addr := conn.RPCAddr()
+	var missing []TransactionSetID
+	tp.mu.Lock()
+	tp.markKnown(addr, ids...)
+	for _, id := range ids {
+		if _, ok := tp.transactionSets[id]; ok {
+			continue
+		}
+		if _, ok := tp.requestedSetIDs[id]; ok {
+			continue
+		}
+		tp.requestedSetIDs[id] = struct{}{}
+		missing = append(missing, id)
+	}
+	tp.mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Request the missing sets. The calls need to be made in a separate
+	// goroutine, as this RPC is called from the gateway.
+	go func() {
+		for _, id := range missing {
+			// Sets which can't be fetched, or which are not accepted,
+			// are requested again once announced by another peer.
+			tp.gateway.RPC(addr, "SendTxnSet", tp.managedReceiveTransactionSet(id))
+			tp.mu.Lock()
+			delete(tp.requestedSetIDs, id)
+			tp.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// rpcSendTxnSet is an RPC that sends the requested transaction set
+// to the requesting peer.
+func (tp *TransactionPool) rpcSendTxnSet(conn modules.PeerConn) error {
+	var id TransactionSetID
+	err := encoding.ReadObject(conn, &id, crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	tp.mu.Lock()
+	ts, ok := tp.transactionSets[id]
+	if ok {
+		tp.markKnown(conn.RPCAddr(), id)
+	}
+	tp.mu.Unlock()
+	if !ok {
+		return errUnknownTransactionSet
+	}
+	return encoding.WriteObject(conn, ts)
+}
+
+// managedReceiveTransactionSet takes a transaction set ID and returns an
+// RPCFunc that requests that set and then calls AcceptTransactionSet on it.
+// The returned function should be used as the calling end of the
+// SendTxnSet RPC.
+func (tp *TransactionPool) managedReceiveTransactionSet(id TransactionSetID) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := encoding.WriteObject(conn, id); err != nil {
+			return err
+		}
+		var ts []types.Transaction
+		if err := encoding.ReadObject(conn, &ts, tp.chainCts.BlockSizeLimit); err != nil {
+			return err
+		}
+		if transactionSetID(ts) != id {
+			tp.gateway.PenalizePeer(conn.RPCAddr(), modules.PenaltyInvalidTransactionSet, errUnexpectedTransactionSet.Error())
+			return errUnexpectedTransactionSet
+		}
+		err := tp.AcceptTransactionSet(ts)
+		tp.penalizeInvalidTransactionSet(conn.RPCAddr(), ts, err)
+		return err
+	}
+}
@@ -0,0 +1,79 @@
+package transactionpool
+
+import (
+	"errors"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// A transaction set which double spends the outputs spent by sets in the pool
+// is rejected, unless the replace-by-fee policy is enabled. With the policy
+// enabled, such a set replaces all sets it conflicts with, as long as it is
+// valid by itself and pays a strictly higher total fee than those sets
+// combined, as well as a strictly higher fee-per-byte than each of them. As
+// dependent transactions are merged into a single set, the dependents of the
+// replaced transactions are replaced as well. Subscribers learn about the
+// replaced sets through the next transaction pool update, which no longer
+// contains them.
+
+var (
+	errReplacementFees = errors.New("replacement transaction set has to pay more fees than the transaction sets it replaces")
+)
+
+// totalFee returns the sum of the miner fees paid by a transaction set.
+func totalFee(ts []types.Transaction) types.Currency {
+	var sum types.Currency
+	for _, txn := range ts {
+		for _, fee := range txn.MinerFees {
+			sum = sum.Add(fee)
+		}
+	}
+	return sum
+}
+
+// replaceTransactionSets replaces the given conflicting transaction sets with
+// the given set, which is valid by itself, resulting in the given consensus
+// change. The set has to outbid the sets it replaces.
+func (tp *TransactionPool) replaceTransactionSets(ts []types.Transaction, cc modules.ConsensusChange, conflicts map[TransactionSetID]struct{}) (TransactionSetID, error) {
+	if !tp.replaceByFee {
+		return TransactionSetID{}, errObjectConflict
+	}
+
+	sf := newSetFee(ts)
+	var replacedFee types.Currency
+	for conflict := range conflicts {
+		if sf.fee.Cmp(tp.transactionSetFees[conflict].fee) <= 0 {
+			return TransactionSetID{}, errReplacementFees
+		}
+		replacedFee = replacedFee.Add(totalFee(tp.transactionSets[conflict]))
+	}
+	if totalFee(ts).Cmp(replacedFee) <= 0 {
+		return TransactionSetID{}, errReplacementFees
+	}
+
+	// Make room for the set, evicting the cheapest sets if needed.
+	err := tp.makeRoom(sf, conflicts)
+	if err != nil {
+		return TransactionSetID{}, err
+	}
+	for conflict := range conflicts {
+		tp.removeTransactionSet(conflict)
+	}
+	return tp.addTransactionSet(ts, relatedObjectIDs(ts), cc, sf), nil
+}
+
+// ReplaceByFee returns whether or not the replace-by-fee policy is enabled.
+func (tp *TransactionPool) ReplaceByFee() bool {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.replaceByFee
+}
+
+// SetReplaceByFee enables or disables the replace-by-fee policy, which allows
+// transaction sets to replace the sets they double spend by paying more fees.
+func (tp *TransactionPool) SetReplaceByFee(enabled bool) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.replaceByFee = enabled
+}
@@ -0,0 +1,103 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+// TestReplaceTransactionSets probes the replace-by-fee policy, replacing a
+// set with a child and a set without one, which both pay a fee.
+func TestReplaceTransactionSets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name         string
+		replaceByFee bool
+		// the conflicting sets which are double spent,
+		// 0 being the set with a child and 1 the set without one
+		spends []int
+		// number of outputs and fee of the replacement
+		outputs int
+		fee     uint64
+		err     error
+	}{
+		{"policy disabled", false, []int{1}, 1, 100, errObjectConflict},
+		{"equal fee", true, []int{1}, 1, 3, errReplacementFees},
+		{"higher total fee, lower fee-per-byte", true, []int{1}, 40, 10, errReplacementFees},
+		{"higher fee-per-byte, lower total fee", true, []int{0, 1}, 1, 6, errReplacementFees},
+		{"outbids the set and its child", true, []int{0}, 1, 5, nil},
+		{"outbids all conflicts", true, []int{0, 1}, 1, 8, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp, mcs := newMockTransactionPool(t)
+			defer tp.Close()
+			tp.SetReplaceByFee(test.replaceByFee)
+
+			outputs := []types.CoinOutputID{mcs.confirmedOutput(), mcs.confirmedOutput()}
+			parent := newTestTransaction(tp, outputs[0:1], 1, types.NewCurrency64(2<<32))
+			child := newTestTransaction(tp, []types.CoinOutputID{parent.CoinOutputID(0)}, 1, types.NewCurrency64(2<<32))
+			other := newTestTransaction(tp, outputs[1:2], 1, types.NewCurrency64(3<<32))
+			var ids []TransactionSetID
+			for _, txn := range []types.Transaction{parent, child, other} {
+				id, err := tp.acceptTransactionSet([]types.Transaction{txn})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			// the child got merged into the set of its parent
+			conflicts := []TransactionSetID{ids[1], ids[2]}
+			if len(tp.transactionSets) != 2 {
+				t.Fatal("expected 2 sets in the pool, got", len(tp.transactionSets))
+			}
+
+			var inputs []types.CoinOutputID
+			for _, i := range test.spends {
+				inputs = append(inputs, outputs[i])
+			}
+			replacement := newTestTransaction(tp, inputs, test.outputs, types.NewCurrency64(test.fee<<32))
+			id, err := tp.acceptTransactionSet([]types.Transaction{replacement})
+			if err != test.err {
+				t.Fatal("expected", test.err, "got", err)
+			}
+			if err != nil {
+				for _, conflict := range conflicts {
+					if _, ok := tp.transactionSets[conflict]; !ok {
+						t.Fatal("a rejected replacement should not remove any set")
+					}
+				}
+				return
+			}
+
+			if _, ok := tp.transactionSets[id]; !ok {
+				t.Fatal("the replacement is not in the pool")
+			}
+			if len(tp.transactionSets) != 1+len(conflicts)-len(test.spends) {
+				t.Fatal("expected only the conflicts to be replaced, got", len(tp.transactionSets), "sets")
+			}
+			for _, i := range test.spends {
+				if _, ok := tp.transactionSets[conflicts[i]]; ok {
+					t.Fatal("conflicting set", i, "should be replaced")
+				}
+			}
+			if _, ok := tp.knownObjects[ObjectID(child.CoinOutputID(0))]; ok {
+				t.Fatal("the outputs of the replaced child should be forgotten")
+			}
+			for _, oid := range relatedObjectIDs([]types.Transaction{replacement}) {
+				if tp.knownObjects[oid] != id {
+					t.Fatal("the objects of the replacement should belong to its set")
+				}
+			}
+			size := 0
+			for _, sf := range tp.transactionSetFees {
+				size += int(sf.size)
+			}
+			if tp.transactionListSize != size {
+				t.Fatal("size of the pool does not match its sets:", tp.transactionListSize, size)
+			}
+		})
+	}
+}
@@ -44,6 +44,17 @@ func (tp *TransactionPool) IsStandardTransaction(t types.Transaction) error {
 		return err
 	}
 
+	// check if the transaction only uses features of the protocol which are
+	// active in the next block, and adheres to the rules of the active upgrades
+	rules := tp.chainCts.ProtocolRules(tp.consensusSet.Height() + 1)
+	err = rules.ValidateTransaction(t, types.TransactionValidationConstants{
+		BlockSizeLimit:         tp.chainCts.BlockSizeLimit,
+		ArbitraryDataSizeLimit: tp.chainCts.ArbitraryDataSizeLimit,
+	})
+	if err != nil {
+		return err
+	}
+
 	// Check that the size of the transaction does not exceed the standard
 	// established in Standard.md. Larger transactions are a DOS vector,
 	// because someone can fill a large transaction with a bunch of signatures
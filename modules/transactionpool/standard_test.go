@@ -23,7 +23,6 @@ func TestIntegrationLargeTransactions(t *testing.T) {
 
 	// Create a large transaction and try to get it accepted.
 	arbData := make([]byte, modules.TransactionSizeLimit)
-	copy(arbData, modules.PrefixNonSia[:])
 	_, err = rand.Read(arbData[100:116]) // prevents collisions with other transacitons in the loop.
 	if err != nil {
 		t.Fatal(err)
@@ -41,7 +40,6 @@ func TestIntegrationLargeTransactions(t *testing.T) {
 	var tset []types.Transaction
 	for i := 0; i <= modules.TransactionSetSizeLimit/10e3; i++ {
 		arbData := make([]byte, 10e3)
-		copy(arbData, modules.PrefixNonSia[:])
 		_, err = rand.Read(arbData[100:116]) // prevents collisions with other transacitons in the loop.
 		if err != nil {
 			t.Fatal(err)
@@ -2,9 +2,11 @@ package transactionpool
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/NebulousLabs/demotemutex"
 
+	"github.com/rivine/rivine/build"
 	"github.com/rivine/rivine/crypto"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/persist"
@@ -13,6 +15,7 @@ import (
 
 const (
 	dbFilename = "transactionpool.db"
+	logFile    = "transactionpool.log"
 )
 
 var (
@@ -56,12 +59,45 @@ type (
 		transactionSets     map[TransactionSetID][]types.Transaction
 		transactionSetDiffs map[TransactionSetID]modules.ConsensusChange
 		transactionListSize int
+
+		// transactionSetFees contains the fee-per-byte and size of each
+		// transaction set, used to evict the cheapest sets once the size of
+		// all sets combined exceeds maxSize.
+		transactionSetFees map[TransactionSetID]setFee
+		maxSize            int
+
+		// dirtySets contains the IDs of the transaction sets which were added
+		// to or removed from the pool since the unconfirmed sets were last
+		// persisted. The unconfirmed sets are only persisted when the
+		// consensus set changes and when the pool is closed, such that
+		// accepting a transaction set does not require a database write.
+		dirtySets map[TransactionSetID]struct{}
+
+		// replaceByFee indicates whether or not transaction sets can replace
+		// the sets they double spend by paying more fees.
+		replaceByFee bool
 		// TODO: Write a consistency check comparing transactionSets,
 		// transactionSetDiffs.
 		//
 		// TODO: Write a consistency check making sure that all unconfirmedIDs
 		// point to the right place, and that all UnconfirmedIDs are accounted for.
 
+		// knownSetIDs contains, per peer, the IDs of the transaction sets
+		// which were announced to or by that peer. requestedSetIDs contains
+		// the IDs of the announced sets which are being requested.
+		knownSetIDs     map[modules.NetAddress]*inventoryFilter
+		requestedSetIDs map[TransactionSetID]struct{}
+
+		// broadcastAges contains, per transaction set, the number of blocks
+		// since it was last broadcast. Sets which remain unconfirmed for
+		// rebroadcastDelay blocks are broadcast again.
+		broadcastAges    map[TransactionSetID]types.BlockHeight
+		rebroadcastDelay types.BlockHeight
+
+		// recentBlockFees contains the fee requirements of the most recent
+		// blocks, used to estimate the fees of new transaction sets.
+		recentBlockFees []blockFees
+
 		// The consensus change index tracks how many consensus changes have
 		// been sent to the transaction pool. When a new subscriber joins the
 		// transaction pool, all prior consensus changes are sent to the new
@@ -70,6 +106,7 @@ type (
 
 		// Utilities.
 		db         *persist.BoltDatabase
+		log        *persist.Logger
 		mu         demotemutex.DemoteMutex
 		persistDir string
 
@@ -96,6 +133,15 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string, bcInfo t
 		knownObjects:        make(map[ObjectID]TransactionSetID),
 		transactionSets:     make(map[TransactionSetID][]types.Transaction),
 		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
+		transactionSetFees:  make(map[TransactionSetID]setFee),
+		maxSize:             DefaultTransactionPoolSize,
+		dirtySets:           make(map[TransactionSetID]struct{}),
+
+		knownSetIDs:     make(map[modules.NetAddress]*inventoryFilter),
+		requestedSetIDs: make(map[TransactionSetID]struct{}),
+
+		broadcastAges:    make(map[TransactionSetID]types.BlockHeight),
+		rebroadcastDelay: defaultRebroadcastDelay,
 
 		persistDir: persistDir,
 
@@ -110,24 +156,34 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string, bcInfo t
 	}
 
 	// Register RPCs
-	g.RegisterRPC("RelayTransactionSet", tp.relayTransactionSet)
+	g.RegisterRPC("RelayTransactionSet", tp.rpcRelayTransactionSet)
+	g.RegisterRPC("RelayTxnSetIDs", tp.rpcRelayTxnSetIDs)
+	g.RegisterRPC("SendTxnSet", tp.rpcSendTxnSet)
 
 	return tp, nil
 }
 
+// Close persists the unconfirmed transaction sets and closes the
+// transaction pool.
 func (tp *TransactionPool) Close() error {
 	tp.gateway.UnregisterRPC("RelayTransactionSet")
+	tp.gateway.UnregisterRPC("RelayTxnSetIDs")
+	tp.gateway.UnregisterRPC("SendTxnSet")
 	tp.consensusSet.Unsubscribe(tp)
-	return tp.db.Close()
-}
 
-// FeeEstimation returns an estimation for what fee should be applied to
-// transactions.
-func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
-	// TODO: The fee estimation tool should look at the recent blocks and use
-	// them to gauge what sort of fee should be required, as opposed to just
-	// guessing blindly.
-	return tp.chainCts.MinimumTransactionFee, tp.chainCts.MinimumTransactionFee
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	var errs []error
+	if err := tp.saveUnconfirmedSets(); err != nil {
+		errs = append(errs, fmt.Errorf("saving the unconfirmed transaction sets failed: %v", err))
+	}
+	if err := tp.db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("db.Close failed: %v", err))
+	}
+	if err := tp.log.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("log.Close failed: %v", err))
+	}
+	return build.JoinErrors(errs, "; ")
 }
 
 // TransactionList returns a list of all transactions in the transaction pool.
@@ -11,6 +11,7 @@ import (
 	"github.com/rivine/rivine/modules/consensus"
 	"github.com/rivine/rivine/modules/gateway"
 	"github.com/rivine/rivine/modules/wallet"
+	"github.com/rivine/rivine/types"
 )
 
 // A tpoolTester is used during testing to initialize a transaction pool and
@@ -31,19 +32,20 @@ type tpoolTester struct {
 func createTpoolTester(name string) (*tpoolTester, error) {
 	// Initialize the modules.
 	testdir := build.TempDir(modules.TransactionPoolDir, name)
-	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	bcInfo, chainCts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir), bcInfo, chainCts, nil)
 	if err != nil {
 		return nil, err
 	}
-	cs, err := consensus.New(g, false, filepath.Join(testdir, modules.ConsensusDir))
+	cs, err := consensus.New(g, false, filepath.Join(testdir, modules.ConsensusDir), bcInfo, chainCts)
 	if err != nil {
 		return nil, err
 	}
-	tp, err := New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
+	tp, err := New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir), bcInfo, chainCts)
 	if err != nil {
 		return nil, err
 	}
-	w, err := wallet.New(cs, tp, filepath.Join(testdir, modules.WalletDir))
+	w, err := wallet.New(cs, tp, filepath.Join(testdir, modules.WalletDir), bcInfo, chainCts)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +54,7 @@ func createTpoolTester(name string) (*tpoolTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, err = w.Encrypt(key)
+	_, err = w.Encrypt(key, modules.Seed{})
 	if err != nil {
 		return nil, err
 	}
@@ -110,30 +112,31 @@ func (tpt *tpoolTester) Close() error {
 func TestIntegrationNewNilInputs(t *testing.T) {
 	// Create a gateway and consensus set.
 	testdir := build.TempDir(modules.TransactionPoolDir, t.Name())
-	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	bcInfo, chainCts := types.DefaultBlockchainInfo(), types.DefaultChainConstants()
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir), bcInfo, chainCts, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	cs, err := consensus.New(g, false, filepath.Join(testdir, modules.ConsensusDir))
+	cs, err := consensus.New(g, false, filepath.Join(testdir, modules.ConsensusDir), bcInfo, chainCts)
 	if err != nil {
 		t.Fatal(err)
 	}
 	tpDir := filepath.Join(testdir, modules.TransactionPoolDir)
 
 	// Try all combinations of nil inputs.
-	_, err = New(nil, nil, tpDir)
+	_, err = New(nil, nil, tpDir, bcInfo, chainCts)
 	if err == nil {
 		t.Error(err)
 	}
-	_, err = New(nil, g, tpDir)
+	_, err = New(nil, g, tpDir, bcInfo, chainCts)
 	if err != errNilCS {
 		t.Error(err)
 	}
-	_, err = New(cs, nil, tpDir)
+	_, err = New(cs, nil, tpDir, bcInfo, chainCts)
 	if err != errNilGateway {
 		t.Error(err)
 	}
-	_, err = New(cs, g, tpDir)
+	_, err = New(cs, g, tpDir, bcInfo, chainCts)
 	if err != nil {
 		t.Error(err)
 	}
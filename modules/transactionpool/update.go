@@ -9,9 +9,13 @@ import (
 
 // purge removes all transactions from the transaction pool.
 func (tp *TransactionPool) purge() {
+	for id := range tp.transactionSets {
+		tp.dirtySets[id] = struct{}{}
+	}
 	tp.knownObjects = make(map[ObjectID]TransactionSetID)
 	tp.transactionSets = make(map[TransactionSetID][]types.Transaction)
 	tp.transactionSetDiffs = make(map[TransactionSetID]modules.ConsensusChange)
+	tp.transactionSetFees = make(map[TransactionSetID]setFee)
 	tp.transactionListSize = 0
 }
 
@@ -41,9 +45,11 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 		return tp.putRecentConsensusChange(tx, cc.ID)
 	})
 	if err != nil {
-		// TODO: Handle error
+		tp.log.Severe("ERROR: could not update the confirmed transactions:", err)
 	}
 
+	tp.updateRecentBlockFees(cc)
+
 	// Scan the applied blocks for transactions that got accepted. This will
 	// help to determine which transactions to remove from the transaction
 	// pool. Having this list enables both efficiency improvements and helps to
@@ -110,6 +116,15 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	for _, set := range unconfirmedSets {
 		tp.acceptTransactionSet(set) // Error is not checked.
 	}
+	// The sets which can't be persisted remain dirty, such that persisting
+	// them is retried on the next consensus change.
+	err = tp.saveUnconfirmedSets()
+	if err != nil {
+		tp.log.Println("WARN: could not persist the unconfirmed transaction sets:", err)
+	}
+
+	// Rebroadcast the transaction sets which remain unconfirmed.
+	tp.rebroadcastTransactionSets(cc)
 
 	// Inform subscribers that an update has executed.
 	tp.mu.Demote()
@@ -381,6 +381,12 @@ type (
 		// The transaction is automatically given to the transaction pool, and is also returned to the caller.
 		SendOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs []types.BlockStakeOutput, data []byte) (types.Transaction, error)
 
+		// BumpFee replaces an unconfirmed transaction sent by the wallet with a
+		// transaction spending the same outputs, paying a higher fee. The
+		// replacement is automatically given to the transaction pool, and is
+		// also returned to the caller.
+		BumpFee(id types.TransactionID) (types.Transaction, error)
+
 		// BlockStakeStats returns the blockstake statistical information of
 		// this wallet of the last 1000 blocks. If the blockcount is less than
 		// 1000 blocks, BlockCount will be the number available.
@@ -5,12 +5,23 @@ import (
 	"strconv"
 
 	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/types"
 )
 
+const (
+	// maxFeeAdjustments is the maximum number of times a transaction is
+	// built again in order to pay the estimated fee.
+	maxFeeAdjustments = 3
+)
+
 var (
 	ErrNilOutputs = errors.New("nil outputs cannot be send")
+
+	errUnknownUnconfirmedTransaction = errors.New("transaction is not an unconfirmed transaction of the wallet")
+	errForeignInputs                 = errors.New("transaction spends outputs which are not owned by the wallet")
+	errNoFeeOutput                   = errors.New("transaction has no output to the wallet which can pay the higher fee")
 )
 
 // sortedOutputs is a struct containing a slice of siacoin outputs and their
@@ -202,19 +213,19 @@ func (w *Wallet) MultiSigWallets() []modules.MultiSigWallet {
 }
 
 // SendCoins creates a transaction sending 'amount' to whoever can fulfill the condition. If data is provided,
-// it is added as arbitrary data to the transaction. The transaction
-// is submitted to the transaction pool and is also returned.
+// it is added as arbitrary data to the transaction. The transaction pays the fee estimated by the
+// transaction pool, and is submitted to the transaction pool and is also returned.
 func (w *Wallet) SendCoins(amount types.Currency, cond types.UnlockConditionProxy, data []byte) (types.Transaction, error) {
 	return w.SendOutputs([]types.CoinOutput{
 		{
 			Condition: cond,
 			Value:     amount,
 		},
-	}, nil, nil)
+	}, nil, data)
 }
 
 // SendBlockStakes creates a transaction sending 'amount' to whoever can fulfill the condition. The transaction
-// is submitted to the transaction pool and is also returned.
+// pays the fee estimated by the transaction pool, and is submitted to the transaction pool and is also returned.
 func (w *Wallet) SendBlockStakes(amount types.Currency, cond types.UnlockConditionProxy) (types.Transaction, error) {
 	return w.SendOutputs(nil, []types.BlockStakeOutput{
 		{
@@ -225,7 +236,8 @@ func (w *Wallet) SendBlockStakes(amount types.Currency, cond types.UnlockConditi
 }
 
 // SendOutputs is a tool for sending coins and block stakes from the wallet, to one or multiple addreses.
-// The transaction is automatically given to the transaction pool, and is also returned to the caller.
+// The transaction pays the fee-per-byte estimated by the transaction pool for its full size, arbitrary data included,
+// and is automatically given to the transaction pool, and is also returned to the caller.
 func (w *Wallet) SendOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs []types.BlockStakeOutput, data []byte) (types.Transaction, error) {
 	if len(coinOutputs) == 0 && len(blockstakeOutputs) == 0 {
 		// at least one coin output OR one block stake output has to be send
@@ -237,8 +249,143 @@ func (w *Wallet) SendOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs [
 	}
 	defer w.tg.Done()
 
-	tpoolFee := w.chainCts.MinimumTransactionFee.Mul64(1) // TODO better fee algo
-	totalAmount := types.NewCurrency64(0).Add(tpoolFee)
+	// The fee-per-byte of the signed transaction set has to match the
+	// estimation of the transaction pool. The size of the set is only known
+	// once signed, such that the set is built again with a higher fee
+	// should the fee turn out to be too low.
+	feePerByte := w.tpool.FeeEstimation(modules.DefaultFeeTarget).Medium
+	fee := w.chainCts.MinimumTransactionFee
+	var txnSet []types.Transaction
+	for attempt := 0; ; attempt++ {
+		txnBuilder, err := w.buildOutputs(coinOutputs, blockstakeOutputs, data, fee)
+		if err != nil {
+			return types.Transaction{}, err
+		}
+		txnSet, err = txnBuilder.Sign()
+		if err != nil {
+			txnBuilder.Drop()
+			return types.Transaction{}, err
+		}
+		if attempt == maxFeeAdjustments || modules.CalculateFee(txnSet).Cmp(feePerByte) >= 0 {
+			break
+		}
+		txnBuilder.Drop()
+		fee = feePerByte.Mul64(uint64(len(encoding.Marshal(txnSet))))
+	}
+	if len(txnSet) == 0 {
+		panic("unexpected txnSet length: " + strconv.Itoa(len(txnSet)))
+	}
+	err := w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	return txnSet[0], nil
+}
+
+// BumpFee replaces an unconfirmed transaction sent by the wallet with a
+// transaction spending the same outputs, paying a higher fee. The fee is
+// raised to the fee estimation of the transaction pool, and by at least the
+// minimum transaction fee. The increase is paid from the last output of the
+// transaction which belongs to the wallet, usually its refund output. The
+// replacement is submitted to the transaction pool, which only accepts it if
+// replace-by-fee is enabled, and is also returned to the caller.
+func (w *Wallet) BumpFee(id types.TransactionID) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+
+	txn, feeOutput, err := w.bumpableTransaction(id)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+
+	var oldFee types.Currency
+	for _, fee := range txn.MinerFees {
+		oldFee = oldFee.Add(fee)
+	}
+	fee := oldFee.Add(w.chainCts.MinimumTransactionFee)
+	estimation := w.tpool.FeeEstimation(modules.DefaultFeeTarget).Medium.Mul64(uint64(len(encoding.Marshal(txn))))
+	if estimation.Cmp(fee) > 0 {
+		fee = estimation
+	}
+	increase := fee.Sub(oldFee)
+	if txn.CoinOutputs[feeOutput].Value.Cmp(increase) <= 0 {
+		return types.Transaction{}, errNoFeeOutput
+	}
+
+	// Build the replacement, spending the same outputs without fulfillments,
+	// such that all inputs get signed again.
+	replacement := types.Transaction{
+		Version:           txn.Version,
+		CoinInputs:        make([]types.CoinInput, len(txn.CoinInputs)),
+		CoinOutputs:       append([]types.CoinOutput(nil), txn.CoinOutputs...),
+		BlockStakeInputs:  make([]types.BlockStakeInput, len(txn.BlockStakeInputs)),
+		BlockStakeOutputs: append([]types.BlockStakeOutput(nil), txn.BlockStakeOutputs...),
+		MinerFees:         []types.Currency{fee},
+		ArbitraryData:     txn.ArbitraryData,
+		Extension:         txn.Extension,
+	}
+	for i, ci := range txn.CoinInputs {
+		replacement.CoinInputs[i] = types.CoinInput{ParentID: ci.ParentID}
+	}
+	for i, bsi := range txn.BlockStakeInputs {
+		replacement.BlockStakeInputs[i] = types.BlockStakeInput{ParentID: bsi.ParentID}
+	}
+	replacement.CoinOutputs[feeOutput].Value = replacement.CoinOutputs[feeOutput].Value.Sub(increase)
+
+	txnBuilder := w.RegisterTransaction(replacement, nil)
+	err = txnBuilder.SignAllPossibleInputs()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	replacement, _ = txnBuilder.View()
+	err = w.tpool.AcceptTransactionSet([]types.Transaction{replacement})
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	return replacement, nil
+}
+
+// bumpableTransaction returns the unconfirmed transaction with the given ID,
+// as long as all of its inputs belong to the wallet, as well as the index of
+// the last coin output which belongs to the wallet.
+func (w *Wallet) bumpableTransaction(id types.TransactionID) (types.Transaction, int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		if upt.TransactionID != id {
+			continue
+		}
+		for _, input := range upt.Inputs {
+			if input.FundType == types.SpecifierCoinInput && !input.WalletAddress {
+				return types.Transaction{}, 0, errForeignInputs
+			}
+		}
+		for _, bsi := range upt.Transaction.BlockStakeInputs {
+			if _, exists := w.blockstakeOutputs[bsi.ParentID]; !exists {
+				return types.Transaction{}, 0, errForeignInputs
+			}
+		}
+		feeOutput := -1
+		for i, co := range upt.Transaction.CoinOutputs {
+			if _, exists := w.keys[co.Condition.UnlockHash()]; exists {
+				feeOutput = i
+			}
+		}
+		if feeOutput == -1 {
+			return types.Transaction{}, 0, errNoFeeOutput
+		}
+		return upt.Transaction, feeOutput, nil
+	}
+	return types.Transaction{}, 0, errUnknownUnconfirmedTransaction
+}
+
+// buildOutputs returns a transaction builder for a transaction sending the
+// given outputs and data, paying the given fee, funded by the wallet.
+func (w *Wallet) buildOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs []types.BlockStakeOutput, data []byte, fee types.Currency) (modules.TransactionBuilder, error) {
+	totalAmount := fee
 	txnBuilder := w.StartTransaction()
 	for _, co := range coinOutputs {
 		txnBuilder.AddCoinOutput(co)
@@ -246,9 +393,10 @@ func (w *Wallet) SendOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs [
 	}
 	err := txnBuilder.FundCoins(totalAmount)
 	if err != nil {
-		return types.Transaction{}, err
+		txnBuilder.Drop()
+		return nil, err
 	}
-	txnBuilder.AddMinerFee(tpoolFee)
+	txnBuilder.AddMinerFee(fee)
 	totalAmount = types.NewCurrency64(0)
 	for _, bso := range blockstakeOutputs {
 		txnBuilder.AddBlockStakeOutput(bso)
@@ -257,24 +405,14 @@ func (w *Wallet) SendOutputs(coinOutputs []types.CoinOutput, blockstakeOutputs [
 	if !totalAmount.Equals64(0) {
 		err = txnBuilder.FundBlockStakes(totalAmount)
 		if err != nil {
-			return types.Transaction{}, err
+			txnBuilder.Drop()
+			return nil, err
 		}
 	}
 	if len(data) != 0 {
 		txnBuilder.SetArbitraryData(data)
 	}
-	txnSet, err := txnBuilder.Sign()
-	if err != nil {
-		return types.Transaction{}, err
-	}
-	if len(txnSet) == 0 {
-		panic("unexpected txnSet length: " + strconv.Itoa(len(txnSet)))
-	}
-	err = w.tpool.AcceptTransactionSet(txnSet)
-	if err != nil {
-		return types.Transaction{}, err
-	}
-	return txnSet[0], nil
+	return txnBuilder, nil
 }
 
 // Len returns the number of elements in the sortedOutputs struct.
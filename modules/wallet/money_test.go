@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"bytes"
 	"sort"
 	"testing"
 
@@ -214,3 +215,137 @@ func TestNilOutputs(t *testing.T) {
 		t.Fatal("expected ErrNilOutput, but receiver: ", err)
 	}
 }
+
+// TestBumpFee probes the cases in which the wallet can't bump the fee of a
+// transaction.
+func TestBumpFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	cs := newConsensusSetStub()
+	wt, err := createWalletTesterWithStubCS(t.Name(), cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// unknown transactions can't be bumped
+	_, err = wt.wallet.BumpFee(types.TransactionID{1})
+	if err != errUnknownUnconfirmedTransaction {
+		t.Fatal("expected errUnknownUnconfirmedTransaction, got", err)
+	}
+
+	// give the wallet just enough money to send 5000 hastings,
+	// leaving a refund which can't pay for a higher fee
+	addr, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.addTransactionAsBlock(addr,
+		wt.wallet.chainCts.MinimumTransactionFee.Add(types.NewCurrency64(5010)))
+	txn, err := wt.wallet.SendCoins(types.NewCurrency64(5000), types.NewCondition(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.BumpFee(txn.ID())
+	if err != errNoFeeOutput {
+		t.Fatal("expected errNoFeeOutput, got", err)
+	}
+
+	// confirmed transactions can't be bumped
+	err = cs.AcceptBlock(types.Block{
+		ParentID:     cs.CurrentBlock().ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		Transactions: []types.Transaction{txn},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.BumpFee(txn.ID())
+	if err != errUnknownUnconfirmedTransaction {
+		t.Fatal("expected errUnknownUnconfirmedTransaction, got", err)
+	}
+}
+
+// estimatingTransactionPool is a transaction pool which estimates a fixed
+// fee-per-byte.
+type estimatingTransactionPool struct {
+	modules.TransactionPool
+	feePerByte types.Currency
+}
+
+// FeeEstimation implements modules.TransactionPool.FeeEstimation.
+func (etp estimatingTransactionPool) FeeEstimation(target types.BlockHeight) modules.FeeEstimate {
+	return modules.FeeEstimate{Target: target, Low: etp.feePerByte, Medium: etp.feePerByte, High: etp.feePerByte}
+}
+
+// TestSendFeeEstimation checks that all ways of sending pay the fee-per-byte
+// estimated by the transaction pool, taking arbitrary data into account.
+func TestSendFeeEstimation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	cs := newConsensusSetStub()
+	wt, err := createWalletTesterWithStubCS(t.Name(), cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	cts := wt.wallet.chainCts
+	feePerByte := cts.MinimumTransactionFee.Div64(50)
+	wt.wallet.tpool = estimatingTransactionPool{TransactionPool: wt.tpool, feePerByte: feePerByte}
+
+	// give the wallet coins and block stakes to send
+	addr, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as unconfirmed outputs can't be spent, each send spends another output
+	condition := types.NewCondition(types.NewUnlockHashCondition(addr))
+	funding := types.Transaction{
+		Version:           cts.DefaultTransactionVersion,
+		BlockStakeOutputs: []types.BlockStakeOutput{{Value: types.NewCurrency64(100), Condition: condition}},
+	}
+	for i := 0; i < 3; i++ {
+		funding.CoinOutputs = append(funding.CoinOutputs, types.CoinOutput{Value: cts.CurrencyUnits.OneCoin.Mul64(100), Condition: condition})
+	}
+	err = cs.AcceptBlock(types.Block{
+		ParentID:     cs.CurrentBlock().ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		Transactions: []types.Transaction{funding},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, cts.ArbitraryDataSizeLimit)
+	tests := []struct {
+		name string
+		send func() (types.Transaction, error)
+		data []byte
+	}{
+		{"coins", func() (types.Transaction, error) {
+			return wt.wallet.SendCoins(types.NewCurrency64(5000), types.NewCondition(nil), nil)
+		}, nil},
+		{"coins with data", func() (types.Transaction, error) {
+			return wt.wallet.SendCoins(types.NewCurrency64(1), types.NewCondition(nil), data)
+		}, data},
+		{"block stakes", func() (types.Transaction, error) {
+			return wt.wallet.SendBlockStakes(types.NewCurrency64(10), types.NewCondition(nil))
+		}, nil},
+	}
+	for _, test := range tests {
+		txn, err := test.send()
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if !bytes.Equal(txn.ArbitraryData, test.data) {
+			t.Error(test.name, "did not send the arbitrary data")
+		}
+		if fee := modules.CalculateFee([]types.Transaction{txn}); fee.Cmp(feePerByte) < 0 {
+			t.Errorf("%s paid %v per byte, expected at least %v", test.name, fee, feePerByte)
+		}
+	}
+}
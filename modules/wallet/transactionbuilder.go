@@ -346,12 +346,15 @@ func (tb *transactionBuilder) Drop() {
 		for _, sci := range txn.CoinInputs {
 			delete(tb.wallet.spentOutputs, types.OutputID(sci.ParentID))
 		}
+		for _, bsi := range txn.BlockStakeInputs {
+			delete(tb.wallet.spentOutputs, types.OutputID(bsi.ParentID))
+		}
 	}
 
 	tb.parents = nil
 	tb.signed = false
 	tb.transaction = types.Transaction{
-		Version: tb.wallet.chainCts.DefaultTransactionVersion,
+		Version: tb.wallet.defaultTransactionVersion(),
 	}
 
 	tb.newParents = nil
@@ -645,9 +648,16 @@ func (w *Wallet) RegisterTransaction(t types.Transaction, parents []types.Transa
 }
 
 // StartTransaction is a convenience function that calls
-// StartTransactionWithVersion with the DefaultTransactionVersion constant.
+// StartTransactionWithVersion with the default transaction version
+// of the protocol rules that apply to the next block.
 func (w *Wallet) StartTransaction() modules.TransactionBuilder {
-	return w.StartTransactionWithVersion(w.chainCts.DefaultTransactionVersion)
+	return w.StartTransactionWithVersion(w.defaultTransactionVersion())
+}
+
+// defaultTransactionVersion returns the default transaction version
+// of the protocol rules that apply to the next block.
+func (w *Wallet) defaultTransactionVersion() types.TransactionVersion {
+	return w.chainCts.ProtocolRules(w.cs.Height() + 1).DefaultTransactionVersion
 }
 
 // StartTransactionWithVersion is a convenience function that calls
@@ -378,13 +378,20 @@ func processAppliedBlock(block types.Block, subscriber modules.ConsensusSetSubsc
 		AppliedBlocks: []types.Block{block},
 	}
 	for _, tx := range block.Transactions {
-		for _, co := range tx.CoinOutputs {
+		for i, co := range tx.CoinOutputs {
 			cc.CoinOutputDiffs = append(cc.CoinOutputDiffs, modules.CoinOutputDiff{
 				Direction:  modules.DiffApply,
-				ID:         types.CoinOutputID(crypto.HashObject(co)),
+				ID:         tx.CoinOutputID(uint64(i)),
 				CoinOutput: co,
 			})
 		}
+		for i, bso := range tx.BlockStakeOutputs {
+			cc.BlockStakeOutputDiffs = append(cc.BlockStakeOutputDiffs, modules.BlockStakeOutputDiff{
+				Direction:        modules.DiffApply,
+				ID:               tx.BlockStakeOutputID(uint64(i)),
+				BlockStakeOutput: bso,
+			})
+		}
 	}
 	subscriber.ProcessConsensusChange(cc)
 }
@@ -432,6 +439,17 @@ func (css *consensusSetStub) TransactionAtID(id types.TransactionID) (types.Tran
 	return types.Transaction{}, 0, false
 }
 
+func (css *consensusSetStub) TransactionProof(id types.TransactionID) (types.TransactionProof, bool) {
+	for _, b := range css.blocks {
+		for j, t := range b.Transactions {
+			if t.ID() == id {
+				return b.TransactionProof(j)
+			}
+		}
+	}
+	return types.TransactionProof{}, false
+}
+
 func (css *consensusSetStub) FindParentBlock(b types.Block, depth types.BlockHeight) (block types.Block, exists bool) {
 	var blockIndex int
 	for i, block := range css.blocks {
@@ -471,6 +489,10 @@ func (css *consensusSetStub) Height() types.BlockHeight {
 	return types.BlockHeight(len(css.blocks))
 }
 
+func (css *consensusSetStub) PruneHeight() types.BlockHeight {
+	return 0
+}
+
 func (css *consensusSetStub) Synced() bool {
 	return true
 }
@@ -552,10 +574,10 @@ func (css *consensusSetStub) TryTransactionSet(txs []types.Transaction) (change
 		ID: modules.ConsensusChangeID(crypto.HashObject(block)),
 	}
 	for _, tx := range block.Transactions {
-		for _, co := range tx.CoinOutputs {
+		for i, co := range tx.CoinOutputs {
 			cc.CoinOutputDiffs = append(cc.CoinOutputDiffs, modules.CoinOutputDiff{
 				Direction:  modules.DiffApply,
-				ID:         types.CoinOutputID(crypto.HashObject(co)),
+				ID:         tx.CoinOutputID(uint64(i)),
 				CoinOutput: co,
 			})
 		}
@@ -583,6 +605,18 @@ func (css *consensusSetStub) ConsensusSetSubscribe(subscriber modules.ConsensusS
 	return nil
 }
 
+func (css *consensusSetStub) ConsensusSetSubscribeAsync(subscriber modules.ConsensusSetSubscriber, changeID modules.ConsensusChangeID, queueSize int) error {
+	return css.ConsensusSetSubscribe(subscriber, changeID)
+}
+
+func (css *consensusSetStub) ConsensusSetSubscribeFiltered(subscriber modules.ConsensusSetSubscriber, changeID modules.ConsensusChangeID, filter modules.ConsensusChangeFilter) error {
+	return css.ConsensusSetSubscribe(subscriber, changeID)
+}
+
+func (css *consensusSetStub) AsyncSubscriberStats() []modules.ConsensusSubscriberStats {
+	return nil
+}
+
 func (css *consensusSetStub) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
 	delete(css.subscribers, subscriber)
 }
@@ -0,0 +1,395 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	errKVStoreClosed = errors.New("key-value store has been closed")
+)
+
+type (
+	// memoryKVStore implements KVStore in memory. A read-write transaction
+	// keeps its modifications in an overlay, which is merged into the
+	// committed buckets when the transaction is committed, such that only
+	// the modified keys are copied. Read-only transactions run concurrently
+	// with each other and with a read-write transaction, which only waits
+	// for them in order to commit.
+	memoryKVStore struct {
+		buckets map[string]map[string][]byte
+		closed  bool
+		// readers counts the read-only transactions in progress,
+		// noReaders is signalled once that count drops to zero.
+		readers   int
+		noReaders *sync.Cond
+		mu        sync.Mutex
+		// writeMu ensures only a single read-write transaction runs at a time.
+		writeMu sync.Mutex
+	}
+
+	// memoryKVTx implements KVTx for the memoryKVStore.
+	memoryKVTx struct {
+		buckets  map[string]map[string][]byte
+		writable bool
+		// changes contains the overlays of the buckets
+		// modified by a read-write transaction.
+		changes map[string]*memoryKVChanges
+	}
+
+	// memoryKVChanges is the overlay of a bucket modified within a read-write
+	// transaction.
+	memoryKVChanges struct {
+		// deleted is true if the bucket is deleted,
+		// created is true if the bucket is (re)created, in which case
+		// the committed data of the bucket is ignored.
+		deleted bool
+		created bool
+		// keys contains the modified keys,
+		// deleted keys have a nil value.
+		keys map[string][]byte
+	}
+
+	// memoryKVBucket implements KVBucket for the memoryKVStore.
+	memoryKVBucket struct {
+		tx   *memoryKVTx
+		name string
+	}
+
+	// memoryKVCursor implements KVCursor for the memoryKVStore.
+	memoryKVCursor struct {
+		bucket *memoryKVBucket
+		keys   []string
+		index  int
+	}
+)
+
+// NewMemoryKVStore creates a new, empty, KVStore which is kept in memory.
+// It is meant for tests, simulations and throwaway networks,
+// as its content is lost once the process exits.
+func NewMemoryKVStore() KVStore {
+	s := &memoryKVStore{
+		buckets: make(map[string]map[string][]byte),
+	}
+	s.noReaders = sync.NewCond(&s.mu)
+	return s
+}
+
+// View implements KVStore.View
+func (s *memoryKVStore) View(fn func(KVTx) error) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errKVStoreClosed
+	}
+	s.readers++
+	tx := &memoryKVTx{buckets: s.buckets}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.readers--
+		if s.readers == 0 {
+			s.noReaders.Broadcast()
+		}
+		s.mu.Unlock()
+	}()
+	return fn(tx)
+}
+
+// Update implements KVStore.Update
+func (s *memoryKVStore) Update(fn func(KVTx) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// The committed buckets are only modified when a read-write transaction
+	// is committed, and thus do not change while fn runs.
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errKVStoreClosed
+	}
+	tx := &memoryKVTx{
+		buckets:  s.buckets,
+		writable: true,
+		changes:  make(map[string]*memoryKVChanges),
+	}
+	s.mu.Unlock()
+
+	err := fn(tx)
+	tx.writable = false
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction, once no read-only transaction
+	// can observe the committed buckets being modified.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.readers > 0 {
+		s.noReaders.Wait()
+	}
+	if s.closed {
+		return errKVStoreClosed
+	}
+	for name, changes := range tx.changes {
+		if changes.deleted {
+			delete(s.buckets, name)
+			continue
+		}
+		data, ok := s.buckets[name]
+		if !ok || changes.created {
+			data = make(map[string][]byte, len(changes.keys))
+			s.buckets[name] = data
+		}
+		for k, v := range changes.keys {
+			if v == nil {
+				delete(data, k)
+			} else {
+				data[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements KVStore.Close
+func (s *memoryKVStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errKVStoreClosed
+	}
+	s.closed = true
+	s.buckets = nil
+	return nil
+}
+
+// bucketExists returns whether or not the bucket with the given name exists,
+// as seen by the transaction.
+func (tx *memoryKVTx) bucketExists(name string) bool {
+	if changes, ok := tx.changes[name]; ok {
+		return !changes.deleted
+	}
+	_, ok := tx.buckets[name]
+	return ok
+}
+
+// Bucket implements KVTx.Bucket
+func (tx *memoryKVTx) Bucket(name []byte) KVBucket {
+	if !tx.bucketExists(string(name)) {
+		return nil
+	}
+	return &memoryKVBucket{tx: tx, name: string(name)}
+}
+
+// CreateBucket implements KVTx.CreateBucket
+func (tx *memoryKVTx) CreateBucket(name []byte) (KVBucket, error) {
+	if !tx.writable {
+		return nil, ErrKVTxNotWritable
+	}
+	if tx.bucketExists(string(name)) {
+		return nil, ErrKVBucketExists
+	}
+	tx.changes[string(name)] = &memoryKVChanges{
+		created: true,
+		keys:    make(map[string][]byte),
+	}
+	return tx.Bucket(name), nil
+}
+
+// CreateBucketIfNotExists implements KVTx.CreateBucketIfNotExists
+func (tx *memoryKVTx) CreateBucketIfNotExists(name []byte) (KVBucket, error) {
+	if b := tx.Bucket(name); b != nil {
+		if !tx.writable {
+			return nil, ErrKVTxNotWritable
+		}
+		return b, nil
+	}
+	return tx.CreateBucket(name)
+}
+
+// DeleteBucket implements KVTx.DeleteBucket
+func (tx *memoryKVTx) DeleteBucket(name []byte) error {
+	if !tx.writable {
+		return ErrKVTxNotWritable
+	}
+	if !tx.bucketExists(string(name)) {
+		return ErrKVBucketNotFound
+	}
+	tx.changes[string(name)] = &memoryKVChanges{deleted: true}
+	return nil
+}
+
+// ForEach implements KVTx.ForEach
+func (tx *memoryKVTx) ForEach(fn func(name []byte, b KVBucket) error) error {
+	names := make([]string, 0, len(tx.buckets))
+	for name := range tx.buckets {
+		if _, ok := tx.changes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name, changes := range tx.changes {
+		if !changes.deleted {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := tx.Bucket([]byte(name))
+		if b == nil {
+			continue // deleted by fn
+		}
+		err := fn([]byte(name), b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changes returns the overlay of the bucket, creating it if this is the first
+// modification of the bucket within the transaction.
+func (b *memoryKVBucket) changes() (*memoryKVChanges, error) {
+	if !b.tx.writable {
+		return nil, ErrKVTxNotWritable
+	}
+	if !b.tx.bucketExists(b.name) {
+		return nil, ErrKVBucketNotFound
+	}
+	changes, ok := b.tx.changes[b.name]
+	if !ok {
+		changes = &memoryKVChanges{keys: make(map[string][]byte)}
+		b.tx.changes[b.name] = changes
+	}
+	return changes, nil
+}
+
+// Get implements KVBucket.Get
+func (b *memoryKVBucket) Get(key []byte) []byte {
+	if changes, ok := b.tx.changes[b.name]; ok {
+		if changes.deleted {
+			return nil
+		}
+		if v, ok := changes.keys[string(key)]; ok || changes.created {
+			return v
+		}
+	}
+	return b.tx.buckets[b.name][string(key)]
+}
+
+// Put implements KVBucket.Put
+func (b *memoryKVBucket) Put(key, value []byte) error {
+	changes, err := b.changes()
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return errors.New("key required")
+	}
+	changes.keys[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete implements KVBucket.Delete
+func (b *memoryKVBucket) Delete(key []byte) error {
+	changes, err := b.changes()
+	if err != nil {
+		return err
+	}
+	if changes.created {
+		delete(changes.keys, string(key))
+	} else {
+		changes.keys[string(key)] = nil
+	}
+	return nil
+}
+
+// ForEach implements KVBucket.ForEach
+func (b *memoryKVBucket) ForEach(fn func(key, value []byte) error) error {
+	for _, k := range b.sortedKeys() {
+		err := fn([]byte(k), b.Get([]byte(k)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cursor implements KVBucket.Cursor
+func (b *memoryKVBucket) Cursor() KVCursor {
+	return &memoryKVCursor{bucket: b, keys: b.sortedKeys()}
+}
+
+// sortedKeys returns the keys of the bucket in byte-sorted order.
+func (b *memoryKVBucket) sortedKeys() []string {
+	changes, ok := b.tx.changes[b.name]
+	if !ok {
+		changes = &memoryKVChanges{}
+	}
+	if changes.deleted {
+		return nil
+	}
+	var keys []string
+	if !changes.created {
+		for k := range b.tx.buckets[b.name] {
+			if _, modified := changes.keys[k]; !modified {
+				keys = append(keys, k)
+			}
+		}
+	}
+	for k, v := range changes.keys {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// First implements KVCursor.First
+func (c *memoryKVCursor) First() (key, value []byte) {
+	c.index = 0
+	return c.current()
+}
+
+// Last implements KVCursor.Last
+func (c *memoryKVCursor) Last() (key, value []byte) {
+	c.index = len(c.keys) - 1
+	return c.current()
+}
+
+// Next implements KVCursor.Next
+func (c *memoryKVCursor) Next() (key, value []byte) {
+	if c.index < len(c.keys) {
+		c.index++
+	}
+	return c.current()
+}
+
+// Prev implements KVCursor.Prev
+func (c *memoryKVCursor) Prev() (key, value []byte) {
+	if c.index >= 0 {
+		c.index--
+	}
+	return c.current()
+}
+
+// Seek implements KVCursor.Seek
+func (c *memoryKVCursor) Seek(seek []byte) (key, value []byte) {
+	c.index = sort.Search(len(c.keys), func(i int) bool {
+		return bytes.Compare([]byte(c.keys[i]), seek) >= 0
+	})
+	return c.current()
+}
+
+// current returns the key-value pair the cursor points to.
+func (c *memoryKVCursor) current() (key, value []byte) {
+	if c.index < 0 || c.index >= len(c.keys) {
+		return nil, nil
+	}
+	k := c.keys[c.index]
+	return []byte(k), c.bucket.Get([]byte(k))
+}
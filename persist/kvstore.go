@@ -0,0 +1,210 @@
+package persist
+
+import (
+	"errors"
+
+	"github.com/rivine/bbolt"
+)
+
+var (
+	// ErrKVBucketExists is returned when creating a bucket that already exists.
+	ErrKVBucketExists = errors.New("bucket already exists")
+	// ErrKVBucketNotFound is returned when deleting a bucket that does not exist.
+	ErrKVBucketNotFound = errors.New("bucket not found")
+	// ErrKVTxNotWritable is returned when modifying a store within a read-only transaction.
+	ErrKVTxNotWritable = errors.New("transaction not writable")
+)
+
+type (
+	// KVStore is a transactional key-value store, in which the key-value
+	// pairs are organised in named buckets. Modules can use it to remain
+	// independent from the embedded database that is used.
+	//
+	// The semantics of a KVStore are the ones of bolt: any number of
+	// read-only transactions can run concurrently with a single read-write
+	// transaction, and each transaction sees a consistent view of the store.
+	KVStore interface {
+		// View executes the given function within a read-only transaction.
+		View(fn func(KVTx) error) error
+		// Update executes the given function within a read-write transaction.
+		// The transaction is committed if the function returns nil,
+		// and rolled back otherwise.
+		Update(fn func(KVTx) error) error
+		// Close releases all resources of the store.
+		Close() error
+	}
+
+	// KVTx is a transaction on a KVStore. A transaction, and all buckets,
+	// cursors, keys and values retrieved from it, are only valid as long as
+	// the transaction is open.
+	KVTx interface {
+		// Bucket returns the bucket with the given name,
+		// or nil if it does not exist.
+		Bucket(name []byte) KVBucket
+		// CreateBucket creates a new bucket, returning ErrKVBucketExists
+		// if a bucket with the given name already exists.
+		CreateBucket(name []byte) (KVBucket, error)
+		// CreateBucketIfNotExists creates a new bucket,
+		// if no bucket with the given name exists yet.
+		CreateBucketIfNotExists(name []byte) (KVBucket, error)
+		// DeleteBucket deletes a bucket, returning ErrKVBucketNotFound
+		// if no bucket with the given name exists.
+		DeleteBucket(name []byte) error
+		// ForEach calls the given function for each bucket,
+		// in byte-sorted order of the bucket names.
+		ForEach(fn func(name []byte, b KVBucket) error) error
+	}
+
+	// KVBucket is a collection of key-value pairs within a KVStore.
+	KVBucket interface {
+		// Get returns the value of a key, or nil if the key does not exist.
+		Get(key []byte) []byte
+		// Put sets the value of a key.
+		Put(key, value []byte) error
+		// Delete removes a key, it is not an error if the key does not exist.
+		Delete(key []byte) error
+		// ForEach calls the given function for each key-value pair,
+		// in byte-sorted order of the keys.
+		// The bucket should not be modified from within the function.
+		ForEach(fn func(key, value []byte) error) error
+		// Cursor returns a cursor to iterate over the key-value pairs,
+		// in byte-sorted order of the keys.
+		Cursor() KVCursor
+	}
+
+	// KVCursor iterates over the key-value pairs of a bucket,
+	// in byte-sorted order of the keys. A nil key is returned
+	// once the cursor moved past the first or last pair.
+	KVCursor interface {
+		First() (key, value []byte)
+		Last() (key, value []byte)
+		Next() (key, value []byte)
+		Prev() (key, value []byte)
+		// Seek moves the cursor to the given key, or to the next key
+		// if the given key does not exist.
+		Seek(seek []byte) (key, value []byte)
+	}
+)
+
+type (
+	// boltKVStore implements KVStore using a bolt database.
+	boltKVStore struct {
+		db *bolt.DB
+	}
+	// boltKVTx implements KVTx using a bolt transaction.
+	boltKVTx struct {
+		tx *bolt.Tx
+	}
+	// boltKVBucket implements KVBucket using a bolt bucket.
+	boltKVBucket struct {
+		*bolt.Bucket
+	}
+)
+
+// KVStore returns the bolt database as a KVStore.
+// Closing the KVStore closes the database.
+func (db *BoltDatabase) KVStore() KVStore {
+	return boltKVStore{db: db.DB}
+}
+
+// NewBoltKVStore returns the given bolt database as a KVStore.
+// Closing the KVStore closes the database.
+func NewBoltKVStore(db *bolt.DB) KVStore {
+	return boltKVStore{db: db}
+}
+
+// View implements KVStore.View
+func (s boltKVStore) View(fn func(KVTx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(NewBoltKVTx(tx))
+	})
+}
+
+// Update implements KVStore.Update
+func (s boltKVStore) Update(fn func(KVTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(NewBoltKVTx(tx))
+	})
+}
+
+// Close implements KVStore.Close
+func (s boltKVStore) Close() error {
+	return s.db.Close()
+}
+
+// NewBoltKVTx returns the given bolt transaction as a KVTx.
+func NewBoltKVTx(tx *bolt.Tx) KVTx {
+	return boltKVTx{tx: tx}
+}
+
+// newBoltKVBucket wraps a bolt bucket, returning a nil KVBucket for a nil bucket.
+func newBoltKVBucket(b *bolt.Bucket) KVBucket {
+	if b == nil {
+		return nil
+	}
+	return boltKVBucket{b}
+}
+
+// boltKVError converts the bolt errors which have a KVStore equivalent.
+func boltKVError(err error) error {
+	switch err {
+	case bolt.ErrBucketExists:
+		return ErrKVBucketExists
+	case bolt.ErrBucketNotFound:
+		return ErrKVBucketNotFound
+	case bolt.ErrTxNotWritable:
+		return ErrKVTxNotWritable
+	default:
+		return err
+	}
+}
+
+// Bucket implements KVTx.Bucket
+func (tx boltKVTx) Bucket(name []byte) KVBucket {
+	return newBoltKVBucket(tx.tx.Bucket(name))
+}
+
+// CreateBucket implements KVTx.CreateBucket
+func (tx boltKVTx) CreateBucket(name []byte) (KVBucket, error) {
+	b, err := tx.tx.CreateBucket(name)
+	if err != nil {
+		return nil, boltKVError(err)
+	}
+	return boltKVBucket{b}, nil
+}
+
+// CreateBucketIfNotExists implements KVTx.CreateBucketIfNotExists
+func (tx boltKVTx) CreateBucketIfNotExists(name []byte) (KVBucket, error) {
+	b, err := tx.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, boltKVError(err)
+	}
+	return boltKVBucket{b}, nil
+}
+
+// DeleteBucket implements KVTx.DeleteBucket
+func (tx boltKVTx) DeleteBucket(name []byte) error {
+	return boltKVError(tx.tx.DeleteBucket(name))
+}
+
+// ForEach implements KVTx.ForEach
+func (tx boltKVTx) ForEach(fn func(name []byte, b KVBucket) error) error {
+	return tx.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		return fn(name, boltKVBucket{b})
+	})
+}
+
+// Put implements KVBucket.Put
+func (b boltKVBucket) Put(key, value []byte) error {
+	return boltKVError(b.Bucket.Put(key, value))
+}
+
+// Delete implements KVBucket.Delete
+func (b boltKVBucket) Delete(key []byte) error {
+	return boltKVError(b.Bucket.Delete(key))
+}
+
+// Cursor implements KVBucket.Cursor
+func (b boltKVBucket) Cursor() KVCursor {
+	return b.Bucket.Cursor()
+}
@@ -0,0 +1,225 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rivine/rivine/build"
+)
+
+// testKVStore probes the behavior that is expected from every KVStore.
+func testKVStore(t *testing.T, store KVStore) {
+	bucketA, bucketB := []byte("a"), []byte("b")
+	errRollback := errors.New("rollback")
+
+	// buckets and key-value pairs can be created
+	err := store.Update(func(tx KVTx) error {
+		if tx.Bucket(bucketA) != nil {
+			t.Error("bucket should not exist yet")
+		}
+		a, err := tx.CreateBucket(bucketA)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.CreateBucket(bucketA); err != ErrKVBucketExists {
+			t.Error("expected ErrKVBucketExists, got", err)
+		}
+		if _, err = tx.CreateBucketIfNotExists(bucketB); err != nil {
+			return err
+		}
+		for _, key := range []string{"3", "1", "2"} {
+			err = a.Put([]byte(key), []byte("value"+key))
+			if err != nil {
+				return err
+			}
+		}
+		// modifications are visible within the transaction
+		if v := tx.Bucket(bucketA).Get([]byte("1")); !bytes.Equal(v, []byte("value1")) {
+			t.Error("unexpected value", string(v))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// failed transactions are rolled back
+	err = store.Update(func(tx KVTx) error {
+		err := tx.Bucket(bucketA).Delete([]byte("1"))
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteBucket(bucketB)
+		if err != nil {
+			return err
+		}
+		if err = tx.DeleteBucket(bucketB); err != ErrKVBucketNotFound {
+			t.Error("expected ErrKVBucketNotFound, got", err)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal("expected errRollback, got", err)
+	}
+
+	err = store.View(func(tx KVTx) error {
+		if tx.Bucket(bucketB) == nil {
+			t.Error("deletion of bucket was not rolled back")
+		}
+		if _, err := tx.CreateBucket([]byte("c")); err != ErrKVTxNotWritable {
+			t.Error("expected ErrKVTxNotWritable, got", err)
+		}
+		a := tx.Bucket(bucketA)
+		if err := a.Put([]byte("4"), nil); err != ErrKVTxNotWritable {
+			t.Error("expected ErrKVTxNotWritable, got", err)
+		}
+
+		// keys and buckets are iterated in sorted order
+		var keys []string
+		a.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+		if len(keys) != 3 || keys[0] != "1" || keys[1] != "2" || keys[2] != "3" {
+			t.Error("unexpected keys", keys)
+		}
+		var names []string
+		tx.ForEach(func(name []byte, _ KVBucket) error {
+			if bytes.Equal(name, bucketA) || bytes.Equal(name, bucketB) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+		if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Error("unexpected buckets", names)
+		}
+
+		c := a.Cursor()
+		if k, v := c.Last(); string(k) != "3" || string(v) != "value3" {
+			t.Error("unexpected last pair", string(k), string(v))
+		}
+		if k, _ := c.Prev(); string(k) != "2" {
+			t.Error("unexpected previous key", string(k))
+		}
+		if k, _ := c.Seek([]byte("15")); string(k) != "2" {
+			t.Error("unexpected seeked key", string(k))
+		}
+		if k, _ := c.Next(); string(k) != "3" {
+			t.Error("unexpected next key", string(k))
+		}
+		if k, _ := c.Next(); k != nil {
+			t.Error("expected the end of the bucket, got", string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only transactions are isolated from a concurrent read-write transaction
+	err = store.Update(func(tx KVTx) error {
+		err := tx.Bucket(bucketA).Put([]byte("1"), []byte("updated"))
+		if err != nil {
+			return err
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			store.View(func(tx KVTx) error {
+				if v := tx.Bucket(bucketA).Get([]byte("1")); !bytes.Equal(v, []byte("value1")) {
+					t.Error("uncommitted value is visible", string(v))
+				}
+				return nil
+			})
+		}()
+		<-done
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.View(func(tx KVTx) error {
+		if v := tx.Bucket(bucketA).Get([]byte("1")); !bytes.Equal(v, []byte("updated")) {
+			t.Error("committed value is not visible", string(v))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// modifications are merged with the committed pairs, within the
+	// transaction as well as once committed
+	keysOf := func(b KVBucket) (keys []string) {
+		b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+		return
+	}
+	err = store.Update(func(tx KVTx) error {
+		a := tx.Bucket(bucketA)
+		if err := a.Delete([]byte("2")); err != nil {
+			return err
+		}
+		if err := a.Put([]byte("0"), []byte("value0")); err != nil {
+			return err
+		}
+		if keys := keysOf(a); len(keys) != 3 || keys[0] != "0" || keys[1] != "1" || keys[2] != "3" {
+			t.Error("unexpected keys", keys)
+		}
+		// a recreated bucket is empty
+		if err := tx.DeleteBucket(bucketB); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketB)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("1"), []byte("value1"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.View(func(tx KVTx) error {
+		a := tx.Bucket(bucketA)
+		if keys := keysOf(a); len(keys) != 3 || keys[0] != "0" || keys[1] != "1" || keys[2] != "3" {
+			t.Error("unexpected keys", keys)
+		}
+		if v := a.Get([]byte("2")); v != nil {
+			t.Error("deleted value is visible", string(v))
+		}
+		if keys := keysOf(tx.Bucket(bucketB)); len(keys) != 1 || keys[0] != "1" {
+			t.Error("unexpected keys", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMemoryKVStore tests the in-memory KVStore.
+func TestMemoryKVStore(t *testing.T) {
+	testKVStore(t, NewMemoryKVStore())
+}
+
+// TestBoltKVStore tests the bolt KVStore.
+func TestBoltKVStore(t *testing.T) {
+	testDir := build.TempDir(persistDir, t.Name())
+	err := os.MkdirAll(testDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDatabase(Metadata{"Test", "1.0"}, filepath.Join(testDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKVStore(t, db.KVStore())
+}
@@ -97,6 +97,43 @@ func (s StringLoaderFlag) Type() string {
 	return "StringLoader"
 }
 
+// OptionalIntFlag defines an int flag which is only set when it is given,
+// such that a zero value can be told apart from a flag which wasn't given.
+type OptionalIntFlag struct {
+	value **int
+}
+
+// NewOptionalIntFlag creates a new OptionalIntFlag, which points the given
+// pointer to the parsed value once set.
+func NewOptionalIntFlag(value **int) OptionalIntFlag {
+	return OptionalIntFlag{value: value}
+}
+
+// String implements pflag.Value.String,
+// returning an empty string if the flag isn't set.
+func (f OptionalIntFlag) String() string {
+	if f.value == nil || *f.value == nil {
+		return ""
+	}
+	return strconv.Itoa(**f.value)
+}
+
+// Set implements pflag.Value.Set,
+// which parses the given string as an int.
+func (f OptionalIntFlag) Set(s string) error {
+	x, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*f.value = &x
+	return nil
+}
+
+// Type implements pflag.Value.Type
+func (f OptionalIntFlag) Type() string {
+	return "int"
+}
+
 type (
 	// EncodingTypeFlag is a utility flag which can be used to
 	// expose an encoding type as an optionally masked flag.
@@ -224,4 +261,5 @@ var computeTimeNow = func() time.Time {
 var (
 	_ pflag.Value = (*LockTimeFlag)(nil)
 	_ pflag.Value = StringLoaderFlag{}
+	_ pflag.Value = OptionalIntFlag{}
 )
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -138,6 +139,28 @@ func TestStringLoaderFlag(t *testing.T) {
 	}
 }
 
+func TestOptionalIntFlag(t *testing.T) {
+	var value *int
+	flag := NewOptionalIntFlag(&value)
+	if value != nil || flag.String() != "" {
+		t.Fatal("flag should not be set")
+	}
+	if err := flag.Set("foo"); err == nil || value != nil {
+		t.Fatal("flag should not be set to an invalid int")
+	}
+	for _, x := range []int{0, -1, 42} {
+		if err := flag.Set(strconv.Itoa(x)); err != nil {
+			t.Fatal(err)
+		}
+		if value == nil || *value != x {
+			t.Fatalf("expected value %d, got %v", x, value)
+		}
+		if flag.String() != strconv.Itoa(x) {
+			t.Fatalf("expected string %d, got %s", x, flag.String())
+		}
+	}
+}
+
 func TestEncodingTypeFlagString(t *testing.T) {
 	testCases := []struct {
 		f EncodingTypeFlag
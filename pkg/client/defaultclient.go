@@ -169,6 +169,7 @@ func DefaultCLIClient(cfg Config) {
 		walletUnlockCmd,
 		walletBlockStakeStatCmd,
 		walletRegisterDataCmd,
+		walletBumpFeeCmd,
 		walletListCmd,
 		walletCreateCmd,
 		walletSignCmd)
@@ -204,7 +205,12 @@ func DefaultCLIClient(cfg Config) {
 		gatewayConnectCmd,
 		gatewayDisconnectCmd,
 		gatewayAddressCmd,
-		gatewayListCmd)
+		gatewayListCmd,
+		gatewayBanCmd,
+		gatewayUnbanCmd,
+		gatewayAllowlistCmd,
+		gatewayAllowCmd,
+		gatewayDisallowCmd)
 
 	root.AddCommand(consensusCmd)
 	consensusCmd.AddCommand(
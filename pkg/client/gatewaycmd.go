@@ -1,13 +1,17 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/rivine/rivine/api"
+	"github.com/rivine/rivine/modules"
 )
 
 var (
@@ -42,8 +46,58 @@ var (
 	gatewayListCmd = &cobra.Command{
 		Use:   "list",
 		Short: "View a list of peers",
-		Long:  "View the current peer list.",
-		Run:   Wrap(gatewaylistcmd),
+		Long: `View the current peer list, including the uptime, bandwidth
+and, optionally, the RPC calls of each peer.`,
+		Run: Wrap(gatewaylistcmd),
+	}
+
+	gatewayBanCmd = &cobra.Command{
+		Use:   "ban [address]",
+		Short: "Ban a host",
+		Long: `Ban the host of an address, disconnecting all of its peers.
+The gateway refuses to connect with the host until the ban expires.`,
+		Run: Wrap(gatewaybancmd),
+	}
+
+	gatewayUnbanCmd = &cobra.Command{
+		Use:   "unban [address]",
+		Short: "Unban a host",
+		Long:  "Lift the ban of the host of an address.",
+		Run:   Wrap(gatewayunbancmd),
+	}
+
+	gatewayAllowlistCmd = &cobra.Command{
+		Use:   "allowlist",
+		Short: "View the allowlist",
+		Long: `View the allowlist, and whether or not the gateway runs in private mode.
+In private mode only nodes on the allowlist can be peers.`,
+		Run: Wrap(gatewayallowlistcmd),
+	}
+
+	gatewayAllowCmd = &cobra.Command{
+		Use:   "allow [address|identity]",
+		Short: "Add a node to the allowlist",
+		Long: `Add a node to the allowlist, identified by its IP address,
+optionally followed by a port, or by its identity key.`,
+		Run: Wrap(gatewayallowcmd),
+	}
+
+	gatewayDisallowCmd = &cobra.Command{
+		Use:   "disallow [address|identity]",
+		Short: "Remove a node from the allowlist",
+		Long: `Remove a node from the allowlist. In private mode,
+peers which are no longer allowed are disconnected.`,
+		Run: Wrap(gatewaydisallowcmd),
+	}
+)
+
+var (
+	gatewayBanCfg struct {
+		duration time.Duration
+		reason   string
+	}
+	gatewayListCfg struct {
+		verbose bool
 	}
 )
 
@@ -67,6 +121,92 @@ func gatewaydisconnectcmd(addr string) {
 	fmt.Println("Removed", addr, "from peer list.")
 }
 
+// gatewaybancmd is the handler for the command `gateway ban [address]`.
+// Bans the host of the given address.
+func gatewaybancmd(addr string) {
+	data, err := json.Marshal(api.GatewayBansPOST{
+		NetAddress: modules.NetAddress(addr),
+		Duration:   uint64(gatewayBanCfg.duration / time.Second),
+		Reason:     gatewayBanCfg.reason,
+	})
+	if err != nil {
+		Die("Could not create the ban request:", err)
+	}
+	err = _DefaultClient.httpClient.Post("/gateway/bans", string(data))
+	if err != nil {
+		Die("Could not ban host:", err)
+	}
+	fmt.Println("Banned", addr)
+}
+
+// gatewayunbancmd is the handler for the command `gateway unban [address]`.
+// Lifts the ban of the host of the given address.
+func gatewayunbancmd(addr string) {
+	data, err := json.Marshal(api.GatewayBansPOST{
+		NetAddress: modules.NetAddress(addr),
+		Unban:      true,
+	})
+	if err != nil {
+		Die("Could not create the unban request:", err)
+	}
+	err = _DefaultClient.httpClient.Post("/gateway/bans", string(data))
+	if err != nil {
+		Die("Could not unban host:", err)
+	}
+	fmt.Println("Unbanned", addr)
+}
+
+// gatewayallowlistcmd is the handler for the command `gateway allowlist`.
+// Prints the allowlist.
+func gatewayallowlistcmd() {
+	var allowlist api.GatewayAllowlistGET
+	err := _DefaultClient.httpClient.GetAPI("/gateway/allowlist", &allowlist)
+	if err != nil {
+		Die("Could not get allowlist:", err)
+	}
+	fmt.Println("Private mode:", YesNo(allowlist.Enabled))
+	if len(allowlist.Entries) == 0 {
+		fmt.Println("No allowed nodes to show.")
+		return
+	}
+	fmt.Println(len(allowlist.Entries), "allowed nodes:")
+	for _, entry := range allowlist.Entries {
+		fmt.Println(entry)
+	}
+}
+
+// gatewayallowcmd is the handler for the command `gateway allow [address|identity]`.
+// Adds a node to the allowlist.
+func gatewayallowcmd(entry string) {
+	data, err := json.Marshal(api.GatewayAllowlistPOST{
+		Add: []string{entry},
+	})
+	if err != nil {
+		Die("Could not create the allowlist request:", err)
+	}
+	err = _DefaultClient.httpClient.Post("/gateway/allowlist", string(data))
+	if err != nil {
+		Die("Could not add node to allowlist:", err)
+	}
+	fmt.Println("Added", entry, "to the allowlist")
+}
+
+// gatewaydisallowcmd is the handler for the command `gateway disallow [address|identity]`.
+// Removes a node from the allowlist.
+func gatewaydisallowcmd(entry string) {
+	data, err := json.Marshal(api.GatewayAllowlistPOST{
+		Remove: []string{entry},
+	})
+	if err != nil {
+		Die("Could not create the allowlist request:", err)
+	}
+	err = _DefaultClient.httpClient.Post("/gateway/allowlist", string(data))
+	if err != nil {
+		Die("Could not remove node from allowlist:", err)
+	}
+	fmt.Println("Removed", entry, "from the allowlist")
+}
+
 // gatewayaddresscmd is the handler for the command `gateway address`.
 // Prints the gateway's network address.
 func gatewayaddresscmd() {
@@ -87,6 +227,7 @@ func gatewaycmd() {
 		Die("Could not get gateway address:", err)
 	}
 	fmt.Println("Address:", info.NetAddress)
+	fmt.Println("Services:", info.Services)
 	fmt.Println("Active peers:", len(info.Peers))
 }
 
@@ -103,10 +244,70 @@ func gatewaylistcmd() {
 		return
 	}
 	fmt.Println(len(info.Peers), "active peers:")
+	now := time.Now()
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Version\tOutbound\tAddress")
+	fmt.Fprintln(w, "Version\tOutbound\tAddress\tUptime\tLast Active\tSent\tReceived")
 	for _, peer := range info.Peers {
-		fmt.Fprintf(w, "%s\t%v\t%v\n", peer.Version, YesNo(!peer.Inbound), peer.NetAddress)
+		fmt.Fprintf(w, "%s\t%v\t%v\t%v\t%v ago\t%v\t%v\n",
+			peer.Version, YesNo(!peer.Inbound), peer.NetAddress,
+			now.Sub(time.Unix(int64(peer.ConnectedSince), 0)).Truncate(time.Second),
+			now.Sub(time.Unix(int64(peer.LastActivity), 0)).Truncate(time.Second),
+			formatBytes(peer.BytesSent), formatBytes(peer.BytesReceived))
 	}
 	w.Flush()
+	if !gatewayListCfg.verbose {
+		return
+	}
+	for _, peer := range info.Peers {
+		fmt.Println()
+		fmt.Println("Services of", peer.NetAddress+":", peer.Services)
+		fmt.Println("RPC calls of", peer.NetAddress+":")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  RPC\tSent\tReceived")
+		for _, name := range rpcNames(peer) {
+			fmt.Fprintf(w, "  %s\t%d\t%d\n", name, peer.RPCsSent[name], peer.RPCsReceived[name])
+		}
+		w.Flush()
+	}
+}
+
+// rpcNames returns the sorted names of all RPCs called on or by a peer.
+func rpcNames(peer modules.Peer) []string {
+	var names []string
+	for name := range peer.RPCsSent {
+		names = append(names, name)
+	}
+	for name := range peer.RPCsReceived {
+		if _, ok := peer.RPCsSent[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatBytes formats an amount of bytes using the largest fitting binary unit.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	gatewayBanCmd.Flags().DurationVarP(
+		&gatewayBanCfg.duration, "duration", "d", 0,
+		"the duration of the ban, the default ban duration of the gateway is used if not given")
+	gatewayBanCmd.Flags().StringVar(
+		&gatewayBanCfg.reason, "reason", "",
+		"optionally describe why the host is banned")
+	gatewayListCmd.Flags().BoolVarP(
+		&gatewayListCfg.verbose, "verbose", "v", false,
+		"also list the RPC calls made to and by each peer")
 }
@@ -138,6 +138,15 @@ Miner fees (expressed in ` + _CurrencyCoinUnit + `) will be added on top automat
 		Run:   Wrap(walletregisterdatacmd),
 	}
 
+	walletBumpFeeCmd = &cobra.Command{
+		Use:   "bumpfee <txid>",
+		Short: "Pay a higher fee for an unconfirmed transaction",
+		Long: `Replace an unconfirmed transaction sent by the wallet with a transaction spending the same
+outputs, paying a higher fee. The higher fee is paid from the refund output of the transaction.
+The replacement is only accepted by nodes with replace-by-fee enabled.`,
+		Run: Wrap(walletbumpfeecmd),
+	}
+
 	walletBalanceCmd = &cobra.Command{
 		Use:   "balance",
 		Short: "View wallet balance",
@@ -254,6 +263,7 @@ var (
 	walletSendCoinsCmd           *cobra.Command
 	walletSendBlockStakesCmd     *cobra.Command
 	walletRegisterDataCmd        *cobra.Command
+	walletBumpFeeCmd             *cobra.Command
 	walletBalanceCmd             *cobra.Command
 	walletTransactionsCmd        *cobra.Command
 	walletUnlockCmd              *cobra.Command
@@ -823,6 +833,21 @@ func walletunlockcmd() {
 	fmt.Println("Wallet unlocked")
 }
 
+// walletbumpfeecmd replaces an unconfirmed transaction
+// with a transaction paying a higher fee
+func walletbumpfeecmd(txid string) {
+	var id types.TransactionID
+	if err := id.LoadString(txid); err != nil {
+		Die("Could not parse transaction id:", err)
+	}
+	var resp api.WalletBumpFeePOSTResp
+	err := _DefaultClient.httpClient.PostResp("/wallet/bumpfee/"+id.String(), "", &resp)
+	if err != nil {
+		Die("Could not bump fee:", err)
+	}
+	fmt.Println("Transaction replaced, transaction id:", resp.TransactionID)
+}
+
 // walletsendtxncmd sends commits a transaction in json format
 // to the transaction pool
 func walletsendtxncmd(txnjson string) {
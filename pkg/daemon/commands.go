@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/pkg/cli"
 	"github.com/rivine/rivine/profile"
 	"github.com/spf13/cobra"
 )
@@ -138,6 +139,8 @@ func SetupDefaultDaemon(cfg Config) {
 		Run:   modulesCmd,
 	})
 
+	root.AddCommand(newSnapshotCmd(&cfg))
+
 	// Set default values, which have the lowest priority.
 	root.Flags().StringVarP(&cfg.RequiredUserAgent, "agent", "", cfg.RequiredUserAgent, "required substring for the user agent")
 	root.Flags().StringVarP(&cfg.ProfileDir, "profile-directory", "", cfg.ProfileDir, "location of the profiling directory")
@@ -146,8 +149,37 @@ func SetupDefaultDaemon(cfg Config) {
 		"location of the root diretory used to store persistent data of the daemon of"+
 			cfg.BlockchainInfo.Name)
 	root.Flags().BoolVarP(&cfg.NoBootstrap, "no-bootstrap", "", cfg.NoBootstrap, "disable bootstrapping on this run")
+	root.Flags().Uint64VarP((*uint64)(&cfg.PruneDepth), "prune-depth", "", uint64(cfg.PruneDepth),
+		"prune blocks deeper than this depth from the consensus set, 0 disables pruning,\ncan't be combined with the explorer (e) or wallet (w) modules")
+	root.Flags().IntVarP(&cfg.TransactionPoolSize, "transactionpool-size", "", cfg.TransactionPoolSize,
+		"maximum size in bytes of all transactions in the transaction pool combined, 0 uses the default")
+	root.Flags().Uint64VarP((*uint64)(&cfg.TransactionPoolRebroadcastDelay), "transactionpool-rebroadcast-delay", "",
+		uint64(cfg.TransactionPoolRebroadcastDelay),
+		"number of blocks a transaction set has to remain unconfirmed before it is broadcast again, 0 uses the default")
+	root.Flags().BoolVarP(&cfg.TransactionPoolReplaceByFee, "transactionpool-replace-by-fee", "", cfg.TransactionPoolReplaceByFee,
+		"allow transactions to replace the unconfirmed transactions they double spend by paying more fees")
 	root.Flags().BoolVarP(&cfg.Profile, "profile", "", cfg.Profile, "enable profiling")
 	root.Flags().StringVarP(&cfg.RPCaddr, "rpc-addr", "", cfg.RPCaddr, "which port the gateway listens on")
+	root.Flags().Var(cli.StringLoaderFlag{StringLoader: &cfg.GatewayEncryption}, "gateway-encryption",
+		"encryption of the gateway's peer connections, one of: optional, required, disabled")
+	root.Flags().BoolVarP(&cfg.GatewayPrivate, "gateway-private", "", cfg.GatewayPrivate,
+		"only allow nodes on the allowlist of the gateway to be peers")
+	root.Flags().StringSliceVarP(&cfg.GatewayAllowlist, "gateway-allowlist", "", cfg.GatewayAllowlist,
+		"add IP addresses (optionally with port) or identity keys to the allowlist of the gateway")
+	root.Flags().StringVarP(&cfg.GatewayProxy, "gateway-proxy", "", cfg.GatewayProxy,
+		"dial outbound peer connections through a SOCKS5 proxy, given as [user:password@]host:port")
+	root.Flags().BoolVarP(&cfg.GatewayNoAdvertise, "gateway-no-advertise", "", cfg.GatewayNoAdvertise,
+		"do not discover and advertise the external address of the gateway, nor forward its port using UPnP")
+	root.Flags().Var(cli.NewOptionalIntFlag(&cfg.GatewayMaxPeers), "gateway-max-peers",
+		"number of peers at which inbound peers are kicked to make room for new ones (default of the gateway if not given)")
+	root.Flags().Var(cli.NewOptionalIntFlag(&cfg.GatewayOutboundPeers), "gateway-outbound-peers",
+		"number of outbound peers the gateway connects to, 0 only accepts inbound peers (default of the gateway if not given)")
+	root.Flags().Var(cli.NewOptionalIntFlag(&cfg.GatewayMaxLocalOutboundPeers), "gateway-max-local-outbound-peers",
+		"number of outbound peers after which no more local nodes are connected to, 0 never connects to local nodes (default of the gateway if not given)")
+	root.Flags().Var(cli.NewOptionalIntFlag(&cfg.GatewayMaxInboundPeersPerIP), "gateway-max-inbound-per-ip",
+		"maximum number of inbound peers from a single IP address, 0 means unlimited (default of the gateway if not given)")
+	root.Flags().BoolVarP(&cfg.GatewayDisableInbound, "gateway-disable-inbound", "", cfg.GatewayDisableInbound,
+		"refuse all inbound peer connections")
 	root.Flags().StringVarP(&cfg.Modules, "modules", "M", cfg.Modules,
 		fmt.Sprintf("enabled modules, see '%s modules' for more info", os.Args[0]))
 	root.Flags().BoolVarP(&cfg.AuthenticateAPI, "authenticate-api", "", cfg.AuthenticateAPI, "enable API password protection")
@@ -38,6 +38,29 @@ type Config struct {
 	APIaddr string
 	// the host:port to listen for RPC calls
 	RPCaddr string
+	// defines if and when the gateway encrypts the connections with its peers,
+	// by default connections are encrypted with all peers which support it
+	GatewayEncryption modules.EncryptionMode
+	// indicates that the gateway runs in private mode,
+	// only allowing nodes on the allowlist to be peers
+	GatewayPrivate bool
+	// the entries added to the allowlist of the gateway, each entry is either
+	// an IP address, optionally followed by a port, or an identity key
+	GatewayAllowlist []string
+	// the SOCKS5 proxy, in the [user:password@]host:port format,
+	// through which the gateway dials its outbound connections
+	GatewayProxy string
+	// indicates that the gateway should not discover and advertise
+	// its external address, nor forward its port using UPnP
+	GatewayNoAdvertise bool
+	// the peer limits of the gateway, the default of the gateway
+	// is used for each limit which is nil
+	GatewayMaxPeers              *int
+	GatewayOutboundPeers         *int
+	GatewayMaxLocalOutboundPeers *int
+	GatewayMaxInboundPeersPerIP  *int
+	// indicates that the gateway should refuse all inbound connections
+	GatewayDisableInbound bool
 	// indicates that the http API can listen on a non localhost address.
 	//  If this is true, then the AuthenticateAPI parameter
 	// must also be true
@@ -52,6 +75,18 @@ type Config struct {
 	// indicates that the daemon should not try to connect to
 	// the bootstrap nodes
 	NoBootstrap bool
+	// the number of most recent blocks kept in full by the consensus set,
+	// older blocks are pruned, 0 disables pruning
+	PruneDepth types.BlockHeight
+	// the maximum size in bytes of all transaction sets in the transaction
+	// pool combined, 0 uses the default of the transaction pool
+	TransactionPoolSize int
+	// the number of blocks a transaction set has to remain unconfirmed
+	// before it is broadcast again, 0 uses the default of the transaction pool
+	TransactionPoolRebroadcastDelay types.BlockHeight
+	// indicates that transaction sets can replace the transaction sets
+	// in the transaction pool they double spend, by paying more fees
+	TransactionPoolReplaceByFee bool
 	// the user agent required to connect to the http api.
 	RequiredUserAgent string
 	// indicates if the http api is password protected
@@ -76,6 +111,46 @@ type Config struct {
 	CreateNetworConfig func(name string) (NetworkConfig, error)
 }
 
+// gatewaySettings returns the given gateway settings,
+// overwritten by the gateway limits of the config which are set.
+func (cfg Config) gatewaySettings(settings modules.GatewaySettings) modules.GatewaySettings {
+	if cfg.GatewayMaxPeers != nil {
+		settings.MaxPeers = *cfg.GatewayMaxPeers
+	}
+	if cfg.GatewayOutboundPeers != nil {
+		settings.OutboundPeers = *cfg.GatewayOutboundPeers
+	}
+	if cfg.GatewayMaxLocalOutboundPeers != nil {
+		settings.MaxLocalOutboundPeers = *cfg.GatewayMaxLocalOutboundPeers
+	}
+	if cfg.GatewayMaxInboundPeersPerIP != nil {
+		settings.MaxInboundPeersPerIP = *cfg.GatewayMaxInboundPeersPerIP
+	}
+	settings.DisableInbound = cfg.GatewayDisableInbound
+	return settings
+}
+
+// gatewayServices returns the services the gateway advertises,
+// based on the modules which are loaded.
+func (cfg Config) gatewayServices() modules.ServiceFlags {
+	var services modules.ServiceFlags
+	if strings.Contains(cfg.Modules, "c") {
+		services |= modules.ServiceHeaders
+		if cfg.PruneDepth == 0 {
+			services |= modules.ServiceFullHistory
+		} else {
+			services |= modules.ServicePruned
+		}
+	}
+	if strings.Contains(cfg.Modules, "t") {
+		services |= modules.ServiceRelayTransactions
+	}
+	if strings.Contains(cfg.Modules, "e") {
+		services |= modules.ServiceExplorer
+	}
+	return services
+}
+
 // DefaultConfig returns the default daemon configuration
 func DefaultConfig() Config {
 	return Config{
@@ -87,8 +162,11 @@ func DefaultConfig() Config {
 		RPCaddr:      ":23112",
 		AllowAPIBind: false,
 
+		GatewayEncryption: modules.EncryptionOptional,
+
 		Modules:           "cgtwb",
 		NoBootstrap:       false,
+		PruneDepth:        0,
 		RequiredUserAgent: "Rivine-Agent",
 		AuthenticateAPI:   false,
 
@@ -149,6 +227,22 @@ func verifyAPISecurity(cfg Config) error {
 	return nil
 }
 
+// verifyPruning checks that no module which has to rescan the consensus set
+// from its first block is loaded together with a pruned consensus set, as the
+// consensus set refuses subscriptions starting below its prune height.
+func verifyPruning(cfg Config) error {
+	if cfg.PruneDepth == 0 {
+		return nil
+	}
+	if strings.Contains(cfg.Modules, "e") {
+		return errors.New("the explorer can not be loaded together with --prune-depth, as it requires all blocks")
+	}
+	if strings.Contains(cfg.Modules, "w") {
+		return errors.New("the wallet can not be loaded together with --prune-depth, as it rescans all blocks when unlocked")
+	}
+	return nil
+}
+
 // processNetAddr adds a ':' to a bare integer, so that it is a proper port
 // number.
 func processNetAddr(addr string) string {
@@ -183,7 +277,8 @@ func processConfig(config *Config) error {
 	config.RPCaddr = processNetAddr(config.RPCaddr)
 	config.Modules, err1 = processModules(config.Modules)
 	err2 := verifyAPISecurity(*config)
-	return build.JoinErrors([]error{err1, err2}, ", and ")
+	err3 := verifyPruning(*config)
+	return build.JoinErrors([]error{err1, err2, err3}, ", and ")
 }
 
 // StartDaemon uses the config parameters
@@ -249,12 +344,19 @@ func StartDaemon(cfg Config) (err error) {
 	if strings.Contains(cfg.Modules, "g") {
 		i++
 		fmt.Printf("(%d/%d) Loading gateway...\n", i, len(cfg.Modules))
-		g, err = gateway.New(cfg.RPCaddr, !cfg.NoBootstrap,
+		var gw *gateway.Gateway
+		gw, err = gateway.NewWithOptions(cfg.RPCaddr, !cfg.NoBootstrap,
 			filepath.Join(cfg.RootPersistentDir, modules.GatewayDir),
-			cfg.BlockchainInfo, networkConfig.Constants, networkConfig.BootstrapPeers)
+			cfg.BlockchainInfo, networkConfig.Constants, networkConfig.BootstrapPeers,
+			gateway.Options{
+				Proxy:       cfg.GatewayProxy,
+				NoAdvertise: cfg.GatewayNoAdvertise,
+				DNSSeeds:    networkConfig.DNSSeeds,
+			})
 		if err != nil {
 			return err
 		}
+		g = gw
 		defer func() {
 			fmt.Println("Closing gateway...")
 			err := g.Close()
@@ -262,18 +364,45 @@ func StartDaemon(cfg Config) (err error) {
 				fmt.Println("Error during gateway shutdown:", err)
 			}
 		}()
+		err = gw.SetEncryptionMode(cfg.GatewayEncryption)
+		if err != nil {
+			return err
+		}
+		err = gw.UpdateAllowlist(cfg.GatewayAllowlist, nil)
+		if err != nil {
+			return err
+		}
+		if cfg.GatewayPrivate {
+			err = gw.SetAllowlistEnabled(true)
+			if err != nil {
+				return err
+			}
+		}
+		err = gw.SetSettings(cfg.gatewaySettings(gw.Settings()))
+		if err != nil {
+			return err
+		}
+		err = gw.SetServices(cfg.gatewayServices())
+		if err != nil {
+			return err
+		}
 
 	}
 	var cs modules.ConsensusSet
 	if strings.Contains(cfg.Modules, "c") {
 		i++
 		fmt.Printf("(%d/%d) Loading consensus...\n", i, len(cfg.Modules))
-		cs, err = consensus.New(g, !cfg.NoBootstrap,
+		var consensusSet *consensus.ConsensusSet
+		consensusSet, err = consensus.NewWithOptions(g, !cfg.NoBootstrap,
 			filepath.Join(cfg.RootPersistentDir, modules.ConsensusDir),
-			cfg.BlockchainInfo, networkConfig.Constants)
+			cfg.BlockchainInfo, networkConfig.Constants,
+			consensus.Options{
+				PruneDepth: cfg.PruneDepth,
+			})
 		if err != nil {
 			return err
 		}
+		cs = consensusSet
 		defer func() {
 			fmt.Println("Closing consensus set...")
 			err := cs.Close()
@@ -306,12 +435,14 @@ func StartDaemon(cfg Config) (err error) {
 	if strings.Contains(cfg.Modules, "t") {
 		i++
 		fmt.Printf("(%d/%d) Loading transaction pool...\n", i, len(cfg.Modules))
-		tpool, err = transactionpool.New(cs, g,
+		var transactionPool *transactionpool.TransactionPool
+		transactionPool, err = transactionpool.New(cs, g,
 			filepath.Join(cfg.RootPersistentDir, modules.TransactionPoolDir),
 			cfg.BlockchainInfo, networkConfig.Constants)
 		if err != nil {
 			return err
 		}
+		tpool = transactionPool
 		defer func() {
 			fmt.Println("Closing transaction pool...")
 			err := tpool.Close()
@@ -319,6 +450,19 @@ func StartDaemon(cfg Config) (err error) {
 				fmt.Println("Error during transaction pool shutdown:", err)
 			}
 		}()
+		if cfg.TransactionPoolSize != 0 {
+			err = transactionPool.SetMaxSize(cfg.TransactionPoolSize)
+			if err != nil {
+				return err
+			}
+		}
+		if cfg.TransactionPoolRebroadcastDelay != 0 {
+			err = transactionPool.SetRebroadcastDelay(cfg.TransactionPoolRebroadcastDelay)
+			if err != nil {
+				return err
+			}
+		}
+		transactionPool.SetReplaceByFee(cfg.TransactionPoolReplaceByFee)
 	}
 	var w modules.Wallet
 	if strings.Contains(cfg.Modules, "w") {
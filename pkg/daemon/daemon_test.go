@@ -2,6 +2,10 @@ package daemon
 
 import (
 	"testing"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/types"
 )
 
 // TestUnitProcessNetAddr probes the 'processNetAddr' function.
@@ -153,3 +157,66 @@ func TestVerifyAPISecurity(t *testing.T) {
 		t.Error("public + securityOff with authentication was rejected:", err)
 	}
 }
+
+// TestVerifyPruning checks that the modules requiring all blocks are refused
+// when pruning is enabled.
+func TestVerifyPruning(t *testing.T) {
+	tests := []struct {
+		modules    string
+		pruneDepth types.BlockHeight
+		valid      bool
+	}{
+		{"cgtwe", 0, true},
+		{"cgtb", 1000, true},
+		{"cgte", 1000, false},
+		{"cgtw", 1000, false},
+	}
+	for _, test := range tests {
+		err := verifyPruning(Config{Modules: test.modules, PruneDepth: test.pruneDepth})
+		if test.valid && err != nil {
+			t.Errorf("modules %q with prune depth %d were refused: %v", test.modules, test.pruneDepth, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("modules %q with prune depth %d were accepted", test.modules, test.pruneDepth)
+		}
+	}
+}
+
+// TestGatewaySettings checks that the gateway limits given as flags overwrite
+// the settings of the gateway, also when they are zero.
+func TestGatewaySettings(t *testing.T) {
+	defaults := modules.GatewaySettings{
+		MaxPeers:              128,
+		OutboundPeers:         8,
+		MaxLocalOutboundPeers: 3,
+	}
+	cfg := DefaultConfig()
+	if settings := cfg.gatewaySettings(defaults); settings != defaults {
+		t.Fatal("settings should not be overwritten without flags, got", settings)
+	}
+
+	flags := []struct {
+		value **int
+		arg   string
+	}{
+		{&cfg.GatewayMaxPeers, "64"},
+		{&cfg.GatewayOutboundPeers, "0"},
+		{&cfg.GatewayMaxLocalOutboundPeers, "0"},
+		{&cfg.GatewayMaxInboundPeersPerIP, "2"},
+	}
+	for _, flag := range flags {
+		if err := cli.NewOptionalIntFlag(flag.value).Set(flag.arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg.GatewayDisableInbound = true
+	expected := modules.GatewaySettings{
+		MaxPeers:              64,
+		OutboundPeers:         0,
+		MaxLocalOutboundPeers: 0,
+		MaxInboundPeersPerIP:  2,
+		DisableInbound:        true,
+	}
+	if settings := cfg.gatewaySettings(defaults); settings != expected {
+		t.Fatalf("expected settings %v, got %v", expected, settings)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"github.com/rivine/rivine/types"
 )
 
-// NetworkConfig are variables for a particular chain. Currently, these are genesis constants and bootstrap peers
+// NetworkConfig are variables for a particular chain. Currently, these are genesis constants, bootstrap peers and DNS seeds
 type NetworkConfig struct {
 	// Blockchain Constants for this network
 	Constants types.ChainConstants
 	// BootstrapPeers for this network
 	BootstrapPeers []modules.NetAddress
+	// DNSSeeds for this network, host:port addresses which are resolved
+	// into the addresses of nodes listening on the given port
+	DNSSeeds []modules.NetAddress
 }
@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/consensus"
+	"github.com/rivine/rivine/types"
+	"github.com/spf13/cobra"
+)
+
+// newSnapshotCmd creates the snapshot command and its subcommands,
+// which export and import snapshots of the consensus set.
+func newSnapshotCmd(cfg *Config) *cobra.Command {
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export or import a snapshot of the consensus set",
+		Long: `Export or import a snapshot of the consensus set.
+A snapshot allows a new node to start from a trusted consensus state,
+instead of downloading and validating the entire blockchain.
+The daemon can not be running while a snapshot is exported or imported.`,
+	}
+
+	var height types.BlockHeight
+	exportCmd := &cobra.Command{
+		Use:   "export <file>",
+		Short: "Export a snapshot of the consensus set",
+		Long: `Export a snapshot of the consensus set to a file,
+at the current height or at the height given by the --height flag.
+The printed checksum has to be given to the nodes importing the snapshot.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			networkConfig, persistDir := snapshotNetworkConfig(cfg)
+			if !cmd.Flags().Changed("height") {
+				height = consensus.SnapshotCurrentHeight
+			}
+			file, err := os.Create(args[0])
+			if err != nil {
+				die("Could not create snapshot file:", err)
+			}
+			info, err := consensus.ExportSnapshot(file, persistDir, height, cfg.BlockchainInfo, networkConfig.Constants)
+			closeErr := file.Close()
+			if err != nil {
+				os.Remove(args[0])
+				die("Could not export snapshot:", err)
+			}
+			if closeErr != nil {
+				die("Could not write snapshot file:", closeErr)
+			}
+			printSnapshotInfo(info)
+		},
+	}
+	exportCmd.Flags().Uint64VarP((*uint64)(&height), "height", "", 0, "height at which the snapshot is taken")
+
+	var checksum string
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a snapshot of the consensus set",
+		Long: `Import a snapshot of the consensus set from a file, creating a new consensus set.
+The snapshot is only imported if its consensus checksum matches the trusted checksum.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			networkConfig, persistDir := snapshotNetworkConfig(cfg)
+			var trusted crypto.Hash
+			if err := trusted.LoadString(checksum); err != nil {
+				die("Invalid checksum:", err)
+			}
+			file, err := os.Open(args[0])
+			if err != nil {
+				die("Could not open snapshot file:", err)
+			}
+			defer file.Close()
+			info, err := consensus.ImportSnapshot(file, persistDir, networkConfig.Constants, trusted)
+			if err != nil {
+				die("Could not import snapshot:", err)
+			}
+			printSnapshotInfo(info)
+		},
+	}
+	importCmd.Flags().StringVarP(&checksum, "checksum", "", "", "trusted consensus checksum of the snapshot (required)")
+
+	snapshotCmd.PersistentFlags().StringVarP(&cfg.RootPersistentDir, "persistent-directory", "d", cfg.RootPersistentDir,
+		"location of the root diretory used to store persistent data of the daemon of"+
+			cfg.BlockchainInfo.Name)
+	snapshotCmd.PersistentFlags().StringVarP(&cfg.NetworkName, "network", "n", cfg.NetworkName, "the name of the network to which the daemon connects")
+	snapshotCmd.AddCommand(exportCmd, importCmd)
+	return snapshotCmd
+}
+
+// snapshotNetworkConfig returns the network config and the consensus
+// directory of the configured network.
+func snapshotNetworkConfig(cfg *Config) (NetworkConfig, string) {
+	networkConfig, err := cfg.createConfiguredNetworkConfig()
+	if err != nil {
+		die(err)
+	}
+	err = networkConfig.Constants.Validate()
+	if err != nil {
+		die(err)
+	}
+	return networkConfig, filepath.Join(cfg.RootPersistentDir, cfg.NetworkName, modules.ConsensusDir)
+}
+
+// printSnapshotInfo prints the consensus state contained in a snapshot.
+func printSnapshotInfo(info consensus.SnapshotInfo) {
+	fmt.Println("Height:  ", info.Height)
+	fmt.Println("Block ID:", info.BlockID)
+	fmt.Println("Checksum:", info.Checksum)
+}
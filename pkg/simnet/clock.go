@@ -0,0 +1,68 @@
+package simnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rivine/rivine/types"
+)
+
+// Clock is a virtual clock, which implements the types.Clock interface.
+// Its time only changes when it is advanced, such that the timestamps of
+// the blocks created within a simulation do not depend on the system time.
+type Clock struct {
+	mu      sync.Mutex
+	now     types.Timestamp
+	waiters []clockWaiter
+}
+
+// clockWaiter is a channel which is closed once the clock reaches its time.
+type clockWaiter struct {
+	t  types.Timestamp
+	ch chan struct{}
+}
+
+// NewClock returns a virtual clock, set to the given time.
+func NewClock(now types.Timestamp) *Clock {
+	return &Clock{now: now}
+}
+
+// Now implements types.Clock.Now
+func (c *Clock) Now() types.Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the given duration,
+// truncated to whole seconds.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now += types.Timestamp(d / time.Second)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.t <= c.now {
+			close(w.ch)
+			continue
+		}
+		waiters = append(waiters, w)
+	}
+	c.waiters = waiters
+}
+
+// Until implements types.Clock.Until
+func (c *Clock) Until(t types.Timestamp) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan struct{})
+	if t <= c.now {
+		close(ch)
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{t: t, ch: ch})
+	return ch
+}
+
+// enforce that Clock satisfies the types.Clock interface
+var _ types.Clock = (*Clock)(nil)
@@ -0,0 +1,36 @@
+package simnet
+
+import (
+	"testing"
+	"time"
+)
+
+// TestClockUntil checks that the channels returned by Clock.Until
+// are only closed once the clock is advanced to their time.
+func TestClockUntil(t *testing.T) {
+	c := NewClock(10)
+	select {
+	case <-c.Until(10):
+	default:
+		t.Fatal("channel of a reached time should be closed")
+	}
+
+	soon, later := c.Until(11), c.Until(13)
+	c.Advance(time.Second)
+	select {
+	case <-soon:
+	default:
+		t.Fatal("channel should be closed once its time is reached")
+	}
+	select {
+	case <-later:
+		t.Fatal("channel should not be closed before its time is reached")
+	default:
+	}
+	c.Advance(5 * time.Second)
+	select {
+	case <-later:
+	default:
+		t.Fatal("channel should be closed once its time is passed")
+	}
+}
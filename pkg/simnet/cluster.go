@@ -0,0 +1,323 @@
+package simnet
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/blockcreator"
+	"github.com/rivine/rivine/modules/consensus"
+	"github.com/rivine/rivine/modules/gateway"
+	"github.com/rivine/rivine/modules/transactionpool"
+	"github.com/rivine/rivine/modules/wallet"
+	"github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+)
+
+const (
+	// genesisTimestamp is the timestamp of the genesis block of a cluster.
+	genesisTimestamp = types.Timestamp(1500000000)
+
+	// maxCreateBlockAttempts is the maximum number of seconds the clock is
+	// advanced while trying to create a block.
+	maxCreateBlockAttempts = 10000
+)
+
+var (
+	errNotSynced = errors.New("nodes are not synced")
+)
+
+// Node is a full node within a cluster. All of its modules are exposed, such
+// that tests can interact with them directly.
+type Node struct {
+	Host string
+	Seed modules.Seed
+	// Dir is the directory in which the node persists its data.
+	Dir string
+
+	Gateway         *gateway.Gateway
+	ConsensusSet    *consensus.ConsensusSet
+	TransactionPool *transactionpool.TransactionPool
+	Wallet          *wallet.Wallet
+	BlockCreator    *blockcreator.BlockCreator
+
+	consensusLog *persist.Logger
+}
+
+// UnlockHash returns the address to which the genesis block
+// allocates the block stakes and coins of the node.
+func (n *Node) UnlockHash() types.UnlockHash {
+	return nodeUnlockHash(n.Seed)
+}
+
+// Close closes all modules of the node,
+// in the reverse order of their creation.
+func (n *Node) Close() error {
+	var errs []error
+	if n.BlockCreator != nil {
+		if err := n.BlockCreator.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("blockcreator.Close failed: %v", err))
+		}
+	}
+	if n.Wallet != nil {
+		if err := n.Wallet.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("wallet.Close failed: %v", err))
+		}
+	}
+	if n.TransactionPool != nil {
+		if err := n.TransactionPool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("transactionpool.Close failed: %v", err))
+		}
+	}
+	if n.ConsensusSet != nil {
+		if err := n.ConsensusSet.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("consensus.Close failed: %v", err))
+		}
+	}
+	if n.consensusLog != nil {
+		if err := n.consensusLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("consensus log.Close failed: %v", err))
+		}
+	}
+	if n.Gateway != nil {
+		if err := n.Gateway.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("gateway.Close failed: %v", err))
+		}
+	}
+	return build.JoinErrors(errs, "; ")
+}
+
+// Cluster is a network of full nodes, connected through an in-memory network
+// and running on a virtual clock. Each node owns an equal share of the block
+// stakes and coins of the genesis block. Blocks are only created when
+// CreateBlock is called, such that tests fully control the chain of each node.
+type Cluster struct {
+	Network        *Network
+	Clock          *Clock
+	BlockchainInfo types.BlockchainInfo
+	ChainConstants types.ChainConstants
+
+	Nodes []*Node
+}
+
+// nodeSeed returns the deterministic seed of the i-th node of a cluster.
+func nodeSeed(i int) modules.Seed {
+	return modules.Seed(crypto.HashAll("simnet", i))
+}
+
+// nodeUnlockHash returns the first address of the given seed,
+// which is the first address of the wallet using that seed.
+func nodeUnlockHash(seed modules.Seed) types.UnlockHash {
+	_, pk := crypto.GenerateKeyPairDeterministic(crypto.HashAll(seed, uint64(0)))
+	return types.NewEd25519PubKeyUnlockHash(pk)
+}
+
+// NewCluster creates a cluster of n nodes, persisting their data in the
+// given directory. The nodes are not connected to each other.
+func NewCluster(dir string, n int) (*Cluster, error) {
+	return NewClusterWithConstants(dir, n, types.DefaultChainConstants())
+}
+
+// NewClusterWithConstants creates a cluster of n nodes using the given chain
+// constants, of which the genesis timestamp and allocations are overwritten.
+func NewClusterWithConstants(dir string, n int, chainCts types.ChainConstants) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.New("a cluster requires at least one node")
+	}
+	c := &Cluster{
+		Network:        NewNetwork(),
+		BlockchainInfo: types.DefaultBlockchainInfo(),
+		ChainConstants: chainCts,
+	}
+
+	// allocate the genesis block stakes and coins equally amongst the nodes
+	c.ChainConstants.GenesisTimestamp = genesisTimestamp
+	c.ChainConstants.GenesisBlockStakeAllocation = nil
+	c.ChainConstants.GenesisCoinDistribution = nil
+	for i := 0; i < n; i++ {
+		condition := types.NewCondition(types.NewUnlockHashCondition(nodeUnlockHash(nodeSeed(i))))
+		c.ChainConstants.GenesisBlockStakeAllocation = append(c.ChainConstants.GenesisBlockStakeAllocation, types.BlockStakeOutput{
+			Value:     types.NewCurrency64(1000),
+			Condition: condition,
+		})
+		c.ChainConstants.GenesisCoinDistribution = append(c.ChainConstants.GenesisCoinDistribution, types.CoinOutput{
+			Value:     c.ChainConstants.CurrencyUnits.OneCoin.Mul64(1000),
+			Condition: condition,
+		})
+	}
+	// Only the block stakes of the first node are part of the first output
+	// of the first transaction, and thus usable right away. Start the clock
+	// once the block stakes of all other nodes have aged as well.
+	c.Clock = NewClock(genesisTimestamp + types.Timestamp(c.ChainConstants.BlockStakeAging) + 1)
+
+	for i := 0; i < n; i++ {
+		node, err := c.newNode(filepath.Join(dir, strconv.Itoa(i)), nodeSeed(i))
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to create node %d: %v", i, err)
+		}
+		c.Nodes = append(c.Nodes, node)
+	}
+
+	// The consensus RPCs are only registered once the consensus set is
+	// started, nodes connected before that would not sync with each other.
+	err := build.Retry(100, 100*time.Millisecond, func() error {
+		for _, node := range c.Nodes {
+			if !node.ConsensusSet.Synced() {
+				return errNotSynced
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newNode creates a node, using the given seed for its wallet.
+func (c *Cluster) newNode(dir string, seed modules.Seed) (n *Node, err error) {
+	n = &Node{Seed: seed, Dir: dir}
+	defer func() {
+		if err != nil {
+			n.Close()
+		}
+	}()
+
+	transport := c.Network.NewTransport()
+	n.Host = transport.Host()
+	n.Gateway, err = gateway.NewWithOptions(":0", false, filepath.Join(dir, modules.GatewayDir),
+		c.BlockchainInfo, c.ChainConstants, nil, gateway.Options{
+			NoAdvertise: true,
+			Transport:   transport,
+		})
+	if err != nil {
+		return
+	}
+
+	n.consensusLog, err = persist.NewFileLogger(c.BlockchainInfo, filepath.Join(dir, "consensus.log"))
+	if err != nil {
+		return
+	}
+	n.ConsensusSet, err = consensus.NewWithStore(n.Gateway, false, persist.NewMemoryKVStore(),
+		n.consensusLog, c.BlockchainInfo, c.ChainConstants)
+	if err != nil {
+		return
+	}
+	n.ConsensusSet.SetClock(c.Clock)
+
+	n.TransactionPool, err = transactionpool.New(n.ConsensusSet, n.Gateway,
+		filepath.Join(dir, modules.TransactionPoolDir), c.BlockchainInfo, c.ChainConstants)
+	if err != nil {
+		return
+	}
+
+	n.Wallet, err = wallet.New(n.ConsensusSet, n.TransactionPool,
+		filepath.Join(dir, modules.WalletDir), c.BlockchainInfo, c.ChainConstants)
+	if err != nil {
+		return
+	}
+	key := crypto.TwofishKey(crypto.HashObject(seed))
+	if _, err = n.Wallet.Encrypt(key, seed); err != nil {
+		return
+	}
+	if err = n.Wallet.Unlock(key); err != nil {
+		return
+	}
+
+	n.BlockCreator, err = blockcreator.NewWithOptions(n.ConsensusSet, n.TransactionPool, n.Wallet,
+		filepath.Join(dir, modules.BlockCreatorDir), c.BlockchainInfo, c.ChainConstants,
+		blockcreator.Options{
+			Clock:  c.Clock,
+			Manual: true,
+		})
+	return
+}
+
+// Close closes all nodes of the cluster.
+func (c *Cluster) Close() error {
+	var errs []error
+	for i, node := range c.Nodes {
+		if err := node.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("node %d: %v", i, err))
+		}
+	}
+	return build.JoinErrors(errs, "; ")
+}
+
+// Connect connects node i to node j. Upon connecting,
+// node i downloads the blocks of node j it doesn't know yet.
+func (c *Cluster) Connect(i, j int) error {
+	return c.Nodes[i].Gateway.Connect(c.Nodes[j].Gateway.Address())
+}
+
+// ConnectAll connects each node to all other nodes.
+func (c *Cluster) ConnectAll() error {
+	for i := range c.Nodes {
+		for j := i + 1; j < len(c.Nodes); j++ {
+			if err := c.Connect(i, j); err != nil {
+				return fmt.Errorf("failed to connect node %d to node %d: %v", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Partition splits the network into the given groups of nodes,
+// cutting all connections between nodes of different groups.
+func (c *Cluster) Partition(groups ...[]int) {
+	hostGroups := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		hosts := make([]string, 0, len(group))
+		for _, i := range group {
+			hosts = append(hosts, c.Nodes[i].Host)
+		}
+		hostGroups = append(hostGroups, hosts)
+	}
+	c.Network.Partition(hostGroups...)
+}
+
+// Heal removes the partition of the network. Nodes have to be reconnected
+// in order to exchange the blocks they created while they were partitioned.
+func (c *Cluster) Heal() {
+	c.Network.Heal()
+}
+
+// CreateBlock lets node i create a block, advancing the clock
+// one second at a time until the node solves a block.
+func (c *Cluster) CreateBlock(i int) (types.Block, error) {
+	for attempt := 0; attempt < maxCreateBlockAttempts; attempt++ {
+		c.Clock.Advance(time.Second)
+		b, err := c.Nodes[i].BlockCreator.CreateBlock()
+		if err == blockcreator.ErrNoSolution {
+			continue
+		}
+		return b, err
+	}
+	return types.Block{}, blockcreator.ErrNoSolution
+}
+
+// Sync waits until the given nodes, or all nodes if none are given,
+// share the same current block.
+func (c *Cluster) Sync(nodes ...int) error {
+	if len(nodes) == 0 {
+		for i := range c.Nodes {
+			nodes = append(nodes, i)
+		}
+	}
+	return build.Retry(100, 100*time.Millisecond, func() error {
+		id := c.Nodes[nodes[0]].ConsensusSet.CurrentBlock().ID()
+		for _, i := range nodes[1:] {
+			if c.Nodes[i].ConsensusSet.CurrentBlock().ID() != id {
+				return errNotSynced
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,751 @@
+package simnet
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/transactionpool"
+	"github.com/rivine/rivine/types"
+)
+
+// newTestingCluster creates a cluster of n nodes for the given test.
+func newTestingCluster(t *testing.T, n int) *Cluster {
+	c, err := NewCluster(build.TempDir("simnet", t.Name()), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestClusterSync tests that blocks created by one node
+// are relayed to all other nodes of the cluster.
+func TestClusterSync(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 3)
+	defer c.Close()
+
+	// blocks created before connecting are downloaded when connecting
+	for i := 0; i < 3; i++ {
+		if _, err := c.CreateBlock(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.ConnectAll(); err != nil {
+		t.Fatal(err)
+	}
+	// blocks created when connected are relayed
+	for i := range c.Nodes {
+		if _, err := c.CreateBlock(i); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, node := range c.Nodes {
+		if h := node.ConsensusSet.Height(); h != 6 {
+			t.Errorf("node %d has height %d, expected 6", i, h)
+		}
+	}
+}
+
+// TestClusterReorg tests that the nodes of a partitioned network
+// converge on the longest chain once the partition is healed.
+func TestClusterReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 2)
+	defer c.Close()
+	if err := c.ConnectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateBlock(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Partition([]int{0}, []int{1})
+	for i := 0; i < 2; i++ {
+		if _, err := c.CreateBlock(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var longest types.Block
+	for i := 0; i < 4; i++ {
+		b, err := c.CreateBlock(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		longest = b
+	}
+	if c.Nodes[0].ConsensusSet.CurrentBlock().ID() == longest.ID() {
+		t.Fatal("partitioned nodes should not sync")
+	}
+
+	c.Heal()
+	if err := c.Connect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if id := c.Nodes[0].ConsensusSet.CurrentBlock().ID(); id != longest.ID() {
+		t.Fatal("node 0 did not reorg to the longest chain")
+	}
+	if h := c.Nodes[0].ConsensusSet.Height(); h != 5 {
+		t.Fatalf("node 0 has height %d, expected 5", h)
+	}
+}
+
+// TestClusterDoubleSpend tests that of two conflicting transactions, each
+// confirmed on one side of a partitioned network, only the transaction of
+// the longest chain remains confirmed once the partition is healed.
+func TestClusterDoubleSpend(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 3)
+	defer c.Close()
+	if err := c.ConnectAll(); err != nil {
+		t.Fatal(err)
+	}
+	c.Partition([]int{0, 1}, []int{2})
+
+	// create two transactions spending the same genesis coins of node 0
+	spend := func(to types.UnlockHash) []types.Transaction {
+		tb := c.Nodes[0].Wallet.StartTransaction()
+		amount := c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10)
+		fee := c.ChainConstants.MinimumTransactionFee
+		if err := tb.FundCoins(amount.Add(fee)); err != nil {
+			t.Fatal(err)
+		}
+		tb.AddMinerFee(fee)
+		tb.AddCoinOutput(types.CoinOutput{
+			Value:     amount,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(to)),
+		})
+		txns, err := tb.Sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// release the spent outputs, such that they can be spent again
+		tb.Drop()
+		return txns
+	}
+	txnsA := spend(c.Nodes[1].UnlockHash())
+	txnsB := spend(c.Nodes[2].UnlockHash())
+	// the output paying the recipient of each transaction
+	outputA := txnsA[len(txnsA)-1].CoinOutputID(0)
+	outputB := txnsB[len(txnsB)-1].CoinOutputID(0)
+
+	// node 1 confirms the first transaction, received from node 0
+	if err := c.Nodes[0].TransactionPool.AcceptTransactionSet(txnsA); err != nil {
+		t.Fatal(err)
+	}
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Nodes[1].TransactionPool.TransactionList()) != len(txnsA) {
+			return errors.New("transaction was not relayed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateBlock(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Nodes[0].ConsensusSet.GetCoinOutput(outputA); err != nil {
+		t.Fatal("first transaction should be confirmed:", err)
+	}
+
+	// node 2 confirms the second transaction on a longer chain
+	if err := c.Nodes[2].TransactionPool.AcceptTransactionSet(txnsB); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.CreateBlock(2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.Heal()
+	for _, i := range []int{0, 1} {
+		if err := c.Connect(i, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range c.Nodes {
+		if _, err := node.ConsensusSet.GetCoinOutput(outputA); err == nil {
+			t.Errorf("first transaction should no longer be confirmed by node %d", i)
+		}
+		if _, err := node.ConsensusSet.GetCoinOutput(outputB); err != nil {
+			t.Errorf("second transaction should be confirmed by node %d: %v", i, err)
+		}
+	}
+}
+
+// TestClusterLatency tests that nodes sync across a network with latency,
+// and that nodes recover from their connections being cut.
+func TestClusterLatency(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 2)
+	defer c.Close()
+	c.Network.SetLatency(50 * time.Millisecond)
+	if err := c.ConnectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateBlock(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Network.Disconnect(c.Nodes[0].Host, c.Nodes[1].Host)
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Nodes[0].Gateway.Peers()) != 0 {
+			return errors.New("node 0 is still connected")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateBlock(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if h := c.Nodes[0].ConsensusSet.Height(); h != 2 {
+		t.Fatalf("node 0 has height %d, expected 2", h)
+	}
+}
+
+// TestClusterTransactionRelay tests that transaction sets are relayed by
+// announcing their IDs, such that each node downloads a set only once,
+// and no set is announced to the node it was received from.
+func TestClusterTransactionRelay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 3)
+	defer c.Close()
+	// connect the nodes in a line, such that node 1 relays to node 2
+	if err := c.Connect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := c.Nodes[0].Wallet.SendCoins(c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[2].UnlockHash())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Nodes[2].TransactionPool.TransactionList()) == 0 {
+			return errors.New("transaction set was not relayed to node 2")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := func(node int, rpc string) (n uint64) {
+		for _, p := range c.Nodes[node].Gateway.Peers() {
+			n += p.RPCsReceived[rpc]
+		}
+		return
+	}
+	for i := range c.Nodes {
+		if n := received(i, "RelayTransactionSet"); n != 0 {
+			t.Errorf("node %d received %d full transaction sets, expected none", i, n)
+		}
+	}
+	if n := received(0, "RelayTxnSetIDs"); n != 0 {
+		t.Errorf("node 0 received %d announcements of its own transaction set", n)
+	}
+	if n := received(0, "SendTxnSet") + received(1, "SendTxnSet"); n != 2 {
+		t.Errorf("transaction set was downloaded %d times, expected 2", n)
+	}
+}
+
+// TestClusterRelayPenalties tests that a peer is only penalized for relaying
+// transaction sets which are invalid by themselves, and not for relaying sets
+// which conflict with the consensus set.
+func TestClusterRelayPenalties(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 2)
+	defer c.Close()
+	if err := c.Connect(1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// relay makes node 1 relay the given transaction set to node 0,
+	// returning once node 0 handled it
+	relay := func(ts []types.Transaction) {
+		err := c.Nodes[1].Gateway.RPC(c.Nodes[0].Gateway.Address(), "RelayTransactionSet", func(conn modules.PeerConn) error {
+			if err := encoding.WriteObject(conn, ts); err != nil {
+				return err
+			}
+			// wait for node 0 to close the connection
+			conn.Read(make([]byte, 1))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// newTxn creates a transaction spending the given outputs, which are
+	// unknown to the consensus set
+	pk := types.Ed25519PublicKey(crypto.PublicKey{})
+	newTxn := func(parents ...types.CoinOutputID) types.Transaction {
+		txn := types.Transaction{
+			Version:   c.ChainConstants.DefaultTransactionVersion,
+			MinerFees: []types.Currency{c.ChainConstants.MinimumTransactionFee},
+			CoinOutputs: []types.CoinOutput{{
+				Value:     c.ChainConstants.CurrencyUnits.OneCoin,
+				Condition: types.NewCondition(types.NewUnlockHashCondition(c.Nodes[0].UnlockHash())),
+			}},
+		}
+		for _, parent := range parents {
+			txn.CoinInputs = append(txn.CoinInputs, types.CoinInput{
+				ParentID: parent,
+				Fulfillment: types.NewFulfillment(&types.SingleSignatureFulfillment{
+					PublicKey: pk,
+					Signature: make([]byte, crypto.SignatureSize),
+				}),
+			})
+		}
+		return txn
+	}
+
+	// sets spending unknown outputs, as relayed after their parents were
+	// evicted, are never penalized
+	for i := 0; i < 2*modules.PeerBanScore/modules.PenaltyInvalidTransactionSet; i++ {
+		parent := types.CoinOutputID(crypto.HashObject(i))
+		relay([]types.Transaction{newTxn(parent)})
+	}
+	if bans := c.Nodes[0].Gateway.Bans(); len(bans) != 0 {
+		t.Fatal("node 1 was banned for relaying conflicting transaction sets:", bans[0].Reason)
+	}
+
+	// sets spending the same output twice are invalid by themselves
+	for i := 0; i < modules.PeerBanScore/modules.PenaltyInvalidTransactionSet; i++ {
+		parent := types.CoinOutputID(crypto.HashObject(i))
+		relay([]types.Transaction{newTxn(parent, parent)})
+	}
+	if bans := c.Nodes[0].Gateway.Bans(); len(bans) != 1 || bans[0].Host != c.Nodes[1].Host {
+		t.Fatal("node 1 should be banned for relaying invalid transaction sets, bans:", bans)
+	}
+}
+
+// splitAndSpend splits 1000 coins of the given node into n outputs, confirmed
+// in a block created by that node. It returns, for each output, a signed
+// transaction spending it to the given condition, where the i-th transaction
+// pays (i+1) times the given fee.
+func splitAndSpend(t *testing.T, c *Cluster, node, n int, fee types.Currency, condition types.UnlockConditionProxy) []types.Transaction {
+	w := c.Nodes[node].Wallet
+	split := make([]types.CoinOutput, n)
+	value := c.ChainConstants.CurrencyUnits.OneCoin.Mul64(1000).Sub(c.ChainConstants.MinimumTransactionFee).Div64(uint64(n))
+	for i := range split {
+		split[i] = types.CoinOutput{
+			Value:     value,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(c.Nodes[node].UnlockHash())),
+		}
+	}
+	splitTxn, err := w.SendOutputs(split, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateBlock(node); err != nil {
+		t.Fatal(err)
+	}
+
+	txns := make([]types.Transaction, n)
+	for i := range txns {
+		txnFee := fee.Mul64(uint64(i + 1))
+		txns[i], err = w.GreedySign(types.Transaction{
+			Version:     c.ChainConstants.DefaultTransactionVersion,
+			CoinInputs:  []types.CoinInput{{ParentID: splitTxn.CoinOutputID(uint64(i))}},
+			CoinOutputs: []types.CoinOutput{{Value: value.Sub(txnFee), Condition: condition}},
+			MinerFees:   []types.Currency{txnFee},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return txns
+}
+
+// TestClusterFeeEstimation tests that the fee estimation rises once the
+// transaction pool holds more transactions than fit in the target number of
+// blocks, and once recent blocks were full, and that the wallet pays the
+// estimated fee.
+func TestClusterFeeEstimation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// use small blocks, such that they are easily filled
+	chainCts := types.DefaultChainConstants()
+	chainCts.BlockSizeLimit = 12e3
+	c, err := NewClusterWithConstants(build.TempDir("simnet", t.Name()), 2, chainCts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ConnectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if est := c.Nodes[1].TransactionPool.FeeEstimation(1); !est.High.IsZero() {
+		t.Fatal("expected no fee to be required by an idle network, got", est.High)
+	}
+
+	// spend each of many small outputs of node 0 in a separate transaction
+	// set, paying increasing fees
+	const outputs = 100
+	txns := splitAndSpend(t, c, 0, outputs, c.ChainConstants.MinimumTransactionFee.Div64(20),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[0].UnlockHash())))
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range txns {
+		if err := c.Nodes[0].TransactionPool.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cheapest := modules.CalculateFee(txns[0:1])
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if len(c.Nodes[1].TransactionPool.TransactionList()) != outputs {
+			return errors.New("transaction sets were not relayed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pool holds more than the next few blocks can confirm
+	est := c.Nodes[1].TransactionPool.FeeEstimation(modules.DefaultFeeTarget)
+	if est.Low.Cmp(cheapest) <= 0 {
+		t.Fatalf("expected the low fee %v to outbid the cheapest set paying %v", est.Low, cheapest)
+	}
+	if est.Medium.Cmp(est.Low) < 0 || est.High.Cmp(est.Medium) < 0 {
+		t.Fatalf("expected increasing fees, got %v", est)
+	}
+	if est := c.Nodes[1].TransactionPool.FeeEstimation(100); !est.High.IsZero() {
+		t.Fatal("expected the pool to fit within the largest target, got", est.High)
+	}
+	if est.Target != modules.DefaultFeeTarget {
+		t.Fatalf("expected target %d, got %d", modules.DefaultFeeTarget, est.Target)
+	}
+
+	// the wallet pays at least the estimated fee
+	txn, err := c.Nodes[1].Wallet.SendCoins(c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[0].UnlockHash())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := modules.CalculateFee([]types.Transaction{txn}); fee.Cmp(est.Medium) < 0 {
+		t.Fatalf("wallet paid %v per byte, expected at least %v", fee, est.Medium)
+	}
+
+	// full blocks raise the fee estimation, even once the pool is empty
+	for attempt := 0; len(c.Nodes[1].TransactionPool.TransactionList()) != 0; attempt++ {
+		if attempt == 10 {
+			t.Fatal("transaction pool was not emptied")
+		}
+		if _, err := c.CreateBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if est := c.Nodes[1].TransactionPool.FeeEstimation(1); est.High.IsZero() {
+		t.Fatal("expected full blocks to require a fee")
+	}
+}
+
+// TestClusterPoolEviction tests that once the transaction pool is full, the
+// sets paying the lowest fee-per-byte are evicted for sets paying more, and
+// that the minimum fee of the pool rises.
+func TestClusterPoolEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 1)
+	defer c.Close()
+	node := c.Nodes[0]
+	if err := node.TransactionPool.SetMaxSize(modules.TransactionSetSizeLimit); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spend each of the outputs of the node in a large transaction set,
+	// paying increasing fees, such that the sets together are more than twice
+	// the size of the pool. The sets are made large using a multisig condition
+	// with many addresses.
+	const sets = 40
+	uhs := make(types.UnlockHashSlice, 400)
+	for i := range uhs {
+		uhs[i] = types.UnlockHash{Type: types.UnlockTypePubKey, Hash: crypto.HashObject(i)}
+	}
+	txns := splitAndSpend(t, c, 0, sets, c.ChainConstants.MinimumTransactionFee.Div64(4),
+		types.NewCondition(types.NewMultiSignatureCondition(uhs, 1)))
+	for i, txn := range txns {
+		if err := node.TransactionPool.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+			t.Fatalf("transaction set %d was not accepted: %v", i, err)
+		}
+	}
+
+	pool := node.TransactionPool.TransactionList()
+	if size := len(encoding.Marshal(pool)); size > int(modules.TransactionSetSizeLimit) {
+		t.Fatalf("transaction pool holds %d bytes, expected at most %d", size, int(modules.TransactionSetSizeLimit))
+	}
+	inPool := make(map[types.TransactionID]bool)
+	for _, txn := range pool {
+		inPool[txn.ID()] = true
+	}
+	if inPool[txns[0].ID()] {
+		t.Error("the cheapest transaction set should have been evicted")
+	}
+	if !inPool[txns[sets-1].ID()] {
+		t.Error("the most expensive transaction set should not have been evicted")
+	}
+
+	// sets paying less than the cheapest set in the full pool are rejected
+	minFee := node.TransactionPool.MinimumFee()
+	if minFee.Cmp(modules.CalculateFee(txns[0:1])) <= 0 {
+		t.Fatalf("expected the minimum fee %v to exceed the fee of the evicted set", minFee)
+	}
+	if est := node.TransactionPool.FeeEstimation(1); est.Low.Cmp(minFee) < 0 {
+		t.Fatalf("expected the low fee %v to be at least the minimum fee %v", est.Low, minFee)
+	}
+	if err := node.TransactionPool.AcceptTransactionSet(txns[0:1]); err == nil {
+		t.Fatal("evicted transaction set should not be accepted while the pool is full")
+	}
+
+	// once the pool is no longer full, no minimum fee is required
+	if err := node.TransactionPool.SetMaxSize(4 * modules.TransactionSetSizeLimit); err != nil {
+		t.Fatal(err)
+	}
+	if minFee := node.TransactionPool.MinimumFee(); !minFee.IsZero() {
+		t.Fatal("expected no minimum fee, got", minFee)
+	}
+	if err := node.TransactionPool.AcceptTransactionSet(txns[0:1]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestClusterPoolPersistence tests that the transaction pool keeps its
+// transaction sets when it is reopened, and that it rebroadcasts the sets
+// which remain unconfirmed, such that peers which missed them receive them.
+func TestClusterPoolPersistence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 3)
+	defer c.Close()
+	if err := c.Connect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := c.Nodes[0].Wallet.SendCoins(c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[1].UnlockHash())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// node 1 creates an independent transaction set as well
+	txn1, err := c.Nodes[1].Wallet.SendCoins(c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[0].UnlockHash())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasTxn := func(node int) error {
+		var found int
+		for _, pooled := range c.Nodes[node].TransactionPool.TransactionList() {
+			if pooled.ID() == txn.ID() || pooled.ID() == txn1.ID() {
+				found++
+			}
+		}
+		if found != 2 {
+			return fmt.Errorf("transactions are not in the pool of node %d", node)
+		}
+		return nil
+	}
+	for _, node := range []int{0, 1} {
+		if err := build.Retry(50, 100*time.Millisecond, func() error { return hasTxn(node) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// reopen the transaction pool of node 0, which should revalidate
+	// and keep the transaction set
+	node := c.Nodes[0]
+	if err := node.TransactionPool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	node.TransactionPool, err = transactionpool.New(node.ConsensusSet, node.Gateway,
+		filepath.Join(node.Dir, modules.TransactionPoolDir), c.BlockchainInfo, c.ChainConstants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hasTxn(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.TransactionPool.SetRebroadcastDelay(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// node 2 connects after the sets were relayed, and only receives them
+	// once node 0 rebroadcasts them, after they remained unconfirmed for
+	// the rebroadcast delay
+	if err := c.Connect(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if len(c.Nodes[2].TransactionPool.TransactionList()) != 0 {
+			t.Fatal("sets were rebroadcast before the rebroadcast delay")
+		}
+		if _, err := c.CreateBlock(2); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := build.Retry(50, 100*time.Millisecond, func() error { return hasTxn(2) }); err != nil {
+		t.Fatal(err)
+	}
+	// both sets are announced in a single announcement
+	var announcements uint64
+	for _, p := range c.Nodes[2].Gateway.Peers() {
+		announcements += p.RPCsReceived["RelayTxnSetIDs"]
+	}
+	if announcements != 1 {
+		t.Errorf("node 2 received %d announcements, expected 1", announcements)
+	}
+}
+
+// TestClusterReplaceByFee tests that with replace-by-fee enabled, the wallet
+// can replace an unconfirmed transaction with one paying a higher fee, which
+// replaces the original transaction in the transaction pools of all nodes.
+func TestClusterReplaceByFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	c := newTestingCluster(t, 2)
+	defer c.Close()
+	if err := c.Connect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := c.Nodes[0].Wallet.SendCoins(c.ChainConstants.CurrencyUnits.OneCoin.Mul64(10),
+		types.NewCondition(types.NewUnlockHashCondition(c.Nodes[1].UnlockHash())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pooled := func(node int, id types.TransactionID) bool {
+		for _, pooled := range c.Nodes[node].TransactionPool.TransactionList() {
+			if pooled.ID() == id {
+				return true
+			}
+		}
+		return false
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if !pooled(1, txn.ID()) {
+			return errors.New("transaction was not relayed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the transaction can't be replaced unless replace-by-fee is enabled
+	if _, err := c.Nodes[0].Wallet.BumpFee(txn.ID()); err == nil {
+		t.Fatal("transaction was replaced with replace-by-fee disabled")
+	}
+	if !pooled(0, txn.ID()) {
+		t.Fatal("transaction was dropped by a failed replacement")
+	}
+
+	for _, node := range c.Nodes {
+		node.TransactionPool.SetReplaceByFee(true)
+	}
+	replacement, err := c.Nodes[0].Wallet.BumpFee(txn.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee, oldFee := replacement.MinerFees[0], txn.MinerFees[0]; fee.Cmp(oldFee) <= 0 {
+		t.Fatalf("replacement pays a fee of %v, which is not higher than %v", fee, oldFee)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		for i := range c.Nodes {
+			if pooled(i, txn.ID()) || !pooled(i, replacement.ID()) {
+				return fmt.Errorf("transaction was not replaced in the pool of node %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, upt := range c.Nodes[0].Wallet.UnconfirmedTransactions() {
+		if upt.TransactionID == txn.ID() {
+			t.Fatal("wallet was not notified of the replaced transaction")
+		}
+	}
+
+	// the original transaction can't replace the replacement,
+	// as it pays a lower fee
+	if err := c.Nodes[1].TransactionPool.AcceptTransactionSet([]types.Transaction{txn}); err == nil {
+		t.Fatal("replacement was replaced by a transaction paying a lower fee")
+	}
+
+	if _, err := c.CreateBlock(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Nodes[0].ConsensusSet.GetCoinOutput(replacement.CoinOutputID(0)); err != nil {
+		t.Fatal("replacement should be confirmed:", err)
+	}
+}
@@ -0,0 +1,532 @@
+// Package simnet simulates networks of full nodes within a single process.
+// Nodes are connected through an in-memory network, which can be partitioned,
+// delayed and cut, and create blocks on a virtual clock, allowing tests to
+// reliably reproduce reorgs, double spends and other edge cases of syncing.
+package simnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rivine/rivine/modules/gateway"
+)
+
+// Network is an in-memory network, connecting the transports it hands out.
+// Connections between its hosts are never backed by sockets, and can be
+// delayed, cut and refused in order to simulate partitions, latency and
+// peer churn. All hosts are given an address within 198.18.0.0/15, the
+// range reserved for benchmarking networks (RFC 2544).
+type Network struct {
+	mu sync.Mutex
+
+	hosts     int
+	nextPort  int
+	listeners map[string]*listener
+	conns     map[*conn]struct{}
+
+	// partition maps each partitioned host to the index of its group.
+	partition map[string]int
+	latency   time.Duration
+}
+
+var (
+	errConnRefused        = errors.New("connection refused")
+	errConnReset          = errors.New("connection reset by peer")
+	errAddressInUse       = errors.New("address already in use")
+	errNetworkUnreachable = errors.New("network is unreachable")
+)
+
+// NewNetwork returns an empty in-memory network.
+func NewNetwork() *Network {
+	return &Network{
+		nextPort:  1024,
+		listeners: make(map[string]*listener),
+		conns:     make(map[*conn]struct{}),
+	}
+}
+
+// NewTransport adds a host to the network, returning the transport through
+// which it listens and dials.
+func (n *Network) NewTransport() *Transport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hosts++
+	ip := net.IPv4(198, 18+byte(n.hosts>>16), byte(n.hosts>>8), byte(n.hosts))
+	return &Transport{network: n, host: ip.String()}
+}
+
+// Partition splits the network into the given groups of hosts, such that
+// hosts can only reach the hosts within their own group. Hosts which are not
+// part of any group together form one more group. All connections between hosts
+// of different groups are cut.
+func (n *Network) Partition(groups ...[]string) {
+	n.mu.Lock()
+	n.partition = make(map[string]int)
+	for i, group := range groups {
+		for _, host := range group {
+			n.partition[host] = i + 1
+		}
+	}
+	var cut []*conn
+	for c := range n.conns {
+		if !n.reachable(c.local.IP.String(), c.remote.IP.String()) {
+			cut = append(cut, c)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, c := range cut {
+		c.cut()
+	}
+}
+
+// Heal removes the partition of the network,
+// such that all hosts can reach each other again.
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.partition = nil
+}
+
+// SetLatency defines the time it takes for data to travel between two hosts,
+// including the time it takes to establish a connection.
+func (n *Network) SetLatency(latency time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.latency = latency
+}
+
+// Disconnect cuts all connections between the two given hosts.
+func (n *Network) Disconnect(a, b string) {
+	n.mu.Lock()
+	var cut []*conn
+	for c := range n.conns {
+		local, remote := c.local.IP.String(), c.remote.IP.String()
+		if (local == a && remote == b) || (local == b && remote == a) {
+			cut = append(cut, c)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, c := range cut {
+		c.cut()
+	}
+}
+
+// reachable returns whether or not host a can reach host b.
+func (n *Network) reachable(a, b string) bool {
+	return n.partition[a] == n.partition[b]
+}
+
+// managedLatency returns the current latency of the network.
+func (n *Network) managedLatency() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.latency
+}
+
+// dial connects the given host to the given address.
+func (n *Network) dial(host, addr string, timeout time.Duration, cancel <-chan struct{}) (net.Conn, error) {
+	raddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// establishing a connection takes as long as a round trip
+	if latency := n.managedLatency(); latency > 0 {
+		if timeout > 0 && 2*latency > timeout {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: raddr, Err: os.ErrDeadlineExceeded}
+		}
+		select {
+		case <-time.After(2 * latency):
+		case <-cancel:
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: raddr, Err: errors.New("operation was canceled")}
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.reachable(host, raddr.IP.String()) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: raddr, Err: errNetworkUnreachable}
+	}
+	l, ok := n.listeners[raddr.String()]
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: raddr, Err: errConnRefused}
+	}
+	laddr := &net.TCPAddr{IP: net.ParseIP(host), Port: n.allocatePort()}
+	local, remote := newConnPair(n, laddr, raddr)
+	// like a full backlog, refuse the connection if it can't be queued
+	select {
+	case l.accept <- remote:
+	default:
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: raddr, Err: errConnRefused}
+	}
+	n.conns[local] = struct{}{}
+	n.conns[remote] = struct{}{}
+	return local, nil
+}
+
+// listen returns a listener for the given host, listening on the port of
+// the given address. A free port is allocated if the port is 0.
+func (n *Network) listen(host, addr string) (net.Listener, error) {
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if port == 0 {
+		port = n.allocatePort()
+	}
+	laddr := &net.TCPAddr{IP: net.ParseIP(host), Port: port}
+	if _, ok := n.listeners[laddr.String()]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "tcp", Addr: laddr, Err: errAddressInUse}
+	}
+	l := &listener{
+		network: n,
+		addr:    laddr,
+		accept:  make(chan *conn, 16),
+		closed:  make(chan struct{}),
+	}
+	n.listeners[laddr.String()] = l
+	return l, nil
+}
+
+// allocatePort returns a port which is not yet used within the network.
+func (n *Network) allocatePort() int {
+	n.nextPort++
+	return n.nextPort
+}
+
+// Transport implements the gateway.Transport interface for a single host
+// of an in-memory network.
+type Transport struct {
+	network *Network
+	host    string
+}
+
+// Host returns the IP address of the host.
+func (t *Transport) Host() string {
+	return t.host
+}
+
+// Dial implements gateway.Transport.Dial
+func (t *Transport) Dial(addr string, timeout time.Duration, cancel <-chan struct{}) (net.Conn, error) {
+	return t.network.dial(t.host, addr, timeout, cancel)
+}
+
+// Listen implements gateway.Transport.Listen
+//
+// The host of the given address is ignored,
+// as a host can only listen on its own address.
+func (t *Transport) Listen(addr string) (net.Listener, error) {
+	return t.network.listen(t.host, addr)
+}
+
+// listener is a net.Listener within an in-memory network.
+type listener struct {
+	network   *Network
+	addr      *net.TCPAddr
+	accept    chan *conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept implements net.Listener.Accept
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.closed:
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: l.addr, Err: net.ErrClosed}
+	}
+}
+
+// Close implements net.Listener.Close
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.network.mu.Lock()
+		delete(l.network.listeners, l.addr.String())
+		l.network.mu.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+// Addr implements net.Listener.Addr
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
+
+// chunk is data written to a connection,
+// which can be read once the given time has passed.
+type chunk struct {
+	data []byte
+	at   time.Time
+}
+
+// pipe is one direction of a connection.
+type pipe struct {
+	mu     sync.Mutex
+	chunks []chunk
+	// eof is set once the writer closed the connection,
+	// reset once the reader closed the connection, or once
+	// the connection was cut.
+	eof   bool
+	reset bool
+	// changed is closed and replaced whenever the pipe changes.
+	changed chan struct{}
+}
+
+func newPipe() *pipe {
+	return &pipe{changed: make(chan struct{})}
+}
+
+// notify wakes up the reader of the pipe. The pipe has to be locked.
+func (p *pipe) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// deadline is a read or write deadline of a connection.
+type deadline struct {
+	mu sync.Mutex
+	t  time.Time
+	// changed is closed and replaced whenever the deadline changes.
+	changed chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{changed: make(chan struct{})}
+}
+
+// set changes the deadline.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// get returns the deadline, and the channel which is closed once it changes.
+func (d *deadline) get() (time.Time, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.t, d.changed
+}
+
+// conn is one end of a connection within an in-memory network.
+// Like TCP, data is delivered in order, writes never block, and once
+// an end is closed, the other end reads the remaining data followed by
+// io.EOF, while its writes fail. Once a connection is cut, both ends fail
+// to read and write.
+type conn struct {
+	network       *Network
+	local, remote *net.TCPAddr
+
+	in, out *pipe
+
+	readDeadline, writeDeadline *deadline
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newConnPair returns both ends of a new connection.
+func newConnPair(n *Network, laddr, raddr *net.TCPAddr) (*conn, *conn) {
+	a, b := newPipe(), newPipe()
+	local := &conn{
+		network:       n,
+		local:         laddr,
+		remote:        raddr,
+		in:            a,
+		out:           b,
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		closed:        make(chan struct{}),
+	}
+	remote := &conn{
+		network:       n,
+		local:         raddr,
+		remote:        laddr,
+		in:            b,
+		out:           a,
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		closed:        make(chan struct{}),
+	}
+	return local, remote
+}
+
+// opError wraps an error in a net.OpError, as returned by TCP connections.
+func (c *conn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "tcp", Source: c.local, Addr: c.remote, Err: err}
+}
+
+// Read implements net.Conn.Read
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		select {
+		case <-c.closed:
+			return 0, c.opError("read", net.ErrClosed)
+		default:
+		}
+		t, deadlineChanged := c.readDeadline.get()
+		if !t.IsZero() && !time.Now().Before(t) {
+			return 0, c.opError("read", os.ErrDeadlineExceeded)
+		}
+
+		c.in.mu.Lock()
+		var wait time.Duration
+		if c.in.reset {
+			c.in.mu.Unlock()
+			return 0, c.opError("read", errConnReset)
+		} else if len(c.in.chunks) > 0 {
+			head := &c.in.chunks[0]
+			wait = time.Until(head.at)
+			if wait <= 0 {
+				n := copy(b, head.data)
+				head.data = head.data[n:]
+				if len(head.data) == 0 {
+					c.in.chunks = c.in.chunks[1:]
+				}
+				c.in.mu.Unlock()
+				return n, nil
+			}
+		} else if c.in.eof {
+			c.in.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := c.in.changed
+		c.in.mu.Unlock()
+
+		// wait for the data to arrive, or for anything to change
+		if !t.IsZero() && (wait <= 0 || time.Until(t) < wait) {
+			wait = time.Until(t)
+		}
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-timeout:
+		case <-changed:
+		case <-deadlineChanged:
+		case <-c.closed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Write implements net.Conn.Write
+func (c *conn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, c.opError("write", net.ErrClosed)
+	default:
+	}
+	if t, _ := c.writeDeadline.get(); !t.IsZero() && !time.Now().Before(t) {
+		return 0, c.opError("write", os.ErrDeadlineExceeded)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	at := time.Now().Add(c.network.managedLatency())
+	c.out.mu.Lock()
+	defer c.out.mu.Unlock()
+	if c.out.reset {
+		return 0, c.opError("write", errConnReset)
+	}
+	// data is never delivered before data that was written earlier
+	if n := len(c.out.chunks); n > 0 && c.out.chunks[n-1].at.After(at) {
+		at = c.out.chunks[n-1].at
+	}
+	c.out.chunks = append(c.out.chunks, chunk{data: append([]byte(nil), b...), at: at})
+	c.out.notify()
+	return len(b), nil
+}
+
+// Close implements net.Conn.Close
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		c.out.mu.Lock()
+		c.out.eof = true
+		c.out.notify()
+		c.out.mu.Unlock()
+
+		c.in.mu.Lock()
+		c.in.reset = true
+		c.in.chunks = nil
+		c.in.notify()
+		c.in.mu.Unlock()
+
+		c.network.mu.Lock()
+		delete(c.network.conns, c)
+		c.network.mu.Unlock()
+	})
+	return nil
+}
+
+// cut resets the connection, dropping any data which is not yet read,
+// as if the link between both hosts failed.
+func (c *conn) cut() {
+	for _, p := range []*pipe{c.in, c.out} {
+		p.mu.Lock()
+		p.reset = true
+		p.chunks = nil
+		p.notify()
+		p.mu.Unlock()
+	}
+	c.network.mu.Lock()
+	delete(c.network.conns, c)
+	c.network.mu.Unlock()
+}
+
+// LocalAddr implements net.Conn.LocalAddr
+func (c *conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr implements net.Conn.RemoteAddr
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline implements net.Conn.SetDeadline
+func (c *conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline implements net.Conn.SetReadDeadline
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.SetWriteDeadline
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// enforce that Transport satisfies the gateway.Transport interface
+var _ gateway.Transport = (*Transport)(nil)
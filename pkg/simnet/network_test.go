@@ -0,0 +1,118 @@
+package simnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestNetworkConn tests the delivery of data between two hosts,
+// as well as the closing of their connection.
+func TestNetworkConn(t *testing.T) {
+	n := NewNetwork()
+	a, b := n.NewTransport(), n.NewTransport()
+	l, err := b.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	latency := 50 * time.Millisecond
+	n.SetLatency(latency)
+	start := time.Now()
+	ca, err := a.Dial(l.Addr().String(), time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, _, _ := net.SplitHostPort(cb.RemoteAddr().String()); host != a.Host() {
+		t.Fatalf("remote address %v does not belong to host %v", cb.RemoteAddr(), a.Host())
+	}
+
+	// data is delayed by the latency, and delivered in order
+	if _, err := ca.Write([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Write([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(cb, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "foobar" {
+		t.Fatalf("expected foobar, read %q", buf)
+	}
+	if elapsed := time.Since(start); elapsed < 3*latency {
+		t.Fatal("data was delivered too fast:", elapsed)
+	}
+
+	// reads time out once their deadline passes
+	cb.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := cb.Read(buf); err == nil {
+		t.Fatal("read should have timed out")
+	} else if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatal("expected a timeout error, got", err)
+	}
+	cb.SetReadDeadline(time.Time{})
+
+	// once closed, the remaining data is read, followed by io.EOF
+	if _, err := ca.Write([]byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	ca.Close()
+	if _, err := io.ReadFull(cb, buf[:3]); err != nil || string(buf[:3]) != "baz" {
+		t.Fatalf("expected to read baz, read %q: %v", buf[:3], err)
+	}
+	if _, err := cb.Read(buf); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+	if _, err := cb.Write(buf); err == nil {
+		t.Fatal("write to a closed connection should fail")
+	}
+}
+
+// TestNetworkPartition tests that partitioned hosts can't reach each other,
+// and that existing connections between them are cut.
+func TestNetworkPartition(t *testing.T) {
+	n := NewNetwork()
+	a, b := n.NewTransport(), n.NewTransport()
+	l, err := b.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ca, err := a.Dial(l.Addr().String(), time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.Partition([]string{a.Host()}, []string{b.Host()})
+	if _, err := cb.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read from a cut connection should fail")
+	}
+	if _, err := ca.Write([]byte("foo")); err == nil {
+		t.Fatal("write to a cut connection should fail")
+	}
+	if _, err := a.Dial(l.Addr().String(), time.Second, nil); err == nil {
+		t.Fatal("partitioned hosts should not be able to connect")
+	}
+
+	n.Heal()
+	if _, err := a.Dial(l.Addr().String(), time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := a.Dial(l.Addr().String(), time.Second, nil); err == nil {
+		t.Fatal("dialing a closed listener should fail")
+	}
+}
@@ -54,6 +54,16 @@ type (
 		MerkleRoot crypto.Hash             `json:"merkleroot"`
 	}
 
+	// A TransactionProof proves that a transaction is part of a block, and can
+	// be verified using only the header of that block. It is up to the
+	// verifier to check that the header is part of the blockchain.
+	TransactionProof struct {
+		Header    BlockHeader   `json:"header"`
+		LeafIndex uint64        `json:"leafindex"`
+		NumLeaves uint64        `json:"numleaves"`
+		HashSet   []crypto.Hash `json:"hashset"`
+	}
+
 	BlockHeight uint64
 	BlockID     crypto.Hash
 )
@@ -105,6 +115,42 @@ func (b Block) MerkleRoot() crypto.Hash {
 	return tree.Root()
 }
 
+// TransactionProof creates a Merkle proof that the transaction at the given
+// index is part of the block. False is returned if no transaction exists at
+// that index.
+func (b Block) TransactionProof(index int) (TransactionProof, bool) {
+	if index < 0 || index >= len(b.Transactions) {
+		return TransactionProof{}, false
+	}
+	leafIndex := uint64(len(b.MinerPayouts) + index)
+	tree := crypto.NewTree()
+	tree.SetIndex(leafIndex)
+	for _, payout := range b.MinerPayouts {
+		tree.PushObject(payout)
+	}
+	for _, txn := range b.Transactions {
+		tree.PushObject(txn)
+	}
+	_, hashSet := tree.Prove()
+	return TransactionProof{
+		Header: BlockHeader{
+			ParentID:   b.ParentID,
+			Timestamp:  b.Timestamp,
+			POBSOutput: b.POBSOutput,
+			MerkleRoot: tree.Root(),
+		},
+		LeafIndex: leafIndex,
+		NumLeaves: uint64(len(b.MinerPayouts) + len(b.Transactions)),
+		HashSet:   hashSet,
+	}, true
+}
+
+// Verify returns true if the proof shows that the given transaction is part
+// of the block identified by the proof's header.
+func (tp TransactionProof) Verify(txn Transaction) bool {
+	return crypto.VerifySegment(encoding.Marshal(txn), tp.HashSet, tp.NumLeaves, tp.LeafIndex, tp.Header.MerkleRoot)
+}
+
 // MinerPayoutID returns the ID of the miner payout at the given index, which
 // is calculated by hashing the concatenation of the BlockID and the payout
 // index.
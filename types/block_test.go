@@ -217,6 +217,41 @@ func TestBlockMinerPayoutID(t *testing.T) {
 	}
 }
 
+// TestBlockTransactionProof probes the TransactionProof method of the block
+// type, and the verification of the created proofs.
+func TestBlockTransactionProof(t *testing.T) {
+	b := Block{
+		MinerPayouts: []MinerPayout{{Value: NewCurrency64(1)}, {Value: NewCurrency64(2)}},
+		Transactions: []Transaction{
+			{ArbitraryData: []byte{1}},
+			{ArbitraryData: []byte{2}},
+			{ArbitraryData: []byte{3}},
+		},
+	}
+	for i, txn := range b.Transactions {
+		proof, ok := b.TransactionProof(i)
+		if !ok {
+			t.Fatal("failed to create proof for transaction", i)
+		}
+		if proof.Header != b.Header() {
+			t.Error("proof header does not match block header for transaction", i)
+		}
+		if proof.LeafIndex != uint64(len(b.MinerPayouts)+i) || proof.NumLeaves != 5 {
+			t.Error("unexpected leaf index or leaf count for transaction", i)
+		}
+		if !proof.Verify(txn) {
+			t.Error("valid proof rejected for transaction", i)
+		}
+		other := b.Transactions[(i+1)%len(b.Transactions)]
+		if proof.Verify(other) {
+			t.Error("proof for transaction", i, "accepted a different transaction")
+		}
+	}
+	if _, ok := b.TransactionProof(len(b.Transactions)); ok {
+		t.Error("created a proof for a transaction that does not exist")
+	}
+}
+
 // TestBlockEncodes probes the MarshalSia and UnmarshalSia methods of the
 // Block type.
 func TestBlockEncoding(t *testing.T) {
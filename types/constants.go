@@ -89,6 +89,17 @@ type ChainConstants struct {
 	// for all to be created transactions. It does not impact how transactions are validated or understood.
 	DefaultTransactionVersion TransactionVersion
 
+	// Checkpoints are the IDs of blocks which are known to be part of the
+	// blockchain, at their height. Blocks conflicting with a checkpoint are rejected,
+	// and once the blockchain reached a checkpoint, it will never fork off below it.
+	// This protects against long range attacks using old blockstake keys.
+	Checkpoints map[BlockHeight]BlockID
+
+	// ProtocolUpgrades are the upgrades of the protocol of the chain,
+	// ordered by the height at which they activate.
+	// See ProtocolRules for more information.
+	ProtocolUpgrades []ProtocolUpgrade
+
 	CurrencyUnits CurrencyUnits
 }
 
@@ -271,7 +282,21 @@ func (c *ChainConstants) Validate() error {
 	if c.GenesisTimestamp < Timestamp(1231006505) {
 		return errors.New("Invalid genesis timestamp")
 	}
-	return nil
+	if id, ok := c.Checkpoints[0]; ok && id != c.GenesisBlockID() {
+		return errors.New("Invalid genesis checkpoint")
+	}
+	return c.validateProtocolUpgrades()
+}
+
+// LatestCheckpoint returns the height of the highest checkpoint
+// at or below the given height, with a bool to indicate whether such a checkpoint exists.
+func (c *ChainConstants) LatestCheckpoint(height BlockHeight) (checkpoint BlockHeight, exists bool) {
+	for cpHeight := range c.Checkpoints {
+		if cpHeight <= height && (!exists || cpHeight > checkpoint) {
+			checkpoint, exists = cpHeight, true
+		}
+	}
+	return
 }
 
 // GenesisBlock returns the genesis block based on the blockchain config
@@ -23,3 +23,44 @@ func TestCheckBuildConstants(t *testing.T) {
 		t.Error(build.DEBUG)
 	}
 }
+
+// TestLatestCheckpoint probes the LatestCheckpoint method of ChainConstants.
+func TestLatestCheckpoint(t *testing.T) {
+	cts := DefaultChainConstants()
+	if _, exists := cts.LatestCheckpoint(100); exists {
+		t.Fatal("no checkpoints should exist by default")
+	}
+	cts.Checkpoints = map[BlockHeight]BlockID{
+		10: {1},
+		50: {2},
+		20: {3},
+	}
+	testCases := []struct {
+		height     BlockHeight
+		checkpoint BlockHeight
+		exists     bool
+	}{
+		{0, 0, false},
+		{9, 0, false},
+		{10, 10, true},
+		{49, 20, true},
+		{50, 50, true},
+		{1000, 50, true},
+	}
+	for _, tc := range testCases {
+		checkpoint, exists := cts.LatestCheckpoint(tc.height)
+		if checkpoint != tc.checkpoint || exists != tc.exists {
+			t.Errorf("height %d: expected (%d, %v), got (%d, %v)",
+				tc.height, tc.checkpoint, tc.exists, checkpoint, exists)
+		}
+	}
+
+	cts.Checkpoints[0] = BlockID{4}
+	if cts.Validate() == nil {
+		t.Fatal("a genesis checkpoint which does not match the genesis block should be invalid")
+	}
+	cts.Checkpoints[0] = cts.GenesisBlockID()
+	if err := cts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
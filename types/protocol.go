@@ -0,0 +1,187 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// protocol.go implements height-gated protocol upgrades.
+// A chain can declare, as part of its ChainConstants, the upgrades of its
+// protocol, each activating at a given block height. Transaction versions,
+// condition types and fulfillment types introduced by an upgrade are invalid
+// in blocks below its activation height, and the transaction and block rules
+// of an upgrade are only enforced from its activation height onwards.
+//
+// Transaction versions, condition types and fulfillment types still have to be
+// registered, as usual, using RegisterTransactionVersion, RegisterUnlockConditionType
+// and RegisterUnlockFulfillmentType, such that they can be decoded at any height.
+
+type (
+	// ProtocolUpgrade defines an upgrade of the protocol of a chain,
+	// which activates at a given block height.
+	ProtocolUpgrade struct {
+		// Name identifies the upgrade, it has to be unique within a chain.
+		Name string
+		// Height is the height of the first block to which the upgrade applies.
+		Height BlockHeight
+
+		// TransactionVersions are the transaction versions introduced by this upgrade.
+		TransactionVersions []TransactionVersion
+		// ConditionTypes are the unlock condition types introduced by this upgrade.
+		ConditionTypes []ConditionType
+		// FulfillmentTypes are the unlock fulfillment types introduced by this upgrade.
+		FulfillmentTypes []FulfillmentType
+
+		// DefaultTransactionVersion optionally overwrites the default transaction version,
+		// used for all to be created transactions, once this upgrade is active.
+		DefaultTransactionVersion *TransactionVersion
+
+		// TransactionValidators define extra rules that all transactions have
+		// to adhere to, once this upgrade is active.
+		TransactionValidators []TransactionValidator
+		// BlockValidators define extra rules that all blocks have
+		// to adhere to, once this upgrade is active.
+		BlockValidators []BlockValidator
+	}
+
+	// BlockValidator defines the interface that can be implemented,
+	// in order to define extra validation logic for blocks as part of a protocol upgrade.
+	BlockValidator interface {
+		ValidateBlock(b Block, height BlockHeight) error
+	}
+
+	// ProtocolRules are the rules of the protocol of a chain,
+	// which apply to a block at a given height.
+	ProtocolRules struct {
+		// Height of the block to which these rules apply.
+		Height BlockHeight
+		// Upgrades are the names of the protocol upgrades active at this height.
+		Upgrades []string
+		// DefaultTransactionVersion defines the default transaction version
+		// to be used for all to be created transactions.
+		DefaultTransactionVersion TransactionVersion
+
+		inactiveTransactionVersions map[TransactionVersion]struct{}
+		inactiveConditionTypes      map[ConditionType]struct{}
+		inactiveFulfillmentTypes    map[FulfillmentType]struct{}
+
+		transactionValidators []TransactionValidator
+		blockValidators       []BlockValidator
+	}
+)
+
+var (
+	// ErrInactiveTransactionVersion is returned in case a transaction
+	// uses a version which is not yet active at the height of its block.
+	ErrInactiveTransactionVersion = errors.New("transaction version is not active at this block height")
+	// ErrInactiveConditionType is returned in case a transaction
+	// uses an unlock condition type which is not yet active at the height of its block.
+	ErrInactiveConditionType = errors.New("unlock condition type is not active at this block height")
+	// ErrInactiveFulfillmentType is returned in case a transaction
+	// uses an unlock fulfillment type which is not yet active at the height of its block.
+	ErrInactiveFulfillmentType = errors.New("unlock fulfillment type is not active at this block height")
+)
+
+// ProtocolRules returns the protocol rules which apply to a block at the given height.
+func (c *ChainConstants) ProtocolRules(height BlockHeight) ProtocolRules {
+	rules := ProtocolRules{
+		Height:                      height,
+		DefaultTransactionVersion:   c.DefaultTransactionVersion,
+		inactiveTransactionVersions: make(map[TransactionVersion]struct{}),
+		inactiveConditionTypes:      make(map[ConditionType]struct{}),
+		inactiveFulfillmentTypes:    make(map[FulfillmentType]struct{}),
+	}
+	for _, upgrade := range c.ProtocolUpgrades {
+		if upgrade.Height > height {
+			for _, version := range upgrade.TransactionVersions {
+				rules.inactiveTransactionVersions[version] = struct{}{}
+			}
+			for _, ct := range upgrade.ConditionTypes {
+				rules.inactiveConditionTypes[ct] = struct{}{}
+			}
+			for _, ft := range upgrade.FulfillmentTypes {
+				rules.inactiveFulfillmentTypes[ft] = struct{}{}
+			}
+			continue
+		}
+		rules.Upgrades = append(rules.Upgrades, upgrade.Name)
+		if upgrade.DefaultTransactionVersion != nil {
+			rules.DefaultTransactionVersion = *upgrade.DefaultTransactionVersion
+		}
+		rules.transactionValidators = append(rules.transactionValidators, upgrade.TransactionValidators...)
+		rules.blockValidators = append(rules.blockValidators, upgrade.BlockValidators...)
+	}
+	return rules
+}
+
+// validateProtocolUpgrades ensures that the protocol upgrades are named uniquely,
+// are ordered by activation height, and do not activate at the genesis block.
+func (c *ChainConstants) validateProtocolUpgrades() error {
+	names := make(map[string]struct{}, len(c.ProtocolUpgrades))
+	var height BlockHeight
+	for _, upgrade := range c.ProtocolUpgrades {
+		if upgrade.Name == "" {
+			return errors.New("Unnamed protocol upgrade")
+		}
+		if _, exists := names[upgrade.Name]; exists {
+			return fmt.Errorf("Duplicate protocol upgrade %q", upgrade.Name)
+		}
+		names[upgrade.Name] = struct{}{}
+		if upgrade.Height == 0 || upgrade.Height < height {
+			return fmt.Errorf("Invalid activation height for protocol upgrade %q", upgrade.Name)
+		}
+		height = upgrade.Height
+	}
+	return nil
+}
+
+// ValidateTransaction validates a transaction against the protocol rules,
+// ensuring it only uses active transaction versions, condition types and
+// fulfillment types, and that it passes the validators of all active upgrades.
+//
+// NOTE: only the top-level conditions and fulfillments are checked.
+func (pr ProtocolRules) ValidateTransaction(t Transaction, constants TransactionValidationConstants) error {
+	if _, inactive := pr.inactiveTransactionVersions[t.Version]; inactive {
+		return ErrInactiveTransactionVersion
+	}
+	for _, co := range t.CoinOutputs {
+		if _, inactive := pr.inactiveConditionTypes[co.Condition.ConditionType()]; inactive {
+			return ErrInactiveConditionType
+		}
+	}
+	for _, bso := range t.BlockStakeOutputs {
+		if _, inactive := pr.inactiveConditionTypes[bso.Condition.ConditionType()]; inactive {
+			return ErrInactiveConditionType
+		}
+	}
+	for _, ci := range t.CoinInputs {
+		if _, inactive := pr.inactiveFulfillmentTypes[ci.Fulfillment.FulfillmentType()]; inactive {
+			return ErrInactiveFulfillmentType
+		}
+	}
+	for _, bsi := range t.BlockStakeInputs {
+		if _, inactive := pr.inactiveFulfillmentTypes[bsi.Fulfillment.FulfillmentType()]; inactive {
+			return ErrInactiveFulfillmentType
+		}
+	}
+	for _, validator := range pr.transactionValidators {
+		err := validator.ValidateTransaction(t, constants)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateBlock validates a block against the validators of all active upgrades.
+// The transactions of the block are not validated, as that is done
+// for each transaction individually, using ValidateTransaction.
+func (pr ProtocolRules) ValidateBlock(b Block) error {
+	for _, validator := range pr.blockValidators {
+		err := validator.ValidateBlock(b, pr.Height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+type testBlockValidator struct{ err error }
+
+func (bv testBlockValidator) ValidateBlock(Block, BlockHeight) error { return bv.err }
+
+// TestProtocolRules probes the protocol rules which apply at different heights.
+func TestProtocolRules(t *testing.T) {
+	errBlock := errors.New("invalid block")
+	txnVersion := TransactionVersion(0x80)
+	cts := DefaultChainConstants()
+	cts.ProtocolUpgrades = []ProtocolUpgrade{
+		{
+			Name:                      "first",
+			Height:                    10,
+			TransactionVersions:       []TransactionVersion{txnVersion},
+			DefaultTransactionVersion: &txnVersion,
+		},
+		{
+			Name:             "second",
+			Height:           20,
+			ConditionTypes:   []ConditionType{ConditionTypeMultiSignature},
+			FulfillmentTypes: []FulfillmentType{FulfillmentTypeMultiSignature},
+			BlockValidators:  []BlockValidator{testBlockValidator{errBlock}},
+		},
+	}
+	if err := cts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	txn := Transaction{Version: txnVersion}
+	multisigTxn := Transaction{
+		Version: cts.DefaultTransactionVersion,
+		CoinOutputs: []CoinOutput{{
+			Condition: NewCondition(NewMultiSignatureCondition(UnlockHashSlice{{Type: UnlockTypePubKey}}, 1)),
+		}},
+	}
+
+	rules := cts.ProtocolRules(9)
+	if len(rules.Upgrades) != 0 || rules.DefaultTransactionVersion != cts.DefaultTransactionVersion {
+		t.Error("unexpected rules at height 9:", rules.Upgrades, rules.DefaultTransactionVersion)
+	}
+	if err := rules.ValidateTransaction(txn, TransactionValidationConstants{}); err != ErrInactiveTransactionVersion {
+		t.Error("expected ErrInactiveTransactionVersion, got", err)
+	}
+	if err := rules.ValidateTransaction(multisigTxn, TransactionValidationConstants{}); err != ErrInactiveConditionType {
+		t.Error("expected ErrInactiveConditionType, got", err)
+	}
+
+	rules = cts.ProtocolRules(10)
+	if len(rules.Upgrades) != 1 || rules.DefaultTransactionVersion != txnVersion {
+		t.Error("unexpected rules at height 10:", rules.Upgrades, rules.DefaultTransactionVersion)
+	}
+	if err := rules.ValidateTransaction(txn, TransactionValidationConstants{}); err != nil {
+		t.Error(err)
+	}
+	if err := rules.ValidateBlock(Block{}); err != nil {
+		t.Error(err)
+	}
+
+	rules = cts.ProtocolRules(20)
+	if len(rules.Upgrades) != 2 {
+		t.Error("unexpected rules at height 20:", rules.Upgrades)
+	}
+	if err := rules.ValidateTransaction(multisigTxn, TransactionValidationConstants{}); err != nil {
+		t.Error(err)
+	}
+	if err := rules.ValidateBlock(Block{}); err != errBlock {
+		t.Error("expected errBlock, got", err)
+	}
+
+	// upgrades have to be ordered and uniquely named
+	cts.ProtocolUpgrades[1].Height = 5
+	if cts.Validate() == nil {
+		t.Error("unordered protocol upgrades should be invalid")
+	}
+	cts.ProtocolUpgrades[1].Height = 20
+	cts.ProtocolUpgrades[1].Name = "first"
+	if cts.Validate() == nil {
+		t.Error("duplicate protocol upgrades should be invalid")
+	}
+}
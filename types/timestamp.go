@@ -47,9 +47,13 @@ func (ts TimestampSlice) Swap(i, j int) {
 	ts[i], ts[j] = ts[j], ts[i]
 }
 
-// Clock allows clients to retrieve the current time.
+// Clock allows clients to retrieve the current time,
+// and to wait until a given time is reached.
 type Clock interface {
 	Now() Timestamp
+	// Until returns a channel which is closed
+	// once the clock reaches the given timestamp.
+	Until(t Timestamp) <-chan struct{}
 }
 
 // StdClock is an implementation of Clock that retrieves the current time using
@@ -60,3 +64,11 @@ type StdClock struct{}
 func (c StdClock) Now() Timestamp {
 	return Timestamp(time.Now().Unix())
 }
+
+// Until returns a channel which is closed once the system time
+// reaches the given timestamp.
+func (c StdClock) Until(t Timestamp) <-chan struct{} {
+	ch := make(chan struct{})
+	time.AfterFunc(time.Until(time.Unix(int64(t), 0)), func() { close(ch) })
+	return ch
+}